@@ -71,6 +71,14 @@ func (cmd *applyCommand) Run(args []string) error {
 		run = cmd.applyUser
 	case "dep-autoassigner":
 		run = cmd.applyDEPAutoAssigner
+	case "invitations":
+		run = cmd.applyInvitation
+	case "enrollment-templates":
+		run = cmd.applyEnrollmentTemplate
+	case "scep-ca":
+		run = cmd.applySCEPCA
+	case "scep-challenges":
+		run = cmd.applySCEPChallenge
 	default:
 		cmd.Usage()
 		os.Exit(1)
@@ -92,6 +100,10 @@ Valid resource types:
   * dep-autoassigner
   * app
   * block
+  * invitations
+  * enrollment-templates
+  * scep-ca
+  * scep-challenges
 
 Examples:
   # Apply a Blueprint.
@@ -100,6 +112,18 @@ Examples:
   # Apply a DEP Profile.
   mdmctl apply dep-profiles -f /path/to/dep-profile.json
 
+  # Create a single-use enrollment invitation.
+  mdmctl apply invitations -owner jane -group lab -ttl 72h
+
+  # Apply an enrollment profile template.
+  mdmctl apply enrollment-templates -f /path/to/template.json
+
+  # Rotate the SCEP CA.
+  mdmctl apply scep-ca -rotate
+
+  # Create a SCEP challenge bound to a serial number.
+  mdmctl apply scep-challenges -serial C02ABCDEF -ttl 1h
+
 `
 	fmt.Println(applyUsage)
 	return nil
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/enrollment"
+)
+
+func (cmd *applyCommand) applyEnrollmentTemplate(args []string) error {
+	flagset := flag.NewFlagSet("enrollment-templates", flag.ExitOnError)
+	var (
+		flTemplatePath = flagset.String("f", "", "filename of enrollment template JSON to apply")
+		flTemplate     = flagset.Bool("template", false, "print a new enrollment template")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl apply enrollment-templates [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	if *flTemplate {
+		newTemplate := &enrollment.Template{
+			Name:         "exampleName",
+			Organization: "Example Inc.",
+			DisplayName:  "Example Enrollment Profile",
+			Description:  "Enrolls your device with Example Inc.",
+			ConsentText:  "Example Inc. may alter the settings of this device.",
+			SCEPSubject:  "/O=Example Inc./CN=%SerialNumber%",
+			SCEPKeySize:  2048,
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(newTemplate); err != nil {
+			return errors.Wrap(err, "encode enrollment template")
+		}
+		return nil
+	}
+
+	if *flTemplatePath == "" {
+		flagset.Usage()
+		return errors.New("bad input: must provide -f or -template flag")
+	}
+
+	jsonBytes, err := readBytesFromPath(*flTemplatePath)
+	if err != nil {
+		return err
+	}
+	var t enrollment.Template
+	if err := json.Unmarshal(jsonBytes, &t); err != nil {
+		return err
+	}
+	if err := t.Verify(); err != nil {
+		return err
+	}
+
+	if err := cmd.templatesvc.ApplyTemplate(context.Background(), &t); err != nil {
+		return err
+	}
+	fmt.Printf("applied enrollment template %s, enroll with %s/mdm/enroll?%s=%s\n",
+		t.Name, strings.TrimRight(cmd.config.ServerURL, "/"), enrollment.TemplateParam, t.Name)
+	return nil
+}
+
+func (cmd *getCommand) getEnrollmentTemplates(args []string) error {
+	flagset := flag.NewFlagSet("enrollment-templates", flag.ExitOnError)
+	var (
+		flName = flagset.String("name", "", "name of enrollment template")
+		flJSON = flagset.Bool("json", false, "print the full templates as JSON")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl get enrollment-templates [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	templates, err := cmd.templatesvc.GetTemplates(context.Background(), enrollment.GetTemplatesOption{
+		FilterName: *flName,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *flJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(templates)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name\tOrganization\tDisplayName\tSCEPSubject\tCACertificates\tExtraPayloads\n")
+	for _, t := range templates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\n",
+			t.Name, t.Organization, t.DisplayName, t.SCEPSubject, len(t.CACertificates), len(t.ExtraPayloads))
+	}
+	return w.Flush()
+}
+
+func (cmd *removeCommand) removeEnrollmentTemplates(args []string) error {
+	flagset := flag.NewFlagSet("remove-enrollment-templates", flag.ExitOnError)
+	var (
+		flNames = flagset.String("name", "", "name of enrollment template, optionally comma-separated")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl remove enrollment-templates [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *flNames == "" {
+		flagset.Usage()
+		return errors.New("bad input: must provide -name")
+	}
+
+	err := cmd.templatesvc.RemoveTemplates(context.Background(), strings.Split(*flNames, ","))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("removed enrollment template(s): %s\n", *flNames)
+	return nil
+}
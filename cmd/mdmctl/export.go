@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+type exportCommand struct {
+	config *ServerConfig
+}
+
+func (cmd *exportCommand) setup() error {
+	cfg, err := LoadServerConfig()
+	if err != nil {
+		return err
+	}
+	cmd.config = cfg
+	return nil
+}
+
+func (cmd *exportCommand) Run(args []string) error {
+	if len(args) < 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	if err := cmd.setup(); err != nil {
+		return err
+	}
+
+	var run func([]string) error
+	switch strings.ToLower(args[0]) {
+	case "devices":
+		run = cmd.exportDevices
+	default:
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	return run(args[1:])
+}
+
+func (cmd *exportCommand) Usage() error {
+	const exportUsage = `
+Export all resources of a type.
+
+Valid resource types:
+
+  * devices
+`
+	fmt.Print(exportUsage)
+	return nil
+}
+
+func (cmd *exportCommand) exportDevices(args []string) error {
+	flagset := flag.NewFlagSet("devices", flag.ExitOnError)
+	var (
+		flFormat  = flagset.String("format", device.ExportFormatCSV, "Export format: csv or jsonl")
+		flColumns = flagset.String("columns", "", "Comma separated list of columns to export. All columns are exported by default")
+		flOutput  = flagset.String("o", "", "Write the export to a file instead of stdout")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl export devices [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	opt := device.ExportDevicesOption{Format: *flFormat}
+	if *flColumns != "" {
+		opt.Columns = strings.Split(*flColumns, ",")
+	}
+
+	var w io.Writer = os.Stdout
+	if *flOutput != "" {
+		f, err := os.Create(*flOutput)
+		if err != nil {
+			return errors.Wrap(err, "create export file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	client := skipVerifyHTTPClient(cmd.config.SkipVerify)
+	return device.ExportDevices(context.Background(), client, cmd.config.ServerURL, cmd.config.APIToken, opt, w)
+}
@@ -54,6 +54,8 @@ func (cmd *getCommand) Run(args []string) error {
 	switch strings.ToLower(args[0]) {
 	case "dev", "device", "devices":
 		run = cmd.getDevices
+	case "user-enrollments":
+		run = cmd.getUserEnrollments
 	case "dep-devices":
 		run = cmd.getDEPDevices
 	case "dep-account":
@@ -72,6 +74,16 @@ func (cmd *getCommand) Run(args []string) error {
 		run = cmd.getApps
 	case "dep-autoassigners":
 		run = cmd.getDEPAutoAssigners
+	case "invitations":
+		run = cmd.getInvitations
+	case "enrollment-templates":
+		run = cmd.getEnrollmentTemplates
+	case "scep-cas":
+		run = cmd.getSCEPCAs
+	case "scep-challenges":
+		run = cmd.getSCEPChallenges
+	case "push-cert":
+		run = cmd.getPushCert
 	default:
 		cmd.Usage()
 		os.Exit(1)
@@ -87,6 +99,7 @@ Display one or many resources.
 Valid resource types:
 
   * devices
+  * user-enrollments
   * blueprints
   * dep-tokens
   * dep-devices
@@ -96,6 +109,11 @@ Valid resource types:
   * users
   * profiles
   * apps
+  * invitations
+  * enrollment-templates
+  * scep-cas
+  * scep-challenges
+  * push-cert
 
 Examples:
   # Get a list of devices
@@ -103,6 +121,12 @@ Examples:
 
   # Get a device by serial (TODO implement filtering)
   mdmctl get devices -serials=C02ABCDEF
+
+  # Search devices by partial name, serial or owner
+  mdmctl get devices -search=jsmith-mb
+
+  # Get User Enrollment (BYOD) devices
+  mdmctl get user-enrollments
 `
 	fmt.Println(getUsage)
 	return nil
@@ -122,11 +146,16 @@ func (cmd *getCommand) getDevices(args []string) error {
 	flagset := flag.NewFlagSet("devices", flag.ExitOnError)
 	var (
 		flFilterSerials = flagset.String("serials", "", "device serial, optionally comma-separated")
+		flSearch        = flagset.String("search", "", "search devices by name, serial, UDID, asset tag, model, description or owner")
+		flFuzzy         = flagset.Bool("fuzzy", false, "allow typos in the -search query")
 	)
 	flagset.Usage = usageFor(flagset, "mdmctl get devices [flags]")
 	if err := flagset.Parse(args); err != nil {
 		return err
 	}
+	if *flSearch != "" {
+		return cmd.searchDevices(*flSearch, *flFuzzy)
+	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
 	out := &devicesTableOutput{w}
 	out.BasicHeader()
@@ -150,6 +179,24 @@ func (cmd *getCommand) getDevices(args []string) error {
 	return nil
 }
 
+func (cmd *getCommand) searchDevices(query string, fuzzy bool) error {
+	devices, err := cmd.devicesvc.SearchDevices(context.Background(), device.SearchDevicesOption{
+		Query: query,
+		Fuzzy: fuzzy,
+	})
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "UDID\tSerialNumber\tDeviceName\tOwners\tMatched\n")
+	for _, d := range devices {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			d.UDID, d.SerialNumber, d.DeviceName,
+			strings.Join(d.Owners, ","), strings.Join(d.MatchedFields, ","))
+	}
+	return w.Flush()
+}
+
 const defaultmdmctlFilesPath = "mdm-files"
 
 func (cmd *getCommand) getDepTokens(args []string) error {
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func (cmd *getCommand) getPushCert(args []string) error {
+	flagset := flag.NewFlagSet("push-cert", flag.ExitOnError)
+	flagset.Usage = usageFor(flagset, "mdmctl get push-cert")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	details, err := cmd.configsvc.PushCertificateDetails(context.Background())
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Subject\t%s\n", details.Subject)
+	fmt.Fprintf(w, "Topic\t%s\n", details.Topic)
+	fmt.Fprintf(w, "Serial\t%s\n", details.Serial)
+	fmt.Fprintf(w, "NotBefore\t%s\n", details.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(w, "NotAfter\t%s\n", details.NotAfter.Format(time.RFC3339))
+	if details.Expired {
+		fmt.Fprintf(w, "DaysLeft\texpired\n")
+	} else {
+		fmt.Fprintf(w, "DaysLeft\t%d\n", details.DaysLeft)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+func (cmd *getCommand) getUserEnrollments(args []string) error {
+	flagset := flag.NewFlagSet("user-enrollments", flag.ExitOnError)
+	var (
+		flEnrollmentIDs = flagset.String("enrollment-ids", "", "enrollment ID, optionally comma-separated")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl get user-enrollments [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	var opt device.ListUserEnrollmentsOption
+	if *flEnrollmentIDs != "" {
+		opt.FilterEnrollmentID = strings.Split(*flEnrollmentIDs, ",")
+	}
+	enrollments, err := cmd.devicesvc.ListUserEnrollments(context.Background(), opt)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "EnrollmentID\tManagedAppleID\tModel\tOSVersion\tEnrolled\tLastSeen\n")
+	for _, e := range enrollments {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\n",
+			e.EnrollmentID, e.ManagedAppleID, e.Model, e.OSVersion, e.Enrolled, e.LastSeen)
+	}
+	return w.Flush()
+}
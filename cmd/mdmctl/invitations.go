@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/invitation"
+)
+
+func (cmd *applyCommand) applyInvitation(args []string) error {
+	flagset := flag.NewFlagSet("invitations", flag.ExitOnError)
+	var (
+		flOwner = flagset.String("owner", "", "owner the enrolled device is bound to")
+		flGroup = flagset.String("group", "", "group the enrolled device is bound to")
+		flTTL   = flagset.Duration("ttl", invitation.DefaultTTL, "how long the invitation can be used")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl apply invitations [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *flTTL < time.Second {
+		return errors.New("bad input: -ttl must be at least one second")
+	}
+
+	inv, err := cmd.invitesvc.CreateInvitation(context.Background(), invitation.CreateInvitationOption{
+		Owner:      *flOwner,
+		Group:      *flGroup,
+		TTLSeconds: int64(*flTTL / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created invitation, expires %s:\n%s\n", inv.ExpiresAt.Local().Format(time.RFC1123), inv.URL)
+	return nil
+}
+
+func (cmd *getCommand) getInvitations(args []string) error {
+	flagset := flag.NewFlagSet("invitations", flag.ExitOnError)
+	var (
+		flToken = flagset.String("token", "", "get an invitation by token")
+		flUDID  = flagset.String("udid", "", "get the invitation a device enrolled with")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl get invitations [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	invitations, err := cmd.invitesvc.GetInvitations(context.Background(), invitation.GetInvitationsOption{
+		FilterToken: *flToken,
+		FilterUDID:  *flUDID,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Token\tOwner\tGroup\tExpiresAt\tUDID\tRedeemedAt\n")
+	for _, inv := range invitations {
+		var redeemed string
+		if !inv.RedeemedAt.IsZero() {
+			redeemed = inv.RedeemedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			inv.Token, inv.Owner, inv.Group, inv.ExpiresAt.Format(time.RFC3339), inv.UDID, redeemed)
+	}
+	return w.Flush()
+}
+
+func (cmd *removeCommand) removeInvitations(args []string) error {
+	flagset := flag.NewFlagSet("remove-invitations", flag.ExitOnError)
+	var (
+		flTokens = flagset.String("tokens", "", "invitation token, optionally comma-separated")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl remove invitations [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *flTokens == "" {
+		flagset.Usage()
+		return errors.New("bad input: must provide -tokens")
+	}
+
+	err := cmd.invitesvc.RemoveInvitations(context.Background(), strings.Split(*flTokens, ","))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("removed invitation(s): %s\n", *flTokens)
+	return nil
+}
@@ -35,6 +35,9 @@ func main() {
 	case "remove":
 		cmd := new(removeCommand)
 		run = cmd.Run
+	case "export":
+		cmd := new(exportCommand)
+		run = cmd.Run
 	case "mdmcert":
 		cmd := new(mdmcertCommand)
 		run = cmd.Run
@@ -60,6 +63,7 @@ Available Commands:
 	apply
 	config
 	remove
+	export
 	mdmcert
 	mdmcert.download
 	version
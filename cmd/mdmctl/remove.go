@@ -50,6 +50,12 @@ func (cmd *removeCommand) Run(args []string) error {
 		run = cmd.removeBlock
 	case "dep-autoassigner":
 		run = cmd.removeDEPAutoAssigner
+	case "invitations":
+		run = cmd.removeInvitations
+	case "enrollment-templates":
+		run = cmd.removeEnrollmentTemplates
+	case "scep-challenges":
+		run = cmd.removeSCEPChallenges
 	default:
 		cmd.Usage()
 		os.Exit(1)
@@ -69,6 +75,9 @@ Valid resource types:
   * profiles
   * block
   * dep-autoassigner
+  * invitations
+  * enrollment-templates
+  * scep-challenges
 `
 
 	fmt.Println(getUsage)
@@ -16,15 +16,16 @@ func (cmd *removeCommand) removeDevices(args []string) error {
 	var (
 		flIdentifier = flagset.String("udid", "", "device UDID, optionally comma-separated")
 		flSerials    = flagset.String("serials", "", "device serial, optionally comma-separated")
+		flEnrollment = flagset.String("enrollment-ids", "", "User Enrollment ID, optionally comma-separated")
 	)
 	flagset.Usage = usageFor(flagset, "mdmctl remove devices [flags]")
 	if err := flagset.Parse(args); err != nil {
 		return err
 	}
 
-	if *flIdentifier == "" && *flSerials == "" {
+	if *flIdentifier == "" && *flSerials == "" && *flEnrollment == "" {
 		flagset.Usage()
-		return errors.New("bad input: device UDID or Serial must be provided")
+		return errors.New("bad input: device UDID, Serial or Enrollment ID must be provided")
 	}
 
 	opts := device.RemoveDevicesOptions{}
@@ -34,6 +35,9 @@ func (cmd *removeCommand) removeDevices(args []string) error {
 	if *flSerials != "" {
 		opts.Serials = strings.Split(*flSerials, ",")
 	}
+	if *flEnrollment != "" {
+		opts.EnrollmentIDs = strings.Split(*flEnrollment, ",")
+	}
 
 	ctx := context.Background()
 	err := cmd.devicesvc.RemoveDevices(ctx, opts)
@@ -41,7 +45,7 @@ func (cmd *removeCommand) removeDevices(args []string) error {
 		return err
 	}
 
-	fmt.Printf("removed devices(s): %s\n", strings.Join(append(opts.UDIDs, opts.Serials...), ", "))
+	fmt.Printf("removed devices(s): %s\n", strings.Join(append(append(opts.UDIDs, opts.Serials...), opts.EnrollmentIDs...), ", "))
 
 	return nil
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/scep"
+)
+
+func (cmd *applyCommand) applySCEPCA(args []string) error {
+	flagset := flag.NewFlagSet("scep-ca", flag.ExitOnError)
+	var (
+		flRotate = flagset.Bool("rotate", false, "replace the SCEP CA with a new CA, which issues all new device identities")
+		flYears  = flagset.Int("years", scep.DefaultCAYears, "validity of the new CA in years")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl apply scep-ca -rotate [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if !*flRotate {
+		flagset.Usage()
+		return errors.New("bad input: must provide -rotate")
+	}
+	if *flYears < 1 {
+		return errors.New("bad input: -years must be at least 1")
+	}
+
+	cas, err := cmd.scepsvc.RotateCA(context.Background(), scep.RotateCAOption{Years: *flYears})
+	if err != nil {
+		return err
+	}
+	fmt.Println("rotated SCEP CA, devices keep their identities until they are renewed or enroll again")
+	return printSCEPCAs(cas)
+}
+
+func (cmd *getCommand) getSCEPCAs(args []string) error {
+	flagset := flag.NewFlagSet("scep-cas", flag.ExitOnError)
+	flagset.Usage = usageFor(flagset, "mdmctl get scep-cas")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	cas, err := cmd.scepsvc.GetCAs(context.Background())
+	if err != nil {
+		return err
+	}
+	return printSCEPCAs(cas)
+}
+
+func printSCEPCAs(cas []scep.CA) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Fingerprint\tCurrent\tNotAfter\tDevices\n")
+	for _, ca := range cas {
+		fmt.Fprintf(w, "%s\t%t\t%s\t%d\n", ca.Fingerprint, ca.Current, ca.NotAfter.Format(time.RFC3339), ca.Devices)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/challenge"
+)
+
+func (cmd *applyCommand) applySCEPChallenge(args []string) error {
+	flagset := flag.NewFlagSet("scep-challenges", flag.ExitOnError)
+	var (
+		flSerial     = flagset.String("serial", "", "serial number the challenge is bound to")
+		flInvitation = flagset.String("invitation", "", "invitation token the challenge is bound to")
+		flTTL        = flagset.Duration("ttl", challenge.DefaultTTL, "how long the challenge can be used")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl apply scep-challenges [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *flTTL < time.Second {
+		return errors.New("bad input: -ttl must be at least one second")
+	}
+
+	c, err := cmd.challengesvc.CreateChallenge(context.Background(), challenge.CreateChallengeOption{
+		Serial:     *flSerial,
+		Invitation: *flInvitation,
+		TTLSeconds: int64(*flTTL / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created SCEP challenge, expires %s:\n%s\n", c.ExpiresAt.Local().Format(time.RFC1123), c.Challenge)
+	return nil
+}
+
+func (cmd *getCommand) getSCEPChallenges(args []string) error {
+	flagset := flag.NewFlagSet("scep-challenges", flag.ExitOnError)
+	var (
+		flSerial     = flagset.String("serial", "", "get the challenges bound to a serial number")
+		flInvitation = flagset.String("invitation", "", "get the challenges bound to an invitation token")
+		flExpired    = flagset.Bool("expired", false, "also get expired challenges")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl get scep-challenges [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	challenges, err := cmd.challengesvc.ListChallenges(context.Background(), challenge.ListChallengesOption{
+		FilterSerial:     *flSerial,
+		FilterInvitation: *flInvitation,
+		IncludeExpired:   *flExpired,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Challenge\tSerial\tInvitation\tCreatedAt\tExpiresAt\n")
+	for _, c := range challenges {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			c.Challenge, c.Serial, c.Invitation, c.CreatedAt.Format(time.RFC3339), c.ExpiresAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (cmd *removeCommand) removeSCEPChallenges(args []string) error {
+	flagset := flag.NewFlagSet("remove-scep-challenges", flag.ExitOnError)
+	var (
+		flChallenges = flagset.String("challenges", "", "SCEP challenge, optionally comma-separated")
+	)
+	flagset.Usage = usageFor(flagset, "mdmctl remove scep-challenges [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+	if *flChallenges == "" {
+		flagset.Usage()
+		return errors.New("bad input: must provide -challenges")
+	}
+
+	err := cmd.challengesvc.RevokeChallenges(context.Background(), strings.Split(*flChallenges, ","))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("revoked SCEP challenge(s): %s\n", *flChallenges)
+	return nil
+}
@@ -6,12 +6,16 @@ import (
 
 	"github.com/micromdm/micromdm/platform/appstore"
 	"github.com/micromdm/micromdm/platform/blueprint"
+	"github.com/micromdm/micromdm/platform/challenge"
 	"github.com/micromdm/micromdm/platform/config"
 	"github.com/micromdm/micromdm/platform/dep"
 	"github.com/micromdm/micromdm/platform/dep/sync"
 	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/enrollment"
+	"github.com/micromdm/micromdm/platform/invitation"
 	"github.com/micromdm/micromdm/platform/profile"
 	"github.com/micromdm/micromdm/platform/remove"
+	"github.com/micromdm/micromdm/platform/scep"
 	"github.com/micromdm/micromdm/platform/user"
 )
 
@@ -25,6 +29,10 @@ type remoteServices struct {
 	appsvc       appstore.Service
 	depsvc       dep.Service
 	depsyncsvc   sync.Service
+	invitesvc    invitation.Service
+	templatesvc  enrollment.Service
+	scepsvc      scep.Service
+	challengesvc challenge.Service
 }
 
 func setupClient(logger log.Logger) (*remoteServices, error) {
@@ -96,6 +104,34 @@ func setupClient(logger log.Logger) (*remoteServices, error) {
 		return nil, err
 	}
 
+	invitesvc, err := invitation.NewHTTPClient(
+		cfg.ServerURL, cfg.APIToken, logger,
+		httptransport.SetClient(skipVerifyHTTPClient(cfg.SkipVerify)))
+	if err != nil {
+		return nil, err
+	}
+
+	templatesvc, err := enrollment.NewHTTPClient(
+		cfg.ServerURL, cfg.APIToken, logger,
+		httptransport.SetClient(skipVerifyHTTPClient(cfg.SkipVerify)))
+	if err != nil {
+		return nil, err
+	}
+
+	scepsvc, err := scep.NewHTTPClient(
+		cfg.ServerURL, cfg.APIToken, logger,
+		httptransport.SetClient(skipVerifyHTTPClient(cfg.SkipVerify)))
+	if err != nil {
+		return nil, err
+	}
+
+	challengesvc, err := challenge.NewHTTPClient(
+		cfg.ServerURL, cfg.APIToken, logger,
+		httptransport.SetClient(skipVerifyHTTPClient(cfg.SkipVerify)))
+	if err != nil {
+		return nil, err
+	}
+
 	return &remoteServices{
 		profilesvc:   profilesvc,
 		blueprintsvc: blueprintsvc,
@@ -106,5 +142,9 @@ func setupClient(logger log.Logger) (*remoteServices, error) {
 		appsvc:       appsvc,
 		depsvc:       depsvc,
 		depsyncsvc:   depsyncsvc,
+		invitesvc:    invitesvc,
+		templatesvc:  templatesvc,
+		scepsvc:      scepsvc,
+		challengesvc: challengesvc,
 	}, nil
 }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/micromdm/go4/env"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/migrate"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+func migrateStorage(args []string) error {
+	flagset := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	var (
+		flConfigPath  = flagset.String("config-path", env.String("MICROMDM_CONFIG_PATH", "/var/db/micromdm"), "Path to configuration directory containing micromdm.db")
+		flStorage     = flagset.String("storage", "sqlite", "destination storage: sqlite or postgres")
+		flSQLitePath  = flagset.String("sqlite-path", "", "Path of the SQLite database. defaults to micromdm.sqlite in the configuration directory")
+		flPostgresDSN = flagset.String("postgres-dsn", env.String("MICROMDM_POSTGRES_DSN", ""), "Postgres connection string. The schema from pg/migrations must already be applied")
+		flDryRun      = flagset.Bool("dry-run", false, "Copy and verify all data, then roll back instead of committing")
+	)
+	flagset.Usage = usageFor(flagset, "micromdm migrate-storage [flags]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	dbPath := filepath.Join(*flConfigPath, "micromdm.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return errors.Wrap(err, "finding boltdb")
+	}
+	// serve holds an exclusive lock on micromdm.db, so the timeout makes
+	// sure the server is stopped before migrating.
+	src, err := bolt.Open(dbPath, 0644, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return errors.Wrap(err, "opening boltdb, is micromdm serve still running?")
+	}
+	defer src.Close()
+
+	var dst *sqlx.DB
+	switch *flStorage {
+	case "sqlite":
+		path := *flSQLitePath
+		if path == "" {
+			path = filepath.Join(*flConfigPath, "micromdm.sqlite")
+		}
+		dst, err = sqlite.Open(path)
+		if err != nil {
+			return err
+		}
+	case "postgres":
+		if *flPostgresDSN == "" {
+			return errors.New("-postgres-dsn is required for postgres storage")
+		}
+		dst, err = sqlx.Connect("postgres", *flPostgresDSN)
+		if err != nil {
+			return errors.Wrap(err, "connecting to postgres")
+		}
+	default:
+		return fmt.Errorf("invalid storage type: %s", *flStorage)
+	}
+	defer dst.Close()
+
+	results, err := migrate.Migrate(src, dst, *flDryRun)
+	printMigrateResults(results)
+	if err != nil {
+		return errors.Wrap(err, "migrating storage")
+	}
+
+	skipped, err := migrate.SkippedBuckets(src)
+	if err != nil {
+		return err
+	}
+	for _, bucket := range skipped {
+		fmt.Printf("bucket %s was not migrated\n", bucket)
+	}
+
+	if *flDryRun {
+		fmt.Println("dry run: all counts verified, no data was written")
+		return nil
+	}
+	fmt.Printf("migrated micromdm.db to %s\n", *flStorage)
+	return nil
+}
+
+func printMigrateResults(results []migrate.Result) {
+	if len(results) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "BUCKET\tTABLE\tRECORDS\tROWS\n")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", r.Bucket, r.Table, r.Records, r.Rows)
+	}
+	w.Flush()
+}
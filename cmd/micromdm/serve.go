@@ -2,37 +2,43 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	stdlog "log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/micromdm/micromdm/mdm"
 	"github.com/micromdm/micromdm/mdm/enroll"
+	"github.com/micromdm/micromdm/pkg/crypto/profileutil"
 	httputil2 "github.com/micromdm/micromdm/pkg/httputil"
+	"github.com/micromdm/micromdm/platform/acme"
 	"github.com/micromdm/micromdm/platform/apns"
 	"github.com/micromdm/micromdm/platform/appstore"
 	appsbuiltin "github.com/micromdm/micromdm/platform/appstore/builtin"
 	"github.com/micromdm/micromdm/platform/blueprint"
-	blueprintbuiltin "github.com/micromdm/micromdm/platform/blueprint/builtin"
 	"github.com/micromdm/micromdm/platform/challenge"
 	"github.com/micromdm/micromdm/platform/command"
 	"github.com/micromdm/micromdm/platform/config"
 	depapi "github.com/micromdm/micromdm/platform/dep"
 	"github.com/micromdm/micromdm/platform/dep/sync"
 	"github.com/micromdm/micromdm/platform/device"
-	devicebuiltin "github.com/micromdm/micromdm/platform/device/builtin"
+	"github.com/micromdm/micromdm/platform/enrollment"
+	"github.com/micromdm/micromdm/platform/invitation"
 	"github.com/micromdm/micromdm/platform/profile"
 	block "github.com/micromdm/micromdm/platform/remove"
+	scepplatform "github.com/micromdm/micromdm/platform/scep"
 	"github.com/micromdm/micromdm/platform/user"
-	userbuiltin "github.com/micromdm/micromdm/platform/user/builtin"
 	"github.com/micromdm/micromdm/server"
 
 	"github.com/boltdb/bolt"
@@ -108,6 +114,10 @@ func serve(args []string) error {
 		flTLS                    = flagset.Bool("tls", env.Bool("MICROMDM_TLS", true), "Use https")
 		flTLSCert                = flagset.String("tls-cert", env.String("MICROMDM_TLS_CERT", ""), "Path to TLS certificate")
 		flTLSKey                 = flagset.String("tls-key", env.String("MICROMDM_TLS_KEY", ""), "Path to TLS private key")
+		flSignProfiles           = flagset.String("sign-profiles", env.String("MICROMDM_SIGN_PROFILES", ""), "Sign profiles served and sent to devices: enrollment (enrollment and OTA profiles) or all (also InstallProfile commands)")
+		flProfileSigningCert     = flagset.String("profile-signing-cert", env.String("MICROMDM_PROFILE_SIGNING_CERT", ""), "Path to the certificate which signs profiles. Defaults to -tls-cert")
+		flProfileSigningKey      = flagset.String("profile-signing-key", env.String("MICROMDM_PROFILE_SIGNING_KEY", ""), "Path to the private key which signs profiles. Defaults to -tls-key")
+		flSCEPSubject            = flagset.String("scep-subject", env.String("MICROMDM_SCEP_SUBJECT", ""), "Subject of device identity certificates in the enrollment profile, like /O=MicroMDM/CN=MicroMDM Identity (%ComputerName%)")
 		flHTTPAddr               = flagset.String("http-addr", env.String("MICROMDM_HTTP_ADDR", ":https"), "http(s) listen address of mdm server. defaults to :8080 if tls is false")
 		flHTTPDebug              = flagset.Bool("http-debug", env.Bool("MICROMDM_HTTP_DEBUG", false), "Enable debug for http(dumps full request)")
 		flHTTPProxyHeaders       = flagset.Bool("http-proxy-headers", env.Bool("MICROMDM_HTTP_PROXY_HEADERS", false), "Enable parsing of proxy headers for use behind a reverse proxy")
@@ -117,6 +127,9 @@ func serve(args []string) error {
 		flCommandWebhookURL      = flagset.String("command-webhook-url", env.String("MICROMDM_WEBHOOK_URL", ""), "URL to send command responses")
 		flHomePage               = flagset.Bool("homepage", env.Bool("MICROMDM_HTTP_HOMEPAGE", true), "Hosts a simple built-in webpage at the / address")
 		flSCEPClientValidity     = flagset.Int("scep-client-validity", env.Int("MICROMDM_SCEP_CLIENT_VALIDITY", 365), "Sets the scep certificate validity in days")
+		flSCEPUpstreamURL        = flagset.String("scep-upstream-url", env.String("MICROMDM_SCEP_UPSTREAM_URL", ""), "Issue device identity certificates from an external CA by forwarding SCEP requests to this SCEP server URL, like https://ca.acme.co/scep")
+		flSCEPCABundle           = flagset.String("scep-ca-bundle", env.String("MICROMDM_SCEP_CA_BUNDLE", ""), "Path to PEM encoded CA certificates of an external PKI which are trusted for device identity certificates")
+		flSCEPRenewalDays        = flagset.Int("scep-renewal-days", env.Int("MICROMDM_SCEP_RENEWAL_DAYS", 0), "Renew device identity certificates this many days before they expire by installing the enrollment profile again (0 disables renewal)")
 		flNoCmdHistory           = flagset.Bool("no-command-history", env.Bool("MICROMDM_NO_COMMAND_HISTORY", false), "disables saving of command history")
 		flUseDynChallenge        = flagset.Bool("use-dynamic-challenge", env.Bool("MICROMDM_USE_DYNAMIC_CHALLENGE", false), "require dynamic SCEP challenges")
 		flGenDynChalEnroll       = flagset.Bool("gen-dynamic-challenge", env.Bool("MICROMDM_GEN_DYNAMIC_CHALLENGE", false), "generate dynamic SCEP challenges in enrollment profile (built-in only)")
@@ -125,6 +138,22 @@ func serve(args []string) error {
 		flValidateSCEPExpiration = flagset.Bool("validate-scep-expiration", env.Bool("MICROMDM_VALIDATE_SCEP_EXPIRATION", false), "validate that the SCEP certificate is still valid")
 		flPrintArgs              = flagset.Bool("print-flags", false, "Print all flags and their values")
 		flQueue                  = flagset.String("queue", env.String("MICROMDM_QUEUE", "builtin"), "command queue type")
+		flStorage                = flagset.String("storage", env.String("MICROMDM_STORAGE", "builtin"), "storage backend: builtin (BoltDB) or sqlite")
+		flACMEEnrollment         = flagset.Bool("acme-enrollment", env.Bool("MICROMDM_ACME_ENROLLMENT", false), "Request device identity certificates over ACME instead of SCEP in the enrollment profile, signed by the SCEP CA")
+		flACMEHardwareBound      = flagset.Bool("acme-request-hardware-bound", env.Bool("MICROMDM_ACME_REQUEST_HARDWARE_BOUND", false), "Request that devices generate their ACME device identity keys in the Secure Enclave (requires iOS 16 or macOS 13). The attestation is not verified")
+		flAccountEnrollDomain    = flagset.String("account-enrollment-domain", env.String("MICROMDM_ACCOUNT_ENROLLMENT_DOMAIN", ""), "Enable account-driven enrollment for Managed Apple IDs in this domain, signing in users created with mdmctl apply users")
+		flSetupAssistantAuth     = flagset.Bool("setup-assistant-auth", env.Bool("MICROMDM_SETUP_ASSISTANT_AUTH", false), "Serve a sign in page at /mdm/setup for the configuration_web_url of DEP profiles, signing in users created with mdmctl apply users")
+		flEnrollAllowedSerials   = flagset.String("enroll-allowed-serials", env.String("MICROMDM_ENROLL_ALLOWED_SERIALS", ""), "Path to a file with the serial numbers allowed to enroll from Setup Assistant, one per line")
+		flEnrollDEPDevicesOnly   = flagset.Bool("enroll-dep-devices-only", env.Bool("MICROMDM_ENROLL_DEP_DEVICES_ONLY", false), "Only allow devices synced from DEP to enroll from Setup Assistant")
+		flEnrollAllowedModels    = flagset.String("enroll-allowed-models", env.String("MICROMDM_ENROLL_ALLOWED_MODELS", ""), "Space separated models allowed to enroll from Setup Assistant, like \"MacBookPro18,3 iPhone*\"")
+		flEnrollMinOSVersion     = flagset.String("enroll-min-os-version", env.String("MICROMDM_ENROLL_MIN_OS_VERSION", ""), "Minimum OS version of devices enrolling from Setup Assistant")
+		flOTAStrict              = flagset.Bool("ota-strict", env.Bool("MICROMDM_OTA_STRICT", false), "Reject OTA enrollment requests whose signed device attributes lack the UDID or serial number")
+		flClientCertAuth         = flagset.Bool("client-cert-auth", env.Bool("MICROMDM_CLIENT_CERT_AUTH", false), "Authenticate devices at /mdm/checkin and /mdm/connect with their identity as TLS client certificate instead of the Mdm-Signature header")
+		flClientCertHeader       = flagset.String("client-cert-header", env.String("MICROMDM_CLIENT_CERT_HEADER", ""), "Header with the TLS client certificate set by a reverse proxy which terminates TLS, like X-Forwarded-Client-Cert (requires -client-cert-auth)")
+		flPushConcurrency        = flagset.Int("push-concurrency", env.Int("MICROMDM_PUSH_CONCURRENCY", apns.DefaultBulkPushConcurrency), "Maximum number of push notifications of a bulk push in flight at once")
+		flPushRate               = flagset.Int("push-rate", env.Int("MICROMDM_PUSH_RATE", apns.DefaultBulkPushRate), "Maximum number of push notifications per second sent by a bulk push, 0 for no limit")
+		flRepushInterval         = flagset.Duration("repush-interval", envDuration("MICROMDM_REPUSH_INTERVAL", apns.DefaultRepushInterval), "Push devices again which haven't fetched their queued commands this long after the push, doubling the delay after every push. At least 1s, or 0 to disable repushes (requires -queue builtin)")
+		flRepushMaxInterval      = flagset.Duration("repush-max-interval", envDuration("MICROMDM_REPUSH_MAX_INTERVAL", apns.DefaultRepushMaxInterval), "Maximum delay between repushes, after which devices are not pushed again")
 	)
 	flagset.Usage = usageFor(flagset, "micromdm serve [flags]")
 	if err := flagset.Parse(args); err != nil {
@@ -151,6 +180,27 @@ func serve(args []string) error {
 	if !*flTLS && (*flTLSCert != "" || *flTLSKey != "") {
 		return errors.New("cannot set -tls=false and supply -tls-cert or -tls-key")
 	}
+	switch *flSignProfiles {
+	case "", server.SignEnrollmentProfiles, server.SignAllProfiles:
+	default:
+		return fmt.Errorf("-sign-profiles must be %s or %s", server.SignEnrollmentProfiles, server.SignAllProfiles)
+	}
+
+	if *flACMEEnrollment && *flSCEPUpstreamURL != "" {
+		return errors.New("cannot use -acme-enrollment with -scep-upstream-url")
+	}
+	if *flRepushInterval != 0 && *flRepushInterval < apns.MinRepushInterval || *flRepushMaxInterval < *flRepushInterval {
+		return fmt.Errorf("-repush-interval must be 0 or at least %s, and not greater than -repush-max-interval", apns.MinRepushInterval)
+	}
+	if *flPushConcurrency < 1 || *flPushRate < 0 {
+		return errors.New("-push-concurrency must be at least 1 and -push-rate must not be negative")
+	}
+	if *flClientCertHeader != "" && !*flClientCertAuth {
+		return errors.New("-client-cert-header requires -client-cert-auth")
+	}
+	if *flClientCertAuth && *flClientCertHeader == "" && (!*flTLS || *flTLSCert == "" || *flTLSKey == "") {
+		return errors.New("-client-cert-auth requires -tls-cert and -tls-key, or -client-cert-header behind a reverse proxy")
+	}
 
 	logger := log.NewLogfmtLogger(os.Stderr)
 	stdlog.SetOutput(log.NewStdlibAdapter(logger)) // force structured logs
@@ -160,11 +210,19 @@ func serve(args []string) error {
 	if err := os.MkdirAll(*flConfigPath, 0755); err != nil {
 		return errors.Wrapf(err, "creating config directory %s", *flConfigPath)
 	}
+	restrictions, err := enrollRestrictions(*flEnrollAllowedSerials, *flEnrollAllowedModels, *flEnrollMinOSVersion)
+	if err != nil {
+		return err
+	}
 	sm := &server.Server{
 		ConfigPath:             *flConfigPath,
 		ServerPublicURL:        strings.TrimRight(*flServerURL, "/"),
 		Depsim:                 *flDepSim,
 		TLSCertPath:            *flTLSCert,
+		SCEPSubject:            *flSCEPSubject,
+		EnrollRestrictions:     restrictions,
+		EnrollDEPDevicesOnly:   *flEnrollDEPDevicesOnly,
+		OTAStrict:              *flOTAStrict,
 		CommandWebhookURL:      *flCommandWebhookURL,
 		NoCmdHistory:           *flNoCmdHistory,
 		UseDynSCEPChallenge:    *flUseDynChallenge,
@@ -172,11 +230,47 @@ func serve(args []string) error {
 		ValidateSCEPIssuer:     *flValidateSCEPIssuer,
 		UDIDCertAuthWarnOnly:   *flUDIDCertAuthWarnOnly,
 		ValidateSCEPExpiration: *flValidateSCEPExpiration,
+		ACMEEnrollment:         *flACMEEnrollment,
+		ACMEHardwareBound:      *flACMEHardwareBound,
+		ClientCertAuth:         *flClientCertAuth,
+		PushConcurrency:        *flPushConcurrency,
+		PushRate:               *flPushRate,
+		RepushInterval:         *flRepushInterval,
+		RepushMaxInterval:      *flRepushMaxInterval,
 
 		WebhooksHTTPClient: &http.Client{Timeout: time.Second * 30},
 
 		SCEPClientValidity: *flSCEPClientValidity,
+		SCEPRenewalDays:    *flSCEPRenewalDays,
+		SCEPUpstreamURL:    *flSCEPUpstreamURL,
 		Queue:              *flQueue,
+		Storage:            *flStorage,
+	}
+	if *flSCEPCABundle != "" {
+		data, err := ioutil.ReadFile(*flSCEPCABundle)
+		if err != nil {
+			return errors.Wrap(err, "read SCEP CA bundle")
+		}
+		bundle, err := scepplatform.ParseCABundle(data)
+		if err != nil {
+			return err
+		}
+		sm.SCEPCABundle = bundle
+	}
+	if *flSignProfiles != "" {
+		certPath, keyPath := *flProfileSigningCert, *flProfileSigningKey
+		if certPath == "" && keyPath == "" {
+			certPath, keyPath = *flTLSCert, *flTLSKey
+		}
+		if certPath == "" || keyPath == "" {
+			return errors.New("-sign-profiles requires -profile-signing-cert and -profile-signing-key, or -tls-cert and -tls-key")
+		}
+		signer, err := profileutil.NewSigner(certPath, keyPath)
+		if err != nil {
+			return err
+		}
+		sm.SignProfiles = *flSignProfiles
+		sm.ProfileSigner = signer
 	}
 	if !sm.UseDynSCEPChallenge {
 		// TODO: we have a static SCEP challenge password here to prevent
@@ -200,29 +294,19 @@ func serve(args []string) error {
 		if err != nil {
 			stdlog.Fatal(err)
 		}
-		removeService = block.LoggingMiddleware(logger)(svc)
+		removeService = block.RevokeMiddleware(sm.SCEPCAService)(svc)
+		removeService = block.LoggingMiddleware(logger)(removeService)
 	}
 
-	devDB, err := devicebuiltin.NewDB(sm.DB)
-	if err != nil {
-		stdlog.Fatal(err)
-	}
-
-	devWorker := device.NewWorker(devDB, sm.PubClient, logger)
+	devDB := sm.DeviceDB
+	devWorker := device.NewWorker(devDB, sm.PubClient, logger, device.WithWorkerSearchIndex(sm.DeviceSearchIndex))
 	go devWorker.Run(context.Background())
 
-	userDB, err := userbuiltin.NewDB(sm.DB)
-	if err != nil {
-		stdlog.Fatal(err)
-	}
+	userDB := sm.UserDB
 	userWorker := user.NewWorker(userDB, sm.PubClient, logger)
 	go userWorker.Run(context.Background())
 
-	bpDB, err := blueprintbuiltin.NewDB(sm.DB, sm.ProfileDB)
-	if err != nil {
-		stdlog.Fatal(err)
-	}
-
+	bpDB := sm.BlueprintDB
 	blueprintWorker := blueprint.NewWorker(
 		bpDB,
 		userDB,
@@ -233,6 +317,19 @@ func serve(args []string) error {
 	)
 	go blueprintWorker.Run(context.Background())
 
+	if sm.SCEPRenewalDB != nil {
+		renewalWorker := scepplatform.NewRenewalWorker(
+			devDB,
+			sm.SCEPDepot,
+			sm.SCEPRenewalDB,
+			sm.EnrollService,
+			sm.CommandService,
+			sm.SCEPRenewalDays,
+			log.With(logger, "component", "scep_renewal"),
+		)
+		go renewalWorker.Run(context.Background())
+	}
+
 	ctx := context.Background()
 	httpLogger := log.With(logger, "transport", "http")
 
@@ -249,18 +346,39 @@ func serve(args []string) error {
 	r, options := httputil2.NewRouter(logger)
 
 	r.Handle("/version", version.Handler())
+	r.Handle("/mdm/enroll", invitation.MakeEnrollHandler(sm.InvitationDB, sm.EnrollService, log.With(logger, "component", "invitation"))).Methods("GET").Queries(invitation.TokenParam, "{token}")
 	r.Handle("/mdm/enroll", enrollHandlers.EnrollHandler).Methods("GET", "POST")
 	r.Handle("/ota/enroll", enrollHandlers.OTAEnrollHandler)
 	r.Handle("/ota/phase23", enrollHandlers.OTAPhase2Phase3Handler).Methods("POST")
 	r.Handle("/scep", scepHandler)
+	r.Handle(scepplatform.CRLPath, scepplatform.MakeCRLHandler(sm.SCEPCAService, scepComponentLogger)).Methods("GET")
+	if sm.ACMEIssuer != nil {
+		r.PathPrefix(acme.PathPrefix + "/").Handler(acme.MakeHTTPHandler(sm.ACMEIssuer, log.With(logger, "component", "acme")))
+	}
+	if *flAccountEnrollDomain != "" {
+		auth := enroll.NewLocalUserAuthenticator(sm.UserDB, *flAccountEnrollDomain)
+		accountHandlers := enroll.MakeAccountHTTPHandlers(sm.EnrollService, auth, sm.ServerPublicURL, log.With(logger, "component", "account-enrollment"))
+		r.Handle(enroll.ServiceDiscoveryPath, accountHandlers.ServiceDiscoveryHandler).Methods("GET")
+		r.Handle(enroll.AccountEnrollPath+"/byod", accountHandlers.BYODEnrollHandler).Methods("POST")
+		r.Handle(enroll.AccountEnrollPath+"/adde", accountHandlers.ADDEEnrollHandler).Methods("POST")
+		r.Handle(enroll.AccountLoginPath, accountHandlers.LoginHandler).Methods("GET", "POST")
+	}
+	if *flSetupAssistantAuth {
+		auth := enroll.NewLocalUserAuthenticator(sm.UserDB, *flAccountEnrollDomain)
+		r.Handle(enroll.ConfigurationWebPath, enroll.MakeConfigurationWebHandler(sm.EnrollService, auth, log.With(logger, "component", "setup-assistant"))).Methods("GET", "POST")
+	}
 	if *flHomePage {
 		r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			io.WriteString(w, homePage)
 		})
 	}
 
+	var mdmOpts []mdm.HTTPOption
+	if *flClientCertAuth {
+		mdmOpts = append(mdmOpts, mdm.WithClientCertAuth(*flClientCertHeader))
+	}
 	mdmEndpoints := mdm.MakeServerEndpoints(sm.MDMService)
-	mdm.RegisterHTTPHandlers(r, mdmEndpoints, logger)
+	mdm.RegisterHTTPHandlers(r, mdmEndpoints, logger, mdmOpts...)
 
 	// API commands. Only handled if the user provides an api key.
 	if *flAPIKey != "" {
@@ -273,9 +391,14 @@ func serve(args []string) error {
 		apnsEndpoints := apns.MakeServerEndpoints(sm.APNSPushService, basicAuthEndpointMiddleware)
 		apns.RegisterHTTPHandlers(r, apnsEndpoints, options...)
 
-		devicesvc := device.New(devDB)
+		devicesvc := device.New(
+			devDB,
+			device.WithSearchIndex(sm.DeviceSearchIndex),
+			device.WithCertificateRevoker(sm.SCEPCAService),
+		)
 		deviceEndpoints := device.MakeServerEndpoints(devicesvc, basicAuthEndpointMiddleware)
 		device.RegisterHTTPHandlers(r, deviceEndpoints, options...)
+		r.Methods("GET").Path("/v1/devices/export").Handler(httputil2.RequireBasicAuth(device.MakeExportDevicesHandler(devDB, sm.DeviceSearchIndex), "micromdm", *flAPIKey, "micromdm"))
 
 		profilesvc := profile.New(sm.ProfileDB)
 		profileEndpoints := profile.MakeServerEndpoints(profilesvc, basicAuthEndpointMiddleware)
@@ -285,6 +408,17 @@ func serve(args []string) error {
 		blueprintEndpoints := blueprint.MakeServerEndpoints(blueprintsvc, basicAuthEndpointMiddleware)
 		blueprint.RegisterHTTPHandlers(r, blueprintEndpoints, options...)
 
+		invitationsvc := invitation.New(sm.InvitationDB, sm.ServerPublicURL)
+		invitationEndpoints := invitation.MakeServerEndpoints(invitationsvc, basicAuthEndpointMiddleware)
+		invitation.RegisterHTTPHandlers(r, invitationEndpoints, options...)
+
+		templatesvc := enrollment.New(sm.EnrollmentTemplateDB)
+		templateEndpoints := enrollment.MakeServerEndpoints(templatesvc, basicAuthEndpointMiddleware)
+		enrollment.RegisterHTTPHandlers(r, templateEndpoints, options...)
+
+		scepCAEndpoints := scepplatform.MakeServerEndpoints(sm.SCEPCAService, basicAuthEndpointMiddleware)
+		scepplatform.RegisterHTTPHandlers(r, scepCAEndpoints, options...)
+
 		blockEndpoints := block.MakeServerEndpoints(removeService, basicAuthEndpointMiddleware)
 		block.RegisterHTTPHandlers(r, blockEndpoints, options...)
 
@@ -311,8 +445,8 @@ func serve(args []string) error {
 		depsyncEndpoints := sync.MakeServerEndpoints(sync.NewService(syncer, sm.SyncDB), basicAuthEndpointMiddleware)
 		sync.RegisterHTTPHandlers(r, depsyncEndpoints, options...)
 
-		if sm.SCEPChallengeDepot != nil {
-			challengeEndpoints := challenge.MakeServerEndpoints(challenge.NewService(sm.SCEPChallengeDepot), basicAuthEndpointMiddleware)
+		if sm.SCEPChallengeService != nil {
+			challengeEndpoints := challenge.MakeServerEndpoints(sm.SCEPChallengeService, basicAuthEndpointMiddleware)
 			challenge.RegisterHTTPHandlers(r, challengeEndpoints, options...)
 		}
 
@@ -344,6 +478,11 @@ func serve(args []string) error {
 		return errors.Wrapf(err, "parsing serverURL %q", sm.ServerPublicURL)
 	}
 
+	if *flClientCertAuth && *flClientCertHeader == "" {
+		err = listenAndServeClientCertAuth(handler, *flHTTPAddr, *flTLSCert, *flTLSKey, logger)
+		return errors.Wrap(err, "calling ListenAndServe")
+	}
+
 	serveOpts := serveOptions(
 		handler,
 		*flHTTPAddr,
@@ -390,6 +529,80 @@ func serveOptions(
 	return serveOpts
 }
 
+// listenAndServeClientCertAuth serves handler over TLS with the key pair at
+// certPath and keyPath, and requests TLS client certificates so that devices
+// can authenticate with their identity. The certificates aren't verified in
+// the TLS handshake, but by the MDM service, which knows the trusted CAs.
+func listenAndServeClientCertAuth(handler http.Handler, addr, certPath, keyPath string, logger log.Logger) error {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return errors.Wrap(err, "loading TLS certificate from file")
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    1 << 20,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+			ClientAuth:   tls.RequestClientCert,
+		},
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		errs <- server.Shutdown(ctx)
+	}()
+	go func() {
+		logger.Log("msg", "serving HTTPS with TLS client certificates", "addr", addr)
+		if err := server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+			errs <- err
+		}
+	}()
+	return <-errs
+}
+
+// enrollRestrictions returns the restrictions for devices enrolling from
+// Setup Assistant, or nil if there are none.
+func enrollRestrictions(serialsPath, models, minOSVersion string) (*enroll.Restrictions, error) {
+	if serialsPath == "" && models == "" && minOSVersion == "" {
+		return nil, nil
+	}
+	r := &enroll.Restrictions{
+		Models:       strings.Fields(models),
+		MinOSVersion: minOSVersion,
+	}
+	if serialsPath != "" {
+		data, err := ioutil.ReadFile(serialsPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "read allowed serials")
+		}
+		r.Serials = strings.Fields(string(data))
+		if len(r.Serials) == 0 {
+			return nil, fmt.Errorf("no serial numbers in %s", serialsPath)
+		}
+	}
+	return r, nil
+}
+
+// envDuration returns the duration of the environment variable key, or def
+// if it isn't set or can't be parsed.
+func envDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(env.String(key, "")); err == nil {
+		return d
+	}
+	return def
+}
+
 func boltBackup(db *bolt.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := db.View(func(tx *bolt.Tx) error {
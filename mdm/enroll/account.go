@@ -0,0 +1,335 @@
+package enroll
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/invitation"
+	"github.com/micromdm/micromdm/platform/profile"
+	"github.com/micromdm/micromdm/platform/user"
+)
+
+// Enrollment modes of account-driven enrollment.
+const (
+	// EnrollmentModeBYOD is account-driven User Enrollment.
+	EnrollmentModeBYOD = "BYOD"
+	// EnrollmentModeADDE is account-driven Device Enrollment.
+	EnrollmentModeADDE = "ADDE"
+)
+
+// AccountIdentity is a user authenticated for account-driven enrollment.
+type AccountIdentity struct {
+	Username       string
+	FullName       string
+	ManagedAppleID string
+}
+
+// ErrInvalidCredentials is returned by an Authenticator if the username or
+// password is wrong.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Authenticator authenticates users signing in to account-driven enrollment.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (*AccountIdentity, error)
+}
+
+// LocalUserAuthenticator authenticates the users created with
+// mdmctl apply users against their password. It is meant for testing
+// account-driven enrollment without an identity provider.
+type LocalUserAuthenticator struct {
+	users  interface{ List() ([]user.User, error) }
+	domain string
+}
+
+// NewLocalUserAuthenticator returns an Authenticator for the users in the
+// store. The Managed Apple ID of a user is their short name at the domain,
+// if a domain is given.
+func NewLocalUserAuthenticator(users interface{ List() ([]user.User, error) }, domain string) *LocalUserAuthenticator {
+	return &LocalUserAuthenticator{users: users, domain: domain}
+}
+
+func (a *LocalUserAuthenticator) Authenticate(ctx context.Context, username, password string) (*AccountIdentity, error) {
+	// users may sign in with their Managed Apple ID.
+	shortname := strings.TrimSuffix(username, "@"+a.domain)
+	users, err := a.users.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if u.UserShortname != shortname || len(u.PasswordHash) == 0 {
+			continue
+		}
+		if err := u.VerifyPassword(password); err != nil {
+			return nil, ErrInvalidCredentials
+		}
+		identity := &AccountIdentity{
+			Username: u.UserShortname,
+			FullName: u.UserLongname,
+		}
+		if a.domain != "" {
+			identity.ManagedAppleID = u.UserShortname + "@" + a.domain
+		}
+		return identity, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// AccountEnroll returns the enrollment profile for an authenticated
+// account-driven enrollment. The profile assigns the Managed Apple ID of the
+// user, which is also passed to check-ins through the sign in invitation, so
+// that user enrollments are recorded with it.
+func (svc *service) AccountEnroll(ctx context.Context, mode string, identity AccountIdentity) (profile.Mobileconfig, error) {
+	inv, err := invitation.NewInvitation("", "", signInTTL)
+	if err != nil {
+		return nil, err
+	}
+	inv.ManagedAppleID = identity.ManagedAppleID
+	params, err := svc.signInParams(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	p, err := svc.MakeEnrollmentProfile()
+	if err != nil {
+		return nil, err
+	}
+	for i, payload := range p.PayloadContent {
+		mdmPayload, ok := payload.(MDMPayloadContent)
+		if !ok {
+			continue
+		}
+		mdmPayload.AssignedManagedAppleID = identity.ManagedAppleID
+		mdmPayload.EnrollmentMode = mode
+		p.PayloadContent[i] = mdmPayload
+	}
+	p = withCheckInParams(p, params)
+	return profileOrPayloadToMobileconfig(p)
+}
+
+// signInTTL is how long a device may take to enroll with the profile it got
+// after the user signed in.
+const signInTTL = time.Hour
+
+// WithInvitations records the users who sign in at Setup Assistant or for
+// account-driven enrollment as single-use invitations. The CheckInURL of the
+// enrollment profile carries the invitation token, and the
+// invitation.CheckinMiddleware passes the user on to the check-ins of the
+// device. Enrollments after sign in fail without it.
+func WithInvitations(store invitation.Store) Option {
+	return func(svc *service) {
+		svc.Invitations = store
+	}
+}
+
+var errNoInvitations = errors.New("enrollment after sign in requires an invitation store")
+
+// signInParams saves the invitation of a signed in user and returns the
+// CheckInURL params which refer to it. The user isn't added to the CheckInURL
+// itself, as the device could change it.
+func (svc *service) signInParams(ctx context.Context, inv *invitation.Invitation) (url.Values, error) {
+	if svc.Invitations == nil {
+		return nil, errNoInvitations
+	}
+	if err := svc.Invitations.Save(ctx, inv); err != nil {
+		return nil, err
+	}
+	params := url.Values{invitation.TokenParam: {inv.Token}}
+	if inv.Group != "" {
+		params.Set(device.GroupParam, inv.Group)
+	}
+	return params, nil
+}
+
+// Paths of account-driven enrollment.
+const (
+	ServiceDiscoveryPath = "/.well-known/com.apple.remotemanagement"
+	AccountEnrollPath    = "/mdm/account/enroll"
+	AccountLoginPath     = "/mdm/account/login"
+)
+
+// accountTokenTTL is how long a device may use the access token it got by
+// signing in to request the enrollment profile.
+const accountTokenTTL = 15 * time.Minute
+
+type AccountHTTPHandlers struct {
+	// ServiceDiscoveryHandler serves the com.apple.remotemanagement
+	// discovery document. It must be reachable at the domain of the Managed
+	// Apple IDs, for example through a reverse proxy.
+	ServiceDiscoveryHandler http.Handler
+	// BYODEnrollHandler and ADDEEnrollHandler return the enrollment profile
+	// to a device with an access token and ask it to sign in otherwise.
+	BYODEnrollHandler http.Handler
+	ADDEEnrollHandler http.Handler
+	// LoginHandler is the sign in page shown by the device, which hands an
+	// access token back to the device.
+	LoginHandler http.Handler
+}
+
+// MakeAccountHTTPHandlers returns the handlers for account-driven enrollment
+// of the server at serverURL. Users sign in with the authenticator.
+func MakeAccountHTTPHandlers(svc Service, auth Authenticator, serverURL string, logger log.Logger) AccountHTTPHandlers {
+	a := &accountEnrollment{
+		svc:       svc,
+		auth:      auth,
+		serverURL: serverURL,
+		logger:    logger,
+		tokens:    make(map[string]accountToken),
+	}
+	return AccountHTTPHandlers{
+		ServiceDiscoveryHandler: http.HandlerFunc(a.serviceDiscovery),
+		BYODEnrollHandler:       a.enrollHandler(EnrollmentModeBYOD),
+		ADDEEnrollHandler:       a.enrollHandler(EnrollmentModeADDE),
+		LoginHandler:            http.HandlerFunc(a.login),
+	}
+}
+
+type accountEnrollment struct {
+	svc       Service
+	auth      Authenticator
+	serverURL string
+	logger    log.Logger
+
+	mu     sync.Mutex
+	tokens map[string]accountToken
+}
+
+type accountToken struct {
+	identity AccountIdentity
+	expires  time.Time
+}
+
+func (a *accountEnrollment) serviceDiscovery(w http.ResponseWriter, r *http.Request) {
+	type server struct {
+		Version string
+		BaseURL string
+	}
+	doc := struct{ Servers []server }{
+		Servers: []server{
+			{Version: "mdm-byod", BaseURL: a.serverURL + AccountEnrollPath + "/byod"},
+			{Version: "mdm-adde", BaseURL: a.serverURL + AccountEnrollPath + "/adde"},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (a *accountEnrollment) enrollHandler(mode string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := a.identity(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if !ok {
+			// ask the device to sign the user in with the web view.
+			w.Header().Set("WWW-Authenticate", `Bearer method="apple-as-web" url="`+a.serverURL+AccountLoginPath+`"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mc, err := a.svc.AccountEnroll(r.Context(), mode, identity)
+		if err != nil {
+			level.Info(a.logger).Log("msg", "account-driven enrollment", "mode", mode, "user", identity.Username, "err", err)
+			http.Error(w, "enrollment failed", http.StatusInternalServerError)
+			return
+		}
+		level.Info(a.logger).Log("msg", "account-driven enrollment", "mode", mode, "user", identity.Username)
+		w.Header().Set("Content-Type", "application/x-apple-aspen-config")
+		w.Write(mc)
+	})
+}
+
+// loginPageData fills the loginPage. MachineInfo is the base64 encoded
+// MachineInfo of the device, which the form posts back.
+type loginPageData struct {
+	Username, Error, MachineInfo string
+}
+
+// machineInfoFormField is the form field of loginPageData.MachineInfo.
+const machineInfoFormField = "machine_info"
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Sign in to enroll</title>
+</head>
+<body>
+	<h1>Sign in to enroll</h1>
+	{{if .Error}}<p>{{.Error}}</p>{{end}}
+	<form method="post">
+		<p><input type="text" name="username" value="{{.Username}}" placeholder="Username" autocapitalize="off" autocorrect="off" required></p>
+		<p><input type="password" name="password" placeholder="Password" required></p>
+		{{if .MachineInfo}}<input type="hidden" name="machine_info" value="{{.MachineInfo}}">{{end}}
+		<p><button type="submit">Sign In</button></p>
+	</form>
+</body>
+</html>
+`))
+
+func (a *accountEnrollment) login(w http.ResponseWriter, r *http.Request) {
+	data := loginPageData{
+		// the device passes the account the user entered in Settings.
+		Username: r.URL.Query().Get("user-identifier"),
+	}
+	if r.Method == http.MethodPost {
+		data.Username = r.PostFormValue("username")
+		identity, err := a.auth.Authenticate(r.Context(), data.Username, r.PostFormValue("password"))
+		if err == nil {
+			token, err := a.issueToken(*identity)
+			if err != nil {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "apple-remotemanagement-user-login://authentication-results?access-token="+url.QueryEscape(token), http.StatusFound)
+			return
+		}
+		level.Info(a.logger).Log("msg", "account-driven enrollment sign in", "user", data.Username, "err", err)
+		data.Error = ErrInvalidCredentials.Error()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		loginPage.Execute(w, data)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	loginPage.Execute(w, data)
+}
+
+func (a *accountEnrollment) issueToken(identity AccountIdentity) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for t, at := range a.tokens {
+		if now.After(at.expires) {
+			delete(a.tokens, t)
+		}
+	}
+	a.tokens[token] = accountToken{identity: identity, expires: now.Add(accountTokenTTL)}
+	return token, nil
+}
+
+func (a *accountEnrollment) identity(token string) (AccountIdentity, bool) {
+	if token == "" {
+		return AccountIdentity{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	at, ok := a.tokens[token]
+	if !ok || time.Now().After(at.expires) {
+		return AccountIdentity{}, false
+	}
+	return at.identity, true
+}
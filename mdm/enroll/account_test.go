@@ -0,0 +1,117 @@
+package enroll
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/groob/plist"
+
+	"github.com/micromdm/micromdm/pkg/crypto/password"
+	"github.com/micromdm/micromdm/platform/invitation"
+	"github.com/micromdm/micromdm/platform/user"
+)
+
+type userList []user.User
+
+func (l userList) List() ([]user.User, error) { return l, nil }
+
+// invitationStore keeps the sign in invitations in memory.
+type invitationStore struct {
+	invitation.Store
+	invitations []*invitation.Invitation
+}
+
+func (s *invitationStore) Save(ctx context.Context, inv *invitation.Invitation) error {
+	s.invitations = append(s.invitations, inv)
+	return nil
+}
+
+func passwordHash(t *testing.T, pass string) []byte {
+	t.Helper()
+	salted, err := password.SaltedSHA512PBKDF2(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := plist.Marshal(struct {
+		SaltedSHA512PBKDF2 password.SaltedSHA512PBKDF2Dictionary `plist:"SALTED-SHA512-PBKDF2"`
+	}{salted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestAccountEnrollment(t *testing.T) {
+	auth := NewLocalUserAuthenticator(userList{{UserShortname: "jane", PasswordHash: passwordHash(t, "secret")}}, "example.org")
+	invitations := new(invitationStore)
+	svc := &service{URL: "https://mdm.example.org", Invitations: invitations}
+	h := MakeAccountHTTPHandlers(svc, auth, svc.URL, log.NewNopLogger())
+
+	// without an access token the device is asked to sign in
+	rec := httptest.NewRecorder()
+	h.BYODEnrollHandler.ServeHTTP(rec, httptest.NewRequest("POST", AccountEnrollPath+"/byod", nil))
+	if have, want := rec.Code, http.StatusUnauthorized; have != want {
+		t.Fatalf("have status %d, want %d", have, want)
+	}
+	if have, want := rec.Header().Get("WWW-Authenticate"), `url="https://mdm.example.org/mdm/account/login"`; !strings.Contains(have, want) {
+		t.Errorf("have %s, want it to contain %s", have, want)
+	}
+
+	login := func(username, pass string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {pass}}
+		req := httptest.NewRequest("POST", AccountLoginPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.LoginHandler.ServeHTTP(rec, req)
+		return rec
+	}
+	if have, want := login("jane", "wrong").Code, http.StatusUnauthorized; have != want {
+		t.Errorf("have status %d, want %d", have, want)
+	}
+	rec = login("jane@example.org", "secret")
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := location.Scheme, "apple-remotemanagement-user-login"; have != want {
+		t.Fatalf("have redirect scheme %q, want %q", have, want)
+	}
+	token := location.Query().Get("access-token")
+
+	req := httptest.NewRequest("POST", AccountEnrollPath+"/byod", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	h.BYODEnrollHandler.ServeHTTP(rec, req)
+	if have, want := rec.Code, http.StatusOK; have != want {
+		t.Fatalf("have status %d, want %d", have, want)
+	}
+	for _, want := range []string{
+		"<key>AssignedManagedAppleID</key>",
+		"<string>jane@example.org</string>",
+		"<key>EnrollmentMode</key>",
+		"<string>BYOD</string>",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("enrollment profile does not contain %s", want)
+		}
+	}
+	// the Managed Apple ID is passed to check-ins by the sign in invitation.
+	if have, want := len(invitations.invitations), 1; have != want {
+		t.Fatalf("have %d invitations, want %d", have, want)
+	}
+	inv := invitations.invitations[0]
+	if have, want := inv.ManagedAppleID, "jane@example.org"; have != want {
+		t.Errorf("have managed apple id %s, want %s", have, want)
+	}
+	if want := "/mdm/checkin?invitation=" + inv.Token; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("enrollment profile does not contain %s", want)
+	}
+	if strings.Contains(rec.Body.String(), "managed_apple_id") {
+		t.Error("the CheckInURL carries the managed apple id")
+	}
+}
@@ -2,10 +2,13 @@ package enroll
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/micromdm/micromdm/pkg/crypto"
+	"github.com/micromdm/micromdm/platform/device"
 	"github.com/micromdm/micromdm/platform/profile"
 
 	"github.com/go-kit/kit/endpoint"
@@ -20,13 +23,10 @@ type Endpoints struct {
 }
 
 type depEnrollmentRequest struct {
-	Language string `plist:"LANGUAGE"`
-	Product  string `plist:"PRODUCT"`
-	Serial   string `plist:"SERIAL"`
-	UDID     string `plist:"UDID"`
-	Version  string `plist:"VERSION"`
-	IMEI     string `plist:"IMEI,omitempty"`
-	MEID     string `plist:"MEID,omitempty"`
+	MachineInfo
+
+	template string
+	group    string
 }
 
 type otaEnrollmentRequest struct {
@@ -47,7 +47,12 @@ type otaEnrollmentRequest struct {
 	UserShortName string
 }
 
-type mdmEnrollRequest struct{}
+type mdmEnrollRequest struct {
+	template string
+	group    string
+	// machineInfo is set if the device sent the MachineInfoHeader.
+	machineInfo *MachineInfo
+}
 
 type mobileconfigResponse struct {
 	profile.Mobileconfig
@@ -71,11 +76,27 @@ func MakeGetEnrollEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		switch req := request.(type) {
 		case mdmEnrollRequest:
-			mc, err := s.Enroll(ctx)
+			if err := s.CheckMachineInfo(ctx, req.machineInfo); err != nil {
+				return nil, err
+			}
+			if req.machineInfo != nil {
+				ctx = withSerial(ctx, req.machineInfo.Serial)
+			}
+			mc, err := enrollWith(ctx, s, req.template, req.group)
+			if _, ok := err.(templateNotFoundError); ok {
+				return nil, err
+			}
 			return mobileconfigResponse{mc, err}, nil
 		case depEnrollmentRequest:
 			fmt.Printf("got DEP enrollment request from %s\n", req.Serial)
-			mc, err := s.Enroll(ctx)
+			if err := s.CheckMachineInfo(ctx, &req.MachineInfo); err != nil {
+				return nil, err
+			}
+			ctx = withSerial(ctx, req.Serial)
+			mc, err := enrollWith(ctx, s, req.template, req.group)
+			if _, ok := err.(templateNotFoundError); ok {
+				return nil, err
+			}
 			return mobileconfigResponse{mc, err}, nil
 		default:
 			return nil, errors.New("unknown enrollment type")
@@ -83,6 +104,19 @@ func MakeGetEnrollEndpoint(s Service) endpoint.Endpoint {
 	}
 }
 
+// enrollWith returns the enrollment profile made from the named template,
+// with the group in its CheckInURL if the enrollment URL was tagged with one.
+func enrollWith(ctx context.Context, s Service, template, group string) (profile.Mobileconfig, error) {
+	if template == "" && group == "" {
+		return s.Enroll(ctx)
+	}
+	var params url.Values
+	if group != "" {
+		params = url.Values{device.GroupParam: {group}}
+	}
+	return s.EnrollWithTemplate(ctx, template, params)
+}
+
 func MakeOTAEnrollEndpoint(s Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		mc, err := s.OTAEnroll(ctx)
@@ -98,16 +132,15 @@ func MakeOTAPhase2Phase3Endpoint(s Service, scepDepot depot.Depot) endpoint.Endp
 			return nil, errors.New("invalid signer/signer not provided")
 		}
 
-		// TODO: currently only verifying the signing certificate but ought to
-		// verify the whole provided chain. Note this will be difficult to do
-		// given the inconsist certificate chain returned by macOS in OTA mode,
-		// macOS in DEP mode, and iOS in either mode. See:
-		// https://openradar.appspot.com/radar?id=4957320861712384
-		if err := crypto.VerifyFromAppleDeviceCA(req.p7.GetOnlySigner()); err == nil {
-			// signing certificate is signed by the Apple Device CA. this means
-			// we don't yet have a SCEP identity and thus are in Phase 2 of the
-			// OTA enrollment
-			mc, err := s.OTAPhase2(ctx)
+		// the signing certificate is signed by the Apple Device CA, directly
+		// or through an intermediate in the CMS. this means we don't yet have
+		// a SCEP identity and thus are in Phase 2 of the OTA enrollment.
+		signer := req.p7.GetOnlySigner()
+		if err := crypto.VerifyChainFromAppleDeviceCA(signer, req.p7.Certificates); err == nil {
+			mc, err := s.OTAPhase2(ctx, req.otaEnrollmentRequest.device())
+			if _, ok := err.(unidentifiedDeviceError); ok {
+				return nil, err
+			}
 			return mobileconfigResponse{mc, err}, nil
 		}
 
@@ -120,7 +153,19 @@ func MakeOTAPhase2Phase3Endpoint(s Service, scepDepot depot.Depot) endpoint.Endp
 			return nil, errors.New("invalid SCEP CA chain")
 		}
 
-		if req.p7.GetOnlySigner().CheckSignatureFrom(caChain[0]) == nil {
+		// identities issued before the SCEP CA was rotated are signed by
+		// a previous CA.
+		if d, ok := scepDepot.(interface {
+			PreviousCAs() ([]*x509.Certificate, error)
+		}); ok {
+			previous, err := d.PreviousCAs()
+			if err != nil {
+				return nil, err
+			}
+			caChain = append(caChain[:1:1], previous...)
+		}
+
+		if signedByAny(signer, caChain) {
 			// signing certificate is signed by our SCEP CA. this means we
 			// we are in Phase 3 of OTA enrollment (as we already have a
 			// identified certificate)
@@ -137,6 +182,28 @@ func MakeOTAPhase2Phase3Endpoint(s Service, scepDepot depot.Depot) endpoint.Endp
 			// profile, err := s.OTAPhase3(ctx)
 			return mobileconfigResponse{mc, err}, nil
 		}
+
 		return mobileconfigResponse{profile.Mobileconfig{}, errors.New("unauthorized client")}, nil
 	}
 }
+
+func (r otaEnrollmentRequest) device() OTADevice {
+	return OTADevice{
+		UDID:       r.UDID,
+		Serial:     r.Serial,
+		Product:    r.Product,
+		Version:    r.Version,
+		IMEI:       r.IMEI,
+		MEID:       r.MEID,
+		DeviceName: r.DeviceName,
+	}
+}
+
+func signedByAny(c *x509.Certificate, cas []*x509.Certificate) bool {
+	for _, ca := range cas {
+		if c.CheckSignatureFrom(ca) == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package enroll
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/profile"
+	"github.com/micromdm/micromdm/platform/pubsub"
+)
+
+// OTADevice is the device of phase 2 of an OTA enrollment, from the
+// attributes it signs with its Apple Device CA certificate.
+type OTADevice struct {
+	UDID       string
+	Serial     string
+	Product    string
+	Version    string // build version
+	IMEI       string
+	MEID       string
+	DeviceName string
+}
+
+// WithStrictOTA rejects phase 2 of OTA enrollments whose signed attributes
+// don't identify the device by its UDID and serial number.
+func WithStrictOTA() Option {
+	return func(svc *service) {
+		svc.OTAStrict = true
+	}
+}
+
+// WithPublisher publishes the verified devices of OTA enrollments to
+// device.OTAEnrollTopic, so that they are added to the device records.
+func WithPublisher(pub pubsub.Publisher) Option {
+	return func(svc *service) {
+		svc.Publisher = pub
+	}
+}
+
+type unidentifiedDeviceError struct{}
+
+func (unidentifiedDeviceError) Error() string {
+	return "unauthorized enrollment client: device attributes lack UDID or serial number"
+}
+
+func (unidentifiedDeviceError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// OTAPhase2 returns a SCEP Profile for use in phase 2 of Over-the-Air
+// enrollment. The device is published to device.OTAEnrollTopic.
+func (svc *service) OTAPhase2(ctx context.Context, dev OTADevice) (profile.Mobileconfig, error) {
+	if svc.OTAStrict && (dev.UDID == "" || dev.Serial == "") {
+		return nil, unidentifiedDeviceError{}
+	}
+	if svc.Publisher != nil && dev.UDID != "" {
+		msg, err := device.MarshalOTAEnrollEvent(&device.OTAEnrollEvent{
+			UDID:         dev.UDID,
+			SerialNumber: dev.Serial,
+			ProductName:  dev.Product,
+			IMEI:         dev.IMEI,
+			MEID:         dev.MEID,
+			BuildVersion: dev.Version,
+			DeviceName:   dev.DeviceName,
+			Time:         time.Now().UTC(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal OTA enroll event")
+		}
+		if err := svc.Publisher.Publish(ctx, device.OTAEnrollTopic, msg); err != nil {
+			return nil, errors.Wrap(err, "publish OTA enroll event")
+		}
+	}
+	return svc.findOrMakeMobileconfig(ctx, OTAProfileId+".phase2", svc.MakeOTAPhase2Profile)
+}
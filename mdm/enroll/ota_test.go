@@ -0,0 +1,132 @@
+package enroll
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/micromdm/scep/v2/depot"
+	"go.mozilla.org/pkcs7"
+
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+type noProfiles struct{ profile.Store }
+
+func (noProfiles) ProfileById(context.Context, string) (*profile.Profile, error) {
+	return nil, notFoundError{}
+}
+
+type publishedMessages map[string][][]byte
+
+func (m publishedMessages) Publish(_ context.Context, topic string, msg []byte) error {
+	m[topic] = append(m[topic], msg)
+	return nil
+}
+
+func TestOTAPhase2(t *testing.T) {
+	published := publishedMessages{}
+	svc := &service{SCEPURL: "https://mdm.example.org/scep", ProfileDB: noProfiles{}}
+	WithPublisher(published)(svc)
+
+	dev := OTADevice{UDID: "UDID-1", Serial: "C02ABC", Product: "iPhone14,2"}
+	if _, err := svc.OTAPhase2(context.Background(), dev); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(published[device.OTAEnrollTopic]), 1; have != want {
+		t.Fatalf("have %d published devices, want %d", have, want)
+	}
+	var ev device.OTAEnrollEvent
+	if err := device.UnmarshalOTAEnrollEvent(published[device.OTAEnrollTopic][0], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := ev.SerialNumber, dev.Serial; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	// strict OTA enrollment requires the device to be identified.
+	WithStrictOTA()(svc)
+	if _, err := svc.OTAPhase2(context.Background(), dev); err != nil {
+		t.Fatal(err)
+	}
+	dev.Serial = ""
+	if _, err := svc.OTAPhase2(context.Background(), dev); err == nil {
+		t.Error("expected strict OTA enrollment to reject a device without serial number")
+	}
+}
+
+// caDepot returns ca as the SCEP CA. The other methods of depot.Depot are not
+// used.
+type caDepot struct {
+	depot.Depot
+	ca *x509.Certificate
+}
+
+func (d caDepot) CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error) {
+	return []*x509.Certificate{d.ca}, nil, nil
+}
+
+func TestOTAPhase2Phase3UnknownSigner(t *testing.T) {
+	svc := &service{SCEPURL: "https://mdm.example.org/scep", ProfileDB: noProfiles{}}
+	WithPublisher(publishedMessages{})(svc)
+	ca, _ := selfSigned(t, "SCEP CA")
+	e := MakeOTAPhase2Phase3Endpoint(svc, caDepot{ca: ca})
+
+	// a CMS signed by neither the Apple Device CA nor the SCEP CA.
+	cert, key := selfSigned(t, "UDID-1")
+	sd, err := pkcs7.NewSignedData([]byte("attributes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := e(context.Background(), mdmOTAPhase2Phase3Request{
+		otaEnrollmentRequest: otaEnrollmentRequest{UDID: "UDID-1", Serial: "C02ABC"},
+		p7:                   p7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := resp.(mobileconfigResponse); have.Err == nil || len(have.Mobileconfig) != 0 {
+		t.Errorf("have profile %q and error %v, want unauthorized client", have.Mobileconfig, have.Err)
+	}
+}
+
+func selfSigned(t *testing.T, cn string) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
@@ -66,6 +66,19 @@ type SCEPPayloadContent struct {
 	URL           string
 }
 
+// ACMEPayloadContent requests the device identity certificate from an ACME
+// server instead of SCEP.
+type ACMEPayloadContent struct {
+	DirectoryURL     string
+	ClientIdentifier string
+	KeySize          int
+	KeyType          string
+	HardwareBound    bool
+	Subject          [][][]string `plist:"Subject,omitempty"`
+	UsageFlags       int
+	ExtendedKeyUsage []string `plist:"ExtendedKeyUsage,omitempty"`
+}
+
 // AccessRights define the management rights of the MDM server over the device.
 // May not be zero. If 2 is specified, 1 must also be specified. If 128 is specified, 64 must also be specified.
 type AccessRights int
@@ -140,6 +153,10 @@ type MDMPayloadContent struct {
 	SignMessage             bool     `plist:"SignMessage,omitempty"`
 	ServerURL               string
 	Topic                   string
+
+	// Set for account-driven enrollments.
+	AssignedManagedAppleID string `plist:"AssignedManagedAppleID,omitempty"`
+	EnrollmentMode         string `plist:"EnrollmentMode,omitempty"`
 }
 
 type ProfileServicePayload struct {
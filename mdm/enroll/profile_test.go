@@ -1,7 +1,15 @@
 package enroll
 
 import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/micromdm/micromdm/platform/challenge"
+	"github.com/micromdm/micromdm/platform/enrollment"
+	"github.com/micromdm/micromdm/platform/invitation"
 )
 
 func TestEnrollProfile(t *testing.T) {
@@ -33,3 +41,224 @@ func TestEnrollProfile(t *testing.T) {
 		t.Errorf("missing ServerCapabilities: macOS enrollment profile requires %s", perUserConnections)
 	}
 }
+
+func TestEnrollInGroup(t *testing.T) {
+	svc := &service{URL: "https://mdm.example.org"}
+	resp, err := MakeGetEnrollEndpoint(svc)(context.Background(), mdmEnrollRequest{group: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := resp.(mobileconfigResponse)
+	if mc.Err != nil {
+		t.Fatal(mc.Err)
+	}
+	if want := "https://mdm.example.org/mdm/checkin?group=lab"; !strings.Contains(string(mc.Mobileconfig), want) {
+		t.Errorf("enrollment profile does not contain CheckInURL %s", want)
+	}
+}
+
+func TestEnrollWithTemplate(t *testing.T) {
+	templates := templateList{
+		"lab": {
+			Name:         "lab",
+			Organization: "Example Inc.",
+			ConsentText:  "Example Inc. may alter your settings.",
+			SCEPSubject:  "/O=Example Inc./CN=%SerialNumber%",
+			SCEPKeySize:  4096,
+			AccessRights: 3,
+			ExtraPayloads: []string{`<dict>
+				<key>PayloadType</key><string>com.apple.example</string>
+				<key>PayloadIdentifier</key><string>com.example.extra</string>
+				<key>PayloadUUID</key><string>D0F6E9B6-1D8A-4E0F-9E0B-5A4C2F4E8D11</string>
+			</dict>`},
+		},
+	}
+	svc := &service{
+		URL:       "https://mdm.example.org",
+		SCEPURL:   "https://mdm.example.org/scep",
+		Templates: templates,
+	}
+
+	resp, err := MakeGetEnrollEndpoint(svc)(context.Background(), mdmEnrollRequest{template: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc := resp.(mobileconfigResponse)
+	if mc.Err != nil {
+		t.Fatal(mc.Err)
+	}
+	for _, want := range []string{
+		"<string>Example Inc.</string>",
+		"<string>Example Inc. may alter your settings.</string>",
+		"<string>%SerialNumber%</string>",
+		"<integer>4096</integer>",
+		"<string>com.example.extra</string>",
+	} {
+		if !strings.Contains(string(mc.Mobileconfig), want) {
+			t.Errorf("enrollment profile does not contain %s", want)
+		}
+	}
+
+	lab := templates["lab"]
+	p, err := svc.makeEnrollmentProfile(context.Background(), &lab, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range p.PayloadContent {
+		if c, ok := payload.(MDMPayloadContent); ok {
+			if have, want := c.AccessRights, AccessRights(3); have != want {
+				t.Errorf("have %d, want %d", have, want)
+			}
+		}
+	}
+
+	_, err = MakeGetEnrollEndpoint(svc)(context.Background(), mdmEnrollRequest{template: "unknown"})
+	if sc, ok := err.(interface{ StatusCode() int }); !ok || sc.StatusCode() != http.StatusNotFound {
+		t.Errorf("have %v, want a not found error", err)
+	}
+}
+
+type templateList map[string]enrollment.Template
+
+func (l templateList) TemplateByName(_ context.Context, name string) (*enrollment.Template, error) {
+	t, ok := l[name]
+	if !ok {
+		return nil, notFoundError{}
+	}
+	return &t, nil
+}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string  { return "not found" }
+func (notFoundError) NotFound() bool { return true }
+
+func TestEnrollWithACME(t *testing.T) {
+	svc := &service{
+		URL:           "https://mdm.example.org",
+		SCEPURL:       "https://mdm.example.org/scep",
+		SCEPChallenge: "secret",
+	}
+	WithACME("https://mdm.example.org/acme/directory", true)(svc)
+
+	p, err := svc.MakeEnrollmentProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		acmePayload *Payload
+		mdmPayload  MDMPayloadContent
+	)
+	for _, payload := range p.PayloadContent {
+		switch payload := payload.(type) {
+		case Payload:
+			if payload.PayloadType == "com.apple.security.scep" {
+				t.Error("enrollment profile with ACME must not have a SCEP payload")
+			}
+			if payload.PayloadType == "com.apple.security.acme" {
+				acmePayload = &payload
+			}
+		case MDMPayloadContent:
+			mdmPayload = payload
+		}
+	}
+	if acmePayload == nil {
+		t.Fatal("enrollment profile has no ACME payload")
+	}
+	if have, want := mdmPayload.IdentityCertificateUUID, acmePayload.PayloadUUID; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	content := acmePayload.PayloadContent.(ACMEPayloadContent)
+	if have, want := content.ClientIdentifier, "secret"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := content.KeyType, "ECSECPrimeRandom"; !content.HardwareBound || have != want {
+		t.Errorf("have %s, want hardware-bound %s key", have, want)
+	}
+}
+
+func TestEnrollWithClientCertAuth(t *testing.T) {
+	for _, clientCertAuth := range []bool{false, true} {
+		svc := &service{URL: "https://mdm.example.org", ClientCertAuth: clientCertAuth}
+		p, err := svc.MakeEnrollmentProfile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, payload := range p.PayloadContent {
+			if c, ok := payload.(MDMPayloadContent); ok {
+				if have, want := c.SignMessage, !clientCertAuth; have != want {
+					t.Errorf("have SignMessage %v, want %v", have, want)
+				}
+			}
+		}
+	}
+}
+
+type challengeRecorder struct {
+	opts []challenge.CreateChallengeOption
+}
+
+func (r *challengeRecorder) CreateChallenge(_ context.Context, opt challenge.CreateChallengeOption) (*challenge.Challenge, error) {
+	r.opts = append(r.opts, opt)
+	return &challenge.Challenge{Challenge: "dynamic"}, nil
+}
+
+func TestEnrollWithBoundChallenge(t *testing.T) {
+	challenges := new(challengeRecorder)
+	svc := &service{
+		URL:                "https://mdm.example.org",
+		SCEPURL:            "https://mdm.example.org/scep",
+		SCEPChallengeStore: challenges,
+		SCEPSubject:        [][][]string{{{"CN", "device"}}},
+	}
+
+	ctx := withSerial(context.Background(), "C02ABC")
+	p, err := svc.makeEnrollmentProfile(ctx, new(enrollment.Template), url.Values{invitation.TokenParam: {"token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(challenges.opts), 1; have != want {
+		t.Fatalf("have %d challenges, want %d", have, want)
+	}
+	if have, want := challenges.opts[0], (challenge.CreateChallengeOption{Serial: "C02ABC", Invitation: "token"}); have != want {
+		t.Errorf("have %+v, want %+v", have, want)
+	}
+
+	content := scepPayloadContent(t, p)
+	if have, want := content.Challenge, "dynamic"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := len(content.Subject), 2; have != want {
+		t.Fatalf("have %d subject attributes, want %d", have, want)
+	}
+	if have, want := content.Subject[1][0][1], "C02ABC"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := len(svc.SCEPSubject), 1; have != want {
+		t.Errorf("the default subject was changed to %v", svc.SCEPSubject)
+	}
+
+	// renewal profiles use the challenge of the renewal.
+	ctx = challenge.NewContext(context.Background(), "renewal")
+	p, err = svc.makeEnrollmentProfile(ctx, new(enrollment.Template), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(challenges.opts), 1; have != want {
+		t.Errorf("have %d challenges, want %d", have, want)
+	}
+	if have, want := scepPayloadContent(t, p).Challenge, "renewal"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+}
+
+func scepPayloadContent(t *testing.T, p Profile) SCEPPayloadContent {
+	t.Helper()
+	for _, payload := range p.PayloadContent {
+		if payload, ok := payload.(Payload); ok && payload.PayloadType == "com.apple.security.scep" {
+			return payload.PayloadContent.(SCEPPayloadContent)
+		}
+	}
+	t.Fatal("enrollment profile has no SCEP payload")
+	return SCEPPayloadContent{}
+}
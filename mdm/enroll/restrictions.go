@@ -0,0 +1,206 @@
+package enroll
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/groob/plist"
+	"github.com/pkg/errors"
+	"go.mozilla.org/pkcs7"
+
+	"github.com/micromdm/micromdm/pkg/crypto"
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// MachineInfo is the device information which devices send, signed by the
+// Apple Device CA, when they request the enrollment profile in Setup
+// Assistant.
+type MachineInfo struct {
+	Language string `plist:"LANGUAGE"`
+	Product  string `plist:"PRODUCT"`
+	Serial   string `plist:"SERIAL"`
+	UDID     string `plist:"UDID"`
+	Version  string `plist:"VERSION"` // build version
+	IMEI     string `plist:"IMEI,omitempty"`
+	MEID     string `plist:"MEID,omitempty"`
+	// OSVersion is only sent by iOS 17, macOS 14 and later.
+	OSVersion string `plist:"OS_VERSION,omitempty"`
+}
+
+// MachineInfoHeader is the header with the base64 encoded MachineInfo which
+// devices send with GET requests for the enrollment profile.
+const MachineInfoHeader = "x-apple-aspen-deviceinfo"
+
+// ParseMachineInfo parses the CMS signed MachineInfo and verifies that it is
+// signed by the Apple Device CA.
+func ParseMachineInfo(data []byte) (*MachineInfo, error) {
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse machine info")
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, errors.Wrap(err, "verify machine info")
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return nil, errors.New("invalid CMS signer during enrollment")
+	}
+	if err := crypto.VerifyFromAppleDeviceCA(signer); err != nil {
+		return nil, errors.New("unauthorized enrollment client: not signed by Apple Device CA")
+	}
+	var info MachineInfo
+	if err := plist.Unmarshal(p7.Content, &info); err != nil {
+		return nil, errors.Wrap(err, "unmarshal machine info")
+	}
+	return &info, nil
+}
+
+// machineInfoFromHeader returns the MachineInfo of the request header, or
+// nil if there is none.
+func machineInfoFromHeader(r *http.Request) (*MachineInfo, error) {
+	header := r.Header.Get(MachineInfoHeader)
+	if header == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode machine info header")
+	}
+	return ParseMachineInfo(data)
+}
+
+// DeviceStore looks up the devices synced from DEP.
+type DeviceStore interface {
+	DeviceBySerial(ctx context.Context, serial string) (*device.Device, error)
+}
+
+// Restrictions limit which devices may enroll with MachineInfo. Devices
+// which don't send their MachineInfo are refused. Empty restrictions allow
+// all devices which send it.
+type Restrictions struct {
+	// Serials allows only the devices with these serial numbers.
+	Serials []string
+	// DEPDevices allows only the devices synced from DEP, if set.
+	DEPDevices DeviceStore
+	// Models allows only these products, for example "MacBookPro18,3". A
+	// trailing * matches any suffix, as in "iPhone*".
+	Models []string
+	// MinOSVersion is the minimum OS version, for example "14.0". Devices
+	// which don't send their OS version are refused.
+	MinOSVersion string
+}
+
+// WithRestrictions refuses enrollment of the devices which don't satisfy r.
+func WithRestrictions(r *Restrictions) Option {
+	return func(svc *service) {
+		svc.Restrictions = r
+	}
+}
+
+// RefusedError is returned if a device may not enroll. Setup Assistant shows
+// the description to the user.
+type RefusedError struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+func (e *RefusedError) Error() string {
+	return "enrollment refused: " + e.Description
+}
+
+func (e *RefusedError) StatusCode() int {
+	return http.StatusForbidden
+}
+
+func (e *RefusedError) MarshalJSON() ([]byte, error) {
+	type refusedError RefusedError
+	return json.Marshal((*refusedError)(e))
+}
+
+// Error codes of RefusedError.
+const (
+	RefusedSerial    = "com.github.micromdm.enroll.serial"
+	RefusedModel     = "com.github.micromdm.enroll.model"
+	RefusedOSVersion = "com.github.micromdm.enroll.osversion"
+	RefusedUnknown   = "com.github.micromdm.enroll.unknown"
+)
+
+// CheckMachineInfo returns a RefusedError if the device may not enroll. info
+// is nil if the device didn't send its MachineInfo.
+func (svc *service) CheckMachineInfo(ctx context.Context, info *MachineInfo) error {
+	r := svc.Restrictions
+	if r == nil {
+		return nil
+	}
+	if info == nil {
+		return &RefusedError{RefusedUnknown, "Enroll this device in Setup Assistant or with its device information."}
+	}
+	if len(r.Serials) > 0 && !contains(r.Serials, info.Serial) {
+		return &RefusedError{RefusedSerial, fmt.Sprintf("This device (%s) is not allowed to enroll.", info.Serial)}
+	}
+	if r.DEPDevices != nil {
+		dev, err := r.DEPDevices.DeviceBySerial(ctx, info.Serial)
+		if err != nil && !isNotFound(err) {
+			return errors.Wrap(err, "get device by serial")
+		}
+		if dev == nil || dev.DEPProfileStatus == "" {
+			return &RefusedError{RefusedSerial, fmt.Sprintf("This device (%s) is not assigned to this organization.", info.Serial)}
+		}
+	}
+	if len(r.Models) > 0 && !matchModel(r.Models, info.Product) {
+		return &RefusedError{RefusedModel, fmt.Sprintf("This model (%s) is not allowed to enroll.", info.Product)}
+	}
+	if r.MinOSVersion != "" && (info.OSVersion == "" || compareVersions(info.OSVersion, r.MinOSVersion) < 0) {
+		return &RefusedError{RefusedOSVersion, fmt.Sprintf("Update this device to version %s or later to enroll.", r.MinOSVersion)}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchModel(models []string, product string) bool {
+	for _, m := range models {
+		if m == product || (strings.HasSuffix(m, "*") && strings.HasPrefix(product, strings.TrimSuffix(m, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareVersions compares dotted versions like "14.2.1" numerically.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(interface{ NotFound() bool })
+	return ok && e.NotFound()
+}
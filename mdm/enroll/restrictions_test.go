@@ -0,0 +1,98 @@
+package enroll
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+type deviceList []device.Device
+
+func (l deviceList) DeviceBySerial(ctx context.Context, serial string) (*device.Device, error) {
+	for i := range l {
+		if l[i].SerialNumber == serial {
+			return &l[i], nil
+		}
+	}
+	return nil, notFoundError{}
+}
+
+func TestCheckMachineInfo(t *testing.T) {
+	restrictions := &Restrictions{
+		Serials: []string{"C02ABC", "C02DEF", "C02GHI"},
+		DEPDevices: deviceList{
+			{SerialNumber: "C02ABC", DEPProfileStatus: device.ASSIGNED},
+			{SerialNumber: "C02DEF"},
+		},
+		Models:       []string{"MacBookPro18,3", "Mac14*"},
+		MinOSVersion: "14.1",
+	}
+	svc := &service{Restrictions: restrictions}
+
+	tests := []struct {
+		info MachineInfo
+		code string
+	}{
+		{MachineInfo{Serial: "C02ABC", Product: "MacBookPro18,3", OSVersion: "14.1"}, ""},
+		{MachineInfo{Serial: "C02ABC", Product: "Mac14,2", OSVersion: "14.10"}, ""},
+		{MachineInfo{Serial: "C02XYZ", Product: "Mac14,2", OSVersion: "14.1"}, RefusedSerial},
+		// known to the server, but not synced from DEP.
+		{MachineInfo{Serial: "C02DEF", Product: "Mac14,2", OSVersion: "14.1"}, RefusedSerial},
+		{MachineInfo{Serial: "C02GHI", Product: "Mac14,2", OSVersion: "14.1"}, RefusedSerial},
+		{MachineInfo{Serial: "C02ABC", Product: "MacBookPro18,4", OSVersion: "14.1"}, RefusedModel},
+		{MachineInfo{Serial: "C02ABC", Product: "Mac14,2", OSVersion: "14.0.1"}, RefusedOSVersion},
+		{MachineInfo{Serial: "C02ABC", Product: "Mac14,2"}, RefusedOSVersion},
+	}
+	for _, tt := range tests {
+		err := svc.CheckMachineInfo(context.Background(), &tt.info)
+		var code string
+		if err != nil {
+			refused, ok := err.(*RefusedError)
+			if !ok {
+				t.Fatalf("%s: unexpected error %v", tt.info.Serial, err)
+			}
+			code = refused.Code
+		}
+		if have, want := code, tt.code; have != want {
+			t.Errorf("%+v: have %q, want %q", tt.info, have, want)
+		}
+	}
+}
+
+func TestMissingMachineInfo(t *testing.T) {
+	svc := &service{Restrictions: &Restrictions{Models: []string{"Mac14*"}}}
+	refused := func(err error) bool {
+		e, ok := err.(*RefusedError)
+		return ok && e.Code == RefusedUnknown
+	}
+
+	if err := svc.CheckMachineInfo(context.Background(), nil); !refused(err) {
+		t.Errorf("have %v, want the device to be refused", err)
+	}
+
+	// a GET of the enrollment profile without the MachineInfoHeader.
+	_, err := MakeGetEnrollEndpoint(svc)(context.Background(), mdmEnrollRequest{})
+	if !refused(err) {
+		t.Errorf("enroll: have %v, want the device to be refused", err)
+	}
+
+	users := userList{{UserShortname: "jane", PasswordHash: passwordHash(t, "secret")}}
+	h := MakeConfigurationWebHandler(svc, NewLocalUserAuthenticator(users, ""), log.NewNopLogger())
+	for _, method := range []string{"GET", "POST"} {
+		form := url.Values{"username": {"jane"}, "password": {"secret"}}
+		req := httptest.NewRequest(method, ConfigurationWebPath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if have, want := rec.Code, http.StatusForbidden; have != want {
+			t.Errorf("setup %s: have status %d, want %d", method, have, want)
+		}
+	}
+}
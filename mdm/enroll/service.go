@@ -3,15 +3,20 @@ package enroll
 import (
 	"bytes"
 	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 
+	"github.com/micromdm/micromdm/platform/challenge"
 	"github.com/micromdm/micromdm/platform/config"
+	"github.com/micromdm/micromdm/platform/enrollment"
+	"github.com/micromdm/micromdm/platform/invitation"
 	"github.com/micromdm/micromdm/platform/profile"
 	"github.com/micromdm/micromdm/platform/pubsub"
-	"github.com/micromdm/scep/v2/challenge"
 
 	"github.com/groob/plist"
 	"github.com/pkg/errors"
@@ -26,11 +31,46 @@ const (
 type Service interface {
 	Enroll(ctx context.Context) (profile.Mobileconfig, error)
 	OTAEnroll(ctx context.Context) (profile.Mobileconfig, error)
-	OTAPhase2(ctx context.Context) (profile.Mobileconfig, error)
+	OTAPhase2(ctx context.Context, device OTADevice) (profile.Mobileconfig, error)
 	OTAPhase3(ctx context.Context) (profile.Mobileconfig, error)
+	AccountEnroll(ctx context.Context, mode string, identity AccountIdentity) (profile.Mobileconfig, error)
+	SetupAssistantEnroll(ctx context.Context, template, group string, identity AccountIdentity) (profile.Mobileconfig, error)
+	EnrollWithParams(ctx context.Context, params url.Values) (profile.Mobileconfig, error)
+	EnrollWithTemplate(ctx context.Context, name string, params url.Values) (profile.Mobileconfig, error)
+	CheckMachineInfo(ctx context.Context, info *MachineInfo) error
 }
 
-func NewService(topic TopicProvider, sub pubsub.Subscriber, scepURL, scepChallenge, url, tlsCertPath, scepSubject string, profileDB profile.Store, challengeStore challenge.Store) (Service, error) {
+// TemplateStore looks up the enrollment templates selected with
+// ?template=name.
+type TemplateStore interface {
+	TemplateByName(ctx context.Context, name string) (*enrollment.Template, error)
+}
+
+// Option configures the enrollment service.
+type Option func(*service)
+
+// WithACME makes enrollment profiles request the device identity certificate
+// from the ACME directory at directoryURL instead of the SCEP server. The
+// ClientIdentifier of the ACME payload is the SCEP challenge. hardwareBound
+// requests the key to be generated in the Secure Enclave. The server doesn't
+// verify the attestation, so it can't tell whether the device complied.
+func WithACME(directoryURL string, hardwareBound bool) Option {
+	return func(svc *service) {
+		svc.ACMEDirectoryURL = directoryURL
+		svc.ACMEHardwareBound = hardwareBound
+	}
+}
+
+// WithClientCertAuth makes enrollment profiles set SignMessage to false, so
+// that devices authenticate check-in and connect requests with their identity
+// as TLS client certificate instead of the Mdm-Signature header.
+func WithClientCertAuth() Option {
+	return func(svc *service) {
+		svc.ClientCertAuth = true
+	}
+}
+
+func NewService(topic TopicProvider, sub pubsub.Subscriber, scepURL, scepChallenge, url, tlsCertPath, scepSubject string, profileDB profile.Store, challengeStore ChallengeStore, templateStore TemplateStore, opts ...Option) (Service, error) {
 	var tlsCert []byte
 	var err error
 
@@ -43,18 +83,12 @@ func NewService(topic TopicProvider, sub pubsub.Subscriber, scepURL, scepChallen
 	}
 
 	if scepSubject == "" {
-		scepSubject = "/O=MicroMDM/CN=MicroMDM Identity (%ComputerName%)"
+		scepSubject = defaultSCEPSubject
 	}
 
-	subjectElements := strings.Split(scepSubject, "/")
-	var subject [][][]string
-
-	for _, element := range subjectElements {
-		if element == "" {
-			continue
-		}
-		subjectKeyValue := strings.Split(element, "=")
-		subject = append(subject, [][]string{{subjectKeyValue[0], subjectKeyValue[1]}})
+	subject, err := parseSCEPSubject(scepSubject)
+	if err != nil {
+		return nil, err
 	}
 
 	// fetch the push topic from the db.
@@ -68,9 +102,13 @@ func NewService(topic TopicProvider, sub pubsub.Subscriber, scepURL, scepChallen
 		SCEPChallengeStore: challengeStore,
 		TLSCert:            tlsCert,
 		ProfileDB:          profileDB,
+		Templates:          templateStore,
 		Topic:              pushTopic,
 		topicProvier:       topic,
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
 
 	if err := updateTopic(svc, sub); err != nil {
 		return nil, errors.Wrap(err, "enroll: start topic update goroutine")
@@ -79,6 +117,25 @@ func NewService(topic TopicProvider, sub pubsub.Subscriber, scepURL, scepChallen
 	return svc, nil
 }
 
+const defaultSCEPSubject = "/O=MicroMDM/CN=MicroMDM Identity (%ComputerName%)"
+
+// parseSCEPSubject parses a subject like "/O=MicroMDM/CN=Identity" into the
+// Subject of a SCEP payload.
+func parseSCEPSubject(s string) ([][][]string, error) {
+	var subject [][][]string
+	for _, element := range strings.Split(s, "/") {
+		if element == "" {
+			continue
+		}
+		kv := strings.SplitN(element, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid SCEP subject %q: %q is not in the form key=value", s, element)
+		}
+		subject = append(subject, [][]string{{kv[0], kv[1]}})
+	}
+	return subject, nil
+}
+
 func updateTopic(svc *service, sub pubsub.Subscriber) error {
 	configEvents, err := sub.Subscribe(context.TODO(), "enroll-server-configs", config.ConfigTopic)
 	if err != nil {
@@ -110,10 +167,18 @@ type service struct {
 	URL                string
 	SCEPURL            string
 	SCEPChallenge      string
-	SCEPChallengeStore challenge.Store
+	SCEPChallengeStore ChallengeStore
 	SCEPSubject        [][][]string
 	TLSCert            []byte
 	ProfileDB          profile.Store
+	Templates          TemplateStore
+	ACMEDirectoryURL   string
+	ACMEHardwareBound  bool
+	ClientCertAuth     bool
+	Restrictions       *Restrictions
+	OTAStrict          bool
+	Publisher          pubsub.Publisher
+	Invitations        invitation.Store
 
 	topicProvier TopicProvider
 
@@ -121,6 +186,11 @@ type service struct {
 	Topic string // APNS Topic for MDM notifications
 }
 
+// ChallengeStore creates the dynamic SCEP challenges of enrollment profiles.
+type ChallengeStore interface {
+	CreateChallenge(ctx context.Context, opt challenge.CreateChallengeOption) (*challenge.Challenge, error)
+}
+
 type TopicProvider interface {
 	PushTopic() (string, error)
 }
@@ -161,23 +231,98 @@ func (svc *service) findOrMakeMobileconfig(ctx context.Context, id string, f int
 }
 
 func (svc *service) Enroll(ctx context.Context) (profile.Mobileconfig, error) {
-	return svc.findOrMakeMobileconfig(ctx, EnrollmentProfileId, svc.MakeEnrollmentProfile)
+	return svc.findOrMakeMobileconfig(ctx, EnrollmentProfileId, func() (Profile, error) {
+		return svc.makeEnrollmentProfile(ctx, new(enrollment.Template), nil)
+	})
+}
+
+// EnrollWithParams returns an enrollment profile whose CheckInURL carries
+// params. The device sends them with every check-in, where they end up in
+// the Params of the CheckinEvent. Unlike Enroll, the profile is always
+// generated, as a stored enrollment profile can't be changed.
+func (svc *service) EnrollWithParams(ctx context.Context, params url.Values) (profile.Mobileconfig, error) {
+	return svc.EnrollWithTemplate(ctx, "", params)
+}
+
+// EnrollWithTemplate returns an enrollment profile made from the named
+// enrollment template, with params in its CheckInURL. The built-in
+// enrollment profile is used if name is empty.
+func (svc *service) EnrollWithTemplate(ctx context.Context, name string, params url.Values) (profile.Mobileconfig, error) {
+	t := new(enrollment.Template)
+	if name != "" {
+		if svc.Templates == nil {
+			return nil, templateNotFoundError{name}
+		}
+		var err error
+		t, err = svc.Templates.TemplateByName(ctx, name)
+		if enrollment.IsNotFound(err) {
+			return nil, templateNotFoundError{name}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	p, err := svc.makeEnrollmentProfile(ctx, t, params)
+	if err != nil {
+		return nil, err
+	}
+	return profileOrPayloadToMobileconfig(withCheckInParams(p, params))
+}
+
+type templateNotFoundError struct {
+	name string
+}
+
+func (e templateNotFoundError) Error() string {
+	return fmt.Sprintf("enrollment template %s not found", e.name)
+}
+
+func (e templateNotFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// withCheckInParams adds params to the CheckInURL of the MDM payload of p.
+func withCheckInParams(p Profile, params url.Values) Profile {
+	if len(params) == 0 {
+		return p
+	}
+	for i, payload := range p.PayloadContent {
+		mdmPayload, ok := payload.(MDMPayloadContent)
+		if !ok {
+			continue
+		}
+		mdmPayload.CheckInURL += "?" + params.Encode()
+		p.PayloadContent[i] = mdmPayload
+	}
+	return p
 }
 
 const perUserConnections = "com.apple.mdm.per-user-connections"
 const bootstrapToken = "com.apple.mdm.bootstraptoken"
 
 func (svc *service) MakeEnrollmentProfile() (Profile, error) {
+	return svc.makeEnrollmentProfile(context.Background(), new(enrollment.Template), nil)
+}
+
+// makeEnrollmentProfile makes the enrollment profile from t. Empty fields of
+// t keep the defaults. The dynamic SCEP challenge is bound to the serial
+// number of the device requesting the profile and to the invitation in
+// params, if any.
+func (svc *service) makeEnrollmentProfile(ctx context.Context, t *enrollment.Template, params url.Values) (Profile, error) {
+	organization := valueOrDefault(t.Organization, "MicroMDM")
+
 	profile := NewProfile()
 	profile.PayloadIdentifier = EnrollmentProfileId
-	profile.PayloadOrganization = "MicroMDM"
-	profile.PayloadDisplayName = "Enrollment Profile"
-	profile.PayloadDescription = "The server may alter your settings"
+	profile.PayloadOrganization = organization
+	profile.PayloadDisplayName = valueOrDefault(t.DisplayName, "Enrollment Profile")
+	profile.PayloadDescription = valueOrDefault(t.Description, "The server may alter your settings")
 	profile.PayloadScope = "System"
+	if t.ConsentText != "" {
+		profile.ConsentText = map[string]string{"default": t.ConsentText}
+	}
 
 	mdmPayload := NewPayload("com.apple.mdm")
 	mdmPayload.PayloadDescription = "Enrolls with the MDM server"
-	mdmPayload.PayloadOrganization = "MicroMDM"
+	mdmPayload.PayloadOrganization = organization
 	mdmPayload.PayloadIdentifier = EnrollmentProfileId + ".mdm"
 	mdmPayload.PayloadScope = "System"
 
@@ -185,44 +330,103 @@ func (svc *service) MakeEnrollmentProfile() (Profile, error) {
 	topic := svc.Topic
 	svc.mu.Unlock()
 
+	accessRights := allRights()
+	if t.AccessRights != 0 {
+		accessRights = AccessRights(t.AccessRights)
+	}
+
 	mdmPayloadContent := MDMPayloadContent{
 		Payload:             *mdmPayload,
-		AccessRights:        allRights(),
+		AccessRights:        accessRights,
 		CheckInURL:          svc.URL + "/mdm/checkin",
 		CheckOutWhenRemoved: true,
 		ServerURL:           svc.URL + "/mdm/connect",
 		Topic:               topic,
-		SignMessage:         true,
+		SignMessage:         !svc.ClientCertAuth,
 		ServerCapabilities:  []string{perUserConnections, bootstrapToken},
 	}
 
 	payloadContent := []interface{}{}
 
-	if svc.SCEPURL != "" {
-		scepContent := SCEPPayloadContent{
-			URL:      svc.SCEPURL,
-			Keysize:  2048,
-			KeyType:  "RSA",
-			KeyUsage: int(x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment),
-			Name:     "Device Management Identity Certificate",
-			Subject:  svc.SCEPSubject,
+	subject := svc.SCEPSubject
+	if t.SCEPSubject != "" {
+		var err error
+		subject, err = parseSCEPSubject(t.SCEPSubject)
+		if err != nil {
+			return *profile, err
+		}
+	}
+	keysize := 2048
+	if t.SCEPKeySize != 0 {
+		keysize = t.SCEPKeySize
+	}
+
+	if svc.ACMEDirectoryURL != "" {
+		// the binding of ACME challenges can't be checked.
+		chal, err := svc.challenge(ctx, challenge.CreateChallengeOption{})
+		if err != nil {
+			return *profile, err
+		}
+		acmeContent := ACMEPayloadContent{
+			DirectoryURL:     svc.ACMEDirectoryURL,
+			ClientIdentifier: chal,
+			KeySize:          keysize,
+			KeyType:          "RSA",
+			Subject:          subject,
+			UsageFlags:       int(x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment),
+			ExtendedKeyUsage: []string{clientAuthOID},
+		}
+		if svc.ACMEHardwareBound {
+			// the Secure Enclave only generates P-384 or P-256 keys.
+			acmeContent.HardwareBound = true
+			acmeContent.KeyType = "ECSECPrimeRandom"
+			acmeContent.KeySize = 384
 		}
 
-		if svc.SCEPChallengeStore != nil {
-			challenge, err := svc.SCEPChallengeStore.SCEPChallenge()
+		acmePayload := NewPayload("com.apple.security.acme")
+		acmePayload.PayloadDescription = "Configures ACME"
+		acmePayload.PayloadDisplayName = "ACME"
+		acmePayload.PayloadIdentifier = EnrollmentProfileId + ".acme"
+		acmePayload.PayloadOrganization = organization
+		acmePayload.PayloadContent = acmeContent
+		acmePayload.PayloadScope = "System"
+
+		payloadContent = append(payloadContent, *acmePayload)
+		mdmPayloadContent.IdentityCertificateUUID = acmePayload.PayloadUUID
+	} else if svc.SCEPURL != "" {
+		// renewal profiles carry the challenge of the renewal.
+		chal, ok := challenge.FromContext(ctx)
+		var bind challenge.CreateChallengeOption
+		if !ok {
+			bind = challenge.CreateChallengeOption{
+				Serial:     serialFromContext(ctx),
+				Invitation: params.Get(invitation.TokenParam),
+			}
+			var err error
+			chal, err = svc.challenge(ctx, bind)
 			if err != nil {
 				return *profile, err
 			}
-			scepContent.Challenge = challenge
-		} else if svc.SCEPChallenge != "" {
-			scepContent.Challenge = svc.SCEPChallenge
+		}
+		if svc.SCEPChallengeStore != nil && bind.Serial != "" {
+			// the serial number must be in the certificate request.
+			subject = append(append([][][]string{}, subject...), [][]string{{challenge.SerialNumberOID, bind.Serial}})
+		}
+		scepContent := SCEPPayloadContent{
+			URL:       svc.SCEPURL,
+			Challenge: chal,
+			Keysize:   keysize,
+			KeyType:   "RSA",
+			KeyUsage:  int(x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment),
+			Name:      "Device Management Identity Certificate",
+			Subject:   subject,
 		}
 
 		scepPayload := NewPayload("com.apple.security.scep")
 		scepPayload.PayloadDescription = "Configures SCEP"
 		scepPayload.PayloadDisplayName = "SCEP"
 		scepPayload.PayloadIdentifier = EnrollmentProfileId + ".scep"
-		scepPayload.PayloadOrganization = "MicroMDM"
+		scepPayload.PayloadOrganization = organization
 		scepPayload.PayloadContent = scepContent
 		scepPayload.PayloadScope = "System"
 
@@ -243,11 +447,70 @@ func (svc *service) MakeEnrollmentProfile() (Profile, error) {
 		payloadContent = append(payloadContent, *tlsPayload)
 	}
 
+	for i := range t.CACertificates {
+		der, err := t.CACertificate(i)
+		if err != nil {
+			return *profile, err
+		}
+		caPayload := NewPayload("com.apple.security.root")
+		caPayload.PayloadDisplayName = fmt.Sprintf("Root certificate %d", i+1)
+		caPayload.PayloadDescription = "Installs a trusted root certificate"
+		caPayload.PayloadIdentifier = fmt.Sprintf("%s.cert.root.%d", EnrollmentProfileId, i)
+		caPayload.PayloadOrganization = organization
+		caPayload.PayloadContent = der
+
+		payloadContent = append(payloadContent, *caPayload)
+	}
+
+	for i := range t.ExtraPayloads {
+		payload, err := t.ExtraPayload(i)
+		if err != nil {
+			return *profile, err
+		}
+		payloadContent = append(payloadContent, payload)
+	}
+
 	profile.PayloadContent = payloadContent
 
 	return *profile, nil
 }
 
+// clientAuthOID is the TLS client authentication extended key usage.
+const clientAuthOID = "1.3.6.1.5.5.7.3.2"
+
+// challenge returns a dynamic SCEP challenge bound as in opt if the service
+// generates them, or the static SCEP challenge.
+func (svc *service) challenge(ctx context.Context, opt challenge.CreateChallengeOption) (string, error) {
+	if svc.SCEPChallengeStore != nil {
+		c, err := svc.SCEPChallengeStore.CreateChallenge(ctx, opt)
+		if err != nil {
+			return "", err
+		}
+		return c.Challenge, nil
+	}
+	return svc.SCEPChallenge, nil
+}
+
+type serialKey struct{}
+
+// withSerial returns a context with the serial number of the device which
+// requested the enrollment profile.
+func withSerial(ctx context.Context, serial string) context.Context {
+	return context.WithValue(ctx, serialKey{}, serial)
+}
+
+func serialFromContext(ctx context.Context) string {
+	serial, _ := ctx.Value(serialKey{}).(string)
+	return serial
+}
+
+func valueOrDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
 // OTAEnroll returns an Over-the-Air "Profile Service" Payload for enrollment.
 func (svc *service) OTAEnroll(ctx context.Context) (profile.Mobileconfig, error) {
 	return svc.findOrMakeMobileconfig(ctx, OTAProfileId, svc.MakeOTAEnrollPayload)
@@ -269,11 +532,6 @@ func (svc *service) MakeOTAEnrollPayload() (Payload, error) {
 	return *payload, nil
 }
 
-// OTAPhase2 returns a SCEP Profile for use in phase 2 of Over-the-Air enrollment.
-func (svc *service) OTAPhase2(ctx context.Context) (profile.Mobileconfig, error) {
-	return svc.findOrMakeMobileconfig(ctx, OTAProfileId+".phase2", svc.MakeOTAPhase2Profile)
-}
-
 func (svc *service) MakeOTAPhase2Profile() (Profile, error) {
 	profile := NewProfile()
 	profile.PayloadIdentifier = OTAProfileId + ".phase2"
@@ -0,0 +1,129 @@
+package enroll
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/enrollment"
+	"github.com/micromdm/micromdm/platform/invitation"
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+// ConfigurationWebPath is the sign in page for DEP enrollment. Set the
+// configuration_web_url of a DEP profile to it to have users sign in at
+// Setup Assistant before the enrollment profile is installed.
+const ConfigurationWebPath = "/mdm/setup"
+
+// MakeConfigurationWebHandler returns the handler of ConfigurationWebPath.
+// Users sign in with the authenticator and the device gets the enrollment
+// profile of the template and group query parameters, with a sign in
+// invitation for the user so that the primary account is prefilled with it.
+func MakeConfigurationWebHandler(svc Service, auth Authenticator, logger log.Logger) http.Handler {
+	return &configurationWeb{svc: svc, auth: auth, logger: logger}
+}
+
+type configurationWeb struct {
+	svc    Service
+	auth   Authenticator
+	logger log.Logger
+}
+
+func (c *configurationWeb) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Setup Assistant sends the MachineInfo when it opens the page. The
+	// sign in form posts it back, as the form submission doesn't carry the
+	// header.
+	var data loginPageData
+	data.MachineInfo = r.Header.Get(MachineInfoHeader)
+	if r.Method == http.MethodPost && data.MachineInfo == "" {
+		data.MachineInfo = r.PostFormValue(machineInfoFormField)
+	}
+	if !c.checkMachineInfo(w, r, data.MachineInfo) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, data)
+		return
+	}
+
+	data.Username = r.PostFormValue("username")
+	identity, err := c.auth.Authenticate(r.Context(), data.Username, r.PostFormValue("password"))
+	if err != nil {
+		level.Info(c.logger).Log("msg", "setup assistant sign in", "user", data.Username, "err", err)
+		data.Error = ErrInvalidCredentials.Error()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		loginPage.Execute(w, data)
+		return
+	}
+
+	// the form posts to the page URL, so the query is the one of the DEP
+	// profile.
+	query := r.URL.Query()
+	mc, err := c.svc.SetupAssistantEnroll(r.Context(), query.Get(enrollment.TemplateParam), query.Get(device.GroupParam), *identity)
+	if err != nil {
+		level.Info(c.logger).Log("msg", "setup assistant enrollment", "user", identity.Username, "err", err)
+		code := http.StatusInternalServerError
+		if sc, ok := err.(interface{ StatusCode() int }); ok {
+			code = sc.StatusCode()
+		}
+		http.Error(w, "enrollment failed", code)
+		return
+	}
+	level.Info(c.logger).Log("msg", "setup assistant enrollment", "user", identity.Username)
+	w.Header().Set("Content-Type", "application/x-apple-aspen-config")
+	w.Write(mc)
+}
+
+// SetupAssistantEnroll returns the enrollment profile of the named template
+// and group for a user who signed in at Setup Assistant. The user is recorded
+// as sign in invitation, which passes the user on to the check-ins of the
+// device.
+func (svc *service) SetupAssistantEnroll(ctx context.Context, template, group string, identity AccountIdentity) (profile.Mobileconfig, error) {
+	inv, err := invitation.NewInvitation("", group, signInTTL)
+	if err != nil {
+		return nil, err
+	}
+	inv.SetupUser = identity.Username
+	inv.SetupFullName = identity.FullName
+	params, err := svc.signInParams(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	return svc.EnrollWithTemplate(ctx, template, params)
+}
+
+// checkMachineInfo verifies the base64 encoded MachineInfo, which may be
+// empty, and writes the error response if the device may not enroll.
+func (c *configurationWeb) checkMachineInfo(w http.ResponseWriter, r *http.Request, encoded string) bool {
+	var info *MachineInfo
+	if encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil {
+			info, err = ParseMachineInfo(data)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+	}
+	if err := c.svc.CheckMachineInfo(r.Context(), info); err != nil {
+		var serial string
+		if info != nil {
+			serial = info.Serial
+		}
+		level.Info(c.logger).Log("msg", "setup assistant enrollment", "serial", serial, "err", err)
+		if refused, ok := err.(*RefusedError); ok {
+			http.Error(w, refused.Description, refused.StatusCode())
+			return false
+		}
+		http.Error(w, "enrollment failed", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
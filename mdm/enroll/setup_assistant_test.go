@@ -0,0 +1,69 @@
+package enroll
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+func TestConfigurationWeb(t *testing.T) {
+	users := userList{{UserShortname: "jane", UserLongname: "Jane Appleseed", PasswordHash: passwordHash(t, "secret")}}
+	invitations := new(invitationStore)
+	svc := &service{URL: "https://mdm.example.org", Invitations: invitations}
+	h := MakeConfigurationWebHandler(svc, NewLocalUserAuthenticator(users, ""), log.NewNopLogger())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", ConfigurationWebPath+"?group=lab", nil))
+	if have, want := rec.Code, http.StatusOK; have != want {
+		t.Fatalf("have status %d, want %d", have, want)
+	}
+	if !strings.Contains(rec.Body.String(), `<form method="post">`) {
+		t.Errorf("sign in page does not contain the form")
+	}
+
+	signIn := func(username, pass string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {pass}}
+		req := httptest.NewRequest("POST", ConfigurationWebPath+"?group=lab", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if have, want := signIn("jane", "wrong").Code, http.StatusUnauthorized; have != want {
+		t.Errorf("have status %d, want %d", have, want)
+	}
+
+	rec = signIn("jane", "secret")
+	if have, want := rec.Code, http.StatusOK; have != want {
+		t.Fatalf("have status %d, want %d", have, want)
+	}
+	if have, want := rec.Header().Get("Content-Type"), "application/x-apple-aspen-config"; have != want {
+		t.Errorf("have content type %s, want %s", have, want)
+	}
+	// the user is passed to check-ins by the sign in invitation.
+	if have, want := len(invitations.invitations), 1; have != want {
+		t.Fatalf("have %d invitations, want %d", have, want)
+	}
+	inv := invitations.invitations[0]
+	if have, want := inv.SetupUser, "jane"; have != want {
+		t.Errorf("have setup user %s, want %s", have, want)
+	}
+	if have, want := inv.SetupFullName, "Jane Appleseed"; have != want {
+		t.Errorf("have setup full name %s, want %s", have, want)
+	}
+	if have, want := inv.Group, "lab"; have != want {
+		t.Errorf("have group %s, want %s", have, want)
+	}
+	// the CheckInURL is XML escaped in the profile.
+	want := "/mdm/checkin?group=lab&amp;invitation=" + inv.Token
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("enrollment profile does not contain %s", want)
+	}
+	if strings.Contains(rec.Body.String(), "setup_user") {
+		t.Error("the CheckInURL carries the setup user")
+	}
+}
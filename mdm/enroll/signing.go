@@ -0,0 +1,72 @@
+package enroll
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+// ProfileSigner signs mobileconfigs.
+type ProfileSigner interface {
+	Sign(mobileconfig []byte) ([]byte, error)
+}
+
+// SigningMiddleware signs the profiles returned by the enrollment service,
+// so that devices show them as verified.
+func SigningMiddleware(signer ProfileSigner) func(Service) Service {
+	return func(next Service) Service {
+		return &signingMiddleware{next: next, signer: signer}
+	}
+}
+
+type signingMiddleware struct {
+	next   Service
+	signer ProfileSigner
+}
+
+func (mw *signingMiddleware) sign(mc profile.Mobileconfig, err error) (profile.Mobileconfig, error) {
+	if err != nil {
+		return mc, err
+	}
+	signed, err := mw.signer.Sign(mc)
+	return signed, errors.Wrap(err, "sign enrollment profile")
+}
+
+func (mw *signingMiddleware) Enroll(ctx context.Context) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.Enroll(ctx))
+}
+
+func (mw *signingMiddleware) OTAEnroll(ctx context.Context) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.OTAEnroll(ctx))
+}
+
+func (mw *signingMiddleware) OTAPhase2(ctx context.Context, device OTADevice) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.OTAPhase2(ctx, device))
+}
+
+func (mw *signingMiddleware) OTAPhase3(ctx context.Context) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.OTAPhase3(ctx))
+}
+
+func (mw *signingMiddleware) AccountEnroll(ctx context.Context, mode string, identity AccountIdentity) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.AccountEnroll(ctx, mode, identity))
+}
+
+func (mw *signingMiddleware) SetupAssistantEnroll(ctx context.Context, template, group string, identity AccountIdentity) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.SetupAssistantEnroll(ctx, template, group, identity))
+}
+
+func (mw *signingMiddleware) EnrollWithParams(ctx context.Context, params url.Values) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.EnrollWithParams(ctx, params))
+}
+
+func (mw *signingMiddleware) EnrollWithTemplate(ctx context.Context, name string, params url.Values) (profile.Mobileconfig, error) {
+	return mw.sign(mw.next.EnrollWithTemplate(ctx, name, params))
+}
+
+func (mw *signingMiddleware) CheckMachineInfo(ctx context.Context, info *MachineInfo) error {
+	return mw.next.CheckMachineInfo(ctx, info)
+}
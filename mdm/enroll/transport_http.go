@@ -6,7 +6,8 @@ import (
 	"io/ioutil"
 	"net/http"
 
-	"github.com/micromdm/micromdm/pkg/crypto"
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/enrollment"
 
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/groob/plist"
@@ -53,33 +54,30 @@ func decodeEmptyRequest(_ context.Context, _ *http.Request) (interface{}, error)
 func decodeMDMEnrollRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	switch r.Method {
 	case "GET":
-		return mdmEnrollRequest{}, nil
-	case "POST": // DEP request
-		data, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return nil, err
-		}
-		p7, err := pkcs7.Parse(data)
+		info, err := machineInfoFromHeader(r)
 		if err != nil {
 			return nil, err
 		}
-		err = p7.Verify()
+		return mdmEnrollRequest{
+			template:    r.URL.Query().Get(enrollment.TemplateParam),
+			group:       r.URL.Query().Get(device.GroupParam),
+			machineInfo: info,
+		}, nil
+	case "POST": // DEP request
+		data, err := ioutil.ReadAll(r.Body)
 		if err != nil {
 			return nil, err
 		}
 		// TODO: for thse errors provide better feedback as 4xx HTTP status
-		signer := p7.GetOnlySigner()
-		if signer == nil {
-			return nil, errors.New("invalid CMS signer during enrollment")
-		}
-		err = crypto.VerifyFromAppleDeviceCA(signer)
+		info, err := ParseMachineInfo(data)
 		if err != nil {
-			return nil, errors.New("unauthorized enrollment client: not signed by Apple Device CA")
-		}
-		var request depEnrollmentRequest
-		if err := plist.Unmarshal(p7.Content, &request); err != nil {
 			return nil, err
 		}
+		request := depEnrollmentRequest{MachineInfo: *info}
+		// the enrollment URL of DEP profiles may select a template and be
+		// tagged with a group.
+		request.template = r.URL.Query().Get(enrollment.TemplateParam)
+		request.group = r.URL.Query().Get(device.GroupParam)
 		return request, nil
 	default:
 		return nil, errors.New("unknown enrollment method")
@@ -8,8 +8,10 @@ import (
 )
 
 type CommandRequest struct {
-	UDID        string `json:"udid"`
-	CommandUUID string `json:"command_uuid"`
+	UDID string `json:"udid"`
+	// EnrollmentID targets a User Enrollment (BYOD) device, which has no UDID.
+	EnrollmentID string `json:"enrollment_id,omitempty"`
+	CommandUUID  string `json:"command_uuid"`
 	*Command
 }
 
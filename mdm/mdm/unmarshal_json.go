@@ -9,14 +9,16 @@ import (
 
 func (c *CommandRequest) UnmarshalJSON(data []byte) error {
 	var request = struct {
-		UDID        string `json:"udid"`
-		RequestType string `json:"request_type"`
-		CommandUUID string `json:"command_uuid"`
+		UDID         string `json:"udid"`
+		EnrollmentID string `json:"enrollment_id"`
+		RequestType  string `json:"request_type"`
+		CommandUUID  string `json:"command_uuid"`
 	}{}
 	if err := json.Unmarshal(data, &request); err != nil {
 		return errors.Wrap(err, "mdm: unmarshal json command request")
 	}
 	c.UDID = request.UDID
+	c.EnrollmentID = request.EnrollmentID
 	c.Command = &Command{}
 	c.CommandUUID = request.CommandUUID
 	return c.Command.UnmarshalJSON(data)
@@ -5,8 +5,11 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
@@ -29,12 +32,44 @@ func MakeServerEndpoints(s Service) Endpoints {
 	}
 }
 
-func RegisterHTTPHandlers(r *mux.Router, e Endpoints, logger log.Logger) {
+// HTTPOption configures the check-in and connect handlers.
+type HTTPOption func(*httpConfig)
+
+type httpConfig struct {
+	clientCertAuth   bool
+	clientCertHeader string
+}
+
+// WithClientCertAuth takes the device certificate from the TLS client
+// certificate instead of the Mdm-Signature header. Enrollment profiles must
+// set SignMessage to false.
+//
+// If header is set, the certificate is taken from that header instead of the
+// TLS connection, for a reverse proxy which terminates TLS. The header must be
+// set by the proxy and never passed on from the client.
+func WithClientCertAuth(header string) HTTPOption {
+	return func(c *httpConfig) {
+		c.clientCertAuth = true
+		c.clientCertHeader = header
+	}
+}
+
+func RegisterHTTPHandlers(r *mux.Router, e Endpoints, logger log.Logger, opts ...HTTPOption) {
+	var config httpConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	populateDeviceCertificate := populateDeviceCertificateFromSignRequestHeader
+	if config.clientCertAuth {
+		populateDeviceCertificate = populateDeviceCertificateFromTLSPeerCertificates(config.clientCertHeader)
+	}
+
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorEncoder(encodeError),
 		httptransport.ServerErrorLogger(logger),
 		httptransport.ServerBefore(httptransport.PopulateRequestContext),
-		httptransport.ServerBefore(populateDeviceCertificateFromSignRequestHeader),
+		httptransport.ServerBefore(populateDeviceCertificate),
 	}
 
 	r.Methods(http.MethodPut).Path("/mdm/checkin").Handler(httptransport.NewServer(
@@ -83,11 +118,77 @@ func populateDeviceCertificateFromSignRequestHeader(ctx context.Context, r *http
 	return ctx
 }
 
-// TODO: If we ever use Go client cert auth we can use
-//       r.TLS.PeerCertificates to return the client cert. Unnecessary
-//       now as default config is uses Mdm-Signature header method instead
-//       (for better compatilibity with proxies, etc.)
-// func populateDeviceCertificateFromTLSPeerCertificates()
+// populateDeviceCertificateFromTLSPeerCertificates takes the device
+// certificate from header if it is set, or else from the TLS connection.
+func populateDeviceCertificateFromTLSPeerCertificates(header string) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		var (
+			cert *x509.Certificate
+			err  error
+		)
+		switch {
+		case header != "":
+			cert, err = parseClientCertHeader(r.Header.Get(header))
+		case r.TLS != nil && len(r.TLS.PeerCertificates) > 0:
+			cert = r.TLS.PeerCertificates[0]
+		default:
+			err = errors.New("TLS client certificate missing")
+		}
+		ctx = context.WithValue(ctx, ContextKeyDeviceCertificate, cert)
+		ctx = context.WithValue(ctx, ContextKeyDeviceCertificateVerifyError, err)
+		return ctx
+	}
+}
+
+// parseClientCertHeader parses the client certificate of a reverse proxy
+// header. The certificate is URL encoded PEM, as with the
+// $ssl_client_escaped_cert variable of nginx, or base64 encoded DER. In an
+// X-Forwarded-Client-Cert header the certificate is the Cert of the last
+// element, which was added by the proxy in front of the server.
+func parseClientCertHeader(header string) (*x509.Certificate, error) {
+	if header == "" {
+		return nil, errors.New("client certificate header missing")
+	}
+	v := header
+	elems := splitQuoted(header, ',')
+	for _, pair := range splitQuoted(elems[len(elems)-1], ';') {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) == 2 && strings.EqualFold(kv[0], "Cert") {
+			v = strings.Trim(kv[1], `"`)
+		}
+	}
+	v, err := url.PathUnescape(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "unescape client certificate header")
+	}
+	var der []byte
+	if block, _ := pem.Decode([]byte(v)); block != nil {
+		der = block.Bytes
+	} else if der, err = base64.StdEncoding.DecodeString(v); err != nil {
+		return nil, errors.Wrap(err, "decode client certificate header")
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, errors.Wrap(err, "parse client certificate header")
+}
+
+// splitQuoted splits s at sep, except within double quotes.
+func splitQuoted(s string, sep rune) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
 
 // Extract (raw) body bytes, parse property list
 func mdmRequestBody(r *http.Request, s interface{}) ([]byte, error) {
@@ -3,9 +3,12 @@ package mdm
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/micromdm/micromdm/pkg/crypto"
@@ -55,3 +58,58 @@ func Test_mdmMdmSignatureHeader(t *testing.T) {
 		t.Error("certificate mismatch")
 	}
 }
+
+func Test_mdmTLSClientCertificate(t *testing.T) {
+	_, cert, err := crypto.SimpleSelfSignedRSAKeypair("device", 365)
+	if err != nil {
+		t.Fatal(err)
+	}
+	escaped := url.PathEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		tls     bool
+		wantErr bool
+	}{
+		{name: "peer certificate", tls: true},
+		{name: "no peer certificate", wantErr: true},
+		{name: "escaped PEM", header: "X-Client-Cert", value: escaped},
+		{name: "base64 DER", header: "X-Client-Cert", value: base64.StdEncoding.EncodeToString(cert.Raw)},
+		{
+			name:   "forwarded client cert",
+			header: "X-Forwarded-Client-Cert",
+			value:  `By=spiffe://attacker;Cert="invalid",By=spiffe://proxy;Hash=abc;Subject="CN=device,O=Example";Cert="` + escaped + `"`,
+		},
+		{name: "header missing", header: "X-Client-Cert", wantErr: true},
+		{name: "header ignores peer certificate", header: "X-Client-Cert", tls: true, wantErr: true},
+		{name: "invalid header", header: "X-Client-Cert", value: "invalid", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/mdm/checkin", bytes.NewReader([]byte(sampleCheckinRequest)))
+			if tt.value != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+			}
+
+			ctx := populateDeviceCertificateFromTLSPeerCertificates(tt.header)(context.Background(), req)
+			reqcert, err := DeviceCertificateFromContext(ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(cert.Raw, reqcert.Raw) {
+				t.Error("certificate mismatch")
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+// Package migrate copies the data of a BoltDB micromdm.db into an SQL
+// database using the schema from sqlite/migrations or pg/migrations.
+//
+// Every table is filled from a single Bolt bucket. The whole copy runs in one
+// transaction and the row count of every table is checked against the number
+// of rows written before the transaction is committed.
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/apns"
+	"github.com/micromdm/micromdm/platform/blueprint"
+	"github.com/micromdm/micromdm/platform/config"
+	"github.com/micromdm/micromdm/platform/dep/sync"
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/profile"
+	"github.com/micromdm/micromdm/platform/queue"
+	"github.com/micromdm/micromdm/platform/user"
+)
+
+// Result is the outcome of copying a bucket into a table.
+type Result struct {
+	Bucket string
+	Table  string
+	// Records is the number of bucket records which were read.
+	Records int
+	// Rows is the number of rows in the table after the copy.
+	Rows int
+}
+
+// A table is filled by calling insert for each row built from the records in
+// bucket. rows returns the number of records it read.
+type table struct {
+	bucket  string
+	name    string
+	columns []string
+	rows    func(b *bolt.Bucket, insert func(values ...interface{}) error) (records int, err error)
+}
+
+// tables lists the migrated tables in the order they are filled.
+var tables = []table{
+	{
+		bucket: "mdm.Devices",
+		name:   "devices",
+		columns: []string{
+			"uuid", "udid", "serial_number", "os_version", "build_version",
+			"product_name", "imei", "meid", "push_magic", "awaiting_configuration",
+			"token", "unlock_token", "enrolled", "description", "model",
+			"model_name", "device_name", "color", "asset_tag", "dep_profile_status",
+			"dep_profile_uuid", "dep_profile_assign_time", "dep_profile_push_time",
+			"dep_profile_assigned_date", "dep_profile_assigned_by", "last_seen",
+			"bootstrap_token", "supervised", "enrollment_group", "push_invalid",
+			"owner",
+		},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var dev device.Device
+			if err := device.UnmarshalDevice(v, &dev); err != nil {
+				return err
+			}
+			return insert(
+				dev.UUID, dev.UDID, dev.SerialNumber, dev.OSVersion, dev.BuildVersion,
+				dev.ProductName, dev.IMEI, dev.MEID, dev.PushMagic, dev.AwaitingConfiguration,
+				dev.Token, dev.UnlockToken, dev.Enrolled, dev.Description, dev.Model,
+				dev.ModelName, dev.DeviceName, dev.Color, dev.AssetTag, dev.DEPProfileStatus,
+				dev.DEPProfileUUID, dev.DEPProfileAssignTime, dev.DEPProfilePushTime,
+				dev.DEPProfileAssignedDate, dev.DEPProfileAssignedBy, dev.LastSeen,
+				dev.BootstrapToken, dev.Supervised, dev.Group, dev.PushInvalid,
+				dev.Owner,
+			)
+		}),
+	},
+	{
+		bucket: "mdm.UserEnrollments",
+		name:   "user_enrollments",
+		columns: []string{
+			"enrollment_id", "managed_apple_id", "os_version", "build_version",
+			"product_name", "model", "model_name", "device_name", "enrolled", "last_seen",
+		},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var e device.UserEnrollment
+			if err := device.UnmarshalUserEnrollment(v, &e); err != nil {
+				return err
+			}
+			return insert(
+				e.EnrollmentID, e.ManagedAppleID, e.OSVersion, e.BuildVersion,
+				e.ProductName, e.Model, e.ModelName, e.DeviceName, e.Enrolled, e.LastSeen,
+			)
+		}),
+	},
+	{
+		bucket:  "mdm.UDIDCertAuth",
+		name:    "udid_cert_auth",
+		columns: []string{"udid", "cert_hash"},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			return insert(string(k), v)
+		}),
+	},
+	{
+		bucket:  "mdm.SCEPRevocations",
+		name:    "scep_revocations",
+		columns: []string{"issuer", "serial", "revoked_at"},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			revokedAt, err := time.Parse(time.RFC3339Nano, string(v))
+			if err != nil {
+				return errors.Wrap(err, "parse revocation time")
+			}
+			// revocations are stored as "<issuer>.<serial>"
+			key := string(k)
+			i := strings.LastIndex(key, ".")
+			if i < 0 {
+				return errors.Errorf("invalid revocation key %q", key)
+			}
+			return insert(key[:i], key[i+1:], revokedAt)
+		}),
+	},
+	{
+		bucket:  "mdm.PushInfo",
+		name:    "push_info",
+		columns: []string{"udid", "push_magic", "token", "mdm_topic", "invalid", "invalid_reason"},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var info apns.PushInfo
+			if err := apns.UnmarshalPushInfo(v, &info); err != nil {
+				return err
+			}
+			return insert(info.UDID, info.PushMagic, info.Token, info.MDMTopic, info.Invalid, info.InvalidReason)
+		}),
+	},
+	{
+		bucket: "mdm.DeviceCommands",
+		name:   "device_commands",
+		columns: []string{
+			"device_udid", "queue", "position", "uuid", "payload", "created_at",
+			"last_sent_at", "acknowledged", "times_sent", "last_status", "failure_message",
+		},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var dc queue.DeviceCommand
+			if err := queue.UnmarshalDeviceCommand(v, &dc); err != nil {
+				return err
+			}
+			// The queue names must match the ones used by platform/queue/sqlite.
+			queues := []struct {
+				name     string
+				commands []queue.Command
+			}{
+				{"pending", dc.Commands},
+				{"completed", dc.Completed},
+				{"failed", dc.Failed},
+				{"not_now", dc.NotNow},
+			}
+			for _, q := range queues {
+				for i, cmd := range q.commands {
+					err := insert(
+						dc.DeviceUDID, q.name, i, cmd.UUID, cmd.Payload, cmd.CreatedAt,
+						cmd.LastSentAt, cmd.Acknowledged, cmd.TimesSent, cmd.LastStatus, cmd.FailureMessage,
+					)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}),
+	},
+	{
+		bucket:  "mdm.Profile",
+		name:    "profiles",
+		columns: []string{"identifier", "mobileconfig"},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var p profile.Profile
+			if err := profile.UnmarshalProfile(v, &p); err != nil {
+				return err
+			}
+			return insert(p.Identifier, []byte(p.Mobileconfig))
+		}),
+	},
+	{
+		bucket: "mdm.Blueprint",
+		name:   "blueprints",
+		columns: []string{
+			"uuid", "name", "install_application_manifest_urls", "profile_ids", "user_uuids",
+			"skip_primary_setup_account_creation", "set_primary_setup_account_as_regular_user", "apply_at",
+			"enrollment_groups",
+		},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var bp blueprint.Blueprint
+			if err := blueprint.UnmarshalBlueprint(v, &bp); err != nil {
+				return err
+			}
+			var lists [5]string
+			for i, l := range [][]string{bp.ApplicationURLs, bp.ProfileIdentifiers, bp.UserUUID, bp.ApplyAt, bp.Groups} {
+				if l == nil {
+					l = []string{}
+				}
+				data, err := json.Marshal(l)
+				if err != nil {
+					return err
+				}
+				lists[i] = string(data)
+			}
+			return insert(
+				bp.UUID, bp.Name, lists[0], lists[1], lists[2],
+				bp.SkipPrimarySetupAccountCreation, bp.SetPrimarySetupAccountAsRegularUser, lists[3],
+				lists[4],
+			)
+		}),
+	},
+	{
+		bucket: "mdm.Users",
+		name:   "users",
+		columns: []string{
+			"uuid", "udid", "user_id", "user_shortname", "user_longname",
+			"auth_token", "password_hash", "hidden",
+		},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			var u user.User
+			if err := user.UnmarshalUser(v, &u); err != nil {
+				return err
+			}
+			return insert(
+				u.UUID, u.UDID, u.UserID, u.UserShortname, u.UserLongname,
+				u.AuthToken, u.PasswordHash, u.Hidden,
+			)
+		}),
+	},
+	{
+		bucket:  "mdm.ServerConfig",
+		name:    "server_config",
+		columns: []string{"id", "push_certificate", "private_key"},
+		rows: func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+			v := b.Get([]byte("config"))
+			if v == nil {
+				return 0, nil
+			}
+			var conf config.ServerConfig
+			if err := config.UnmarshalServerConfig(v, &conf); err != nil {
+				return 0, err
+			}
+			return 1, insert(1, conf.PushCertificate, conf.PrivateKey)
+		},
+	},
+	{
+		bucket:  "mdm.DEPToken",
+		name:    "dep_tokens",
+		columns: []string{"consumer_key", "token", "added_at"},
+		rows: func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+			// Bolt only remembers which token was added last, and the server
+			// uses the most recently added token. Other tokens get the
+			// epoch as their added_at time.
+			lastAdded := string(b.Get([]byte("last_added")))
+			now := time.Now().UTC()
+			var records int
+			c := b.Cursor()
+			prefix := []byte("CK_")
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				records++
+				addedAt := time.Unix(0, 0).UTC()
+				if string(k) == lastAdded {
+					addedAt = now
+				}
+				if err := insert(string(k), string(v), addedAt); err != nil {
+					return records, err
+				}
+			}
+			return records, nil
+		},
+	},
+	{
+		bucket:  "mdm.DEPToken",
+		name:    "dep_keypair",
+		columns: []string{"id", "key", "certificate"},
+		rows: func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+			key, cert := b.Get([]byte("key")), b.Get([]byte("certificate"))
+			if key == nil || cert == nil {
+				return 0, nil
+			}
+			return 1, insert(1, key, cert)
+		},
+	},
+	{
+		bucket:  "mdm.DEPConfig",
+		name:    "dep_sync_cursor",
+		columns: []string{"id", "value", "created_at"},
+		rows: func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+			v := b.Get([]byte("configuration"))
+			if v == nil {
+				return 0, nil
+			}
+			var cursor struct {
+				Cursor sync.Cursor `json:"cursor"`
+			}
+			if err := json.Unmarshal(v, &cursor); err != nil {
+				return 0, errors.Wrap(err, "unmarshal dep cursor")
+			}
+			return 1, insert(1, cursor.Cursor.Value, cursor.Cursor.CreatedAt)
+		},
+	},
+	{
+		bucket:  "mdm.DEPAutoAssign",
+		name:    "dep_auto_assigners",
+		columns: []string{"filter", "profile_uuid"},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			return insert(string(k), string(v))
+		}),
+	},
+	{
+		bucket:  "scep_certificates",
+		name:    "scep_depot",
+		columns: []string{"id", "ca_certificate", "ca_key", "serial"},
+		rows: func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+			caCert, caKey, serial := b.Get([]byte("ca_certificate")), b.Get([]byte("ca_key")), b.Get([]byte("serial"))
+			if caCert == nil && caKey == nil && serial == nil {
+				return 0, nil
+			}
+			var serialText string
+			if serial != nil {
+				serialText = new(big.Int).SetBytes(serial).String()
+			}
+			return 1, insert(1, caCert, caKey, serialText)
+		},
+	},
+	{
+		bucket:  "scep_certificates",
+		name:    "scep_certificates",
+		columns: []string{"name", "common_name", "serial", "certificate"},
+		rows: func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+			var records int
+			err := b.ForEach(func(k, v []byte) error {
+				name := string(k)
+				// certificates are stored as "<common name>.<serial>"
+				i := strings.LastIndex(name, ".")
+				if i < 0 {
+					return nil // ca_certificate, ca_key and serial
+				}
+				records++
+				return insert(name, name[:i], name[i+1:], v)
+			})
+			return records, err
+		},
+	},
+	{
+		bucket:  "mdm.SCEPPreviousCAs",
+		name:    "scep_previous_cas",
+		columns: []string{"fingerprint", "certificate"},
+		rows: eachRecord(func(k, v []byte, insert func(...interface{}) error) error {
+			return insert(string(k), v)
+		}),
+	},
+}
+
+// derivedBuckets only hold indexes which are rebuilt from the SQL tables.
+var derivedBuckets = map[string]bool{
+	"mdm.DeviceIdx":    true,
+	"mdm.UserIdx":      true,
+	"mdm.BlueprintIdx": true,
+}
+
+// eachRecord returns a table rows function which calls fn for every record
+// of the bucket.
+func eachRecord(fn func(k, v []byte, insert func(...interface{}) error) error) func(*bolt.Bucket, func(...interface{}) error) (int, error) {
+	return func(b *bolt.Bucket, insert func(...interface{}) error) (int, error) {
+		var records int
+		err := b.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil // nested bucket
+			}
+			records++
+			return errors.Wrapf(fn(k, v, insert), "record %q", k)
+		})
+		return records, err
+	}
+}
+
+// Migrate copies all supported buckets from src into dst. Every destination
+// table must be empty. With dryRun the copy is verified and then rolled
+// back, leaving dst unchanged.
+func Migrate(src *bolt.DB, dst *sqlx.DB, dryRun bool) ([]Result, error) {
+	tx, err := dst.Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	for _, t := range tables {
+		rows, err := countRows(tx, t.name)
+		if err != nil {
+			return nil, err
+		}
+		if rows > 0 {
+			return nil, fmt.Errorf("table %s already has %d rows", t.name, rows)
+		}
+	}
+
+	var results []Result
+	err = src.View(func(btx *bolt.Tx) error {
+		for _, t := range tables {
+			result, err := copyTable(btx, tx, t)
+			if err != nil {
+				return errors.Wrapf(err, "copy bucket %s to table %s", t.bucket, t.name)
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return results, err
+	}
+	if dryRun {
+		return results, nil
+	}
+	return results, errors.Wrap(tx.Commit(), "commit migration")
+}
+
+func copyTable(btx *bolt.Tx, tx *sqlx.Tx, t table) (Result, error) {
+	result := Result{Bucket: t.bucket, Table: t.name}
+	var written int
+	insert := func(values ...interface{}) error {
+		query, args, err := sq.
+			Insert(t.name).
+			Columns(t.columns...).
+			Values(values...).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "building sql")
+		}
+		if _, err := tx.Exec(tx.Rebind(query), args...); err != nil {
+			return errors.Wrapf(err, "insert into %s", t.name)
+		}
+		written++
+		return nil
+	}
+
+	if b := btx.Bucket([]byte(t.bucket)); b != nil {
+		records, err := t.rows(b, insert)
+		if err != nil {
+			return result, err
+		}
+		result.Records = records
+	}
+
+	rows, err := countRows(tx, t.name)
+	if err != nil {
+		return result, err
+	}
+	result.Rows = rows
+	if rows != written {
+		return result, fmt.Errorf("verify: wrote %d rows, table has %d", written, rows)
+	}
+	return result, nil
+}
+
+func countRows(tx *sqlx.Tx, table string) (int, error) {
+	query, args, err := sq.Select("COUNT(*)").From(table).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "building sql")
+	}
+	var rows int
+	err = tx.Get(&rows, tx.Rebind(query), args...)
+	return rows, errors.Wrapf(err, "count rows in %s", table)
+}
+
+// SkippedBuckets returns the buckets in src which Migrate does not copy, such
+// as SCEP challenges and the device block list.
+func SkippedBuckets(src *bolt.DB) ([]string, error) {
+	migrated := make(map[string]bool)
+	for _, t := range tables {
+		migrated[t.bucket] = true
+	}
+	var skipped []string
+	err := src.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if !migrated[string(name)] && !derivedBuckets[string(name)] {
+				skipped = append(skipped, string(name))
+			}
+			return nil
+		})
+	})
+	sort.Strings(skipped)
+	return skipped, errors.Wrap(err, "list bolt buckets")
+}
@@ -0,0 +1,223 @@
+package migrate
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/jmoiron/sqlx"
+	boltdepot "github.com/micromdm/scep/v2/depot/bolt"
+
+	"github.com/micromdm/micromdm/platform/device"
+	devicebuiltin "github.com/micromdm/micromdm/platform/device/builtin"
+	devicesqlite "github.com/micromdm/micromdm/platform/device/sqlite"
+	"github.com/micromdm/micromdm/platform/profile"
+	profilebuiltin "github.com/micromdm/micromdm/platform/profile/builtin"
+	profilesqlite "github.com/micromdm/micromdm/platform/profile/sqlite"
+	"github.com/micromdm/micromdm/platform/queue"
+	queuesqlite "github.com/micromdm/micromdm/platform/queue/sqlite"
+	scepbuiltin "github.com/micromdm/micromdm/platform/scep/builtin"
+	scepsqlite "github.com/micromdm/micromdm/platform/scep/sqlite"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+func TestMigrate(t *testing.T) {
+	src := setupBolt(t)
+	dst := setupSQLite(t)
+	ctx := context.Background()
+
+	results, err := Migrate(src, dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]int)
+	for _, r := range results {
+		rows[r.Table] = r.Rows
+	}
+	for table, want := range map[string]int{
+		"devices":           2,
+		"udid_cert_auth":    1,
+		"device_commands":   3,
+		"profiles":          1,
+		"scep_depot":        1,
+		"scep_certificates": 1,
+		"scep_revocations":  1,
+		"users":             0,
+	} {
+		if have := rows[table]; have != want {
+			t.Errorf("%s: have %d rows, want %d", table, have, want)
+		}
+	}
+
+	devices := devicesqlite.New(dst)
+	dev, err := devices.DeviceBySerial(ctx, "serial-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := dev.UDID, "udid-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	hash, err := devices.GetUDIDCertHash([]byte("udid-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(hash), "hash"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	dc, err := queuesqlite.New(dst).DeviceCommand("udid-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(dc.Commands), 2; have != want {
+		t.Fatalf("have %d pending commands, want %d", have, want)
+	}
+	if have, want := dc.Commands[1].UUID, "cmd-2"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := len(dc.Failed), 1; have != want {
+		t.Errorf("have %d failed commands, want %d", have, want)
+	}
+
+	p, err := profilesqlite.New(dst).ProfileById(ctx, "com.example.profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(p.Mobileconfig), testMobileconfig; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	serial, err := scepsqlite.New(dst).Serial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := serial.Int64(), int64(3); have != want {
+		t.Errorf("have serial %d, want %d", have, want)
+	}
+	revoked, err := scepsqlite.New(dst).IsRevoked("issuer", big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("revocation was not migrated")
+	}
+
+	// a second migration must not mix data into a populated database
+	if _, err := Migrate(src, dst, false); err == nil {
+		t.Error("expected migration into a populated database to fail")
+	}
+}
+
+func TestMigrate_dryRun(t *testing.T) {
+	src := setupBolt(t)
+	dst := setupSQLite(t)
+
+	results, err := Migrate(src, dst, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Table == "devices" && r.Rows != 2 {
+			t.Errorf("have %d device rows, want 2", r.Rows)
+		}
+	}
+
+	var rows int
+	if err := dst.Get(&rows, `SELECT COUNT(*) FROM devices`); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("dry run left %d devices behind", rows)
+	}
+}
+
+const testMobileconfig = `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>PayloadIdentifier</key>
+	<string>com.example.profile</string>
+</dict>
+</plist>`
+
+func setupBolt(t *testing.T) *bolt.DB {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "micromdm.db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+
+	devices, err := devicebuiltin.NewDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dev := range []*device.Device{
+		{UUID: "uuid-1", UDID: "udid-1", SerialNumber: "serial-1", LastSeen: time.Now().UTC()},
+		{UUID: "uuid-2", SerialNumber: "serial-2", DEPProfileStatus: device.ASSIGNED},
+	} {
+		if err := devices.Save(ctx, dev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := devices.SaveUDIDCertHash([]byte("udid-1"), []byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(queue.DeviceCommandBucket))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc := &queue.DeviceCommand{DeviceUDID: "udid-1"}
+	dc.Commands = append(dc.Commands, queue.Command{UUID: "cmd-1"}, queue.Command{UUID: "cmd-2"})
+	dc.Failed = append(dc.Failed, queue.Command{UUID: "cmd-0", LastStatus: "Error"})
+	if err := (&queue.Store{DB: db}).Save(dc); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := profilebuiltin.NewDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := profiles.Save(&profile.Profile{Identifier: "com.example.profile", Mobileconfig: []byte(testMobileconfig)}); err != nil {
+		t.Fatal(err)
+	}
+
+	depot, err := boltdepot.NewBoltDepot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := depot.CreateOrLoadKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := depot.CreateOrLoadCA(key, 1, "MicroMDM", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := depot.Put(ca.Subject.CommonName, ca); err != nil {
+		t.Fatal(err)
+	}
+	revocations, err := scepbuiltin.NewDepot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := revocations.Revoke("issuer", big.NewInt(2), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func setupSQLite(t *testing.T) *sqlx.DB {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,130 @@
+-- +goose Up
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS bootstrap_token BYTEA;
+
+CREATE INDEX IF NOT EXISTS devices_udid_idx ON devices (udid);
+CREATE INDEX IF NOT EXISTS devices_serial_number_idx ON devices (serial_number);
+
+
+CREATE TABLE IF NOT EXISTS udid_cert_auth (
+    udid TEXT PRIMARY KEY,
+    cert_hash BYTEA NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS profiles (
+    identifier TEXT PRIMARY KEY,
+    mobileconfig BYTEA NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS blueprints (
+    uuid TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    install_application_manifest_urls TEXT DEFAULT '[]',
+    profile_ids TEXT DEFAULT '[]',
+    user_uuids TEXT DEFAULT '[]',
+    skip_primary_setup_account_creation BOOLEAN DEFAULT false,
+    set_primary_setup_account_as_regular_user BOOLEAN DEFAULT false,
+    apply_at TEXT DEFAULT '[]'
+);
+
+
+CREATE TABLE IF NOT EXISTS users (
+    uuid TEXT PRIMARY KEY,
+    udid TEXT DEFAULT '',
+    user_id TEXT DEFAULT '',
+    user_shortname TEXT DEFAULT '',
+    user_longname TEXT DEFAULT '',
+    auth_token TEXT DEFAULT '',
+    password_hash BYTEA,
+    hidden BOOLEAN DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS users_udid_idx ON users (udid);
+CREATE INDEX IF NOT EXISTS users_user_id_idx ON users (user_id);
+
+
+CREATE TABLE IF NOT EXISTS server_config (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    push_certificate BYTEA,
+    private_key BYTEA
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_tokens (
+    consumer_key TEXT PRIMARY KEY,
+    token TEXT NOT NULL,
+    added_at TIMESTAMP DEFAULT '1970-01-01 00:00:00'
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_keypair (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    key BYTEA NOT NULL,
+    certificate BYTEA NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_sync_cursor (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    value TEXT DEFAULT '',
+    created_at TIMESTAMP DEFAULT '1970-01-01 00:00:00'
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_auto_assigners (
+    filter TEXT PRIMARY KEY,
+    profile_uuid TEXT NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS device_commands (
+    device_udid TEXT NOT NULL,
+    queue TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    uuid TEXT DEFAULT '',
+    payload BYTEA,
+    created_at TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    last_sent_at TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    acknowledged TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    times_sent INTEGER DEFAULT 0,
+    last_status TEXT DEFAULT '',
+    failure_message BYTEA,
+    PRIMARY KEY (device_udid, queue, position)
+);
+
+
+CREATE TABLE IF NOT EXISTS scep_depot (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    ca_certificate BYTEA,
+    ca_key BYTEA,
+    serial TEXT DEFAULT ''
+);
+
+
+CREATE TABLE IF NOT EXISTS scep_certificates (
+    name TEXT PRIMARY KEY,
+    common_name TEXT NOT NULL,
+    serial TEXT NOT NULL,
+    certificate BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scep_certificates_common_name_idx ON scep_certificates (common_name);
+
+
+-- +goose Down
+ALTER TABLE devices DROP COLUMN IF EXISTS bootstrap_token;
+DROP INDEX IF EXISTS devices_udid_idx;
+DROP INDEX IF EXISTS devices_serial_number_idx;
+DROP TABLE IF EXISTS udid_cert_auth;
+DROP TABLE IF EXISTS profiles;
+DROP TABLE IF EXISTS blueprints;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS server_config;
+DROP TABLE IF EXISTS dep_tokens;
+DROP TABLE IF EXISTS dep_keypair;
+DROP TABLE IF EXISTS dep_sync_cursor;
+DROP TABLE IF EXISTS dep_auto_assigners;
+DROP TABLE IF EXISTS device_commands;
+DROP TABLE IF EXISTS scep_depot;
+DROP TABLE IF EXISTS scep_certificates;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS enrollment_group TEXT DEFAULT '';
+ALTER TABLE blueprints ADD COLUMN IF NOT EXISTS enrollment_groups TEXT DEFAULT '[]';
+
+
+-- +goose Down
+ALTER TABLE devices DROP COLUMN IF EXISTS enrollment_group;
+ALTER TABLE blueprints DROP COLUMN IF EXISTS enrollment_groups;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS scep_revocations (
+    issuer TEXT NOT NULL,
+    serial TEXT NOT NULL,
+    revoked_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (issuer, serial)
+);
+
+
+-- +goose Down
+DROP TABLE IF EXISTS scep_revocations;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE push_info ADD COLUMN IF NOT EXISTS invalid BOOLEAN DEFAULT false;
+ALTER TABLE push_info ADD COLUMN IF NOT EXISTS invalid_reason TEXT DEFAULT '';
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS push_invalid BOOLEAN DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS push_history (
+    id SERIAL PRIMARY KEY,
+    udid TEXT NOT NULL,
+    pushed_at TIMESTAMP NOT NULL,
+    apns_id TEXT DEFAULT '',
+    status INTEGER DEFAULT 0,
+    reason TEXT DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS push_history_udid_idx ON push_history (udid, id);
+
+
+-- +goose Down
+DROP TABLE IF EXISTS push_history;
+ALTER TABLE devices DROP COLUMN IF EXISTS push_invalid;
+ALTER TABLE push_info DROP COLUMN IF EXISTS invalid_reason;
+ALTER TABLE push_info DROP COLUMN IF EXISTS invalid;
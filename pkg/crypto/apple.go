@@ -107,3 +107,22 @@ func VerifyFromAppleDeviceCA(c *x509.Certificate) error {
 	// (known expired intermediate)
 	return c.CheckSignatureFrom(parent)
 }
+
+// VerifyChainFromAppleDeviceCA verifies a certificate was signed by Apple's
+// iPhone Device CA, either directly or through one of the intermediates,
+// which are usually the other certificates of the CMS message.
+func VerifyChainFromAppleDeviceCA(c *x509.Certificate, intermediates []*x509.Certificate) error {
+	err := VerifyFromAppleDeviceCA(c)
+	if err == nil {
+		return nil
+	}
+	for _, intermediate := range intermediates {
+		if intermediate.Equal(c) {
+			continue
+		}
+		if c.CheckSignatureFrom(intermediate) == nil && VerifyFromAppleDeviceCA(intermediate) == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package profileutil
 
 import (
 	"crypto"
+	"crypto/tls"
 	"crypto/x509"
 
 	"github.com/pkg/errors"
@@ -10,16 +11,58 @@ import (
 )
 
 // Sign takes an unsigned payload and signs it with the provided private key and certificate.
-func Sign(key crypto.PrivateKey, cert *x509.Certificate, mobileconfig []byte) ([]byte, error) {
+func Sign(key crypto.PrivateKey, cert *x509.Certificate, mobileconfig []byte, intermediates ...*x509.Certificate) ([]byte, error) {
 	sd, err := pkcs7.NewSignedData(mobileconfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "create signed data for mobileconfig")
 	}
 
-	if err := sd.AddSigner(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+	if err := sd.AddSignerChain(cert, key, intermediates, pkcs7.SignerInfoConfig{}); err != nil {
 		return nil, errors.Wrap(err, "add crypto signer to mobileconfig signed data")
 	}
 
 	signedMobileconfig, err := sd.Finish()
 	return signedMobileconfig, errors.Wrap(err, "complete mobileconfig signing")
 }
+
+// IsSigned returns true if mobileconfig is CMS signed or encrypted, rather
+// than a plain property list.
+func IsSigned(mobileconfig []byte) bool {
+	_, err := pkcs7.Parse(mobileconfig)
+	return err == nil
+}
+
+// Signer signs profiles with a certificate, such as the TLS certificate of
+// the server.
+type Signer struct {
+	key           crypto.PrivateKey
+	cert          *x509.Certificate
+	intermediates []*x509.Certificate
+}
+
+// NewSigner loads a PEM encoded certificate chain and private key. The
+// intermediates of the chain are included in signed profiles.
+func NewSigner(certPath, keyPath string) (*Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "load profile signing certificate")
+	}
+	var chain []*x509.Certificate
+	for _, der := range pair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse profile signing certificate")
+		}
+		chain = append(chain, cert)
+	}
+	return &Signer{key: pair.PrivateKey, cert: chain[0], intermediates: chain[1:]}, nil
+}
+
+// Sign signs mobileconfig. Profiles which are already signed or encrypted
+// are returned unchanged.
+func (s *Signer) Sign(mobileconfig []byte) ([]byte, error) {
+	if len(mobileconfig) == 0 || IsSigned(mobileconfig) {
+		return mobileconfig, nil
+	}
+	return Sign(s.key, s.cert, mobileconfig, s.intermediates...)
+}
@@ -0,0 +1,93 @@
+package profileutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mozilla.org/pkcs7"
+)
+
+func TestSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certPath, keyPath := writeKeyPair(t, dir)
+
+	signer, err := NewSigner(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mobileconfig := []byte(`<?xml version="1.0" encoding="UTF-8"?><plist version="1.0"><dict/></plist>`)
+	if IsSigned(mobileconfig) {
+		t.Fatal("plain mobileconfig reported as signed")
+	}
+	signed, err := signer.Sign(mobileconfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p7.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(p7.Content), string(mobileconfig); have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	// signed profiles are not signed again.
+	resigned, err := signer.Sign(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(resigned), len(signed); have != want {
+		t.Errorf("have %d bytes, want %d", have, want)
+	}
+}
+
+func writeKeyPair(t *testing.T, dir string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mdm.example.org"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(certPath, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
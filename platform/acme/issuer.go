@@ -0,0 +1,526 @@
+// Package acme is a minimal ACME (RFC 8555) issuer for device identity
+// certificates, which devices request with a com.apple.security.acme payload.
+//
+// Certificates are signed by the SCEP CA and stored in the SCEP depot, so
+// they are accepted wherever SCEP certificates are. Devices authorize their
+// order with the ClientIdentifier of the payload, which is checked like a
+// SCEP challenge. The device-attest-01 challenge is accepted without
+// verifying an attestation statement.
+//
+// Accounts and orders are only kept in memory. Devices request a
+// certificate once, when the enrollment profile is installed, so an order
+// that is lost on restart is retried by installing the profile again. Nonces
+// are HMACs, so only the used ones are kept until they expire. Accounts are
+// kept for as long as their orders, and accounts without an authorized order
+// are limited in number and expire like orders.
+package acme
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/micromdm/scep/v2/challenge"
+	"github.com/micromdm/scep/v2/depot"
+	"github.com/pkg/errors"
+)
+
+// PathPrefix is the path of the ACME endpoints on the MDM server.
+const PathPrefix = "/acme"
+
+// ChallengeType is the ACME challenge type used by Apple devices.
+const ChallengeType = "device-attest-01"
+
+// IdentifierType is the ACME identifier type of the ClientIdentifier.
+const IdentifierType = "permanent-identifier"
+
+const (
+	statusPending    = "pending"
+	statusReady      = "ready"
+	statusProcessing = "processing"
+	statusValid      = "valid"
+	statusInvalid    = "invalid"
+)
+
+// orderTTL is how long a device has to complete an order, and how long an
+// account without an authorized order is kept.
+const orderTTL = time.Hour
+
+// nonceTTL is how long a nonce is valid.
+const nonceTTL = 10 * time.Minute
+
+// sweepInterval is how often expired accounts, orders and authorizations are
+// removed.
+const sweepInterval = time.Minute
+
+// Limits of the state unauthenticated clients can create.
+const (
+	defaultMaxPendingAccounts = 10000
+	defaultMaxUsedNonces      = 100000
+)
+
+type Issuer struct {
+	depot        depot.Depot
+	serverURL    string
+	validityDays int
+	verify       func(clientIdentifier string) (bool, error)
+	now          func() time.Time
+	nonceKey     []byte
+
+	maxPendingAccounts int
+	maxUsedNonces      int
+
+	mu              sync.Mutex
+	lastSweep       time.Time
+	pendingAccounts int
+	accounts        map[string]*account
+	keys            map[string]string
+	orders          map[string]*order
+	authzs          map[string]*authorization
+
+	// usedNonces are the nonces used since usedSince, and prevUsedNonces
+	// the ones used in the nonceTTL before. Older nonces are expired.
+	usedSince      time.Time
+	usedNonces     map[string]bool
+	prevUsedNonces map[string]bool
+}
+
+type Option func(*Issuer)
+
+// WithValidityDays sets the validity of issued certificates. The default is
+// 365 days.
+func WithValidityDays(days int) Option {
+	return func(iss *Issuer) {
+		iss.validityDays = days
+	}
+}
+
+// WithStaticChallenge accepts orders for the ClientIdentifier pw.
+func WithStaticChallenge(pw string) Option {
+	return func(iss *Issuer) {
+		iss.verify = func(clientIdentifier string) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(pw), []byte(clientIdentifier)) == 1, nil
+		}
+	}
+}
+
+// WithChallengeStore accepts orders for the dynamic SCEP challenges of store.
+// Like SCEP challenges, each ClientIdentifier can be used once.
+func WithChallengeStore(store challenge.Store) Option {
+	return func(iss *Issuer) {
+		iss.verify = store.HasChallenge
+	}
+}
+
+// NewIssuer creates an Issuer which signs with the CA of depot. Orders are
+// rejected unless one of WithStaticChallenge or WithChallengeStore is used.
+func NewIssuer(depot depot.Depot, serverURL string, opts ...Option) *Issuer {
+	iss := &Issuer{
+		depot:        depot,
+		serverURL:    serverURL,
+		validityDays: 365,
+		verify: func(string) (bool, error) {
+			return false, nil
+		},
+		now:                time.Now,
+		nonceKey:           make([]byte, 32),
+		maxPendingAccounts: defaultMaxPendingAccounts,
+		maxUsedNonces:      defaultMaxUsedNonces,
+		accounts:           make(map[string]*account),
+		keys:               make(map[string]string),
+		orders:             make(map[string]*order),
+		authzs:             make(map[string]*authorization),
+		usedNonces:         make(map[string]bool),
+	}
+	if _, err := rand.Read(iss.nonceKey); err != nil {
+		panic(err)
+	}
+	for _, opt := range opts {
+		opt(iss)
+	}
+	return iss
+}
+
+// DirectoryURL is the ACME directory, the DirectoryURL of the payload.
+func (iss *Issuer) DirectoryURL() string {
+	return iss.url("/directory")
+}
+
+func (iss *Issuer) url(path string) string {
+	return iss.serverURL + PathPrefix + path
+}
+
+type account struct {
+	id         string
+	key        crypto.PublicKey
+	thumbprint string
+	// authorized is set once the account has an authorized order.
+	authorized bool
+	expires    time.Time
+}
+
+type identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type order struct {
+	id          string
+	accountID   string
+	status      string
+	expires     time.Time
+	identifiers []identifier
+	authzIDs    []string
+	cert        *x509.Certificate
+}
+
+type authorization struct {
+	id         string
+	accountID  string
+	orderID    string
+	identifier identifier
+	status     string
+	token      string
+	validated  time.Time
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newNonce returns a nonce which is valid for nonceTTL. It is the time it was
+// issued and a random value, authenticated with an HMAC.
+func (iss *Issuer) newNonce() string {
+	b := make([]byte, 16, 16+sha256.Size)
+	binary.BigEndian.PutUint64(b, uint64(iss.now().Unix()))
+	if _, err := rand.Read(b[8:]); err != nil {
+		panic(err)
+	}
+	mac := hmac.New(sha256.New, iss.nonceKey)
+	mac.Write(b)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(b))
+}
+
+// useNonce consumes nonce, which is only valid once.
+func (iss *Issuer) useNonce(nonce string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(nonce)
+	if err != nil || len(b) != 16+sha256.Size {
+		return false
+	}
+	mac := hmac.New(sha256.New, iss.nonceKey)
+	mac.Write(b[:16])
+	if !hmac.Equal(mac.Sum(nil), b[16:]) {
+		return false
+	}
+	now := iss.now()
+	issued := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	if now.Sub(issued) > nonceTTL || issued.After(now) {
+		return false
+	}
+
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	if elapsed := now.Sub(iss.usedSince); elapsed > nonceTTL {
+		iss.prevUsedNonces = iss.usedNonces
+		if elapsed > 2*nonceTTL {
+			// all of them expired.
+			iss.prevUsedNonces = nil
+		}
+		iss.usedNonces = make(map[string]bool)
+		iss.usedSince = now
+	}
+	if iss.usedNonces[nonce] || iss.prevUsedNonces[nonce] || len(iss.usedNonces) >= iss.maxUsedNonces {
+		return false
+	}
+	iss.usedNonces[nonce] = true
+	return true
+}
+
+// sweep removes expired accounts, orders and authorizations, at most once
+// per sweepInterval. It must be called with iss.mu held.
+func (iss *Issuer) sweep(now time.Time) {
+	if now.Sub(iss.lastSweep) < sweepInterval {
+		return
+	}
+	iss.lastSweep = now
+	for id, o := range iss.orders {
+		if now.After(o.expires) {
+			for _, authzID := range o.authzIDs {
+				delete(iss.authzs, authzID)
+			}
+			delete(iss.orders, id)
+		}
+	}
+	for id, acct := range iss.accounts {
+		if now.After(acct.expires) {
+			iss.deleteAccount(id)
+		}
+	}
+}
+
+// deleteAccount must be called with iss.mu held.
+func (iss *Issuer) deleteAccount(id string) {
+	acct, ok := iss.accounts[id]
+	if !ok {
+		return
+	}
+	if !acct.authorized {
+		iss.pendingAccounts--
+	}
+	delete(iss.keys, acct.thumbprint)
+	delete(iss.accounts, id)
+}
+
+var problemRateLimited = &problem{
+	Type:   "urn:ietf:params:acme:error:rateLimited",
+	Detail: "too many pending accounts",
+	Status: http.StatusTooManyRequests,
+}
+
+// register returns the account of key, creating one unless onlyExisting. New
+// accounts expire after orderTTL unless they get an authorized order.
+func (iss *Issuer) register(key *jsonWebKey, pub crypto.PublicKey, onlyExisting bool) (acct *account, created bool, err error) {
+	now := iss.now()
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.sweep(now)
+	thumbprint := key.thumbprint()
+	if id, ok := iss.keys[thumbprint]; ok {
+		if acct := iss.accounts[id]; !now.After(acct.expires) {
+			return acct, false, nil
+		}
+		iss.deleteAccount(id)
+	}
+	if onlyExisting {
+		return nil, false, problemAccountDoesNotExist
+	}
+	if iss.pendingAccounts >= iss.maxPendingAccounts {
+		return nil, false, problemRateLimited
+	}
+	acct = &account{
+		id:         randomID(),
+		key:        pub,
+		thumbprint: thumbprint,
+		expires:    now.Add(orderTTL),
+	}
+	iss.accounts[acct.id] = acct
+	iss.keys[thumbprint] = acct.id
+	iss.pendingAccounts++
+	return acct, true, nil
+}
+
+func (iss *Issuer) account(id string) (*account, bool) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	acct, ok := iss.accounts[id]
+	if !ok || iss.now().After(acct.expires) {
+		return nil, false
+	}
+	return acct, true
+}
+
+// newOrder creates an order for the ClientIdentifier of a device.
+func (iss *Issuer) newOrder(acct *account, identifiers []identifier) (*order, error) {
+	if len(identifiers) != 1 || identifiers[0].Type != IdentifierType {
+		return nil, &problem{
+			Type:   "urn:ietf:params:acme:error:rejectedIdentifier",
+			Detail: "order must have a single " + IdentifierType + " identifier",
+			Status: 403,
+		}
+	}
+	ok, err := iss.verify(identifiers[0].Value)
+	if err != nil {
+		return nil, errors.Wrap(err, "verify ACME client identifier")
+	}
+	if !ok {
+		return nil, &problem{
+			Type:   "urn:ietf:params:acme:error:unauthorized",
+			Detail: "invalid client identifier",
+			Status: 403,
+		}
+	}
+
+	o := &order{
+		id:          randomID(),
+		accountID:   acct.id,
+		status:      statusPending,
+		expires:     iss.now().Add(orderTTL).UTC(),
+		identifiers: identifiers,
+	}
+	authz := &authorization{
+		id:         randomID(),
+		accountID:  acct.id,
+		orderID:    o.id,
+		identifier: identifiers[0],
+		status:     statusPending,
+		token:      randomID(),
+	}
+	o.authzIDs = []string{authz.id}
+
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	iss.sweep(iss.now())
+	// the account is kept as long as its authorized orders.
+	if a, ok := iss.accounts[acct.id]; ok {
+		if !a.authorized {
+			a.authorized = true
+			iss.pendingAccounts--
+		}
+		if a.expires.Before(o.expires) {
+			a.expires = o.expires
+		}
+	}
+	iss.orders[o.id] = o
+	iss.authzs[authz.id] = authz
+	return o, nil
+}
+
+func (iss *Issuer) order(acct *account, id string) (order, error) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	o, ok := iss.orders[id]
+	if !ok || o.accountID != acct.id {
+		return order{}, problemNotFound
+	}
+	return *o, nil
+}
+
+func (iss *Issuer) authorization(acct *account, id string) (authorization, error) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	authz, ok := iss.authzs[id]
+	if !ok || authz.accountID != acct.id {
+		return authorization{}, problemNotFound
+	}
+	return *authz, nil
+}
+
+// validate responds to the device-attest-01 challenge of an authorization.
+// The order was authorized by its ClientIdentifier, so the challenge is
+// accepted and the order becomes ready.
+func (iss *Issuer) validate(acct *account, authzID string) (authorization, error) {
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	authz, ok := iss.authzs[authzID]
+	if !ok || authz.accountID != acct.id {
+		return authorization{}, problemNotFound
+	}
+	if authz.status == statusPending {
+		authz.status = statusValid
+		authz.validated = iss.now().UTC()
+		if o, ok := iss.orders[authz.orderID]; ok && o.status == statusPending {
+			o.status = statusReady
+		}
+	}
+	return *authz, nil
+}
+
+// finalize signs the CSR of a ready order.
+func (iss *Issuer) finalize(acct *account, orderID string, csr *x509.CertificateRequest) (order, error) {
+	iss.mu.Lock()
+	o, ok := iss.orders[orderID]
+	if !ok || o.accountID != acct.id {
+		iss.mu.Unlock()
+		return order{}, problemNotFound
+	}
+	if o.status != statusReady {
+		iss.mu.Unlock()
+		return order{}, &problem{
+			Type:   "urn:ietf:params:acme:error:orderNotReady",
+			Detail: "order is " + o.status,
+			Status: 403,
+		}
+	}
+	o.status = statusProcessing
+	iss.mu.Unlock()
+
+	crt, err := iss.sign(csr)
+
+	iss.mu.Lock()
+	defer iss.mu.Unlock()
+	if err != nil {
+		o.status = statusInvalid
+		return *o, err
+	}
+	o.status = statusValid
+	o.cert = crt
+	return *o, nil
+}
+
+// sign signs csr with the SCEP CA and stores the certificate in the depot,
+// like the SCEP signer does. The signature algorithm is chosen by the CA
+// key, as hardware-bound keys are EC keys while the SCEP CA is RSA.
+func (iss *Issuer) sign(csr *x509.CertificateRequest) (*x509.Certificate, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, &problem{
+			Type:   "urn:ietf:params:acme:error:badCSR",
+			Detail: err.Error(),
+			Status: 400,
+		}
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(csr.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal CSR public key")
+	}
+	keyID := sha1.Sum(pubBytes)
+
+	serial, err := iss.depot.Serial()
+	if err != nil {
+		return nil, errors.Wrap(err, "get serial from SCEP depot")
+	}
+	now := iss.now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      csr.Subject,
+		NotBefore:    now.Add(-10 * time.Minute).UTC(),
+		NotAfter:     now.AddDate(0, 0, iss.validityDays).UTC(),
+		SubjectKeyId: keyID[:],
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageClientAuth,
+		},
+		DNSNames:       csr.DNSNames,
+		EmailAddresses: csr.EmailAddresses,
+		IPAddresses:    csr.IPAddresses,
+		URIs:           csr.URIs,
+	}
+
+	caCerts, caKey, err := iss.depot.CA(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "get SCEP CA")
+	}
+	crtBytes, err := x509.CreateCertificate(rand.Reader, tmpl, caCerts[0], csr.PublicKey, caKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "create certificate")
+	}
+	crt, err := x509.ParseCertificate(crtBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse certificate")
+	}
+
+	name := crt.Subject.CommonName
+	if name == "" {
+		name = string(crt.Signature)
+	}
+	if _, err := iss.depot.HasCN(name, 0, crt, false); err != nil {
+		return nil, errors.Wrap(err, "check SCEP depot")
+	}
+	if err := iss.depot.Put(name, crt); err != nil {
+		return nil, errors.Wrap(err, "store certificate in SCEP depot")
+	}
+	return crt, nil
+}
@@ -0,0 +1,213 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/go-kit/kit/log"
+	boltdepot "github.com/micromdm/scep/v2/depot/bolt"
+	"golang.org/x/crypto/acme"
+)
+
+func TestIssueCertificate(t *testing.T) {
+	depot := setupDepot(t)
+
+	var handler http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	iss := NewIssuer(depot, srv.URL, WithStaticChallenge("secret"))
+	handler = MakeHTTPHandler(iss, log.NewNopLogger())
+
+	ctx := context.Background()
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &acme.Client{Key: accountKey, DirectoryURL: iss.DirectoryURL()}
+	if _, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS); err != nil {
+		t.Fatalf("register account: %s", err)
+	}
+
+	if _, err := client.AuthorizeOrder(ctx, []acme.AuthzID{{Type: IdentifierType, Value: "wrong"}}); err == nil {
+		t.Fatal("expected an order with a wrong client identifier to fail")
+	}
+
+	order, err := client.AuthorizeOrder(ctx, []acme.AuthzID{{Type: IdentifierType, Value: "secret"}})
+	if err != nil {
+		t.Fatalf("create order: %s", err)
+	}
+	authz, err := client.GetAuthorization(ctx, order.AuthzURLs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := authz.Challenges[0].Type, ChallengeType; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if _, err := client.Accept(ctx, authz.Challenges[0]); err != nil {
+		t.Fatalf("accept challenge: %s", err)
+	}
+
+	// the device identity key is a hardware-bound EC key, while the SCEP CA
+	// has an RSA key.
+	deviceKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "device-1"},
+	}, deviceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		t.Fatalf("finalize order: %s", err)
+	}
+	if have, want := len(chain), 2; have != want {
+		t.Fatalf("have %d certificates, want %d", have, want)
+	}
+	crt, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := crt.Subject.CommonName, "device-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	// the certificate is in the depot, so it is accepted like a SCEP one.
+	hasCN, err := depot.HasCN("device-1", 0, crt, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCN {
+		t.Error("issued certificate is not in the SCEP depot")
+	}
+}
+
+func TestNonces(t *testing.T) {
+	iss := NewIssuer(nil, "https://mdm.example.org")
+	now := time.Now()
+	iss.now = func() time.Time { return now }
+
+	nonce := iss.newNonce()
+	if !iss.useNonce(nonce) {
+		t.Fatal("new nonce is not valid")
+	}
+	if iss.useNonce(nonce) {
+		t.Error("nonce was used twice")
+	}
+	if iss.useNonce(iss.newNonce()[1:]) {
+		t.Error("tampered nonce is valid")
+	}
+	if iss.useNonce(NewIssuer(nil, "https://mdm.example.org").newNonce()) {
+		t.Error("nonce of another issuer is valid")
+	}
+
+	// nonces are not stored until they are used.
+	for i := 0; i < 100; i++ {
+		iss.newNonce()
+	}
+	if have, want := len(iss.usedNonces), 1; have != want {
+		t.Errorf("have %d stored nonces, want %d", have, want)
+	}
+
+	old := iss.newNonce()
+	now = now.Add(nonceTTL + time.Second)
+	if iss.useNonce(old) {
+		t.Error("expired nonce is valid")
+	}
+	// the used nonce expired, so it is forgotten after another nonceTTL.
+	now = now.Add(nonceTTL + time.Second)
+	iss.useNonce(iss.newNonce())
+	if have, want := len(iss.usedNonces)+len(iss.prevUsedNonces), 1; have != want {
+		t.Errorf("have %d stored nonces, want %d", have, want)
+	}
+}
+
+func TestPendingAccounts(t *testing.T) {
+	iss := NewIssuer(nil, "https://mdm.example.org", WithStaticChallenge("secret"))
+	iss.maxPendingAccounts = 2
+	now := time.Now()
+	iss.now = func() time.Time { return now }
+
+	register := func() (*account, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwk := &jsonWebKey{Kty: "EC", Crv: "P-256", X: b64(key.X.Bytes()), Y: b64(key.Y.Bytes())}
+		acct, _, err := iss.register(jwk, &key.PublicKey, false)
+		return acct, err
+	}
+	first, err := register()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := register(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := register(); err != problemRateLimited {
+		t.Fatalf("have %v, want too many pending accounts", err)
+	}
+
+	// an account with an authorized order is no longer pending.
+	if _, err := iss.newOrder(first, []identifier{{Type: IdentifierType, Value: "secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := register(); err != nil {
+		t.Fatal(err)
+	}
+
+	// accounts expire with their orders.
+	now = now.Add(orderTTL + time.Minute)
+	if _, ok := iss.account(first.id); ok {
+		t.Error("expired account still exists")
+	}
+	if _, err := register(); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(iss.accounts), 1; have != want {
+		t.Errorf("have %d accounts, want %d", have, want)
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func setupDepot(t *testing.T) *boltdepot.Depot {
+	f, _ := ioutil.TempFile("", "bolt-")
+	f.Close()
+	os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0777, nil)
+	if err != nil {
+		t.Fatalf("couldn't open bolt, err %s\n", err)
+	}
+	depot, err := boltdepot.NewBoltDepot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := depot.CreateOrLoadKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := depot.CreateOrLoadCA(key, 5, "MicroMDM", "US"); err != nil {
+		t.Fatal(err)
+	}
+	return depot
+}
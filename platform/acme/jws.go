@@ -0,0 +1,169 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// jwsMessage is a JWS in the flattened JSON serialization, which is how
+// ACME clients send every POST request.
+type jwsMessage struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+type jwsHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	KID   string          `json:"kid,omitempty"`
+	JWK   json.RawMessage `json:"jwk,omitempty"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// parseJWS decodes the protected header and payload of msg. The signature
+// is checked separately with verify, once the key is known.
+func parseJWS(data []byte) (*jwsMessage, *jwsHeader, []byte, error) {
+	var msg jwsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "decode JWS")
+	}
+	protected, err := base64.RawURLEncoding.DecodeString(msg.Protected)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "decode JWS protected header")
+	}
+	var hdr jwsHeader
+	if err := json.Unmarshal(protected, &hdr); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "decode JWS protected header")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "decode JWS payload")
+	}
+	return &msg, &hdr, payload, nil
+}
+
+// verify checks the signature of msg with pub.
+func (msg *jwsMessage) verify(alg string, pub crypto.PublicKey) error {
+	sig, err := base64.RawURLEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return errors.Wrap(err, "decode JWS signature")
+	}
+	signed := []byte(msg.Protected + "." + msg.Payload)
+
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		var digest []byte
+		switch alg {
+		case "ES256":
+			sum := sha256.Sum256(signed)
+			digest = sum[:]
+		case "ES384":
+			sum := sha512.Sum384(signed)
+			digest = sum[:]
+		default:
+			return fmt.Errorf("unsupported JWS algorithm %s for EC key", alg)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid JWS signature length")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("invalid JWS signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return fmt.Errorf("unsupported JWS algorithm %s for RSA key", alg)
+		}
+		sum := sha256.Sum256(signed)
+		return errors.Wrap(rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig), "invalid JWS signature")
+	default:
+		return errors.New("unsupported JWS key type")
+	}
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported JWK curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("JWK point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid JWK RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type %s", k.Kty)
+	}
+}
+
+// thumbprint is the RFC 7638 thumbprint of the key, which identifies the
+// account of a key.
+func (k *jsonWebKey) thumbprint() string {
+	var canonical string
+	switch k.Kty {
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Crv, k.X, k.Y)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid JWK parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,433 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// problem is an ACME error document.
+type problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+func (p *problem) Error() string {
+	return p.Detail
+}
+
+var (
+	problemNotFound = &problem{
+		Type:   "urn:ietf:params:acme:error:malformed",
+		Detail: "resource not found",
+		Status: http.StatusNotFound,
+	}
+	problemAccountDoesNotExist = &problem{
+		Type:   "urn:ietf:params:acme:error:accountDoesNotExist",
+		Detail: "no account for this key",
+		Status: http.StatusBadRequest,
+	}
+)
+
+func malformed(err error) *problem {
+	return &problem{
+		Type:   "urn:ietf:params:acme:error:malformed",
+		Detail: err.Error(),
+		Status: http.StatusBadRequest,
+	}
+}
+
+// MakeHTTPHandler returns the ACME endpoints of iss, served under PathPrefix.
+func MakeHTTPHandler(iss *Issuer, logger log.Logger) http.Handler {
+	h := &handler{iss: iss, logger: logger}
+	r := mux.NewRouter()
+	r.Methods("GET").Path(PathPrefix + "/directory").HandlerFunc(h.directory)
+	r.Methods("HEAD", "GET").Path(PathPrefix + "/new-nonce").HandlerFunc(h.newNonce)
+	r.Methods("POST").Path(PathPrefix + "/new-account").HandlerFunc(h.newAccount)
+	r.Methods("POST").Path(PathPrefix + "/account/{id}").HandlerFunc(h.getAccount)
+	r.Methods("POST").Path(PathPrefix + "/new-order").HandlerFunc(h.newOrder)
+	r.Methods("POST").Path(PathPrefix + "/order/{id}").HandlerFunc(h.getOrder)
+	r.Methods("POST").Path(PathPrefix + "/order/{id}/finalize").HandlerFunc(h.finalize)
+	r.Methods("POST").Path(PathPrefix + "/authz/{id}").HandlerFunc(h.getAuthorization)
+	r.Methods("POST").Path(PathPrefix + "/challenge/{id}").HandlerFunc(h.challenge)
+	r.Methods("POST").Path(PathPrefix + "/cert/{id}").HandlerFunc(h.certificate)
+	return r
+}
+
+type handler struct {
+	iss    *Issuer
+	logger log.Logger
+}
+
+// request is an authenticated ACME POST request.
+type request struct {
+	header  *jwsHeader
+	payload []byte
+	key     *jsonWebKey
+	account *account
+}
+
+func (h *handler) writeHeaders(w http.ResponseWriter) {
+	w.Header().Set("Replay-Nonce", h.iss.newNonce())
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Link", `<`+h.iss.DirectoryURL()+`>;rel="index"`)
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	h.writeHeaders(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Log("msg", "encode ACME response", "err", err)
+	}
+}
+
+func (h *handler) writeError(w http.ResponseWriter, err error) {
+	p, ok := errors.Cause(err).(*problem)
+	if !ok {
+		h.logger.Log("msg", "ACME request", "err", err)
+		p = &problem{
+			Type:   "urn:ietf:params:acme:error:serverInternal",
+			Detail: "internal server error",
+			Status: http.StatusInternalServerError,
+		}
+	}
+	h.writeHeaders(w)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// authenticate verifies the JWS of r. Requests to new-account are signed by
+// the key in the jwk header, all others by the account in the kid header.
+func (h *handler) authenticate(r *http.Request, newAccount bool) (*request, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		return nil, errors.Wrap(err, "read ACME request")
+	}
+	msg, hdr, payload, err := parseJWS(body)
+	if err != nil {
+		return nil, malformed(err)
+	}
+	if !h.iss.useNonce(hdr.Nonce) {
+		return nil, &problem{
+			Type:   "urn:ietf:params:acme:error:badNonce",
+			Detail: "invalid or reused nonce",
+			Status: http.StatusBadRequest,
+		}
+	}
+	if hdr.URL != h.iss.serverURL+r.URL.Path {
+		return nil, &problem{
+			Type:   "urn:ietf:params:acme:error:unauthorized",
+			Detail: "JWS url does not match the request URL",
+			Status: http.StatusUnauthorized,
+		}
+	}
+
+	req := &request{header: hdr, payload: payload}
+	if newAccount {
+		if len(hdr.JWK) == 0 {
+			return nil, malformed(errors.New("new-account request must have a jwk"))
+		}
+		var key jsonWebKey
+		if err := json.Unmarshal(hdr.JWK, &key); err != nil {
+			return nil, malformed(errors.Wrap(err, "decode jwk"))
+		}
+		pub, err := key.publicKey()
+		if err != nil {
+			return nil, malformed(err)
+		}
+		if err := msg.verify(hdr.Alg, pub); err != nil {
+			return nil, malformed(err)
+		}
+		req.key = &key
+		return req, nil
+	}
+
+	accountPrefix := h.iss.url("/account/")
+	if !strings.HasPrefix(hdr.KID, accountPrefix) {
+		return nil, problemAccountDoesNotExist
+	}
+	acct, ok := h.iss.account(strings.TrimPrefix(hdr.KID, accountPrefix))
+	if !ok {
+		return nil, problemAccountDoesNotExist
+	}
+	if err := msg.verify(hdr.Alg, acct.key); err != nil {
+		return nil, malformed(err)
+	}
+	req.account = acct
+	return req, nil
+}
+
+func (h *handler) directory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"newNonce":   h.iss.url("/new-nonce"),
+		"newAccount": h.iss.url("/new-account"),
+		"newOrder":   h.iss.url("/new-order"),
+	})
+}
+
+func (h *handler) newNonce(w http.ResponseWriter, r *http.Request) {
+	h.writeHeaders(w)
+	if r.Method == "GET" {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *handler) newAccount(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, true)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var payload struct {
+		OnlyReturnExisting bool `json:"onlyReturnExisting"`
+	}
+	if len(req.payload) > 0 {
+		if err := json.Unmarshal(req.payload, &payload); err != nil {
+			h.writeError(w, malformed(err))
+			return
+		}
+	}
+	pub, _ := req.key.publicKey()
+	acct, created, err := h.iss.register(req.key, pub, payload.OnlyReturnExisting)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Location", h.iss.url("/account/"+acct.id))
+	h.writeJSON(w, status, accountResponse{Status: statusValid, Orders: h.iss.url("/account/" + acct.id + "/orders")})
+}
+
+type accountResponse struct {
+	Status string `json:"status"`
+	Orders string `json:"orders"`
+}
+
+func (h *handler) getAccount(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if req.account.id != mux.Vars(r)["id"] {
+		h.writeError(w, &problem{
+			Type:   "urn:ietf:params:acme:error:unauthorized",
+			Detail: "account does not match the signing key",
+			Status: http.StatusForbidden,
+		})
+		return
+	}
+	w.Header().Set("Location", h.iss.url("/account/"+req.account.id))
+	h.writeJSON(w, http.StatusOK, accountResponse{Status: statusValid, Orders: h.iss.url("/account/" + req.account.id + "/orders")})
+}
+
+type orderResponse struct {
+	Status         string       `json:"status"`
+	Expires        string       `json:"expires"`
+	Identifiers    []identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+}
+
+func (h *handler) writeOrder(w http.ResponseWriter, status int, o order) {
+	resp := orderResponse{
+		Status:      o.status,
+		Expires:     o.expires.Format(time.RFC3339),
+		Identifiers: o.identifiers,
+		Finalize:    h.iss.url("/order/" + o.id + "/finalize"),
+	}
+	for _, id := range o.authzIDs {
+		resp.Authorizations = append(resp.Authorizations, h.iss.url("/authz/"+id))
+	}
+	if o.cert != nil {
+		resp.Certificate = h.iss.url("/cert/" + o.id)
+	}
+	w.Header().Set("Location", h.iss.url("/order/"+o.id))
+	h.writeJSON(w, status, resp)
+}
+
+func (h *handler) newOrder(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var payload struct {
+		Identifiers []identifier `json:"identifiers"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		h.writeError(w, malformed(err))
+		return
+	}
+	o, err := h.iss.newOrder(req.account, payload.Identifiers)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeOrder(w, http.StatusCreated, *o)
+}
+
+func (h *handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	o, err := h.iss.order(req.account, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeOrder(w, http.StatusOK, o)
+}
+
+func (h *handler) finalize(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		h.writeError(w, malformed(err))
+		return
+	}
+	der, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		h.writeError(w, malformed(errors.Wrap(err, "decode CSR")))
+		return
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		h.writeError(w, &problem{
+			Type:   "urn:ietf:params:acme:error:badCSR",
+			Detail: err.Error(),
+			Status: http.StatusBadRequest,
+		})
+		return
+	}
+	o, err := h.iss.finalize(req.account, mux.Vars(r)["id"], csr)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeOrder(w, http.StatusOK, o)
+}
+
+type challengeResponse struct {
+	Type      string `json:"type"`
+	URL       string `json:"url"`
+	Token     string `json:"token"`
+	Status    string `json:"status"`
+	Validated string `json:"validated,omitempty"`
+}
+
+func (h *handler) challengeResponse(authz authorization) challengeResponse {
+	resp := challengeResponse{
+		Type:   ChallengeType,
+		URL:    h.iss.url("/challenge/" + authz.id),
+		Token:  authz.token,
+		Status: authz.status,
+	}
+	if !authz.validated.IsZero() {
+		resp.Validated = authz.validated.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func (h *handler) getAuthorization(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	authz, err := h.iss.authorization(req.account, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, struct {
+		Status     string              `json:"status"`
+		Identifier identifier          `json:"identifier"`
+		Challenges []challengeResponse `json:"challenges"`
+	}{
+		Status:     authz.status,
+		Identifier: authz.identifier,
+		Challenges: []challengeResponse{h.challengeResponse(authz)},
+	})
+}
+
+func (h *handler) challenge(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	// an empty payload is a POST-as-GET of the challenge, any JSON object
+	// is the response to it.
+	if len(req.payload) == 0 {
+		authz, err := h.iss.authorization(req.account, id)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, h.challengeResponse(authz))
+		return
+	}
+	authz, err := h.iss.validate(req.account, id)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.Header().Add("Link", `<`+h.iss.url("/authz/"+authz.id)+`>;rel="up"`)
+	h.writeJSON(w, http.StatusOK, h.challengeResponse(authz))
+}
+
+func (h *handler) certificate(w http.ResponseWriter, r *http.Request) {
+	req, err := h.authenticate(r, false)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	o, err := h.iss.order(req.account, mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if o.cert == nil {
+		h.writeError(w, problemNotFound)
+		return
+	}
+	caCerts, _, err := h.iss.depot.CA(nil)
+	if err != nil {
+		h.writeError(w, errors.Wrap(err, "get SCEP CA"))
+		return
+	}
+	h.writeHeaders(w)
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: o.cert.Raw})
+	for _, crt := range caCerts {
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+	}
+}
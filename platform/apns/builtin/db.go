@@ -2,6 +2,7 @@ package builtin
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/boltdb/bolt"
@@ -11,7 +12,12 @@ import (
 	"github.com/micromdm/micromdm/platform/pubsub"
 )
 
-const PushBucket = "mdm.PushInfo"
+const (
+	PushBucket = "mdm.PushInfo"
+	// PushHistoryBucket has a bucket of push attempts for every UDID, keyed
+	// by sequence number.
+	PushHistoryBucket = "mdm.PushHistory"
+)
 
 type DB struct {
 	*bolt.DB
@@ -19,11 +25,14 @@ type DB struct {
 
 func NewDB(db *bolt.DB, sub pubsub.Subscriber) (*DB, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(PushBucket))
+		if _, err := tx.CreateBucketIfNotExists([]byte(PushBucket)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists([]byte(PushHistoryBucket))
 		return err
 	})
 	if err != nil {
-		return nil, errors.Wrapf(err, "creating %s bucket", PushBucket)
+		return nil, errors.Wrap(err, "creating push buckets")
 	}
 	datastore := &DB{
 		DB: db,
@@ -40,6 +49,10 @@ func (e *notFound) Error() string {
 	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
 }
 
+func (e *notFound) NotFound() bool {
+	return true
+}
+
 func (db *DB) PushInfo(ctx context.Context, udid string) (*apns.PushInfo, error) {
 	var info apns.PushInfo
 	err := db.View(func(tx *bolt.Tx) error {
@@ -75,3 +88,59 @@ func (db *DB) Save(ctx context.Context, info *apns.PushInfo) error {
 	}
 	return tx.Commit()
 }
+
+func (db *DB) SavePushAttempt(ctx context.Context, attempt *apns.PushAttempt) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket([]byte(PushHistoryBucket)).CreateBucketIfNotExists([]byte(attempt.UDID))
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		v, err := apns.MarshalPushAttempt(attempt)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := b.Put(key, v); err != nil {
+			return err
+		}
+
+		// keep the newest attempts, the keys are in the order they were added.
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+		for i := 0; i < len(keys)-apns.MaxPushHistory; i++ {
+			if err := b.Delete(keys[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "save push attempt")
+}
+
+func (db *DB) PushHistory(ctx context.Context, udid string, limit int) ([]apns.PushAttempt, error) {
+	var attempts []apns.PushAttempt
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(PushHistoryBucket)).Bucket([]byte(udid))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(attempts) < limit); k, v = c.Prev() {
+			var attempt apns.PushAttempt
+			if err := apns.UnmarshalPushAttempt(v, &attempt); err != nil {
+				return err
+			}
+			attempts = append(attempts, attempt)
+		}
+		return nil
+	})
+	return attempts, errors.Wrap(err, "get push history")
+}
@@ -0,0 +1,339 @@
+package apns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+// Defaults of the bulk push fan-out, used unless WithBulkPush sets them.
+const (
+	DefaultBulkPushConcurrency = 20
+	DefaultBulkPushRate        = 100
+)
+
+// MaxBulkPushJobs is how many bulk push jobs are kept to report their
+// results. Jobs are only kept in memory.
+const MaxBulkPushJobs = 20
+
+// BulkPushOption selects the devices of a bulk push: the listed UDIDs and
+// serials, and the devices matching the filter.
+type BulkPushOption struct {
+	UDIDs   []string        `json:"udids,omitempty"`
+	Serials []string        `json:"serials,omitempty"`
+	Filter  *BulkPushFilter `json:"filter,omitempty"`
+}
+
+// BulkPushFilter matches the devices which meet all of the set conditions.
+type BulkPushFilter struct {
+	EnrolledOnly bool   `json:"enrolled_only,omitempty"`
+	Group        string `json:"group,omitempty"`
+	// LastSeenBefore matches the devices which haven't checked in since.
+	LastSeenBefore time.Time `json:"last_seen_before,omitempty"`
+}
+
+// DeviceSelector looks up the UDIDs of the devices with the serials and of
+// the devices matching filter. Serials without a device are skipped. filter
+// may be nil.
+type DeviceSelector interface {
+	SelectDevices(ctx context.Context, serials []string, filter *BulkPushFilter) ([]string, error)
+}
+
+// BulkPushJob is the progress and the per-device results of a bulk push.
+type BulkPushJob struct {
+	ID         string           `json:"id"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Total      int              `json:"total"`
+	Sent       int              `json:"sent"`
+	Failed     int              `json:"failed"`
+	Results    []BulkPushResult `json:"results,omitempty"`
+}
+
+// BulkPushResult is the result of the push to one device of a bulk push. It
+// is empty until the device was pushed.
+type BulkPushResult struct {
+	UDID   string `json:"udid"`
+	APNsID string `json:"push_notification_id,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+// bulkPushJob guards a running job, which the workers of the fan-out update.
+type bulkPushJob struct {
+	mu  sync.Mutex
+	job BulkPushJob
+}
+
+func (j *bulkPushJob) done(i int, id string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := &j.job.Results[i]
+	r.APNsID = id
+	if err != nil {
+		r.Err = err.Error()
+		j.job.Failed++
+		return
+	}
+	j.job.Sent++
+}
+
+func (j *bulkPushJob) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now().UTC()
+	j.job.FinishedAt = &now
+}
+
+func (j *bulkPushJob) snapshot() *BulkPushJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	job.Results = append([]BulkPushResult(nil), j.job.Results...)
+	return &job
+}
+
+type bulkPushJobNotFoundError struct {
+	id string
+}
+
+func (e bulkPushJobNotFoundError) Error() string {
+	return fmt.Sprintf("bulk push job %s not found", e.id)
+}
+
+func (e bulkPushJobNotFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+func (e bulkPushJobNotFoundError) NotFound() bool {
+	return true
+}
+
+// BulkPush starts a job which pushes the selected devices, and returns it
+// before the first push. The pushes are spread over the configured number
+// of workers and limited to the configured rate, so that waking a whole
+// fleet doesn't hammer APNs or the check-in endpoint.
+func (svc *PushService) BulkPush(ctx context.Context, opt BulkPushOption) (*BulkPushJob, error) {
+	svc.mu.RLock()
+	configured := svc.pushsvc != nil
+	svc.mu.RUnlock()
+	if !configured {
+		return nil, errors.New("bulk push: no push certificate")
+	}
+
+	udids, err := svc.selectDevices(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	if len(udids) == 0 {
+		return nil, errors.New("bulk push: no devices selected")
+	}
+
+	j := &bulkPushJob{job: BulkPushJob{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		Total:     len(udids),
+		Results:   make([]BulkPushResult, len(udids)),
+	}}
+	for i, udid := range udids {
+		j.job.Results[i].UDID = udid
+	}
+
+	svc.jobsMu.Lock()
+	svc.jobs = append(svc.jobs, j)
+	if len(svc.jobs) > MaxBulkPushJobs {
+		svc.jobs = svc.jobs[len(svc.jobs)-MaxBulkPushJobs:]
+	}
+	svc.jobsMu.Unlock()
+
+	go svc.runBulkPush(j, udids)
+	return j.snapshot(), nil
+}
+
+// selectDevices returns the UDIDs of opt without duplicates, in the order
+// they were given or found.
+func (svc *PushService) selectDevices(ctx context.Context, opt BulkPushOption) ([]string, error) {
+	udids := opt.UDIDs
+	if len(opt.Serials) > 0 || opt.Filter != nil {
+		if svc.selector == nil {
+			return nil, errors.New("bulk push: selecting devices by serial or filter is not supported")
+		}
+		selected, err := svc.selector.SelectDevices(ctx, opt.Serials, opt.Filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "bulk push: select devices")
+		}
+		udids = append(append([]string(nil), udids...), selected...)
+	}
+
+	seen := make(map[string]bool, len(udids))
+	var unique []string
+	for _, udid := range udids {
+		if udid == "" || seen[udid] {
+			continue
+		}
+		seen[udid] = true
+		unique = append(unique, udid)
+	}
+	return unique, nil
+}
+
+func (svc *PushService) runBulkPush(j *bulkPushJob, udids []string) {
+	ctx := context.Background()
+	concurrency := svc.bulkConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBulkPushConcurrency
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(udids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				id, err := svc.Push(ctx, udids[i])
+				j.done(i, id, err)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if svc.bulkRate > 0 {
+		// NewTicker panics for rates above one push per nanosecond.
+		interval := time.Second / time.Duration(svc.bulkRate)
+		if interval < time.Nanosecond {
+			interval = time.Nanosecond
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for i := range udids {
+		if tick != nil {
+			<-tick
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	j.finish()
+
+	job := j.snapshot()
+	level.Info(svc.logger).Log("msg", "bulk push finished", "job", job.ID, "sent", job.Sent, "failed", job.Failed)
+}
+
+func (svc *PushService) BulkPushJob(ctx context.Context, id string) (*BulkPushJob, error) {
+	svc.jobsMu.Lock()
+	defer svc.jobsMu.Unlock()
+	for _, j := range svc.jobs {
+		if j.job.ID == id {
+			return j.snapshot(), nil
+		}
+	}
+	return nil, bulkPushJobNotFoundError{id: id}
+}
+
+type bulkPushRequest struct {
+	BulkPushOption
+}
+
+type bulkPushResponse struct {
+	*BulkPushJob
+	Err error `json:"err,omitempty"`
+}
+
+func (r bulkPushResponse) Failed() error { return r.Err }
+
+func decodeBulkPushRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req bulkPushRequest
+	err := httputil.DecodeJSONRequest(r, &req)
+	return req, err
+}
+
+func decodeBulkPushResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp bulkPushResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeBulkPushEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(bulkPushRequest)
+		job, err := svc.BulkPush(ctx, req.BulkPushOption)
+		return bulkPushResponse{BulkPushJob: job, Err: err}, nil
+	}
+}
+
+type bulkPushJobRequest struct {
+	ID string
+}
+
+type bulkPushJobResponse struct {
+	*BulkPushJob
+	Err error `json:"err,omitempty"`
+}
+
+func (r bulkPushJobResponse) Failed() error { return r.Err }
+
+func decodeBulkPushJobRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		return 0, errors.New("apns: bad route")
+	}
+	return bulkPushJobRequest{ID: id}, nil
+}
+
+func decodeBulkPushJobResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp bulkPushJobResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeBulkPushJobEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(bulkPushJobRequest)
+		job, err := svc.BulkPushJob(ctx, req.ID)
+		return bulkPushJobResponse{BulkPushJob: job, Err: err}, nil
+	}
+}
+
+func (mw loggingMiddleware) BulkPush(ctx context.Context, opt BulkPushOption) (job *BulkPushJob, err error) {
+	defer func(begin time.Time) {
+		var total int
+		if job != nil {
+			total = job.Total
+		}
+		_ = mw.logger.Log(
+			"method", "BulkPush",
+			"total", total,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	job, err = mw.next.BulkPush(ctx, opt)
+	return
+}
+
+func (mw loggingMiddleware) BulkPushJob(ctx context.Context, id string) (job *BulkPushJob, err error) {
+	defer func(begin time.Time) {
+		_ = mw.logger.Log(
+			"method", "BulkPushJob",
+			"id", id,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	job, err = mw.next.BulkPushJob(ctx, id)
+	return
+}
@@ -0,0 +1,172 @@
+package apns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RobotsAndPencils/buford/push"
+	"github.com/go-kit/kit/log"
+)
+
+type serialSelector map[string]string
+
+func (s serialSelector) SelectDevices(_ context.Context, serials []string, _ *BulkPushFilter) ([]string, error) {
+	var udids []string
+	for _, serial := range serials {
+		if udid, ok := s[serial]; ok {
+			udids = append(udids, udid)
+		}
+	}
+	return udids, nil
+}
+
+func TestBulkPush(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxSeen {
+			maxSeen = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Header().Set("apns-id", "apns-id")
+	}))
+	defer srv.Close()
+
+	store := &syncStore{memStore: memStore{info: map[string]*PushInfo{}}}
+	for _, udid := range []string{"A", "B", "C", "D"} {
+		store.info[udid] = &PushInfo{UDID: udid, Token: strings.Repeat("ab", 32), PushMagic: "magic"}
+	}
+	svc := &PushService{
+		store:   store,
+		pushsvc: push.NewService(srv.Client(), srv.URL),
+		logger:  log.NewNopLogger(),
+	}
+	WithBulkPush(serialSelector{"SERIAL-C": "C", "SERIAL-D": "D"}, 2, 0)(svc)
+	ctx := context.Background()
+
+	job, err := svc.BulkPush(ctx, BulkPushOption{
+		UDIDs:   []string{"A", "B", "A", "UNKNOWN"},
+		Serials: []string{"SERIAL-C", "SERIAL-D", "SERIAL-X"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := job.Total, 5; have != want {
+		t.Fatalf("have %d devices, want %d", have, want)
+	}
+
+	for job.FinishedAt == nil {
+		time.Sleep(5 * time.Millisecond)
+		if job, err = svc.BulkPushJob(ctx, job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have, want := job.Sent, 4; have != want {
+		t.Errorf("have %d sent, want %d", have, want)
+	}
+	if have, want := job.Failed, 1; have != want {
+		t.Errorf("have %d failed, want %d", have, want)
+	}
+	for _, r := range job.Results {
+		if failed := r.Err != ""; failed != (r.UDID == "UNKNOWN") {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+	if maxSeen > 2 {
+		t.Errorf("have %d pushes in flight, want at most 2", maxSeen)
+	}
+
+	if _, err := svc.BulkPushJob(ctx, "missing"); !isNotFound(err) {
+		t.Errorf("have %v, want a not found error", err)
+	}
+}
+
+func TestBulkPushRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	store := &syncStore{memStore: memStore{info: map[string]*PushInfo{}}}
+	var udids []string
+	for _, udid := range []string{"A", "B", "C", "D", "E"} {
+		store.info[udid] = &PushInfo{UDID: udid, Token: strings.Repeat("ab", 32), PushMagic: "magic"}
+		udids = append(udids, udid)
+	}
+	svc := &PushService{
+		store:   store,
+		pushsvc: push.NewService(srv.Client(), srv.URL),
+		logger:  log.NewNopLogger(),
+	}
+	WithBulkPush(nil, 5, 100)(svc)
+	ctx := context.Background()
+
+	begin := time.Now()
+	job, err := svc.BulkPush(ctx, BulkPushOption{UDIDs: udids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for job.FinishedAt == nil {
+		time.Sleep(5 * time.Millisecond)
+		if job, err = svc.BulkPushJob(ctx, job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// five pushes at 100 per second take at least 50ms.
+	if took := time.Since(begin); took < 50*time.Millisecond {
+		t.Errorf("bulk push took %s, want at least 50ms", took)
+	}
+
+	// a rate above one push per nanosecond doesn't panic.
+	WithBulkPush(nil, 5, 2e9)(svc)
+	job, err = svc.BulkPush(ctx, BulkPushOption{UDIDs: udids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for job.FinishedAt == nil {
+		time.Sleep(5 * time.Millisecond)
+		if job, err = svc.BulkPushJob(ctx, job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := svc.BulkPush(ctx, BulkPushOption{Serials: []string{"SERIAL"}}); err == nil {
+		t.Error("want an error selecting by serial without a selector")
+	}
+}
+
+// syncStore guards memStore for the concurrent pushes of a bulk push.
+type syncStore struct {
+	mu sync.Mutex
+	memStore
+}
+
+func (s *syncStore) PushInfo(ctx context.Context, udid string) (*PushInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.memStore.PushInfo(ctx, udid)
+}
+
+func (s *syncStore) Save(ctx context.Context, info *PushInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.memStore.Save(ctx, info)
+}
+
+func (s *syncStore) SavePushAttempt(ctx context.Context, a *PushAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.memStore.SavePushAttempt(ctx, a)
+}
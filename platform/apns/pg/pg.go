@@ -24,6 +24,8 @@ func columns() []string {
 		"push_magic",
 		"token",
 		"mdm_topic",
+		"invalid",
+		"invalid_reason",
 	}
 }
 
@@ -37,6 +39,8 @@ func (d *Postgres) Save(ctx context.Context, i *apns.PushInfo) error {
 		Set("push_magic", i.PushMagic).
 		Set("token", i.Token).
 		Set("mdm_topic", i.MDMTopic).
+		Set("invalid", i.Invalid).
+		Set("invalid_reason", i.InvalidReason).
 		ToSql()
 	if err != nil {
 		return errors.Wrap(err, "building update query for push_info save")
@@ -51,6 +55,8 @@ func (d *Postgres) Save(ctx context.Context, i *apns.PushInfo) error {
 			i.PushMagic,
 			i.Token,
 			i.MDMTopic,
+			i.Invalid,
+			i.InvalidReason,
 		).
 		Suffix(updateQuery).
 		ToSql()
@@ -80,6 +86,65 @@ func (d *Postgres) PushInfo(ctx context.Context, udid string) (*apns.PushInfo, e
 	return &i, errors.Wrap(err, "finding push_info by udid")
 }
 
+const historyTableName = "push_history"
+
+func (d *Postgres) SavePushAttempt(ctx context.Context, a *apns.PushAttempt) error {
+	query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Insert(historyTableName).
+		Columns("udid", "pushed_at", "apns_id", "status", "reason").
+		Values(a.UDID, a.PushedAt, a.APNsID, a.Status, a.Reason).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building push_history save query")
+	}
+	if _, err := d.db.ExecContext(ctx, query, args...); err != nil {
+		return errors.Wrap(err, "exec push_history save in pg")
+	}
+
+	// keep the newest attempts of the device. The subquery keeps ?
+	// placeholders, which are numbered with the ones of the outer query.
+	newest := sq.
+		Select("id").
+		From(historyTableName).
+		Where(sq.Eq{"udid": a.UDID}).
+		OrderBy("id DESC").
+		Limit(apns.MaxPushHistory)
+	newestQuery, newestArgs, err := newest.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	query, args, err = sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Delete(historyTableName).
+		Where(sq.Eq{"udid": a.UDID}).
+		Where("id NOT IN ("+newestQuery+")", newestArgs...).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building push_history trim query")
+	}
+	_, err = d.db.ExecContext(ctx, query, args...)
+	return errors.Wrap(err, "exec push_history trim in pg")
+}
+
+func (d *Postgres) PushHistory(ctx context.Context, udid string, limit int) ([]apns.PushAttempt, error) {
+	if limit <= 0 {
+		limit = apns.MaxPushHistory
+	}
+	query, args, err := sq.StatementBuilder.PlaceholderFormat(sq.Dollar).
+		Select("udid", "pushed_at", "apns_id", "status", "reason").
+		From(historyTableName).
+		Where(sq.Eq{"udid": udid}).
+		OrderBy("id DESC").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+
+	var attempts []apns.PushAttempt
+	err = d.db.SelectContext(ctx, &attempts, query, args...)
+	return attempts, errors.Wrap(err, "finding push_history by udid")
+}
+
 type pushInfoNotFoundErr struct{}
 
 func (e pushInfoNotFoundErr) Error() string  { return "push_info not found" }
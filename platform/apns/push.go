@@ -50,10 +50,20 @@ func (svc *PushService) Push(ctx context.Context, deviceUDID string, opts ...Pus
 		return "", errors.Wrap(err, "retrieving PushInfo by UDID")
 	}
 
+	// APNs rejected the token before. Pushing again won't work until the
+	// device sends a new token.
+	if info.Invalid {
+		err := fmt.Errorf("invalid push token: %s", info.InvalidReason)
+		svc.record(ctx, info, "", err)
+		return "", err
+	}
+
 	p := payload.MDM{Token: info.PushMagic}
 	valid := push.IsDeviceTokenValid(info.Token)
 	if !valid {
-		return "", errors.New("invalid push token")
+		err := errors.New("invalid push token")
+		svc.record(ctx, info, "", err)
+		return "", err
 	}
 	jsonPayload, err := json.Marshal(p)
 	if err != nil {
@@ -61,6 +71,7 @@ func (svc *PushService) Push(ctx context.Context, deviceUDID string, opts ...Pus
 	}
 
 	result, err := svc.pushsvc.Push(info.Token, headers, jsonPayload)
+	svc.record(ctx, info, result, err)
 	if err != nil && strings.HasSuffix(err.Error(), "remote error: tls: internal error") {
 		// TODO: yuck, error substring searching. see:
 		// https://github.com/micromdm/micromdm/issues/150
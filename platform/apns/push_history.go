@@ -0,0 +1,216 @@
+package apns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RobotsAndPencils/buford/push"
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+	"github.com/micromdm/micromdm/platform/apns/internal/pushproto"
+)
+
+// PushInvalidTopic is published with the PushAttempt when APNs rejects the
+// push token of a device as unregistered or bad.
+const PushInvalidTopic = "mdm.PushInvalid"
+
+// MaxPushHistory is how many push attempts are kept for every device.
+const MaxPushHistory = 100
+
+// PushAttempt is the result of a push notification to a device or user.
+type PushAttempt struct {
+	UDID     string    `json:"udid" db:"udid"`
+	PushedAt time.Time `json:"pushed_at" db:"pushed_at"`
+	// APNsID is the apns-id of a push accepted by APNs.
+	APNsID string `json:"apns_id,omitempty" db:"apns_id"`
+	// Status is the HTTP status of the APNs response, or 0 if the push
+	// wasn't sent or APNs didn't respond.
+	Status int `json:"status" db:"status"`
+	// Reason is the reason APNs rejected the push, like Unregistered, or
+	// the error which prevented the push.
+	Reason string `json:"reason,omitempty" db:"reason"`
+}
+
+// PushHistory is the recent push attempts of a device, newest first.
+type PushHistory struct {
+	UDID          string        `json:"udid"`
+	Invalid       bool          `json:"invalid"`
+	InvalidReason string        `json:"invalid_reason,omitempty"`
+	Attempts      []PushAttempt `json:"attempts"`
+}
+
+func (svc *PushService) PushHistory(ctx context.Context, udid string, limit int) (*PushHistory, error) {
+	history := &PushHistory{UDID: udid}
+	info, err := svc.store.PushInfo(ctx, udid)
+	if err != nil && !isNotFound(err) {
+		return nil, errors.Wrap(err, "retrieving PushInfo by UDID")
+	}
+	if info != nil {
+		history.Invalid = info.Invalid
+		history.InvalidReason = info.InvalidReason
+	}
+	history.Attempts, err = svc.store.PushHistory(ctx, udid, limit)
+	return history, errors.Wrap(err, "retrieving push history")
+}
+
+// record saves the push attempt with the result of the push, and marks the
+// push info invalid if APNs rejected the token. The push went out either
+// way, so errors are only logged.
+func (svc *PushService) record(ctx context.Context, info *PushInfo, id string, pushErr error) {
+	attempt := &PushAttempt{
+		UDID:     info.UDID,
+		PushedAt: time.Now().UTC(),
+		APNsID:   id,
+	}
+	var invalid bool
+	switch e := errors.Cause(pushErr).(type) {
+	case nil:
+		attempt.Status = http.StatusOK
+	case *push.Error:
+		attempt.Status = e.Status
+		attempt.Reason = e.Reason.Error()
+		invalid = e.Reason == push.ErrUnregistered || e.Reason == push.ErrBadDeviceToken
+	default:
+		attempt.Reason = pushErr.Error()
+	}
+
+	if err := svc.store.SavePushAttempt(ctx, attempt); err != nil {
+		level.Info(svc.logger).Log("msg", "save push attempt", "udid", info.UDID, "err", err)
+	}
+	if invalid {
+		if err := svc.invalidate(ctx, info, attempt); err != nil {
+			level.Info(svc.logger).Log("msg", "mark push info invalid", "udid", info.UDID, "err", err)
+		}
+	}
+}
+
+// invalidate marks the push info invalid, unless the device sent a new
+// token in the meantime.
+func (svc *PushService) invalidate(ctx context.Context, info *PushInfo, attempt *PushAttempt) error {
+	current, err := svc.store.PushInfo(ctx, info.UDID)
+	if err != nil {
+		return err
+	}
+	if current.Token != info.Token {
+		return nil
+	}
+	current.Invalid = true
+	current.InvalidReason = attempt.Reason
+	if err := svc.store.Save(ctx, current); err != nil {
+		return err
+	}
+	if svc.pub == nil {
+		return nil
+	}
+	message, err := MarshalPushAttempt(attempt)
+	if err != nil {
+		return err
+	}
+	return svc.pub.Publish(ctx, PushInvalidTopic, message)
+}
+
+func MarshalPushAttempt(a *PushAttempt) ([]byte, error) {
+	return proto.Marshal(&pushproto.PushAttempt{
+		Udid:     a.UDID,
+		PushedAt: timeToNano(a.PushedAt),
+		ApnsId:   a.APNsID,
+		Status:   int32(a.Status),
+		Reason:   a.Reason,
+	})
+}
+
+func UnmarshalPushAttempt(data []byte, a *PushAttempt) error {
+	var pb pushproto.PushAttempt
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to PushAttempt")
+	}
+	a.UDID = pb.GetUdid()
+	a.PushedAt = timeFromNano(pb.GetPushedAt())
+	a.APNsID = pb.GetApnsId()
+	a.Status = int(pb.GetStatus())
+	a.Reason = pb.GetReason()
+	return nil
+}
+
+func timeToNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func timeFromNano(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano).UTC()
+}
+
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(interface{ NotFound() bool })
+	return ok && e.NotFound()
+}
+
+type pushHistoryRequest struct {
+	UDID  string
+	Limit int
+}
+
+type pushHistoryResponse struct {
+	*PushHistory
+	Err error `json:"err,omitempty"`
+}
+
+func (r pushHistoryResponse) Failed() error { return r.Err }
+
+func decodePushHistoryRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	udid, ok := mux.Vars(r)["udid"]
+	if !ok {
+		return 0, errors.New("apns: bad route")
+	}
+	req := pushHistoryRequest{UDID: udid}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing limit: %s", err)
+		}
+		req.Limit = limit
+	}
+	return req, nil
+}
+
+func decodePushHistoryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp pushHistoryResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakePushHistoryEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(pushHistoryRequest)
+		history, err := svc.PushHistory(ctx, req.UDID, req.Limit)
+		return pushHistoryResponse{PushHistory: history, Err: err}, nil
+	}
+}
+
+func (mw loggingMiddleware) PushHistory(ctx context.Context, udid string, limit int) (history *PushHistory, err error) {
+	defer func(begin time.Time) {
+		_ = mw.logger.Log(
+			"method", "PushHistory",
+			"udid", udid,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	history, err = mw.next.PushHistory(ctx, udid, limit)
+	return
+}
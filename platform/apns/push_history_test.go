@@ -0,0 +1,143 @@
+package apns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RobotsAndPencils/buford/push"
+	"github.com/go-kit/kit/log"
+)
+
+func TestPushHistory(t *testing.T) {
+	var (
+		requests int
+		status   = http.StatusOK
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"reason": "Unregistered", "timestamp": 1600000000000}`)
+			return
+		}
+		w.Header().Set("apns-id", "apns-id-1")
+	}))
+	defer srv.Close()
+
+	store := &memStore{info: map[string]*PushInfo{
+		"UDID": {UDID: "UDID", Token: strings.Repeat("ab", 32), PushMagic: "magic"},
+	}}
+	pub := new(recordingPublisher)
+	svc := &PushService{
+		store:   store,
+		pushsvc: push.NewService(srv.Client(), srv.URL),
+		logger:  log.NewNopLogger(),
+		pub:     pub,
+	}
+	ctx := context.Background()
+
+	id, err := svc.Push(ctx, "UDID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := id, "apns-id-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	status = http.StatusGone
+	if _, err := svc.Push(ctx, "UDID"); err == nil {
+		t.Fatal("want an error for an unregistered token")
+	}
+	if !store.info["UDID"].Invalid {
+		t.Error("push info not marked invalid")
+	}
+	if have, want := len(pub.messages), 1; have != want {
+		t.Fatalf("have %d published messages, want %d", have, want)
+	}
+	var published PushAttempt
+	if err := UnmarshalPushAttempt(pub.messages[0], &published); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := published.UDID, "UDID"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	// the invalid token is not pushed again.
+	if _, err := svc.Push(ctx, "UDID"); err == nil {
+		t.Fatal("want an error for an invalid token")
+	}
+	if have, want := requests, 2; have != want {
+		t.Errorf("have %d requests to APNs, want %d", have, want)
+	}
+
+	history, err := svc.PushHistory(ctx, "UDID", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !history.Invalid || history.InvalidReason != "Unregistered" {
+		t.Errorf("have invalid %v with reason %q, want invalid with reason Unregistered", history.Invalid, history.InvalidReason)
+	}
+	if have, want := len(history.Attempts), 3; have != want {
+		t.Fatalf("have %d attempts, want %d", have, want)
+	}
+	// newest first
+	for i, want := range []PushAttempt{
+		{Reason: "invalid push token: Unregistered"},
+		{Status: http.StatusGone, Reason: "Unregistered"},
+		{Status: http.StatusOK, APNsID: "apns-id-1"},
+	} {
+		have := history.Attempts[i]
+		if have.Status != want.Status || have.Reason != want.Reason || have.APNsID != want.APNsID {
+			t.Errorf("attempt %d: have %+v, want %+v", i, have, want)
+		}
+	}
+}
+
+type memStore struct {
+	info     map[string]*PushInfo
+	attempts []PushAttempt
+}
+
+func (s *memStore) PushInfo(_ context.Context, udid string) (*PushInfo, error) {
+	info, ok := s.info[udid]
+	if !ok {
+		return nil, fmt.Errorf("push info for %s not found", udid)
+	}
+	c := *info
+	return &c, nil
+}
+
+func (s *memStore) Save(_ context.Context, info *PushInfo) error {
+	s.info[info.UDID] = info
+	return nil
+}
+
+func (s *memStore) SavePushAttempt(_ context.Context, a *PushAttempt) error {
+	s.attempts = append(s.attempts, *a)
+	return nil
+}
+
+func (s *memStore) PushHistory(_ context.Context, udid string, limit int) ([]PushAttempt, error) {
+	var attempts []PushAttempt
+	for i := len(s.attempts) - 1; i >= 0; i-- {
+		if s.attempts[i].UDID == udid && (limit <= 0 || len(attempts) < limit) {
+			attempts = append(attempts, s.attempts[i])
+		}
+	}
+	return attempts, nil
+}
+
+type recordingPublisher struct {
+	messages [][]byte
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, topic string, msg []byte) error {
+	if topic == PushInvalidTopic {
+		p.messages = append(p.messages, msg)
+	}
+	return nil
+}
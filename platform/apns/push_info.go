@@ -12,14 +12,20 @@ type PushInfo struct {
 	PushMagic string `db:"push_magic"`
 	Token     string `db:"token"`
 	MDMTopic  string `db:"mdm_topic"`
+	// Invalid is set when APNs rejects the token as unregistered or bad.
+	// Pushes are not sent until the device sends a new token.
+	Invalid       bool   `db:"invalid"`
+	InvalidReason string `db:"invalid_reason"`
 }
 
 func MarshalPushInfo(p *PushInfo) ([]byte, error) {
 	protopush := pushproto.PushInfo{
-		Udid:      p.UDID,
-		PushMagic: p.PushMagic,
-		Token:     p.Token,
-		MdmTopic:  p.MDMTopic,
+		Udid:          p.UDID,
+		PushMagic:     p.PushMagic,
+		Token:         p.Token,
+		MdmTopic:      p.MDMTopic,
+		Invalid:       p.Invalid,
+		InvalidReason: p.InvalidReason,
 	}
 	return proto.Marshal(&protopush)
 }
@@ -33,5 +39,7 @@ func UnmarshalPushInfo(data []byte, p *PushInfo) error {
 	p.Token = pb.GetToken()
 	p.PushMagic = pb.GetPushMagic()
 	p.MDMTopic = pb.GetMdmTopic()
+	p.Invalid = pb.GetInvalid()
+	p.InvalidReason = pb.GetInvalidReason()
 	return nil
 }
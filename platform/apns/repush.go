@@ -0,0 +1,171 @@
+package apns
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/pubsub"
+	"github.com/micromdm/micromdm/platform/queue"
+)
+
+// Defaults of the RepushWorker backoff, and the shortest interval it accepts.
+const (
+	DefaultRepushInterval    = 5 * time.Minute
+	DefaultRepushMaxInterval = 4 * time.Hour
+	MinRepushInterval        = time.Second
+)
+
+// CommandQueue reports whether a device has queued commands it hasn't
+// fetched yet.
+type CommandQueue interface {
+	HasUnfetchedCommands(ctx context.Context, udid string) (bool, error)
+}
+
+// RepushWorker sends the push notification again to devices which didn't
+// connect to fetch their queued commands. Without an expiration, APNs tries
+// to deliver a push notification only once.
+//
+// The first push is sent again interval after the command was queued. The
+// delay doubles after every push up to maxInterval, which is the last one.
+// Every repush expires when the next one is due, so APNs stores it for an
+// offline device in the meantime. A device which connects, or whose commands
+// were all fetched, isn't pushed again. The pending pushes are only kept in
+// memory.
+type RepushWorker struct {
+	push        Service
+	queue       CommandQueue
+	sub         pubsub.Subscriber
+	logger      log.Logger
+	interval    time.Duration
+	maxInterval time.Duration
+
+	pending map[string]*repush
+}
+
+type repush struct {
+	next  time.Time
+	delay time.Duration
+}
+
+// NewRepushWorker returns a worker which repushes after interval, at least
+// MinRepushInterval.
+func NewRepushWorker(push Service, q CommandQueue, sub pubsub.Subscriber, interval, maxInterval time.Duration, logger log.Logger) *RepushWorker {
+	if interval < MinRepushInterval {
+		interval = MinRepushInterval
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+	return &RepushWorker{
+		push:        push,
+		queue:       q,
+		sub:         sub,
+		logger:      logger,
+		interval:    interval,
+		maxInterval: maxInterval,
+		pending:     make(map[string]*repush),
+	}
+}
+
+func (w *RepushWorker) Run(ctx context.Context) error {
+	const subscription = "repush_worker"
+	queuedEvents, err := w.sub.Subscribe(ctx, subscription, queue.CommandQueuedTopic)
+	if err != nil {
+		return errors.Wrapf(err,
+			"subscribing %s to %s topic", subscription, queue.CommandQueuedTopic)
+	}
+	connectEvents, err := w.sub.Subscribe(ctx, subscription, mdm.ConnectTopic)
+	if err != nil {
+		return errors.Wrapf(err,
+			"subscribing %s to %s topic", subscription, mdm.ConnectTopic)
+	}
+
+	ticker := time.NewTicker(w.interval / 4)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-queuedEvents:
+			err = w.queued(event.Message, time.Now())
+		case event := <-connectEvents:
+			err = w.connected(event.Message)
+		case now := <-ticker.C:
+			w.repushDue(ctx, now)
+		}
+		if err != nil {
+			level.Info(w.logger).Log(
+				"msg", "track queued commands for repush",
+				"err", err,
+			)
+			continue
+		}
+	}
+}
+
+// queued schedules the first repush of the device. A newly queued command
+// was pushed, which restarts the backoff.
+func (w *RepushWorker) queued(message []byte, now time.Time) error {
+	cq, err := queue.UnmarshalQueuedCommand(message)
+	if err != nil {
+		return errors.Wrap(err, "unmarshal queued command event")
+	}
+	w.pending[cq.DeviceUDID] = &repush{next: now.Add(w.interval), delay: w.interval}
+	return nil
+}
+
+func (w *RepushWorker) connected(message []byte) error {
+	var ev mdm.AcknowledgeEvent
+	if err := mdm.UnmarshalAcknowledgeEvent(message, &ev); err != nil {
+		return errors.Wrap(err, "unmarshal connect event")
+	}
+	// the queue is keyed by the same ID as the push info.
+	udid := ev.Response.UDID
+	if ev.Response.UserID != nil {
+		udid = *ev.Response.UserID
+	}
+	if ev.Response.EnrollmentID != nil {
+		udid = *ev.Response.EnrollmentID
+	}
+	delete(w.pending, udid)
+	return nil
+}
+
+func (w *RepushWorker) repushDue(ctx context.Context, now time.Time) {
+	for udid, r := range w.pending {
+		if now.Before(r.next) {
+			continue
+		}
+		unfetched, err := w.queue.HasUnfetchedCommands(ctx, udid)
+		if err != nil {
+			level.Info(w.logger).Log("msg", "check unfetched commands", "udid", udid, "err", err)
+			continue
+		}
+		if !unfetched {
+			delete(w.pending, udid)
+			continue
+		}
+
+		last := r.delay >= w.maxInterval
+		r.delay *= 2
+		if r.delay > w.maxInterval {
+			r.delay = w.maxInterval
+		}
+		// APNs stores the push for an offline device until the next one.
+		if _, err := w.push.Push(ctx, udid, WithExpiration(now.Add(r.delay))); err != nil {
+			level.Info(w.logger).Log("msg", "repush device", "udid", udid, "err", err)
+		}
+		if last {
+			level.Info(w.logger).Log("msg", "device did not fetch queued commands, giving up repush", "udid", udid)
+			delete(w.pending, udid)
+			continue
+		}
+		r.next = now.Add(r.delay)
+	}
+}
@@ -0,0 +1,94 @@
+package apns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/queue"
+)
+
+type unfetchedQueue map[string]bool
+
+func (q unfetchedQueue) HasUnfetchedCommands(_ context.Context, udid string) (bool, error) {
+	return q[udid], nil
+}
+
+type recordingPusher struct {
+	Service
+	pushes []string
+}
+
+func (p *recordingPusher) Push(_ context.Context, udid string, _ ...PushOption) (string, error) {
+	p.pushes = append(p.pushes, udid)
+	return "", nil
+}
+
+func TestRepushWorker(t *testing.T) {
+	pusher := new(recordingPusher)
+	q := unfetchedQueue{"A": true, "B": true, "C": false}
+	w := NewRepushWorker(pusher, q, nil, time.Minute, 4*time.Minute, log.NewNopLogger())
+	ctx := context.Background()
+	start := time.Now()
+
+	for _, udid := range []string{"A", "B", "C"} {
+		msg, err := queue.MarshalQueuedCommand(&queue.QueueCommandQueued{DeviceUDID: udid, CommandUUID: "cmd"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.queued(msg, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, err := mdm.MarshalAcknowledgeEvent(&mdm.AcknowledgeEvent{Response: mdm.Response{UDID: "B", Status: "Idle"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.connected(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A is pushed again after 1, 1+2 and 1+2+4 minutes, then given up. B
+	// connected and C fetched its commands.
+	for _, tt := range []struct {
+		after  time.Duration
+		pushes int
+	}{
+		{30 * time.Second, 0},
+		{time.Minute, 1},
+		{2 * time.Minute, 1},
+		{3 * time.Minute, 2},
+		{6 * time.Minute, 2},
+		{7 * time.Minute, 3},
+		{time.Hour, 3},
+	} {
+		w.repushDue(ctx, start.Add(tt.after))
+		if have, want := len(pusher.pushes), tt.pushes; have != want {
+			t.Errorf("after %s: have %d pushes, want %d", tt.after, have, want)
+		}
+	}
+	for _, udid := range pusher.pushes {
+		if udid != "A" {
+			t.Errorf("pushed %s, want only A", udid)
+		}
+	}
+	if have, want := len(w.pending), 0; have != want {
+		t.Errorf("have %d pending repushes, want %d", have, want)
+	}
+}
+
+func TestRepushWorkerMinInterval(t *testing.T) {
+	w := NewRepushWorker(new(recordingPusher), unfetchedQueue{}, nil, 2*time.Nanosecond, 2*time.Nanosecond, log.NewNopLogger())
+	if have, want := w.interval, MinRepushInterval; have != want {
+		t.Errorf("have interval %s, want %s", have, want)
+	}
+	if w.maxInterval < w.interval {
+		t.Errorf("have max interval %s below interval %s", w.maxInterval, w.interval)
+	}
+	// the tick of Run must be positive.
+	time.NewTicker(w.interval / 4).Stop()
+}
@@ -9,18 +9,27 @@ import (
 )
 
 type Endpoints struct {
-	PushEndpoint endpoint.Endpoint
+	PushEndpoint        endpoint.Endpoint
+	PushHistoryEndpoint endpoint.Endpoint
+	BulkPushEndpoint    endpoint.Endpoint
+	BulkPushJobEndpoint endpoint.Endpoint
 }
 
 func MakeServerEndpoints(s Service, outer endpoint.Middleware, others ...endpoint.Middleware) Endpoints {
 	return Endpoints{
-		PushEndpoint: endpoint.Chain(outer, others...)(MakePushEndpoint(s)),
+		PushEndpoint:        endpoint.Chain(outer, others...)(MakePushEndpoint(s)),
+		PushHistoryEndpoint: endpoint.Chain(outer, others...)(MakePushHistoryEndpoint(s)),
+		BulkPushEndpoint:    endpoint.Chain(outer, others...)(MakeBulkPushEndpoint(s)),
+		BulkPushJobEndpoint: endpoint.Chain(outer, others...)(MakeBulkPushJobEndpoint(s)),
 	}
 }
 
 func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.ServerOption) {
 	// GET    /push/:udid		create an APNS Push notification for a managed device or user(deprecated)
 	// POST   /v1/push/:udid	create an APNS Push notification for a managed device or user
+	// GET    /v1/push/:udid/history	get the recent push notifications of a managed device or user
+	// POST   /v1/push			start a push to many devices selected by UDID, serial or filter
+	// GET    /v1/push/jobs/:id		get the progress and results of a bulk push
 
 	r.Methods("GET").Path("/push/{udid}").Handler(httptransport.NewServer(
 		e.PushEndpoint,
@@ -35,4 +44,25 @@ func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.S
 		httputil.EncodeJSONResponse,
 		options...,
 	))
+
+	r.Methods("GET").Path("/v1/push/{udid}/history").Handler(httptransport.NewServer(
+		e.PushHistoryEndpoint,
+		decodePushHistoryRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/push").Handler(httptransport.NewServer(
+		e.BulkPushEndpoint,
+		decodeBulkPushRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/v1/push/jobs/{id}").Handler(httptransport.NewServer(
+		e.BulkPushJobEndpoint,
+		decodeBulkPushJobRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/RobotsAndPencils/buford/push"
+	kitlog "github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 	"golang.org/x/net/http2"
 
@@ -20,19 +21,39 @@ import (
 
 type Service interface {
 	Push(ctx context.Context, udid string, opts ...PushOption) (string, error)
+	PushHistory(ctx context.Context, udid string, limit int) (*PushHistory, error)
+	BulkPush(ctx context.Context, opt BulkPushOption) (*BulkPushJob, error)
+	BulkPushJob(ctx context.Context, id string) (*BulkPushJob, error)
 }
 
 type Store interface {
 	PushInfo(ctx context.Context, udid string) (*PushInfo, error)
+	Save(ctx context.Context, info *PushInfo) error
+
+	// SavePushAttempt adds the attempt to the push history of the device,
+	// keeping the last MaxPushHistory attempts.
+	SavePushAttempt(ctx context.Context, attempt *PushAttempt) error
+	// PushHistory returns up to limit attempts, newest first. A limit of 0
+	// returns all the kept attempts.
+	PushHistory(ctx context.Context, udid string, limit int) ([]PushAttempt, error)
 }
 
 type PushService struct {
 	store    Store
 	start    chan struct{}
 	provider PushCertificateProvider
+	pub      pubsub.Publisher
+	logger   kitlog.Logger
+
+	selector        DeviceSelector
+	bulkConcurrency int
+	bulkRate        int
 
 	mu      sync.RWMutex
 	pushsvc *push.Service
+
+	jobsMu sync.Mutex
+	jobs   []*bulkPushJob
 }
 
 type PushCertificateProvider interface {
@@ -47,11 +68,43 @@ func WithPushService(svc *push.Service) Option {
 	}
 }
 
+// WithPublisher publishes the push attempts which APNs rejected because of an
+// invalid token to PushInvalidTopic.
+func WithPublisher(pub pubsub.Publisher) Option {
+	return func(p *PushService) {
+		p.pub = pub
+	}
+}
+
+// WithLogger logs the errors of recording push attempts, which don't fail the
+// push, and the results of bulk pushes.
+func WithLogger(logger kitlog.Logger) Option {
+	return func(p *PushService) {
+		p.logger = logger
+	}
+}
+
+// WithBulkPush configures the fan-out of bulk pushes. selector looks up the
+// devices selected by serial or filter, and may be nil to only accept UDIDs.
+// At most concurrency pushes are in flight, at a rate of at most rate pushes
+// per second. A rate of 0 doesn't limit the rate.
+func WithBulkPush(selector DeviceSelector, concurrency, rate int) Option {
+	return func(p *PushService) {
+		p.selector = selector
+		p.bulkConcurrency = concurrency
+		p.bulkRate = rate
+	}
+}
+
 func New(db Store, provider PushCertificateProvider, sub pubsub.Subscriber, opts ...Option) (*PushService, error) {
 	pushSvc := PushService{
 		store:    db,
 		provider: provider,
 		start:    make(chan struct{}),
+		logger:   kitlog.NewNopLogger(),
+
+		bulkConcurrency: DefaultBulkPushConcurrency,
+		bulkRate:        DefaultBulkPushRate,
 	}
 	for _, opt := range opts {
 		opt(&pushSvc)
@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/apns"
+)
+
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func columns() []string {
+	return []string{
+		"udid",
+		"push_magic",
+		"token",
+		"mdm_topic",
+		"invalid",
+		"invalid_reason",
+	}
+}
+
+const tableName = "push_info"
+
+func (d *SQLite) Save(ctx context.Context, i *apns.PushInfo) error {
+	query, args, err := sq.
+		Insert(tableName).
+		Options("OR REPLACE").
+		Columns(columns()...).
+		Values(
+			i.UDID,
+			i.PushMagic,
+			i.Token,
+			i.MDMTopic,
+			i.Invalid,
+			i.InvalidReason,
+		).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building push_info save query")
+	}
+
+	_, err = d.db.ExecContext(ctx, query, args...)
+	return errors.Wrap(err, "exec push_info save in sqlite")
+}
+
+func (d *SQLite) PushInfo(ctx context.Context, udid string) (*apns.PushInfo, error) {
+	query, args, err := sq.
+		Select(columns()...).
+		From(tableName).
+		Where(sq.Eq{"udid": udid}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+
+	var i apns.PushInfo
+	err = d.db.QueryRowxContext(ctx, query, args...).StructScan(&i)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, pushInfoNotFoundErr{}
+	}
+	return &i, errors.Wrap(err, "finding push_info by udid")
+}
+
+const historyTableName = "push_history"
+
+func (d *SQLite) SavePushAttempt(ctx context.Context, a *apns.PushAttempt) error {
+	query, args, err := sq.
+		Insert(historyTableName).
+		Columns("udid", "pushed_at", "apns_id", "status", "reason").
+		Values(a.UDID, a.PushedAt, a.APNsID, a.Status, a.Reason).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building push_history save query")
+	}
+	if _, err := d.db.ExecContext(ctx, query, args...); err != nil {
+		return errors.Wrap(err, "exec push_history save in sqlite")
+	}
+
+	// keep the newest attempts of the device.
+	newest := sq.
+		Select("id").
+		From(historyTableName).
+		Where(sq.Eq{"udid": a.UDID}).
+		OrderBy("id DESC").
+		Limit(apns.MaxPushHistory)
+	newestQuery, newestArgs, err := newest.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	query, args, err = sq.
+		Delete(historyTableName).
+		Where(sq.Eq{"udid": a.UDID}).
+		Where("id NOT IN ("+newestQuery+")", newestArgs...).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building push_history trim query")
+	}
+	_, err = d.db.ExecContext(ctx, query, args...)
+	return errors.Wrap(err, "exec push_history trim in sqlite")
+}
+
+func (d *SQLite) PushHistory(ctx context.Context, udid string, limit int) ([]apns.PushAttempt, error) {
+	if limit <= 0 {
+		limit = apns.MaxPushHistory
+	}
+	query, args, err := sq.
+		Select("udid", "pushed_at", "apns_id", "status", "reason").
+		From(historyTableName).
+		Where(sq.Eq{"udid": udid}).
+		OrderBy("id DESC").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+
+	var attempts []apns.PushAttempt
+	err = d.db.SelectContext(ctx, &attempts, query, args...)
+	return attempts, errors.Wrap(err, "finding push_history by udid")
+}
+
+type pushInfoNotFoundErr struct{}
+
+func (e pushInfoNotFoundErr) Error() string  { return "push_info not found" }
+func (e pushInfoNotFoundErr) NotFound() bool { return true }
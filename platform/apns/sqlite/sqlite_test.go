@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/apns"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+func TestPushHistory(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+
+	start := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < apns.MaxPushHistory+5; i++ {
+		for _, udid := range []string{"UDID-1", "UDID-2"} {
+			attempt := &apns.PushAttempt{
+				UDID:     udid,
+				PushedAt: start.Add(time.Duration(i) * time.Second),
+				Status:   200,
+			}
+			if err := db.SavePushAttempt(ctx, attempt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	attempts, err := db.PushHistory(ctx, "UDID-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(attempts), apns.MaxPushHistory; have != want {
+		t.Fatalf("have %d attempts, want %d", have, want)
+	}
+	if have, want := attempts[0].PushedAt, start.Add((apns.MaxPushHistory+4)*time.Second); !have.Equal(want) {
+		t.Errorf("have newest attempt at %s, want %s", have, want)
+	}
+
+	attempts, err = db.PushHistory(ctx, "UDID-2", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(attempts), 3; have != want {
+		t.Errorf("have %d attempts, want %d", have, want)
+	}
+}
+
+func TestPushInfoInvalid(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+
+	info := &apns.PushInfo{UDID: "UDID", Token: "token", Invalid: true, InvalidReason: "Unregistered"}
+	if err := db.Save(ctx, info); err != nil {
+		t.Fatal(err)
+	}
+	found, err := db.PushInfo(ctx, "UDID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *found != *info {
+		t.Errorf("have %+v, want %+v", found, info)
+	}
+}
+
+func setup(t *testing.T) *SQLite {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db)
+}
@@ -26,6 +26,23 @@ type Blueprint struct {
 	SkipPrimarySetupAccountCreation     bool     `json:"skip_primary_setup_account_creation"`
 	SetPrimarySetupAccountAsRegularUser bool     `json:"set_primary_setup_account_as_regular_user"`
 	ApplyAt                             []string `json:"apply_at"`
+	// Groups limits the Blueprint to devices which enroll in one of the
+	// enrollment groups. A Blueprint without Groups applies to all devices.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// AppliesToGroup reports whether the Blueprint applies to a device in the
+// enrollment group.
+func (bp *Blueprint) AppliesToGroup(group string) bool {
+	if len(bp.Groups) == 0 {
+		return true
+	}
+	for _, g := range bp.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
 }
 
 func (bp *Blueprint) Verify() error {
@@ -45,6 +62,7 @@ func MarshalBlueprint(bp *Blueprint) ([]byte, error) {
 		SkipPrimarySetupAccountCreation:     bp.SkipPrimarySetupAccountCreation,
 		SetPrimarySetupAccountAsRegularUser: bp.SetPrimarySetupAccountAsRegularUser,
 		ApplyAt:                             bp.ApplyAt,
+		Groups:                              bp.Groups,
 	}
 	return proto.Marshal(&protobp)
 }
@@ -62,5 +80,6 @@ func UnmarshalBlueprint(data []byte, bp *Blueprint) error {
 	bp.UserUUID = pb.GetUserUuid()
 	bp.SkipPrimarySetupAccountCreation = pb.GetSkipPrimarySetupAccountCreation()
 	bp.SetPrimarySetupAccountAsRegularUser = pb.GetSetPrimarySetupAccountAsRegularUser()
+	bp.Groups = pb.GetGroups()
 	return nil
 }
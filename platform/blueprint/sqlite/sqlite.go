@@ -0,0 +1,245 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/blueprint"
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+type SQLite struct {
+	db     *sqlx.DB
+	profDB profile.Store
+}
+
+func New(db *sqlx.DB, profDB profile.Store) *SQLite {
+	return &SQLite{db: db, profDB: profDB}
+}
+
+func columns() []string {
+	return []string{
+		"uuid",
+		"name",
+		"install_application_manifest_urls",
+		"profile_ids",
+		"user_uuids",
+		"skip_primary_setup_account_creation",
+		"set_primary_setup_account_as_regular_user",
+		"apply_at",
+		"enrollment_groups",
+	}
+}
+
+const tableName = "blueprints"
+
+// blueprintRow is the table representation of a blueprint.
+// List fields are stored as JSON arrays.
+type blueprintRow struct {
+	UUID                                string `db:"uuid"`
+	Name                                string `db:"name"`
+	ApplicationURLs                     string `db:"install_application_manifest_urls"`
+	ProfileIdentifiers                  string `db:"profile_ids"`
+	UserUUID                            string `db:"user_uuids"`
+	SkipPrimarySetupAccountCreation     bool   `db:"skip_primary_setup_account_creation"`
+	SetPrimarySetupAccountAsRegularUser bool   `db:"set_primary_setup_account_as_regular_user"`
+	ApplyAt                             string `db:"apply_at"`
+	Groups                              string `db:"enrollment_groups"`
+}
+
+func (r *blueprintRow) blueprint() (*blueprint.Blueprint, error) {
+	bp := &blueprint.Blueprint{
+		UUID:                                r.UUID,
+		Name:                                r.Name,
+		SkipPrimarySetupAccountCreation:     r.SkipPrimarySetupAccountCreation,
+		SetPrimarySetupAccountAsRegularUser: r.SetPrimarySetupAccountAsRegularUser,
+	}
+	lists := []struct {
+		data string
+		dst  *[]string
+	}{
+		{r.ApplicationURLs, &bp.ApplicationURLs},
+		{r.ProfileIdentifiers, &bp.ProfileIdentifiers},
+		{r.UserUUID, &bp.UserUUID},
+		{r.ApplyAt, &bp.ApplyAt},
+		{r.Groups, &bp.Groups},
+	}
+	for _, l := range lists {
+		if l.data == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(l.data), l.dst); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal blueprint %s", r.Name)
+		}
+	}
+	return bp, nil
+}
+
+func marshalList(l []string) (string, error) {
+	if l == nil {
+		l = []string{}
+	}
+	data, err := json.Marshal(l)
+	return string(data), err
+}
+
+func (d *SQLite) List() ([]blueprint.Blueprint, error) {
+	return d.list(context.TODO())
+}
+
+func (d *SQLite) list(ctx context.Context) ([]blueprint.Blueprint, error) {
+	query, args, err := sq.
+		Select(columns()...).
+		From(tableName).
+		OrderBy("name").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var rows []blueprintRow
+	if err := d.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, errors.Wrap(err, "list blueprints")
+	}
+	var blueprints []blueprint.Blueprint
+	for _, r := range rows {
+		bp, err := r.blueprint()
+		if err != nil {
+			return nil, err
+		}
+		blueprints = append(blueprints, *bp)
+	}
+	return blueprints, nil
+}
+
+func (d *SQLite) Save(bp *blueprint.Blueprint) error {
+	if bp == nil {
+		return errors.New("no blueprint supplied")
+	}
+	ctx := context.TODO()
+	if err := bp.Verify(); err != nil {
+		return err
+	}
+	checkBP, err := d.BlueprintByName(bp.Name)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	if err == nil && bp.UUID != checkBP.UUID {
+		return fmt.Errorf("Blueprint not saved: same name %s exists", bp.Name)
+	}
+	// verify that each Profile ID represents a profile we know about
+	for _, p := range bp.ProfileIdentifiers {
+		if _, err := d.profDB.ProfileById(ctx, p); err != nil {
+			if profile.IsNotFound(err) {
+				return fmt.Errorf("Profile ID %s in Blueprint %s does not exist", p, bp.Name)
+			}
+			return errors.Wrap(err, "fetching profile")
+		}
+	}
+
+	var lists [5]string
+	for i, l := range [][]string{bp.ApplicationURLs, bp.ProfileIdentifiers, bp.UserUUID, bp.ApplyAt, bp.Groups} {
+		if lists[i], err = marshalList(l); err != nil {
+			return errors.Wrap(err, "marshalling blueprint")
+		}
+	}
+
+	query, args, err := sq.
+		Insert(tableName).
+		Options("OR REPLACE").
+		Columns(columns()...).
+		Values(
+			bp.UUID,
+			bp.Name,
+			lists[0],
+			lists[1],
+			lists[2],
+			bp.SkipPrimarySetupAccountCreation,
+			bp.SetPrimarySetupAccountAsRegularUser,
+			lists[3],
+			lists[4],
+		).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building blueprint save query")
+	}
+	_, err = d.db.ExecContext(ctx, query, args...)
+	return errors.Wrap(err, "exec blueprint save in sqlite")
+}
+
+func (d *SQLite) BlueprintByName(name string) (*blueprint.Blueprint, error) {
+	query, args, err := sq.
+		Select(columns()...).
+		From(tableName).
+		Where(sq.Eq{"name": name}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var r blueprintRow
+	err = d.db.QueryRowx(query, args...).StructScan(&r)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, &notFound{"Blueprint", fmt.Sprintf("name %s", name)}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "finding blueprint by name")
+	}
+	return r.blueprint()
+}
+
+func (d *SQLite) BlueprintsByApplyAt(ctx context.Context, name string) ([]blueprint.Blueprint, error) {
+	all, err := d.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var bps []blueprint.Blueprint
+	for _, bp := range all {
+		for _, n := range bp.ApplyAt {
+			if strings.ToLower(n) == strings.ToLower(name) {
+				bps = append(bps, bp)
+				break
+			}
+		}
+	}
+	return bps, nil
+}
+
+func (d *SQLite) Delete(name string) error {
+	query, args, err := sq.
+		Delete(tableName).
+		Where(sq.Eq{"name": name}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	res, err := d.db.Exec(query, args...)
+	if err != nil {
+		return errors.Wrap(err, "delete blueprint")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return &notFound{"Blueprint", fmt.Sprintf("name %s", name)}
+	}
+	return nil
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func isNotFound(err error) bool {
+	if _, ok := err.(*notFound); ok {
+		return true
+	}
+	return false
+}
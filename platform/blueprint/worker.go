@@ -107,11 +107,47 @@ func (w *Worker) handleTokenUpdateEvent(ctx context.Context, message []byte) err
 		return errors.Wrap(err, "get blueprints by ApplyAtEnroll")
 	}
 
+	// only apply the blueprints of the enrollment group of the device.
+	group := ev.Params[device.GroupParam]
+	var applicable []Blueprint
+	for _, bp := range bps {
+		if bp.AppliesToGroup(group) {
+			applicable = append(applicable, bp)
+		}
+	}
+	bps = applicable
+
+	// the user who signed in at Setup Assistant is prefilled as the primary
+	// account. Blueprints with users add it to their AccountConfiguration,
+	// otherwise it is sent on its own.
+	setup := setupAccount{
+		userName: ev.Params[device.SetupUserParam],
+		fullName: ev.Params[device.SetupFullNameParam],
+	}
+	if setup.userName != "" && ev.Command.AwaitingConfiguration && !configuresAccounts(bps) {
+		level.Debug(w.logger).Log(
+			"msg", "prefilling primary account",
+			"device_udid", ev.Command.UDID,
+			"user", setup.userName,
+		)
+		_, err := w.cmdsvc.NewCommand(ctx, &mdm.CommandRequest{
+			UDID: ev.Command.UDID,
+			Command: &mdm.Command{
+				RequestType:          "AccountConfiguration",
+				AccountConfiguration: setup.accountConfiguration(),
+			},
+		})
+		if err != nil {
+			return errors.Wrap(err, "send AccountConfiguration for setup user")
+		}
+	}
+
 	// if there are no blueprints exit early. This will ensure that DeviceConfigured is not sent.
 	if len(bps) == 0 {
 		level.Debug(w.logger).Log(
 			"msg", "no blueprints to apply",
 			"device_udid", ev.Command.UDID,
+			"group", group,
 		)
 		return nil
 	}
@@ -123,7 +159,7 @@ func (w *Worker) handleTokenUpdateEvent(ctx context.Context, message []byte) err
 			"blueprint_name", bp.Name,
 		)
 
-		if err := w.applyToDevice(ctx, bp, ev.Command.UDID); err != nil {
+		if err := w.applyToDevice(ctx, bp, ev.Command.UDID, setup); err != nil {
 			return errors.Wrapf(err, "apply blueprint to udid name=%s, udid=%s", bp.Name, ev.Command.UDID)
 		}
 	}
@@ -146,7 +182,35 @@ func (w *Worker) handleTokenUpdateEvent(ctx context.Context, message []byte) err
 
 }
 
-func (w *Worker) applyToDevice(ctx context.Context, bp Blueprint, udid string) error {
+// setupAccount is the user who signed in at Setup Assistant.
+type setupAccount struct {
+	userName string
+	fullName string
+}
+
+// accountConfiguration returns an AccountConfiguration which prefills and
+// locks the primary account with the setup user, if there is one.
+func (s setupAccount) accountConfiguration() *mdm.AccountConfiguration {
+	if s.userName == "" {
+		return &mdm.AccountConfiguration{}
+	}
+	return &mdm.AccountConfiguration{
+		PrimaryAccountUserName: s.userName,
+		PrimaryAccountFullName: s.fullName,
+		LockPrimaryAccountInfo: true,
+	}
+}
+
+func configuresAccounts(bps []Blueprint) bool {
+	for _, bp := range bps {
+		if len(bp.UserUUID) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Worker) applyToDevice(ctx context.Context, bp Blueprint, udid string, setup setupAccount) error {
 	var requests []*mdm.CommandRequest
 	for _, uuid := range bp.UserUUID {
 		level.Debug(w.logger).Log(
@@ -169,22 +233,22 @@ func (w *Worker) applyToDevice(ctx context.Context, bp Blueprint, udid string) e
 			continue
 		}
 
+		accountConfiguration := setup.accountConfiguration()
+		accountConfiguration.SkipPrimarySetupAccountCreation = bp.SkipPrimarySetupAccountCreation
+		accountConfiguration.SetPrimarySetupAccountAsRegularUser = bp.SetPrimarySetupAccountAsRegularUser
+		accountConfiguration.AutoSetupAdminAccounts = []mdm.AdminAccount{
+			{
+				ShortName:    usr.UserShortname,
+				FullName:     usr.UserLongname,
+				PasswordHash: usr.PasswordHash,
+				Hidden:       usr.Hidden,
+			},
+		}
 		requests = append(requests, &mdm.CommandRequest{
 			UDID: udid,
 			Command: &mdm.Command{
-				RequestType: "AccountConfiguration",
-				AccountConfiguration: &mdm.AccountConfiguration{
-					SkipPrimarySetupAccountCreation:     bp.SkipPrimarySetupAccountCreation,
-					SetPrimarySetupAccountAsRegularUser: bp.SetPrimarySetupAccountAsRegularUser,
-					AutoSetupAdminAccounts: []mdm.AdminAccount{
-						{
-							ShortName:    usr.UserShortname,
-							FullName:     usr.UserLongname,
-							PasswordHash: usr.PasswordHash,
-							Hidden:       usr.Hidden,
-						},
-					},
-				},
+				RequestType:          "AccountConfiguration",
+				AccountConfiguration: accountConfiguration,
 			},
 		})
 	}
@@ -0,0 +1,212 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/challenge"
+)
+
+const ChallengeBucket = "scep.Challenges"
+
+// CertificateSerialBucket holds the serial numbers of the challenges
+// certificates were issued for, by certificate hash.
+const CertificateSerialBucket = "scep.ChallengeCertificates"
+
+// legacyChallengeBucket holds the unbound challenges of previous versions,
+// which are moved to ChallengeBucket.
+const legacyChallengeBucket = "scep_challenges"
+
+type DB struct {
+	*bolt.DB
+}
+
+func NewDB(db *bolt.DB) (*DB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(ChallengeBucket))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists([]byte(CertificateSerialBucket)); err != nil {
+			return err
+		}
+		return migrateLegacy(tx, b)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s bucket", ChallengeBucket)
+	}
+	datastore := &DB{
+		DB: db,
+	}
+	return datastore, nil
+}
+
+// migrateLegacy moves the challenges of previous versions to b. They didn't
+// expire, so they are valid for DefaultTTL from now.
+func migrateLegacy(tx *bolt.Tx, b *bolt.Bucket) error {
+	legacy := tx.Bucket([]byte(legacyChallengeBucket))
+	if legacy == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	err := legacy.ForEach(func(k, _ []byte) error {
+		return put(b, &challenge.Challenge{
+			Challenge: string(k),
+			CreatedAt: now,
+			ExpiresAt: now.Add(challenge.DefaultTTL),
+		})
+	})
+	if err != nil {
+		return errors.Wrap(err, "migrate legacy challenges")
+	}
+	return tx.DeleteBucket([]byte(legacyChallengeBucket))
+}
+
+func (db *DB) Save(ctx context.Context, c *challenge.Challenge) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket([]byte(ChallengeBucket)), c)
+	})
+	return errors.Wrap(err, "save challenge")
+}
+
+func put(b *bolt.Bucket, c *challenge.Challenge) error {
+	pb, err := challenge.MarshalChallenge(c)
+	if err != nil {
+		return errors.Wrap(err, "marshal challenge")
+	}
+	return b.Put([]byte(c.Challenge), pb)
+}
+
+func (db *DB) List(ctx context.Context) ([]challenge.Challenge, error) {
+	var challenges []challenge.Challenge
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(ChallengeBucket)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var ch challenge.Challenge
+			if err := challenge.UnmarshalChallenge(v, &ch); err != nil {
+				return err
+			}
+			challenges = append(challenges, ch)
+		}
+		return nil
+	})
+	return challenges, errors.Wrap(err, "list challenges")
+}
+
+func (db *DB) Delete(ctx context.Context, ch string) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ChallengeBucket))
+		if b.Get([]byte(ch)) == nil {
+			return &notFound{"Challenge", ch}
+		}
+		return b.Delete([]byte(ch))
+	})
+	return errors.Wrap(err, "delete challenge")
+}
+
+func (db *DB) DeleteExpired(ctx context.Context, now time.Time) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ChallengeBucket))
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var ch challenge.Challenge
+			if err := challenge.UnmarshalChallenge(v, &ch); err != nil {
+				return err
+			}
+			if now.After(ch.ExpiresAt) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// keys can't be deleted while iterating with ForEach.
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "delete expired challenges")
+}
+
+func (db *DB) Redeem(ctx context.Context, ch string) (*challenge.Challenge, error) {
+	var redeemed *challenge.Challenge
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ChallengeBucket))
+		v := b.Get([]byte(ch))
+		if v == nil {
+			return &notFound{"Challenge", "redeem"}
+		}
+		redeemed = new(challenge.Challenge)
+		if err := challenge.UnmarshalChallenge(v, redeemed); err != nil {
+			return err
+		}
+		return b.Delete([]byte(ch))
+	})
+	return redeemed, errors.Wrap(err, "redeem challenge")
+}
+
+func (db *DB) SaveCertificateSerial(ctx context.Context, certHash []byte, serial string) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(CertificateSerialBucket)).Put(certHash, []byte(serial))
+	})
+	return errors.Wrap(err, "save certificate serial")
+}
+
+func (db *DB) CertificateSerial(ctx context.Context, certHash []byte) (string, error) {
+	var serial string
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(CertificateSerialBucket)).Get(certHash)
+		if v == nil {
+			return &notFound{"Certificate", fmt.Sprintf("hash %x", certHash)}
+		}
+		serial = string(v)
+		return nil
+	})
+	return serial, errors.Wrap(err, "get certificate serial")
+}
+
+// SCEPChallenge and HasChallenge implement the challenge store of the SCEP
+// and ACME servers with unbound challenges.
+
+// SCEPChallenge creates an unbound challenge, valid for DefaultTTL.
+func (db *DB) SCEPChallenge() (string, error) {
+	c, err := challenge.NewChallenge("", "", challenge.DefaultTTL)
+	if err != nil {
+		return "", err
+	}
+	return c.Challenge, db.Save(context.Background(), c)
+}
+
+// HasChallenge redeems pw, and reports whether it was an unexpired, unbound
+// challenge. The binding of challenges can't be checked without the
+// certificate request.
+func (db *DB) HasChallenge(pw string) (bool, error) {
+	c, err := db.Redeem(context.Background(), pw)
+	if e, ok := errors.Cause(err).(*notFound); ok && e.NotFound() {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return c.Unbound() && time.Now().Before(c.ExpiresAt), nil
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
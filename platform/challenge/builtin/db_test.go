@@ -0,0 +1,159 @@
+package builtin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/challenge"
+)
+
+func TestRedeem(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	c, err := challenge.NewChallenge("C02ABC", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, c); err != nil {
+		t.Fatalf("saving challenge: %s", err)
+	}
+
+	redeemed, err := db.Redeem(ctx, c.Challenge)
+	if err != nil {
+		t.Fatalf("redeem challenge: %s", err)
+	}
+	if have, want := redeemed.Serial, "C02ABC"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if _, err := db.Redeem(ctx, c.Challenge); err == nil {
+		t.Error("expected an error redeeming a challenge twice")
+	}
+}
+
+func TestHasChallenge(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	pw, err := db.SCEPChallenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := db.HasChallenge(pw); err != nil || !ok {
+		t.Errorf("have %v, %v, want an unbound challenge", ok, err)
+	}
+	if ok, _ := db.HasChallenge(pw); ok {
+		t.Error("challenge used twice")
+	}
+
+	bound, err := challenge.NewChallenge("C02ABC", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, bound); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.HasChallenge(bound.Challenge); ok {
+		t.Error("bound challenge accepted without checking its binding")
+	}
+}
+
+func TestCertificateSerial(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	if _, err := db.CertificateSerial(ctx, []byte("hash")); !isNotFound(err) {
+		t.Fatalf("have err %v, want not found", err)
+	}
+	if err := db.SaveCertificateSerial(ctx, []byte("hash"), "C02ABC"); err != nil {
+		t.Fatal(err)
+	}
+	serial, err := db.CertificateSerial(ctx, []byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := serial, "C02ABC"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+}
+
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(interface{ NotFound() bool })
+	return ok && e.NotFound()
+}
+
+func TestDeleteExpired(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	for _, ttl := range []time.Duration{time.Minute, time.Hour} {
+		c, err := challenge.NewChallenge("", "", ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Save(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.DeleteExpired(ctx, time.Now().Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	challenges, err := db.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(challenges), 1; have != want {
+		t.Errorf("have %d challenges, want %d", have, want)
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	f, _ := ioutil.TempFile("", "bolt-")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0777, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(legacyChallengeBucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("legacy"), []byte("legacy"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chDB, err := NewDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := chDB.HasChallenge("legacy"); err != nil || !ok {
+		t.Errorf("have %v, %v, want the legacy challenge", ok, err)
+	}
+}
+
+func setupDB(t *testing.T) *DB {
+	f, _ := ioutil.TempFile("", "bolt-")
+	f.Close()
+	os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0777, nil)
+	if err != nil {
+		t.Fatalf("couldn't open bolt, err %s\n", err)
+	}
+	chDB, err := NewDB(db)
+	if err != nil {
+		t.Fatalf("couldn't create challenge DB, err %s\n", err)
+	}
+	return chDB
+}
@@ -1,22 +1,116 @@
+// Package challenge provides the dynamic SCEP challenges of enrollment
+// profiles.
+//
+// A challenge can be used once and expires. It can be bound to the serial
+// number of a device, which must then be in the subject of the certificate
+// request and be reported by the device when it enrolls with the certificate,
+// or to an enrollment invitation, which must still be usable when the
+// challenge is redeemed.
 package challenge
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
 
-	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/platform/challenge/internal/challengeproto"
+)
+
+// DefaultTTL is how long a challenge is valid unless created with a TTL.
+const DefaultTTL = 24 * time.Hour
+
+type Challenge struct {
+	Challenge string `json:"challenge"`
+	// Serial and Invitation are the serial number and the invitation token
+	// the challenge is bound to.
+	Serial     string    `json:"serial,omitempty"`
+	Invitation string    `json:"invitation,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+var (
+	ErrExpired        = errors.New("challenge expired")
+	ErrSerialMismatch = errors.New("challenge is bound to another serial number")
+	ErrInvitation     = errors.New("challenge is bound to an invitation which can't be used")
 )
 
-type challengeResponse struct {
-	Challenge string `json:"string"`
-	Err       error  `json:"err,omitempty"`
+// NewChallenge creates a random challenge, valid for ttl.
+func NewChallenge(serial, invitation string, ttl time.Duration) (*Challenge, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generate challenge")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	now := time.Now().UTC()
+	return &Challenge{
+		Challenge:  base64.StdEncoding.EncodeToString(b),
+		Serial:     serial,
+		Invitation: invitation,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}, nil
+}
+
+// Unbound reports whether the challenge is bound to neither a serial number
+// nor an invitation.
+func (c *Challenge) Unbound() bool {
+	return c.Serial == "" && c.Invitation == ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a context which makes the enrollment service use
+// challenge as the SCEP challenge of the profile, in place of a new one.
+func NewContext(ctx context.Context, challenge string) context.Context {
+	return context.WithValue(ctx, contextKey{}, challenge)
+}
+
+// FromContext returns the challenge set with NewContext.
+func FromContext(ctx context.Context) (string, bool) {
+	challenge, ok := ctx.Value(contextKey{}).(string)
+	return challenge, ok
+}
+
+func MarshalChallenge(c *Challenge) ([]byte, error) {
+	return proto.Marshal(&challengeproto.Challenge{
+		Challenge:  c.Challenge,
+		Serial:     c.Serial,
+		Invitation: c.Invitation,
+		CreatedAt:  timeToNano(c.CreatedAt),
+		ExpiresAt:  timeToNano(c.ExpiresAt),
+	})
 }
 
-func (r challengeResponse) Failed() error { return r.Err }
+func UnmarshalChallenge(data []byte, c *Challenge) error {
+	var pb challengeproto.Challenge
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to challenge")
+	}
+	c.Challenge = pb.GetChallenge()
+	c.Serial = pb.GetSerial()
+	c.Invitation = pb.GetInvitation()
+	c.CreatedAt = timeFromNano(pb.GetCreatedAt())
+	c.ExpiresAt = timeFromNano(pb.GetExpiresAt())
+	return nil
+}
+
+func timeToNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
 
-func MakeChallengeEndpoint(svc Service) endpoint.Endpoint {
-	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
-		r := challengeResponse{}
-		r.Challenge, r.Err = svc.SCEPChallenge(ctx)
-		return r, nil
+func timeFromNano(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
 	}
+	return time.Unix(0, nano).UTC()
 }
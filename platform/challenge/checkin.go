@@ -0,0 +1,72 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/mdm"
+)
+
+// CheckinMiddleware rejects the Authenticate check-in of devices whose
+// identity certificate was issued for a challenge bound to another serial
+// number. The serial number in the certificate request is the one of the
+// enrollment profile, so it is checked against the serial number the device
+// reports as well.
+func CheckinMiddleware(store Store, logger log.Logger) mdm.Middleware {
+	return func(next mdm.Service) mdm.Service {
+		return &checkinMiddleware{
+			store:  store,
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type checkinMiddleware struct {
+	store  Store
+	logger log.Logger
+	next   mdm.Service
+}
+
+func (mw *checkinMiddleware) Checkin(ctx context.Context, req mdm.CheckinEvent) ([]byte, error) {
+	// user enrollments don't report the serial number.
+	if req.Command.MessageType != "Authenticate" || req.Command.EnrollmentID != "" {
+		return mw.next.Checkin(ctx, req)
+	}
+	devcert, err := mdm.DeviceCertificateFromContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error retrieving device certificate")
+	}
+	sum := sha256.Sum256(devcert.Raw)
+	serial, err := mw.store.CertificateSerial(ctx, sum[:])
+	if isNotFound(err) {
+		return mw.next.Checkin(ctx, req)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "get serial number of device certificate")
+	}
+	if serial != req.Command.SerialNumber {
+		level.Info(mw.logger).Log("msg", "rejected device identity", "udid", req.Command.UDID, "serial", req.Command.SerialNumber, "err", ErrSerialMismatch)
+		return nil, rejectErr{ErrSerialMismatch}
+	}
+	return mw.next.Checkin(ctx, req)
+}
+
+func (mw *checkinMiddleware) Acknowledge(ctx context.Context, req mdm.AcknowledgeEvent) ([]byte, error) {
+	return mw.next.Acknowledge(ctx, req)
+}
+
+// rejectErr fails the enrollment of a device with the identity of another
+// device.
+type rejectErr struct{ err error }
+
+func (e rejectErr) Error() string {
+	return "device identity rejected: " + e.err.Error()
+}
+
+func (rejectErr) Checkout() bool {
+	return true
+}
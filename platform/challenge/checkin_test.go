@@ -0,0 +1,113 @@
+package challenge
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/micromdm/scep/v2/scep"
+	scepserver "github.com/micromdm/scep/v2/server"
+
+	"github.com/micromdm/micromdm/mdm"
+)
+
+type memStore struct {
+	Store
+	challenges map[string]*Challenge
+	serials    map[string]string
+}
+
+func (s *memStore) Redeem(ctx context.Context, ch string) (*Challenge, error) {
+	c, ok := s.challenges[ch]
+	if !ok {
+		return nil, notFound{}
+	}
+	delete(s.challenges, ch)
+	return c, nil
+}
+
+func (s *memStore) SaveCertificateSerial(ctx context.Context, certHash []byte, serial string) error {
+	s.serials[string(certHash)] = serial
+	return nil
+}
+
+func (s *memStore) CertificateSerial(ctx context.Context, certHash []byte) (string, error) {
+	serial, ok := s.serials[string(certHash)]
+	if !ok {
+		return "", notFound{}
+	}
+	return serial, nil
+}
+
+type nopService struct {
+	checkins int
+}
+
+func (s *nopService) Checkin(ctx context.Context, req mdm.CheckinEvent) ([]byte, error) {
+	s.checkins++
+	return nil, nil
+}
+
+func (s *nopService) Acknowledge(ctx context.Context, req mdm.AcknowledgeEvent) ([]byte, error) {
+	return nil, nil
+}
+
+func TestCheckinMiddleware(t *testing.T) {
+	store := &memStore{
+		challenges: map[string]*Challenge{
+			"bound":   {Challenge: "bound", Serial: "C02ABC", ExpiresAt: time.Now().Add(time.Hour)},
+			"unbound": {Challenge: "unbound", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		serials: make(map[string]string),
+	}
+	// the signer issues a certificate named after the challenge.
+	signer := Middleware(store, nil, scepserver.CSRSignerFunc(func(m *scep.CSRReqMessage) (*x509.Certificate, error) {
+		return &x509.Certificate{Raw: []byte(m.ChallengePassword)}, nil
+	}))
+	sign := func(challenge string) *x509.Certificate {
+		t.Helper()
+		// the serial number of the enrollment profile is in the subject.
+		csr := &x509.CertificateRequest{Subject: pkix.Name{SerialNumber: "C02ABC"}}
+		cert, err := signer.SignCSR(&scep.CSRReqMessage{ChallengePassword: challenge, CSR: csr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	bound, unbound := sign("bound"), sign("unbound")
+
+	next := new(nopService)
+	svc := CheckinMiddleware(store, log.NewNopLogger())(next)
+	authenticate := func(cert *x509.Certificate, serial string) error {
+		ev := mdm.CheckinEvent{}
+		ev.Command.MessageType = "Authenticate"
+		ev.Command.UDID = "UDID-1"
+		ev.Command.SerialNumber = serial
+		ctx := context.WithValue(context.Background(), mdm.ContextKeyDeviceCertificate, cert)
+		_, err := svc.Checkin(ctx, ev)
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		cert     *x509.Certificate
+		serial   string
+		rejected bool
+	}{
+		{"other serial", bound, "C02XYZ", true},
+		{"bound serial", bound, "C02ABC", false},
+		{"unbound", unbound, "C02XYZ", false},
+	}
+	for _, tt := range tests {
+		err := authenticate(tt.cert, tt.serial)
+		if _, rejected := err.(rejectErr); rejected != tt.rejected {
+			t.Errorf("%s: have err %v, want rejected %v", tt.name, err, tt.rejected)
+		}
+	}
+	if have, want := next.checkins, 2; have != want {
+		t.Errorf("have %d check-ins passed through, want %d", have, want)
+	}
+}
@@ -0,0 +1,69 @@
+package challenge
+
+import (
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransport.ClientOption) (Service, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var challengeEndpoint endpoint.Endpoint
+	{
+		challengeEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/challenge"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeChallengeResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var createChallengeEndpoint endpoint.Endpoint
+	{
+		createChallengeEndpoint = httptransport.NewClient(
+			"PUT",
+			httputil.CopyURL(u, "/v1/challenges"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeCreateChallengeResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var listChallengesEndpoint endpoint.Endpoint
+	{
+		listChallengesEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/challenges"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeListChallengesResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var revokeChallengesEndpoint endpoint.Endpoint
+	{
+		revokeChallengesEndpoint = httptransport.NewClient(
+			"DELETE",
+			httputil.CopyURL(u, "/v1/challenges"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeRevokeChallengesResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	return Endpoints{
+		ChallengeEndpoint:        challengeEndpoint,
+		CreateChallengeEndpoint:  createChallengeEndpoint,
+		ListChallengesEndpoint:   listChallengesEndpoint,
+		RevokeChallengesEndpoint: revokeChallengesEndpoint,
+	}, nil
+}
@@ -0,0 +1,74 @@
+package challenge
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type CreateChallengeOption struct {
+	// Serial and Invitation bind the challenge to the serial number of a
+	// device or to an invitation token.
+	Serial     string `json:"serial"`
+	Invitation string `json:"invitation"`
+	// TTLSeconds is how long the challenge can be used. DefaultTTL if zero.
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+func (svc *ChallengeService) CreateChallenge(ctx context.Context, opt CreateChallengeOption) (*Challenge, error) {
+	if err := svc.sweep(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+	c, err := NewChallenge(opt.Serial, opt.Invitation, time.Duration(opt.TTLSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.store.Save(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type createChallengeRequest struct{ Opts CreateChallengeOption }
+type createChallengeResponse struct {
+	Challenge *Challenge `json:"challenge,omitempty"`
+	Err       error      `json:"err,omitempty"`
+}
+
+func (r createChallengeResponse) Failed() error { return r.Err }
+
+func decodeCreateChallengeRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts CreateChallengeOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return createChallengeRequest{Opts: opts}, err
+}
+
+func decodeCreateChallengeResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createChallengeResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeCreateChallengeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createChallengeRequest)
+		c, err := svc.CreateChallenge(ctx, req.Opts)
+		return createChallengeResponse{
+			Challenge: c,
+			Err:       err,
+		}, nil
+	}
+}
+
+func (e Endpoints) CreateChallenge(ctx context.Context, opt CreateChallengeOption) (*Challenge, error) {
+	request := createChallengeRequest{opt}
+	response, err := e.CreateChallengeEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(createChallengeResponse).Challenge, response.(createChallengeResponse).Err
+}
@@ -0,0 +1,82 @@
+package challenge
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type ListChallengesOption struct {
+	FilterSerial     string `json:"filter_serial"`
+	FilterInvitation string `json:"filter_invitation"`
+	// IncludeExpired also lists expired challenges which were not removed
+	// yet.
+	IncludeExpired bool `json:"include_expired"`
+}
+
+// ListChallenges lists the challenges which were not used or revoked.
+func (svc *ChallengeService) ListChallenges(ctx context.Context, opt ListChallengesOption) ([]Challenge, error) {
+	challenges, err := svc.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var filtered []Challenge
+	for _, c := range challenges {
+		if opt.FilterSerial != "" && c.Serial != opt.FilterSerial {
+			continue
+		}
+		if opt.FilterInvitation != "" && c.Invitation != opt.FilterInvitation {
+			continue
+		}
+		if !opt.IncludeExpired && now.After(c.ExpiresAt) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered, nil
+}
+
+type listChallengesRequest struct{ Opts ListChallengesOption }
+type listChallengesResponse struct {
+	Challenges []Challenge `json:"challenges"`
+	Err        error       `json:"err,omitempty"`
+}
+
+func (r listChallengesResponse) Failed() error { return r.Err }
+
+func decodeListChallengesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts ListChallengesOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return listChallengesRequest{Opts: opts}, err
+}
+
+func decodeListChallengesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listChallengesResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeListChallengesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listChallengesRequest)
+		challenges, err := svc.ListChallenges(ctx, req.Opts)
+		return listChallengesResponse{
+			Challenges: challenges,
+			Err:        err,
+		}, nil
+	}
+}
+
+func (e Endpoints) ListChallenges(ctx context.Context, opt ListChallengesOption) ([]Challenge, error) {
+	request := listChallengesRequest{opt}
+	response, err := e.ListChallengesEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(listChallengesResponse).Challenges, response.(listChallengesResponse).Err
+}
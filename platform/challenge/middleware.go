@@ -0,0 +1,77 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"time"
+
+	"github.com/micromdm/scep/v2/scep"
+	scepserver "github.com/micromdm/scep/v2/server"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/invitation"
+)
+
+// SerialNumberOID is the serialNumber attribute of the SCEP subject which
+// carries the serial number a challenge is bound to.
+const SerialNumberOID = "2.5.4.5"
+
+// Invitations looks up the invitations challenges are bound to.
+type Invitations interface {
+	InvitationByToken(ctx context.Context, token string) (*invitation.Invitation, error)
+}
+
+// Middleware wraps next in a CSRSigner which redeems the challenge of the
+// CSR and checks its expiry and binding. The serial number of the challenge
+// is recorded for the issued certificate, which CheckinMiddleware checks.
+// invitations may be nil if no challenges are bound to invitations.
+func Middleware(store Store, invitations Invitations, next scepserver.CSRSigner) scepserver.CSRSignerFunc {
+	return func(m *scep.CSRReqMessage) (*x509.Certificate, error) {
+		ctx := context.Background()
+		c, err := store.Redeem(ctx, m.ChallengePassword)
+		if isNotFound(err) {
+			return nil, errors.New("invalid challenge")
+		} else if err != nil {
+			return nil, err
+		}
+		if err := verify(ctx, c, m.CSR, invitations, time.Now()); err != nil {
+			return nil, err
+		}
+		cert, err := next.SignCSR(m)
+		if err != nil || c.Serial == "" {
+			return cert, err
+		}
+		sum := sha256.Sum256(cert.Raw)
+		if err := store.SaveCertificateSerial(ctx, sum[:], c.Serial); err != nil {
+			return nil, errors.Wrap(err, "save serial number of certificate")
+		}
+		return cert, nil
+	}
+}
+
+func verify(ctx context.Context, c *Challenge, csr *x509.CertificateRequest, invitations Invitations, now time.Time) error {
+	if now.After(c.ExpiresAt) {
+		return ErrExpired
+	}
+	if c.Serial != "" && (csr == nil || csr.Subject.SerialNumber != c.Serial) {
+		return ErrSerialMismatch
+	}
+	if c.Invitation != "" {
+		if invitations == nil {
+			return ErrInvitation
+		}
+		inv, err := invitations.InvitationByToken(ctx, c.Invitation)
+		if isNotFound(err) {
+			return ErrInvitation
+		} else if err != nil {
+			return errors.Wrap(err, "get invitation of challenge")
+		}
+		// the invitation is redeemed on the first check-in, after the
+		// device got its identity.
+		if inv.UDID != "" || now.After(inv.ExpiresAt) {
+			return ErrInvitation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package challenge
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/invitation"
+)
+
+type invitationMap map[string]*invitation.Invitation
+
+func (m invitationMap) InvitationByToken(ctx context.Context, token string) (*invitation.Invitation, error) {
+	inv, ok := m[token]
+	if !ok {
+		return nil, notFound{}
+	}
+	return inv, nil
+}
+
+type notFound struct{}
+
+func (notFound) Error() string  { return "not found" }
+func (notFound) NotFound() bool { return true }
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	invitations := invitationMap{
+		"open":     {Token: "open", ExpiresAt: now.Add(time.Hour)},
+		"used":     {Token: "used", ExpiresAt: now.Add(time.Hour), UDID: "UDID-1"},
+		"outdated": {Token: "outdated", ExpiresAt: now.Add(-time.Hour)},
+	}
+	csr := func(serial string) *x509.CertificateRequest {
+		return &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device", SerialNumber: serial}}
+	}
+
+	tests := []struct {
+		challenge Challenge
+		csr       *x509.CertificateRequest
+		want      error
+	}{
+		{Challenge{ExpiresAt: now.Add(time.Hour)}, csr(""), nil},
+		{Challenge{ExpiresAt: now.Add(-time.Minute)}, csr(""), ErrExpired},
+		{Challenge{Serial: "C02ABC", ExpiresAt: now.Add(time.Hour)}, csr("C02ABC"), nil},
+		{Challenge{Serial: "C02ABC", ExpiresAt: now.Add(time.Hour)}, csr("C02XYZ"), ErrSerialMismatch},
+		{Challenge{Serial: "C02ABC", ExpiresAt: now.Add(time.Hour)}, csr(""), ErrSerialMismatch},
+		{Challenge{Invitation: "open", ExpiresAt: now.Add(time.Hour)}, csr(""), nil},
+		{Challenge{Invitation: "used", ExpiresAt: now.Add(time.Hour)}, csr(""), ErrInvitation},
+		{Challenge{Invitation: "outdated", ExpiresAt: now.Add(time.Hour)}, csr(""), ErrInvitation},
+		{Challenge{Invitation: "revoked", ExpiresAt: now.Add(time.Hour)}, csr(""), ErrInvitation},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			if have, want := verify(context.Background(), &tt.challenge, tt.csr, invitations, now), tt.want; have != want {
+				t.Errorf("have %v, want %v", have, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package challenge
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+// RevokeChallenges removes challenges, so that the enrollment profiles with
+// them can't be used to get a device identity. Devices which already got
+// their identity stay enrolled.
+func (svc *ChallengeService) RevokeChallenges(ctx context.Context, challenges []string) error {
+	for _, c := range challenges {
+		if err := svc.store.Delete(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type revokeChallengesRequest struct {
+	Challenges []string `json:"challenges"`
+}
+
+type revokeChallengesResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r revokeChallengesResponse) Failed() error { return r.Err }
+
+func decodeRevokeChallengesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req revokeChallengesRequest
+	err := httputil.DecodeJSONRequest(r, &req)
+	return req, err
+}
+
+func decodeRevokeChallengesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp revokeChallengesResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeRevokeChallengesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(revokeChallengesRequest)
+		err = svc.RevokeChallenges(ctx, req.Challenges)
+		return revokeChallengesResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+func (e Endpoints) RevokeChallenges(ctx context.Context, challenges []string) error {
+	request := revokeChallengesRequest{Challenges: challenges}
+	resp, err := e.RevokeChallengesEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	return resp.(revokeChallengesResponse).Err
+}
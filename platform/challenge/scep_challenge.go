@@ -0,0 +1,39 @@
+package challenge
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type challengeResponse struct {
+	Challenge string `json:"string"`
+	Err       error  `json:"err,omitempty"`
+}
+
+func (r challengeResponse) Failed() error { return r.Err }
+
+func MakeChallengeEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		r := challengeResponse{}
+		r.Challenge, r.Err = svc.SCEPChallenge(ctx)
+		return r, nil
+	}
+}
+
+func decodeChallengeResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp challengeResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func (e Endpoints) SCEPChallenge(ctx context.Context) (string, error) {
+	response, err := e.ChallengeEndpoint(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return response.(challengeResponse).Challenge, response.(challengeResponse).Err
+}
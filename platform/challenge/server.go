@@ -11,17 +11,26 @@ import (
 )
 
 type Endpoints struct {
-	ChallengeEndpoint endpoint.Endpoint
+	ChallengeEndpoint        endpoint.Endpoint
+	CreateChallengeEndpoint  endpoint.Endpoint
+	ListChallengesEndpoint   endpoint.Endpoint
+	RevokeChallengesEndpoint endpoint.Endpoint
 }
 
 func MakeServerEndpoints(s Service, outer endpoint.Middleware, others ...endpoint.Middleware) Endpoints {
 	return Endpoints{
-		ChallengeEndpoint: endpoint.Chain(outer, others...)(MakeChallengeEndpoint(s)),
+		ChallengeEndpoint:        endpoint.Chain(outer, others...)(MakeChallengeEndpoint(s)),
+		CreateChallengeEndpoint:  endpoint.Chain(outer, others...)(MakeCreateChallengeEndpoint(s)),
+		ListChallengesEndpoint:   endpoint.Chain(outer, others...)(MakeListChallengesEndpoint(s)),
+		RevokeChallengesEndpoint: endpoint.Chain(outer, others...)(MakeRevokeChallengesEndpoint(s)),
 	}
 }
 
 func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.ServerOption) {
 	// POST   /v1/challenge    Generate and return SCEP challenge
+	// PUT    /v1/challenges   create a SCEP challenge, optionally bound to a serial or invitation
+	// POST   /v1/challenges   get a list of outstanding SCEP challenges
+	// DELETE /v1/challenges   revoke one or more SCEP challenges
 
 	r.Methods("POST").Path("/v1/challenge").Handler(httptransport.NewServer(
 		e.ChallengeEndpoint,
@@ -29,6 +38,27 @@ func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.S
 		httputil.EncodeJSONResponse,
 		options...,
 	))
+
+	r.Methods("PUT").Path("/v1/challenges").Handler(httptransport.NewServer(
+		e.CreateChallengeEndpoint,
+		decodeCreateChallengeRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/challenges").Handler(httptransport.NewServer(
+		e.ListChallengesEndpoint,
+		decodeListChallengesRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("DELETE").Path("/v1/challenges").Handler(httptransport.NewServer(
+		e.RevokeChallengesEndpoint,
+		decodeRevokeChallengesRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
 }
 
 func decodeEmptyRequest(ctx context.Context, r *http.Request) (interface{}, error) {
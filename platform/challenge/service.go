@@ -2,28 +2,71 @@ package challenge
 
 import (
 	"context"
-	"errors"
+	"sync"
+	"time"
 
-	"github.com/micromdm/scep/v2/challenge"
+	"github.com/pkg/errors"
 )
 
 type Service interface {
 	SCEPChallenge(ctx context.Context) (string, error)
+	CreateChallenge(ctx context.Context, opt CreateChallengeOption) (*Challenge, error)
+	ListChallenges(ctx context.Context, opt ListChallengesOption) ([]Challenge, error)
+	RevokeChallenges(ctx context.Context, challenges []string) error
 }
 
+type Store interface {
+	Save(ctx context.Context, c *Challenge) error
+	List(ctx context.Context) ([]Challenge, error)
+	Delete(ctx context.Context, challenge string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+
+	// Redeem removes and returns the challenge, as a single transaction so
+	// that a challenge can only be used once.
+	Redeem(ctx context.Context, challenge string) (*Challenge, error)
+
+	// SaveCertificateSerial records the serial number of the challenge a
+	// certificate was issued for, by the SHA-256 hash of the certificate.
+	SaveCertificateSerial(ctx context.Context, certHash []byte, serial string) error
+	CertificateSerial(ctx context.Context, certHash []byte) (string, error)
+}
+
+// sweepInterval is how often expired challenges are removed when challenges
+// are created.
+const sweepInterval = time.Hour
+
 type ChallengeService struct {
-	challenge.Store
+	store Store
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewService(store Store) *ChallengeService {
+	return &ChallengeService{store: store}
 }
 
-func (c *ChallengeService) SCEPChallenge(_ context.Context) (string, error) {
-	if c.Store == nil {
-		return "", errors.New("SCEP challenge store missing")
+// SCEPChallenge creates an unbound challenge valid for DefaultTTL.
+func (svc *ChallengeService) SCEPChallenge(ctx context.Context) (string, error) {
+	c, err := svc.CreateChallenge(ctx, CreateChallengeOption{})
+	if err != nil {
+		return "", err
 	}
-	return c.Store.SCEPChallenge()
+	return c.Challenge, nil
 }
 
-func NewService(challengeStore challenge.Store) *ChallengeService {
-	return &ChallengeService{
-		Store: challengeStore,
+// sweep removes expired challenges, at most every sweepInterval.
+func (svc *ChallengeService) sweep(ctx context.Context, now time.Time) error {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if now.Sub(svc.lastSweep) < sweepInterval {
+		return nil
 	}
+	svc.lastSweep = now
+	return svc.store.DeleteExpired(ctx, now)
+}
+
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(interface{ NotFound() bool })
+	return ok && e.NotFound()
 }
@@ -24,7 +24,12 @@ func (svc *CommandService) NewCommand(ctx context.Context, request *mdm.CommandR
 	if err != nil {
 		return nil, errors.Wrap(err, "creating mdm payload")
 	}
-	event := NewEvent(payload, request.UDID)
+	// User enrollments are queued and pushed by their EnrollmentID.
+	target := request.UDID
+	if request.EnrollmentID != "" {
+		target = request.EnrollmentID
+	}
+	event := NewEvent(payload, target)
 	msg, err := MarshalEvent(event)
 	if err != nil {
 		return nil, errors.Wrap(err, "marshalling mdm command event")
@@ -53,13 +58,13 @@ func decodeNewCommandRequest(ctx context.Context, r *http.Request) (interface{},
 	return req, err
 }
 
-var errEmptyRequest = errors.New("request must contain UDID of the device")
+var errEmptyRequest = errors.New("request must contain UDID or EnrollmentID of the device")
 
 // MakeNewCommandEndpoint creates an endpoint which creates new MDM Commands.
 func MakeNewCommandEndpoint(svc Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(newCommandRequest)
-		if req.UDID == "" || req.RequestType == "" {
+		if (req.UDID == "" && req.EnrollmentID == "") || req.RequestType == "" {
 			return newCommandResponse{Err: errEmptyRequest}, nil
 		}
 		payload, err := svc.NewCommand(ctx, &req.CommandRequest)
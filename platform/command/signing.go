@@ -0,0 +1,41 @@
+package command
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/micromdm/micromdm/mdm/mdm"
+)
+
+// ProfileSigner signs mobileconfigs.
+type ProfileSigner interface {
+	Sign(mobileconfig []byte) ([]byte, error)
+}
+
+// SigningMiddleware signs the profiles of InstallProfile commands before
+// they are queued. Profiles which are already signed are queued unchanged.
+func SigningMiddleware(signer ProfileSigner) func(Service) Service {
+	return func(next Service) Service {
+		return &signingMiddleware{next: next, signer: signer}
+	}
+}
+
+type signingMiddleware struct {
+	next   Service
+	signer ProfileSigner
+}
+
+func (mw *signingMiddleware) NewCommand(ctx context.Context, request *mdm.CommandRequest) (*mdm.CommandPayload, error) {
+	if request != nil && request.InstallProfile != nil && len(request.InstallProfile.Payload) > 0 {
+		signed, err := mw.signer.Sign(request.InstallProfile.Payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "sign InstallProfile payload")
+		}
+		install := *request.InstallProfile
+		install.Payload = signed
+		req := *request
+		req.InstallProfile = &install
+		request = &req
+	}
+	return mw.next.NewCommand(ctx, request)
+}
@@ -3,8 +3,6 @@ package builtin
 import (
 	"context"
 	"crypto/tls"
-	"crypto/x509"
-	"encoding/pem"
 	"fmt"
 
 	"github.com/boltdb/bolt"
@@ -93,35 +91,7 @@ func (db *DB) PushCertificate() (*tls.Certificate, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "get server config for push cert")
 	}
-
-	// load private key
-	pkeyBlock, _ := pem.Decode(conf.PrivateKey)
-	if pkeyBlock == nil {
-		return nil, errors.New("decode private key for push cert")
-	}
-
-	priv, err := x509.ParsePKCS1PrivateKey(pkeyBlock.Bytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "parse push certificate key from server config")
-	}
-
-	// load certificate
-	certBlock, _ := pem.Decode(conf.PushCertificate)
-	if certBlock == nil {
-		return nil, errors.New("decode push certificate PEM")
-	}
-
-	pushCert, err := x509.ParseCertificate(certBlock.Bytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "parse push certificate from server config")
-	}
-
-	cert := tls.Certificate{
-		Certificate: [][]byte{pushCert.Raw},
-		PrivateKey:  priv,
-		Leaf:        pushCert,
-	}
-	return &cert, nil
+	return config.ParsePushCertificate(conf)
 }
 
 func (db *DB) PushTopic() (string, error) {
@@ -26,6 +26,17 @@ func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransp
 		).Endpoint()
 	}
 
+	var pushCertificateDetailsEndpoint endpoint.Endpoint
+	{
+		pushCertificateDetailsEndpoint = httptransport.NewClient(
+			"GET",
+			httputil.CopyURL(u, "/v1/config/certificate/details"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodePushCertificateDetailsResponse,
+			opts...,
+		).Endpoint()
+	}
+
 	var applyDEPTokensEndpoint endpoint.Endpoint
 	{
 		applyDEPTokensEndpoint = httptransport.NewClient(
@@ -49,8 +60,9 @@ func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransp
 	}
 
 	return Endpoints{
-		SavePushCertificateEndpoint: saveEndpoint,
-		ApplyDEPTokensEndpoint:      applyDEPTokensEndpoint,
-		GetDEPTokensEndpoint:        getDEPTokensEndpoint,
+		SavePushCertificateEndpoint:    saveEndpoint,
+		PushCertificateDetailsEndpoint: pushCertificateDetailsEndpoint,
+		ApplyDEPTokensEndpoint:         applyDEPTokensEndpoint,
+		GetDEPTokensEndpoint:           getDEPTokensEndpoint,
 	}, nil
 }
@@ -1,6 +1,10 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
 
@@ -33,3 +37,36 @@ func UnmarshalServerConfig(data []byte, conf *ServerConfig) error {
 	conf.PrivateKey = pb.GetPushCertificateKey()
 	return nil
 }
+
+// ParsePushCertificate decodes the PEM encoded push certificate and private key
+// of the server config into a certificate usable by the APNs client.
+func ParsePushCertificate(conf *ServerConfig) (*tls.Certificate, error) {
+	// load private key
+	pkeyBlock, _ := pem.Decode(conf.PrivateKey)
+	if pkeyBlock == nil {
+		return nil, errors.New("decode private key for push cert")
+	}
+
+	priv, err := x509.ParsePKCS1PrivateKey(pkeyBlock.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse push certificate key from server config")
+	}
+
+	// load certificate
+	certBlock, _ := pem.Decode(conf.PushCertificate)
+	if certBlock == nil {
+		return nil, errors.New("decode push certificate PEM")
+	}
+
+	pushCert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse push certificate from server config")
+	}
+
+	cert := tls.Certificate{
+		Certificate: [][]byte{pushCert.Raw},
+		PrivateKey:  priv,
+		Leaf:        pushCert,
+	}
+	return &cert, nil
+}
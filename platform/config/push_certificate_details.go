@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/crypto"
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+// PushCertificateDetails describes the MDM push certificate.
+type PushCertificateDetails struct {
+	Subject   string    `json:"subject"`
+	Topic     string    `json:"topic"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	// DaysLeft is the number of whole days until the certificate expires.
+	DaysLeft int  `json:"days_left"`
+	Expired  bool `json:"expired"`
+}
+
+// NewPushCertificateDetails describes cert as of now.
+func NewPushCertificateDetails(cert *x509.Certificate, now time.Time) *PushCertificateDetails {
+	// the topic is left empty if the certificate has none, which
+	// SavePushCertificate prevents.
+	topic, _ := crypto.TopicFromCert(cert)
+	return &PushCertificateDetails{
+		Subject:   cert.Subject.String(),
+		Topic:     topic,
+		Serial:    cert.SerialNumber.String(),
+		NotBefore: cert.NotBefore.UTC(),
+		NotAfter:  cert.NotAfter.UTC(),
+		DaysLeft:  int(cert.NotAfter.Sub(now).Hours() / 24),
+		Expired:   now.After(cert.NotAfter),
+	}
+}
+
+func (svc *ConfigService) PushCertificateDetails(ctx context.Context) (*PushCertificateDetails, error) {
+	cert, err := svc.store.PushCertificate()
+	if err != nil {
+		return nil, errors.Wrap(err, "get push certificate details")
+	}
+	return NewPushCertificateDetails(cert.Leaf, time.Now()), nil
+}
+
+type pushCertificateDetailsResponse struct {
+	*PushCertificateDetails
+	Err error `json:"err,omitempty"`
+}
+
+func (r pushCertificateDetailsResponse) Failed() error { return r.Err }
+
+func decodePushCertificateDetailsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+func decodePushCertificateDetailsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp pushCertificateDetailsResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakePushCertificateDetailsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		details, err := svc.PushCertificateDetails(ctx)
+		return pushCertificateDetailsResponse{PushCertificateDetails: details, Err: err}, nil
+	}
+}
+
+func (e Endpoints) PushCertificateDetails(ctx context.Context) (*PushCertificateDetails, error) {
+	response, err := e.PushCertificateDetailsEndpoint(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(pushCertificateDetailsResponse)
+	return resp.PushCertificateDetails, resp.Err
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/pubsub"
+)
+
+// PushCertificateExpiryTopic is published with the JSON PushCertificateDetails
+// when the push certificate reaches one of ExpiryWarningDays, and when it
+// expired.
+const PushCertificateExpiryTopic = "mdm.PushCertificateExpiry"
+
+// ExpiryWarningDays are the days before the push certificate expires at which
+// a warning is published.
+var ExpiryWarningDays = []int{30, 14, 7, 1}
+
+// ExpiryWorker checks the push certificate periodically and warns about its
+// expiry.
+type ExpiryWorker struct {
+	store    Store
+	pub      pubsub.Publisher
+	logger   log.Logger
+	interval time.Duration
+
+	// warned is the last warning threshold of every certificate serial, so
+	// every threshold is only warned about once. A renewed certificate has a
+	// new serial. -1 is the warning that the certificate expired.
+	warned map[string]int
+}
+
+func NewExpiryWorker(store Store, pub pubsub.Publisher, logger log.Logger) *ExpiryWorker {
+	return &ExpiryWorker{
+		store:    store,
+		pub:      pub,
+		logger:   logger,
+		interval: time.Hour,
+		warned:   make(map[string]int),
+	}
+}
+
+func (w *ExpiryWorker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(ctx, time.Now()); err != nil {
+			level.Info(w.logger).Log("msg", "check push certificate expiry", "err", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (w *ExpiryWorker) check(ctx context.Context, now time.Time) error {
+	cert, err := w.store.PushCertificate()
+	if err != nil {
+		// no push certificate has been uploaded yet.
+		return nil
+	}
+	details := NewPushCertificateDetails(cert.Leaf, now)
+	threshold, ok := expiryThreshold(details)
+	if !ok {
+		return nil
+	}
+	if last, warned := w.warned[details.Serial]; warned && last <= threshold {
+		return nil
+	}
+	w.warned[details.Serial] = threshold
+
+	if details.Expired {
+		level.Info(w.logger).Log("msg", "push certificate expired, devices can't be pushed until it is renewed",
+			"topic", details.Topic, "not_after", details.NotAfter)
+	} else {
+		level.Info(w.logger).Log("msg", "push certificate expires soon, renew it with the same Apple ID",
+			"topic", details.Topic, "not_after", details.NotAfter, "days_left", details.DaysLeft)
+	}
+	message, err := json.Marshal(details)
+	if err != nil {
+		return errors.Wrap(err, "marshal push certificate details")
+	}
+	return w.pub.Publish(ctx, PushCertificateExpiryTopic, message)
+}
+
+// expiryThreshold returns the smallest of ExpiryWarningDays which the
+// certificate reached, or -1 if it expired.
+func expiryThreshold(details *PushCertificateDetails) (int, bool) {
+	if details.Expired {
+		return -1, true
+	}
+	threshold, ok := 0, false
+	for _, days := range ExpiryWarningDays {
+		if details.DaysLeft < days && (!ok || days < threshold) {
+			threshold, ok = days, true
+		}
+	}
+	return threshold, ok
+}
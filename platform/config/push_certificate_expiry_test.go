@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/micromdm/micromdm/pkg/crypto"
+)
+
+func TestExpiryWorker(t *testing.T) {
+	notAfter := time.Date(2022, 3, 15, 0, 0, 0, 0, time.UTC)
+	store := new(certStore)
+	if err := store.SavePushCertificate(newPushCert(t, 1, "com.apple.mgmt.External.test", notAfter)); err != nil {
+		t.Fatal(err)
+	}
+	pub := new(recordingPublisher)
+	w := NewExpiryWorker(store, pub, log.NewNopLogger())
+	ctx := context.Background()
+
+	days := func(n float64) time.Time {
+		return notAfter.Add(-time.Duration(n * 24 * float64(time.Hour)))
+	}
+	for _, tt := range []struct {
+		now      time.Time
+		warnings int
+	}{
+		{days(45), 0},
+		{days(29.5), 1}, // 30 days
+		{days(29), 1},
+		{days(20), 1},
+		{days(13), 2},  // 14 days
+		{days(3), 3},   // 7 days
+		{days(0.5), 4}, // 1 day
+		{days(0.2), 4},
+		{days(-1), 5}, // expired
+		{days(-2), 5},
+	} {
+		if err := w.check(ctx, tt.now); err != nil {
+			t.Fatal(err)
+		}
+		if have, want := len(pub.messages), tt.warnings; have != want {
+			t.Errorf("%s before expiry: have %d warnings, want %d", notAfter.Sub(tt.now), have, want)
+		}
+	}
+
+	// a renewed certificate has a new serial and is warned about again.
+	if err := store.SavePushCertificate(newPushCert(t, 2, "com.apple.mgmt.External.test", notAfter.AddDate(0, 0, 5))); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.check(ctx, days(0)); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(pub.messages), 6; have != want {
+		t.Errorf("have %d warnings, want %d", have, want)
+	}
+}
+
+func TestSavePushCertificateTopic(t *testing.T) {
+	store := new(certStore)
+	svc := New(store)
+	ctx := context.Background()
+	notAfter := time.Now().AddDate(1, 0, 0)
+
+	cert, key := newPushCert(t, 1, "com.apple.mgmt.External.test", notAfter)
+	if err := svc.SavePushCertificate(ctx, cert, key); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, key = newPushCert(t, 2, "com.apple.mgmt.External.test", notAfter)
+	if err := svc.SavePushCertificate(ctx, cert, key); err != nil {
+		t.Errorf("renewing with the same topic: %s", err)
+	}
+
+	cert, key = newPushCert(t, 3, "com.apple.mgmt.External.other", notAfter)
+	err := svc.SavePushCertificate(ctx, cert, key)
+	if sc, ok := err.(interface{ StatusCode() int }); !ok || sc.StatusCode() != http.StatusBadRequest {
+		t.Errorf("have %v, want a topic mismatch error", err)
+	}
+
+	details, err := svc.PushCertificateDetails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := details.Serial, "2"; have != want {
+		t.Errorf("have serial %s, want %s", have, want)
+	}
+	if have, want := details.Topic, "com.apple.mgmt.External.test"; have != want {
+		t.Errorf("have topic %s, want %s", have, want)
+	}
+}
+
+func newPushCert(t *testing.T, serial int64, topic string, notAfter time.Time) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{
+			CommonName: "APSP:test",
+			ExtraNames: []pkix.AttributeTypeAndValue{{
+				Type:  asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1},
+				Value: topic,
+			}},
+		},
+		NotBefore: notAfter.AddDate(-1, 0, 0),
+		NotAfter:  notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM
+}
+
+// certStore keeps the push certificate in memory. The other methods of Store
+// are not used.
+type certStore struct {
+	Store
+	conf *ServerConfig
+}
+
+func (s *certStore) SavePushCertificate(cert, key []byte) error {
+	s.conf = &ServerConfig{PushCertificate: cert, PrivateKey: key}
+	return nil
+}
+
+func (s *certStore) PushCertificate() (*tls.Certificate, error) {
+	if s.conf == nil {
+		return nil, errors.New("no push certificate")
+	}
+	return ParsePushCertificate(s.conf)
+}
+
+func (s *certStore) PushTopic() (string, error) {
+	cert, err := s.PushCertificate()
+	if err != nil {
+		return "", err
+	}
+	return crypto.TopicFromCert(cert.Leaf)
+}
+
+type recordingPublisher struct {
+	messages [][]byte
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, topic string, msg []byte) error {
+	if topic == PushCertificateExpiryTopic {
+		p.messages = append(p.messages, msg)
+	}
+	return nil
+}
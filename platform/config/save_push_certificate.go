@@ -2,18 +2,47 @@ package config
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/micromdm/micromdm/pkg/crypto"
 	"github.com/micromdm/micromdm/pkg/httputil"
 	"github.com/pkg/errors"
 )
 
+// SavePushCertificate saves the push certificate and key. A renewed
+// certificate must have the topic of the current one, because enrolled
+// devices only accept pushes for the topic of their enrollment profile.
 func (svc *ConfigService) SavePushCertificate(ctx context.Context, cert, key []byte) error {
-	err := svc.store.SavePushCertificate(cert, key)
+	pushCert, err := ParsePushCertificate(&ServerConfig{PushCertificate: cert, PrivateKey: key})
+	if err != nil {
+		return errors.Wrap(err, "save push certificate")
+	}
+	topic, err := crypto.TopicFromCert(pushCert.Leaf)
+	if err != nil {
+		return errors.Wrap(err, "save push certificate")
+	}
+	// a current certificate which can't be read doesn't prevent replacing it.
+	if current, err := svc.store.PushTopic(); err == nil && current != topic {
+		return topicMismatchError{current: current, topic: topic}
+	}
+	err = svc.store.SavePushCertificate(cert, key)
 	return errors.Wrap(err, "save push certificate")
 }
 
+type topicMismatchError struct {
+	current, topic string
+}
+
+func (e topicMismatchError) Error() string {
+	return fmt.Sprintf("push certificate topic %s differs from the current topic %s: renew the certificate with the Apple ID which created it", e.topic, e.current)
+}
+
+func (e topicMismatchError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
 type saveRequest struct {
 	Cert []byte `json:"cert"`
 	Key  []byte `json:"key"`
@@ -10,6 +10,7 @@ import (
 type Service interface {
 	SavePushCertificate(ctx context.Context, cert, key []byte) error
 	GetPushCertificate(ctx context.Context) ([]byte, error)
+	PushCertificateDetails(ctx context.Context) (*PushCertificateDetails, error)
 	ApplyDEPToken(ctx context.Context, P7MContent []byte) error
 	GetDEPTokens(ctx context.Context) ([]DEPToken, []byte, error)
 }
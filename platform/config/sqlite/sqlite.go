@@ -0,0 +1,193 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/pkg/crypto"
+	"github.com/micromdm/micromdm/platform/config"
+	"github.com/micromdm/micromdm/platform/pubsub"
+)
+
+const (
+	serverConfigTableName = "server_config"
+	depTokensTableName    = "dep_tokens"
+	depKeypairTableName   = "dep_keypair"
+)
+
+// SQLite stores server configuration in SQLite.
+type SQLite struct {
+	db        *sqlx.DB
+	Publisher pubsub.Publisher
+}
+
+func New(db *sqlx.DB, pub pubsub.Publisher) *SQLite {
+	return &SQLite{db: db, Publisher: pub}
+}
+
+func (d *SQLite) SavePushCertificate(cert, key []byte) error {
+	query, args, err := sq.
+		Insert(serverConfigTableName).
+		Options("OR REPLACE").
+		Columns("id", "push_certificate", "private_key").
+		Values(1, cert, key).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building push certificate save query")
+	}
+	if _, err := d.db.Exec(query, args...); err != nil {
+		return errors.Wrap(err, "save push certificate in sqlite")
+	}
+	return d.Publisher.Publish(context.TODO(), config.ConfigTopic, []byte("updated"))
+}
+
+func (d *SQLite) serverConfig() (*config.ServerConfig, error) {
+	query, args, err := sq.
+		Select("push_certificate", "private_key").
+		From(serverConfigTableName).
+		Where(sq.Eq{"id": 1}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var conf config.ServerConfig
+	err = d.db.QueryRowx(query, args...).Scan(&conf.PushCertificate, &conf.PrivateKey)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, &notFound{"ServerConfig", "no config found in sqlite"}
+	}
+	return &conf, errors.Wrap(err, "get server config from sqlite")
+}
+
+func (d *SQLite) GetPushCertificate() ([]byte, error) {
+	cert, err := d.PushCertificate()
+	if err != nil {
+		return nil, err
+	}
+	if len(cert.Certificate) > 0 {
+		return cert.Certificate[0], nil
+	}
+	return nil, nil
+}
+
+func (d *SQLite) PushCertificate() (*tls.Certificate, error) {
+	conf, err := d.serverConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "get server config for push cert")
+	}
+	return config.ParsePushCertificate(conf)
+}
+
+func (d *SQLite) PushTopic() (string, error) {
+	cert, err := d.PushCertificate()
+	if err != nil {
+		return "", errors.Wrap(err, "get push certificate for topic")
+	}
+	topic, err := crypto.TopicFromCert(cert.Leaf)
+	return topic, errors.Wrap(err, "get topic from push certificate")
+}
+
+func (d *SQLite) AddToken(consumerKey string, json []byte) error {
+	query, args, err := sq.
+		Insert(depTokensTableName).
+		Options("OR REPLACE").
+		Columns("consumer_key", "token", "added_at").
+		Values(consumerKey, string(json), time.Now().UTC()).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building dep token save query")
+	}
+	if _, err := d.db.Exec(query, args...); err != nil {
+		return errors.Wrap(err, "save dep token in sqlite")
+	}
+	return d.Publisher.Publish(context.TODO(), config.DEPTokenTopic, json)
+}
+
+// DEPTokens returns the DEP tokens with the most recently added token first,
+// because the server only uses the first one.
+func (d *SQLite) DEPTokens() ([]config.DEPToken, error) {
+	query, args, err := sq.
+		Select("token").
+		From(depTokensTableName).
+		OrderBy("added_at DESC", "consumer_key").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var tokens []string
+	if err := d.db.Select(&tokens, query, args...); err != nil {
+		return nil, errors.Wrap(err, "list dep tokens")
+	}
+	var result []config.DEPToken
+	for _, t := range tokens {
+		var depToken config.DEPToken
+		if err := json.Unmarshal([]byte(t), &depToken); err != nil {
+			// TODO: log problematic DEP token, or remove altogether?
+			continue
+		}
+		result = append(result, depToken)
+	}
+	return result, nil
+}
+
+func (d *SQLite) DEPKeypair() (key *rsa.PrivateKey, cert *x509.Certificate, err error) {
+	query, args, err := sq.
+		Select("key", "certificate").
+		From(depKeypairTableName).
+		Where(sq.Eq{"id": 1}).
+		ToSql()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "building sql")
+	}
+	var keyBytes, certBytes []byte
+	err = d.db.QueryRowx(query, args...).Scan(&keyBytes, &certBytes)
+	if errors.Cause(err) == sql.ErrNoRows {
+		// if there is no certificate or private key then generate
+		return d.generateAndStoreDEPKeypair()
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get dep keypair from sqlite")
+	}
+	key, err = x509.ParsePKCS1PrivateKey(keyBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err = x509.ParseCertificate(certBytes)
+	return key, cert, err
+}
+
+func (d *SQLite) generateAndStoreDEPKeypair() (key *rsa.PrivateKey, cert *x509.Certificate, err error) {
+	key, cert, err = crypto.SimpleSelfSignedRSAKeypair("micromdm-dep-token", 365)
+	if err != nil {
+		return nil, nil, err
+	}
+	query, args, err := sq.
+		Insert(depKeypairTableName).
+		Options("OR REPLACE").
+		Columns("id", "key", "certificate").
+		Values(1, x509.MarshalPKCS1PrivateKey(key), cert.Raw).
+		ToSql()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "building dep keypair save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return key, cert, errors.Wrap(err, "save dep keypair in sqlite")
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/dep/sync"
+)
+
+const (
+	cursorTableName     = "dep_sync_cursor"
+	autoAssignTableName = "dep_auto_assigners"
+)
+
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func (d *SQLite) LoadCursor() (*sync.Cursor, error) {
+	query, args, err := sq.
+		Select("value", "created_at").
+		From(cursorTableName).
+		Where(sq.Eq{"id": 1}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var cursor sync.Cursor
+	err = d.db.QueryRowx(query, args...).Scan(&cursor.Value, &cursor.CreatedAt)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return &cursor, nil // TODO add notfound
+	}
+	return &cursor, errors.Wrap(err, "load cursor from sqlite")
+}
+
+func (d *SQLite) SaveCursor(c sync.Cursor) error {
+	query, args, err := sq.
+		Insert(cursorTableName).
+		Options("OR REPLACE").
+		Columns("id", "value", "created_at").
+		Values(1, c.Value, c.CreatedAt).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building dep cursor save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrap(err, "saving dep sync cursor")
+}
+
+func (d *SQLite) SaveAutoAssigner(a *sync.AutoAssigner) error {
+	if a.Filter != "*" {
+		return errors.New("only '*' filter auto-assigners supported")
+	}
+	query, args, err := sq.
+		Insert(autoAssignTableName).
+		Options("OR REPLACE").
+		Columns("filter", "profile_uuid").
+		Values(a.Filter, a.ProfileUUID).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building auto-assigner save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrap(err, "saving auto-assigner")
+}
+
+func (d *SQLite) DeleteAutoAssigner(filter string) error {
+	query, args, err := sq.
+		Delete(autoAssignTableName).
+		Where(sq.Eq{"filter": filter}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrap(err, "deleting auto-assigner")
+}
+
+func (d *SQLite) LoadAutoAssigners() ([]sync.AutoAssigner, error) {
+	query, args, err := sq.
+		Select("filter", "profile_uuid").
+		From(autoAssignTableName).
+		OrderBy("filter").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading auto-assigners")
+	}
+	defer rows.Close()
+	var aa []sync.AutoAssigner
+	for rows.Next() {
+		var a sync.AutoAssigner
+		if err := rows.Scan(&a.Filter, &a.ProfileUUID); err != nil {
+			return nil, errors.Wrap(err, "loading auto-assigners")
+		}
+		aa = append(aa, a)
+	}
+	return aa, errors.Wrap(rows.Err(), "loading auto-assigners")
+}
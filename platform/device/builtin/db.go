@@ -1,6 +1,7 @@
 package builtin
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 
@@ -37,6 +38,10 @@ func NewDB(db *bolt.DB) (*DB, error) {
 			return err
 		}
 		_, err = tx.CreateBucketIfNotExists([]byte(udidCertAuthBucket))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(UserEnrollmentBucket))
 		return err
 	})
 	if err != nil {
@@ -81,6 +86,52 @@ func (db *DB) List(ctx context.Context, opt device.ListDevicesOption) ([]device.
 	return devices, err
 }
 
+// eachDeviceBatch is the number of devices EachDevice reads per transaction.
+const eachDeviceBatch = 500
+
+// EachDevice calls fn for every device. Devices are read in batches, each in
+// its own read transaction, so that a slow fn does not hold a transaction open
+// for the whole fleet.
+func (db *DB) EachDevice(ctx context.Context, fn func(device.Device) error) error {
+	var after []byte
+	for {
+		batch := make([]device.Device, 0, eachDeviceBatch)
+		err := db.View(func(tx *bolt.Tx) error {
+			c := tx.Bucket([]byte(DeviceBucket)).Cursor()
+			k, v := c.First()
+			if after != nil {
+				k, v = c.Seek(after)
+				if bytes.Equal(k, after) {
+					k, v = c.Next()
+				}
+			}
+			for ; k != nil && len(batch) < eachDeviceBatch; k, v = c.Next() {
+				var dev device.Device
+				if err := device.UnmarshalDevice(v, &dev); err != nil {
+					return err
+				}
+				batch = append(batch, dev)
+				after = append(after[:0], k...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, dev := range batch {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(dev); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachDeviceBatch {
+			return nil
+		}
+	}
+}
+
 func (db *DB) Save(ctx context.Context, dev *device.Device) error {
 	tx, err := db.DB.Begin(true)
 	if err != nil {
@@ -226,3 +277,24 @@ func (db *DB) GetUDIDCertHash(udid []byte) ([]byte, error) {
 	})
 	return certHash, err
 }
+
+// UDIDByCertHash returns the UDID the certificate hash is associated with.
+func (db *DB) UDIDByCertHash(certHash []byte) (string, error) {
+	var udid string
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(udidCertAuthBucket))
+		if b == nil {
+			return fmt.Errorf("bucket %q not found!", udidCertAuthBucket)
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if udid == "" && bytes.Equal(v, certHash) {
+				udid = string(k)
+			}
+			return nil
+		})
+	})
+	if err == nil && udid == "" {
+		return "", &notFound{"UDID", "cert hash"}
+	}
+	return udid, err
+}
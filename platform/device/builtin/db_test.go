@@ -3,6 +3,7 @@ package builtin
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -139,6 +140,32 @@ func TestDeleteBySerial(t *testing.T) {
 	}
 }
 
+func TestEachDevice(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	// more than one batch
+	total := eachDeviceBatch + 3
+	for i := 0; i < total; i++ {
+		dev := &device.Device{UUID: fmt.Sprintf("uuid-%04d", i), SerialNumber: fmt.Sprintf("serial-%04d", i)}
+		if err := db.Save(ctx, dev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	err := db.EachDevice(ctx, func(dev device.Device) error {
+		seen[dev.UUID] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(seen), total; have != want {
+		t.Errorf("have %d devices, want %d", have, want)
+	}
+}
+
 func setupDB(t *testing.T) *DB {
 	f, _ := ioutil.TempFile("", "bolt-")
 	f.Close()
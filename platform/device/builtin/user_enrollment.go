@@ -0,0 +1,65 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// UserEnrollmentBucket stores user enrollments by EnrollmentID.
+const UserEnrollmentBucket = "mdm.UserEnrollments"
+
+func (db *DB) SaveUserEnrollment(ctx context.Context, e *device.UserEnrollment) error {
+	v, err := device.MarshalUserEnrollment(e)
+	if err != nil {
+		return errors.Wrap(err, "marshal user enrollment")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(UserEnrollmentBucket))
+		return b.Put([]byte(e.EnrollmentID), v)
+	})
+}
+
+func (db *DB) UserEnrollmentByID(ctx context.Context, enrollmentID string) (*device.UserEnrollment, error) {
+	var e device.UserEnrollment
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(UserEnrollmentBucket)).Get([]byte(enrollmentID))
+		if v == nil {
+			return &notFound{"UserEnrollment", fmt.Sprintf("enrollment id %s", enrollmentID)}
+		}
+		return device.UnmarshalUserEnrollment(v, &e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (db *DB) ListUserEnrollments(ctx context.Context) ([]device.UserEnrollment, error) {
+	var enrollments []device.UserEnrollment
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(UserEnrollmentBucket)).ForEach(func(k, v []byte) error {
+			var e device.UserEnrollment
+			if err := device.UnmarshalUserEnrollment(v, &e); err != nil {
+				return err
+			}
+			enrollments = append(enrollments, e)
+			return nil
+		})
+	})
+	return enrollments, err
+}
+
+func (db *DB) DeleteUserEnrollment(ctx context.Context, enrollmentID string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(UserEnrollmentBucket))
+		if b.Get([]byte(enrollmentID)) == nil {
+			return &notFound{"UserEnrollment", fmt.Sprintf("enrollment id %s", enrollmentID)}
+		}
+		return b.Delete([]byte(enrollmentID))
+	})
+}
@@ -38,9 +38,46 @@ func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransp
 		).Endpoint()
 	}
 
+	var searchDevicesEndpoint endpoint.Endpoint
+	{
+		searchDevicesEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/devices/search"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeSearchDevicesResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var fleetStatsEndpoint endpoint.Endpoint
+	{
+		fleetStatsEndpoint = httptransport.NewClient(
+			"GET",
+			httputil.CopyURL(u, "/v1/devices/stats"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeFleetStatsResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var listUserEnrollmentsEndpoint endpoint.Endpoint
+	{
+		listUserEnrollmentsEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/devices/user-enrollments"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeListUserEnrollmentsResponse,
+			opts...,
+		).Endpoint()
+	}
+
 	return Endpoints{
 		ListDevicesEndpoint:   listDevicesEndpoint,
 		RemoveDevicesEndpoint: removeDevicesEndpoint,
+		SearchDevicesEndpoint: searchDevicesEndpoint,
+		FleetStatsEndpoint:    fleetStatsEndpoint,
+
+		ListUserEnrollmentsEndpoint: listUserEnrollmentsEndpoint,
 	}, nil
 
 }
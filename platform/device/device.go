@@ -11,6 +11,25 @@ import (
 
 const DeviceEnrolledTopic = "mdm.DeviceEnrolled"
 
+// GroupParam is the CheckInURL query parameter which carries the enrollment
+// group of a device. Devices join the group on Authenticate, and Blueprints
+// can be limited to groups.
+const GroupParam = "group"
+
+// SetupUserParam and SetupFullNameParam are the check-in parameters which
+// carry the user who signed in at Setup Assistant. The primary account of the
+// device is prefilled with them. They are set from the sign in invitation of
+// the device, never taken from the CheckInURL.
+const (
+	SetupUserParam     = "setup_user"
+	SetupFullNameParam = "setup_full_name"
+)
+
+// OwnerParam is the check-in parameter which carries the owner of a device
+// which enrolled with an enrollment invitation. Only the invitation check-in
+// middleware sets it.
+const OwnerParam = "owner"
+
 type Device struct {
 	UUID                   string           `db:"uuid"`
 	UDID                   string           `db:"udid"`
@@ -39,6 +58,16 @@ type Device struct {
 	DEPProfileAssignedBy   string           `db:"dep_profile_assigned_by"`
 	LastSeen               time.Time        `db:"last_seen"`
 	BootstrapToken         []byte           `db:"bootstrap_token"`
+	Supervised             bool             `db:"supervised"`
+	// PushInvalid is set when APNs rejected the push token of the device,
+	// until the device sends a new one.
+	PushInvalid bool `db:"push_invalid"`
+	// Group is the enrollment group of the device, from the GroupParam of
+	// its CheckInURL.
+	Group string `db:"enrollment_group"`
+	// Owner is the user the device was assigned to by the enrollment
+	// invitation it enrolled with.
+	Owner string `db:"owner"`
 }
 
 // DEPProfileStatus is the status of the DEP Profile
@@ -82,6 +111,10 @@ func MarshalDevice(dev *Device) ([]byte, error) {
 		DepProfileAssignedBy:   dev.DEPProfileAssignedBy,
 		LastSeen:               timeToNano(dev.LastSeen),
 		BootstrapToken:         dev.BootstrapToken,
+		Supervised:             dev.Supervised,
+		PushInvalid:            dev.PushInvalid,
+		Group:                  dev.Group,
+		Owner:                  dev.Owner,
 	}
 	return proto.Marshal(&protodev)
 }
@@ -118,6 +151,10 @@ func UnmarshalDevice(data []byte, dev *Device) error {
 	dev.DEPProfileAssignedBy = pb.GetDepProfileAssignedBy()
 	dev.LastSeen = timeFromNano(pb.GetLastSeen())
 	dev.BootstrapToken = pb.GetBootstrapToken()
+	dev.Supervised = pb.GetSupervised()
+	dev.PushInvalid = pb.GetPushInvalid()
+	dev.Group = pb.GetGroup()
+	dev.Owner = pb.GetOwner()
 	return nil
 }
 
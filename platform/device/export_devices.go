@@ -0,0 +1,279 @@
+package device
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+// DeviceIterator is implemented by stores which can visit every device
+// without loading the whole fleet into memory.
+type DeviceIterator interface {
+	EachDevice(ctx context.Context, fn func(Device) error) error
+}
+
+// Export formats.
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+)
+
+type ExportDevicesOption struct {
+	// Format is ExportFormatCSV or ExportFormatJSONL.
+	Format string
+	// Columns to export. All columns are exported if empty.
+	Columns []string
+}
+
+type exportColumn struct {
+	name  string
+	value func(d *Device, owners []string) interface{}
+}
+
+// exportColumns are the exportable columns in their default order. Push
+// tokens, unlock tokens and bootstrap tokens are never exported.
+var exportColumns = []exportColumn{
+	{"serial_number", func(d *Device, _ []string) interface{} { return d.SerialNumber }},
+	{"udid", func(d *Device, _ []string) interface{} { return d.UDID }},
+	{"device_name", func(d *Device, _ []string) interface{} { return d.DeviceName }},
+	{"model", func(d *Device, _ []string) interface{} { return d.Model }},
+	{"model_name", func(d *Device, _ []string) interface{} { return d.ModelName }},
+	{"product_name", func(d *Device, _ []string) interface{} { return d.ProductName }},
+	{"os_version", func(d *Device, _ []string) interface{} { return d.OSVersion }},
+	{"build_version", func(d *Device, _ []string) interface{} { return d.BuildVersion }},
+	{"imei", func(d *Device, _ []string) interface{} { return d.IMEI }},
+	{"meid", func(d *Device, _ []string) interface{} { return d.MEID }},
+	{"color", func(d *Device, _ []string) interface{} { return d.Color }},
+	{"asset_tag", func(d *Device, _ []string) interface{} { return d.AssetTag }},
+	{"description", func(d *Device, _ []string) interface{} { return d.Description }},
+	{"enrolled", func(d *Device, _ []string) interface{} { return d.Enrolled }},
+	{"awaiting_configuration", func(d *Device, _ []string) interface{} { return d.AwaitingConfiguration }},
+	{"supervised", func(d *Device, _ []string) interface{} { return d.Supervised }},
+	{"push_invalid", func(d *Device, _ []string) interface{} { return d.PushInvalid }},
+	{"group", func(d *Device, _ []string) interface{} { return d.Group }},
+	{"owner", func(d *Device, _ []string) interface{} { return d.Owner }},
+	{"last_seen", func(d *Device, _ []string) interface{} { return d.LastSeen }},
+	{"dep_profile_status", func(d *Device, _ []string) interface{} { return string(d.DEPProfileStatus) }},
+	{"dep_profile_uuid", func(d *Device, _ []string) interface{} { return d.DEPProfileUUID }},
+	{"dep_profile_assign_time", func(d *Device, _ []string) interface{} { return d.DEPProfileAssignTime }},
+	{"dep_profile_push_time", func(d *Device, _ []string) interface{} { return d.DEPProfilePushTime }},
+	{"dep_profile_assigned_date", func(d *Device, _ []string) interface{} { return d.DEPProfileAssignedDate }},
+	{"dep_profile_assigned_by", func(d *Device, _ []string) interface{} { return d.DEPProfileAssignedBy }},
+	{"owners", func(_ *Device, owners []string) interface{} { return owners }},
+}
+
+func selectExportColumns(names []string) ([]exportColumn, error) {
+	if len(names) == 0 {
+		return exportColumns, nil
+	}
+	var selected []exportColumn
+	for _, name := range names {
+		var found bool
+		for _, c := range exportColumns {
+			if c.name == name {
+				selected = append(selected, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown export column %q", name)
+		}
+	}
+	return selected, nil
+}
+
+// exportRowWriter writes a single device in an export format.
+type exportRowWriter interface {
+	WriteHeader(columns []exportColumn) error
+	WriteRow(columns []exportColumn, values []interface{}) error
+	Flush() error
+}
+
+type csvRowWriter struct{ w *csv.Writer }
+
+func (c csvRowWriter) WriteHeader(columns []exportColumn) error {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	return c.w.Write(header)
+}
+
+func (c csvRowWriter) WriteRow(columns []exportColumn, values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			record[i] = v
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.UTC().Format(time.RFC3339)
+			}
+		case []string:
+			record[i] = strings.Join(v, ",")
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonlRowWriter struct{ enc *json.Encoder }
+
+func (j jsonlRowWriter) WriteHeader([]exportColumn) error { return nil }
+
+func (j jsonlRowWriter) WriteRow(columns []exportColumn, values []interface{}) error {
+	row := make(map[string]interface{}, len(columns))
+	for i, col := range columns {
+		row[col.name] = values[i]
+	}
+	return j.enc.Encode(row)
+}
+
+func (j jsonlRowWriter) Flush() error { return nil }
+
+// exportFlushRows is the number of rows after which the response is flushed
+// to the client.
+const exportFlushRows = 100
+
+// MakeExportDevicesHandler returns a handler which streams every device as
+// CSV or JSON Lines. The format and columns query parameters select the
+// ExportDevicesOption. If index is not nil, device owners are exported from
+// it.
+func MakeExportDevicesHandler(store DeviceIterator, index *SearchIndex) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opt := ExportDevicesOption{Format: r.URL.Query().Get("format")}
+		if columns := r.URL.Query().Get("columns"); columns != "" {
+			opt.Columns = strings.Split(columns, ",")
+		}
+		columns, err := selectExportColumns(opt.Columns)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ew := &exportResponseWriter{ResponseWriter: w}
+		var rw exportRowWriter
+		switch opt.Format {
+		case ExportFormatCSV, "":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="devices.csv"`)
+			rw = csvRowWriter{csv.NewWriter(ew)}
+		case ExportFormatJSONL:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="devices.jsonl"`)
+			rw = jsonlRowWriter{json.NewEncoder(ew)}
+		default:
+			http.Error(w, fmt.Sprintf("unknown export format %q", opt.Format), http.StatusBadRequest)
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+		flush := func() error {
+			if err := rw.Flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				ew.written = true
+				flusher.Flush()
+			}
+			return nil
+		}
+
+		var rows int
+		err = rw.WriteHeader(columns)
+		if err == nil {
+			err = store.EachDevice(r.Context(), func(d Device) error {
+				var owners []string
+				if index != nil {
+					owners = index.OwnerNames(d.UDID)
+				}
+				values := make([]interface{}, len(columns))
+				for i, col := range columns {
+					values[i] = col.value(&d, owners)
+				}
+				if err := rw.WriteRow(columns, values); err != nil {
+					return err
+				}
+				if rows++; rows%exportFlushRows == 0 {
+					return flush()
+				}
+				return nil
+			})
+		}
+		if err == nil {
+			err = flush()
+		}
+		if err != nil && !ew.written {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if err != nil {
+			// The status was sent with the first rows, so abort the response
+			// to let the client know the export is incomplete.
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// exportResponseWriter records whether the response was started, after
+// which errors can't change the status anymore.
+type exportResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *exportResponseWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+// ExportDevices streams the device export of the server at instance into w.
+func ExportDevices(ctx context.Context, client *http.Client, instance, token string, opt ExportDevicesOption, w io.Writer) error {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return err
+	}
+	u = httputil.CopyURL(u, "/v1/devices/export")
+	q := url.Values{}
+	q.Set("format", opt.Format)
+	if len(opt.Columns) > 0 {
+		q.Set("columns", strings.Join(opt.Columns, ","))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("micromdm", token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "requesting device export")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("device export failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, err = io.Copy(w, resp.Body)
+	return errors.Wrap(err, "reading device export")
+}
@@ -0,0 +1,157 @@
+package device
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+// FleetStats are device counts by attribute. Each map counts every device
+// once, so its values add up to Total.
+type FleetStats struct {
+	Total            int            `json:"total"`
+	OSVersion        map[string]int `json:"os_version"`
+	Model            map[string]int `json:"model"`
+	EnrollmentStatus map[string]int `json:"enrollment_status"`
+	DEPProfileStatus map[string]int `json:"dep_profile_status"`
+	LastSeen         map[string]int `json:"last_seen"`
+	Supervised       map[string]int `json:"supervised"`
+	Group            map[string]int `json:"group"`
+
+	// ComputedAt is when the stats were computed. Stats are cached, so they
+	// can be up to the cache TTL old.
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// Last seen buckets of FleetStats.
+const (
+	LastSeen24h    = "24h"
+	LastSeen7d     = "7d"
+	LastSeen30d    = "30d"
+	LastSeenOlder  = "30d+"
+	LastSeenNever  = "never"
+	unknownStatKey = "unknown"
+)
+
+// DefaultStatsCacheTTL is how long fleet stats are cached unless changed
+// with WithStatsCacheTTL.
+const DefaultStatsCacheTTL = time.Minute
+
+// WithStatsCacheTTL sets how long fleet stats are cached.
+func WithStatsCacheTTL(ttl time.Duration) Option {
+	return func(svc *DeviceService) {
+		svc.statsTTL = ttl
+	}
+}
+
+func (svc *DeviceService) FleetStats(ctx context.Context) (*FleetStats, error) {
+	// Holding the lock while computing means concurrent requests on an
+	// expired cache wait for a single pass over the store.
+	svc.statsMu.Lock()
+	defer svc.statsMu.Unlock()
+	now := time.Now()
+	if svc.stats != nil && now.Sub(svc.stats.ComputedAt) < svc.statsTTL {
+		return svc.stats, nil
+	}
+	stats, err := computeFleetStats(ctx, svc.store, now)
+	if err != nil {
+		return nil, err
+	}
+	svc.stats = stats
+	return stats, nil
+}
+
+func computeFleetStats(ctx context.Context, store DeviceIterator, now time.Time) (*FleetStats, error) {
+	stats := &FleetStats{
+		OSVersion:        make(map[string]int),
+		Model:            make(map[string]int),
+		EnrollmentStatus: make(map[string]int),
+		DEPProfileStatus: make(map[string]int),
+		LastSeen:         make(map[string]int),
+		Supervised:       make(map[string]int),
+		Group:            make(map[string]int),
+		ComputedAt:       now,
+	}
+	count := func(m map[string]int, key string) {
+		if key == "" {
+			key = unknownStatKey
+		}
+		m[key]++
+	}
+	err := store.EachDevice(ctx, func(d Device) error {
+		stats.Total++
+		count(stats.OSVersion, d.OSVersion)
+		model := d.ModelName
+		if model == "" {
+			model = d.Model
+		}
+		count(stats.Model, model)
+		if d.Enrolled {
+			count(stats.EnrollmentStatus, "enrolled")
+		} else {
+			count(stats.EnrollmentStatus, "unenrolled")
+		}
+		count(stats.DEPProfileStatus, string(d.DEPProfileStatus))
+		count(stats.LastSeen, lastSeenBucket(d.LastSeen, now))
+		count(stats.Supervised, strconv.FormatBool(d.Supervised))
+		count(stats.Group, d.Group)
+		return nil
+	})
+	return stats, err
+}
+
+func lastSeenBucket(lastSeen, now time.Time) string {
+	switch since := now.Sub(lastSeen); {
+	case lastSeen.IsZero():
+		return LastSeenNever
+	case since < 24*time.Hour:
+		return LastSeen24h
+	case since < 7*24*time.Hour:
+		return LastSeen7d
+	case since < 30*24*time.Hour:
+		return LastSeen30d
+	default:
+		return LastSeenOlder
+	}
+}
+
+type fleetStatsRequest struct{}
+type fleetStatsResponse struct {
+	Stats *FleetStats `json:"stats,omitempty"`
+	Err   error       `json:"err,omitempty"`
+}
+
+func (r fleetStatsResponse) Failed() error { return r.Err }
+
+func decodeFleetStatsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return fleetStatsRequest{}, nil
+}
+
+func decodeFleetStatsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp fleetStatsResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeFleetStatsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		stats, err := svc.FleetStats(ctx)
+		return fleetStatsResponse{
+			Stats: stats,
+			Err:   err,
+		}, nil
+	}
+}
+
+func (e Endpoints) FleetStats(ctx context.Context) (*FleetStats, error) {
+	response, err := e.FleetStatsEndpoint(ctx, fleetStatsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return response.(fleetStatsResponse).Stats, response.(fleetStatsResponse).Err
+}
@@ -0,0 +1,108 @@
+package device
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type countingStore struct {
+	sliceIterator
+	UserEnrollmentStore
+	passes int
+}
+
+func (s *countingStore) EachDevice(ctx context.Context, fn func(Device) error) error {
+	s.passes++
+	return s.sliceIterator.EachDevice(ctx, fn)
+}
+
+func (s *countingStore) List(context.Context, ListDevicesOption) ([]Device, error) {
+	return s.sliceIterator, nil
+}
+func (s *countingStore) DeleteByUDID(context.Context, string) error   { return nil }
+func (s *countingStore) DeleteBySerial(context.Context, string) error { return nil }
+
+func TestFleetStats(t *testing.T) {
+	now := time.Now()
+	store := &countingStore{sliceIterator: sliceIterator{
+		{UUID: "1", OSVersion: "12.3", ModelName: "MacBook Pro", Enrolled: true, Supervised: true, LastSeen: now.Add(-time.Hour), DEPProfileStatus: PUSHED},
+		{UUID: "2", OSVersion: "12.3", Model: "iPad8,1", Enrolled: true, LastSeen: now.Add(-72 * time.Hour)},
+		{UUID: "3", LastSeen: now.Add(-60 * 24 * time.Hour), DEPProfileStatus: ASSIGNED},
+		{UUID: "4"},
+	}}
+	svc := New(store)
+
+	stats, err := svc.FleetStats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		have int
+		want int
+	}{
+		{"total", stats.Total, 4},
+		{"os 12.3", stats.OSVersion["12.3"], 2},
+		{"os unknown", stats.OSVersion["unknown"], 2},
+		{"model name", stats.Model["MacBook Pro"], 1},
+		{"model identifier", stats.Model["iPad8,1"], 1},
+		{"enrolled", stats.EnrollmentStatus["enrolled"], 2},
+		{"unenrolled", stats.EnrollmentStatus["unenrolled"], 2},
+		{"dep pushed", stats.DEPProfileStatus["pushed"], 1},
+		{"dep unknown", stats.DEPProfileStatus["unknown"], 2},
+		{"seen 24h", stats.LastSeen[LastSeen24h], 1},
+		{"seen 7d", stats.LastSeen[LastSeen7d], 1},
+		{"seen 30d+", stats.LastSeen[LastSeenOlder], 1},
+		{"never seen", stats.LastSeen[LastSeenNever], 1},
+		{"supervised", stats.Supervised["true"], 1},
+		{"unsupervised", stats.Supervised["false"], 3},
+	}
+	for _, tt := range tests {
+		if tt.have != tt.want {
+			t.Errorf("%s: have %d, want %d", tt.name, tt.have, tt.want)
+		}
+	}
+
+	// cached
+	if _, err := svc.FleetStats(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := store.passes, 1; have != want {
+		t.Errorf("have %d passes over the store, want %d", have, want)
+	}
+
+	// expired
+	svc.statsTTL = 0
+	if _, err := svc.FleetStats(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := store.passes, 2; have != want {
+		t.Errorf("have %d passes over the store, want %d", have, want)
+	}
+}
+
+func TestSupervisedFromResponse(t *testing.T) {
+	resp := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>CommandUUID</key>
+	<string>abc</string>
+	<key>QueryResponses</key>
+	<dict>
+		<key>IsSupervised</key>
+		<true/>
+	</dict>
+	<key>Status</key>
+	<string>Acknowledged</string>
+</dict>
+</plist>`)
+	supervised, ok := supervisedFromResponse(resp)
+	if !ok || !supervised {
+		t.Errorf("have %v, %v, want true, true", supervised, ok)
+	}
+	if _, ok := supervisedFromResponse(nil); ok {
+		t.Error("empty response reported supervised state")
+	}
+}
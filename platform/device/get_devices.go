@@ -24,6 +24,9 @@ type DeviceDTO struct {
 	EnrollmentStatus bool             `json:"enrollment_status"`
 	LastSeen         time.Time        `json:"last_seen"`
 	DEPProfileStatus DEPProfileStatus `json:"dep_profile_status"`
+	Group            string           `json:"group,omitempty"`
+	Owner            string           `json:"owner,omitempty"`
+	PushInvalid      bool             `json:"push_invalid,omitempty"`
 }
 
 func (svc *DeviceService) ListDevices(ctx context.Context, opt ListDevicesOption) ([]DeviceDTO, error) {
@@ -36,6 +39,9 @@ func (svc *DeviceService) ListDevices(ctx context.Context, opt ListDevicesOption
 			EnrollmentStatus: d.Enrolled,
 			LastSeen:         d.LastSeen,
 			DEPProfileStatus: d.DEPProfileStatus,
+			Group:            d.Group,
+			Owner:            d.Owner,
+			PushInvalid:      d.PushInvalid,
 		})
 	}
 	return dto, err
@@ -0,0 +1,55 @@
+package device
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/platform/device/internal/deviceproto"
+)
+
+// OTAEnrollTopic is published with an OTAEnrollEvent when a device sends
+// phase 2 of an OTA enrollment with attributes signed by the Apple Device CA.
+const OTAEnrollTopic = "mdm.OTAEnroll"
+
+// OTAEnrollEvent is the device identity of an OTA enrollment.
+type OTAEnrollEvent struct {
+	UDID         string
+	SerialNumber string
+	ProductName  string
+	IMEI         string
+	MEID         string
+	BuildVersion string
+	DeviceName   string
+	Time         time.Time
+}
+
+func MarshalOTAEnrollEvent(e *OTAEnrollEvent) ([]byte, error) {
+	return proto.Marshal(&deviceproto.OTAEnrollEvent{
+		Udid:         e.UDID,
+		SerialNumber: e.SerialNumber,
+		ProductName:  e.ProductName,
+		Imei:         e.IMEI,
+		Meid:         e.MEID,
+		BuildVersion: e.BuildVersion,
+		DeviceName:   e.DeviceName,
+		Time:         timeToNano(e.Time),
+	})
+}
+
+func UnmarshalOTAEnrollEvent(data []byte, e *OTAEnrollEvent) error {
+	var pb deviceproto.OTAEnrollEvent
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to OTA enroll event")
+	}
+	e.UDID = pb.GetUdid()
+	e.SerialNumber = pb.GetSerialNumber()
+	e.ProductName = pb.GetProductName()
+	e.IMEI = pb.GetImei()
+	e.MEID = pb.GetMeid()
+	e.BuildVersion = pb.GetBuildVersion()
+	e.DeviceName = pb.GetDeviceName()
+	e.Time = timeFromNano(pb.GetTime())
+	return nil
+}
@@ -61,6 +61,53 @@ func TestPGCrud(t *testing.T) {
 	}
 }
 
+func TestUserEnrollments(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+
+	e := &device.UserEnrollment{
+		EnrollmentID:   "enrollment-1",
+		ManagedAppleID: "jane@example.org",
+		OSVersion:      "15.4",
+		Enrolled:       true,
+		LastSeen:       time.Now().UTC(),
+	}
+	if err := db.SaveUserEnrollment(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.UserEnrollmentByID(ctx, e.EnrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := found.ManagedAppleID, e.ManagedAppleID; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := found.LastSeen, e.LastSeen; !have.Equal(want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	list, err := db.ListUserEnrollments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(list), 1; have != want {
+		t.Errorf("have %d user enrollments, want %d", have, want)
+	}
+
+	if err := db.DeleteUserEnrollment(ctx, e.EnrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UserEnrollmentByID(ctx, e.EnrollmentID); !isNotFound(err) {
+		t.Errorf("have %v, want not found error", err)
+	}
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(interface{ NotFound() bool })
+	return ok && e.NotFound()
+}
+
 func setup(t *testing.T) *Postgres {
 	db, err := dbutil.OpenDBX(
 		"postgres",
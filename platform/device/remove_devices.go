@@ -10,22 +10,63 @@ import (
 
 func (svc *DeviceService) RemoveDevices(ctx context.Context, opt RemoveDevicesOptions) error {
 	for _, udid := range opt.UDIDs {
+		if err := svc.revoke(ctx, udid); err != nil {
+			return err
+		}
 		err := svc.store.DeleteByUDID(ctx, udid)
 		if err != nil {
 			return err
 		}
+		if svc.index != nil {
+			svc.index.RemoveByUDID(udid)
+		}
 	}
 
 	for _, serial := range opt.Serials {
+		if err := svc.revokeBySerial(ctx, serial); err != nil {
+			return err
+		}
 		err := svc.store.DeleteBySerial(ctx, serial)
 		if err != nil {
 			return err
 		}
+		if svc.index != nil {
+			svc.index.RemoveBySerial(serial)
+		}
+	}
+
+	for _, id := range opt.EnrollmentIDs {
+		if err := svc.store.DeleteUserEnrollment(ctx, id); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func (svc *DeviceService) revoke(ctx context.Context, udid string) error {
+	if svc.revoker == nil {
+		return nil
+	}
+	return svc.revoker.RevokeDevice(ctx, udid)
+}
+
+func (svc *DeviceService) revokeBySerial(ctx context.Context, serial string) error {
+	if svc.revoker == nil {
+		return nil
+	}
+	devices, err := svc.store.List(ctx, ListDevicesOption{FilterSerial: []string{serial}})
+	if err != nil {
+		return err
+	}
+	for _, dev := range devices {
+		if err := svc.revoker.RevokeDevice(ctx, dev.UDID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type removeDevicesRequest struct{ Opts RemoveDevicesOptions }
 
 type removeDevicesResponse struct {
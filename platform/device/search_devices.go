@@ -0,0 +1,84 @@
+package device
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type SearchDevicesOption struct {
+	// Query is matched against the device name, serial number, UDID, asset
+	// tag, model, DEP description and the device owners. Every word of the
+	// query must match the start of a word in one of these fields.
+	Query string `json:"query"`
+	// Fuzzy also matches words with a few typos.
+	Fuzzy bool `json:"fuzzy"`
+	Limit int  `json:"limit"`
+}
+
+type DeviceSearchResult struct {
+	DeviceDTO
+	DeviceName  string   `json:"device_name,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	AssetTag    string   `json:"asset_tag,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Owners      []string `json:"owners,omitempty"`
+
+	// MatchedFields lists the fields the query matched, such as device_name.
+	MatchedFields []string `json:"matched_fields"`
+	Score         int      `json:"score"`
+}
+
+func (svc *DeviceService) SearchDevices(ctx context.Context, opt SearchDevicesOption) ([]DeviceSearchResult, error) {
+	if svc.index == nil {
+		return nil, errors.New("device search index not configured")
+	}
+	return svc.index.Search(opt), nil
+}
+
+type searchDevicesRequest struct{ Opts SearchDevicesOption }
+type searchDevicesResponse struct {
+	Devices []DeviceSearchResult `json:"devices"`
+	Err     error                `json:"err,omitempty"`
+}
+
+func (r searchDevicesResponse) Failed() error { return r.Err }
+
+func decodeSearchDevicesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts SearchDevicesOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	req := searchDevicesRequest{
+		Opts: opts,
+	}
+	return req, err
+}
+
+func decodeSearchDevicesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp searchDevicesResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeSearchDevicesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(searchDevicesRequest)
+		devices, err := svc.SearchDevices(ctx, req.Opts)
+		return searchDevicesResponse{
+			Devices: devices,
+			Err:     err,
+		}, nil
+	}
+}
+
+func (e Endpoints) SearchDevices(ctx context.Context, opts SearchDevicesOption) ([]DeviceSearchResult, error) {
+	request := searchDevicesRequest{opts}
+	response, err := e.SearchDevicesEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(searchDevicesResponse).Devices, response.(searchDevicesResponse).Err
+}
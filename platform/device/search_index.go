@@ -0,0 +1,435 @@
+package device
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Fields which are indexed for device search.
+const (
+	SearchFieldDeviceName  = "device_name"
+	SearchFieldSerial      = "serial_number"
+	SearchFieldUDID        = "udid"
+	SearchFieldAssetTag    = "asset_tag"
+	SearchFieldModel       = "model"
+	SearchFieldModelName   = "model_name"
+	SearchFieldProductName = "product_name"
+	SearchFieldDescription = "description"
+	SearchFieldOwner       = "owner"
+)
+
+// Owner is a user of an enrolled device.
+type Owner struct {
+	UserID    string
+	Shortname string
+	Longname  string
+}
+
+// SearchIndex is an in-memory index over device attributes and device
+// owners. It supports prefix and fuzzy matching of search terms.
+//
+// The index is filled when the server starts and kept up to date by the
+// device Worker, which indexes every device it saves.
+type SearchIndex struct {
+	mu sync.Mutex
+	// devices by UUID.
+	devices map[string]*indexedDevice
+	// device UUIDs by UDID.
+	uuids map[string]string
+	// owners by device UDID, then by user ID.
+	owners map[string]map[string]Owner
+	// postings maps every term to the devices and fields containing it.
+	postings map[string][]posting
+	// terms are the sorted keys of postings, or nil if they need to be
+	// sorted again.
+	terms []string
+}
+
+type posting struct {
+	uuid  string
+	field string
+}
+
+type indexedDevice struct {
+	device   Device
+	postings []string // terms indexed for the device
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		devices:  make(map[string]*indexedDevice),
+		uuids:    make(map[string]string),
+		owners:   make(map[string]map[string]Owner),
+		postings: make(map[string][]posting),
+	}
+}
+
+// Load indexes every device in the store.
+func (idx *SearchIndex) Load(ctx context.Context, store DeviceIterator) error {
+	return store.EachDevice(ctx, func(dev Device) error {
+		idx.IndexDevice(dev)
+		return nil
+	})
+}
+
+// IndexDevice adds or replaces the device in the index.
+func (idx *SearchIndex) IndexDevice(dev Device) {
+	if dev.UUID == "" {
+		return
+	}
+	dev.BootstrapToken = nil // not searchable, and not worth keeping in memory
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeDevice(dev.UUID)
+	idx.addDevice(dev)
+}
+
+// IndexOwner adds or replaces a user of the device with the UDID.
+func (idx *SearchIndex) IndexOwner(udid string, owner Owner) {
+	if udid == "" {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.owners[udid] == nil {
+		idx.owners[udid] = make(map[string]Owner)
+	}
+	idx.owners[udid][owner.UserID] = owner
+	idx.reindexUDID(udid)
+}
+
+// OwnerNames returns the sorted short names of the users of the device with
+// the UDID.
+func (idx *SearchIndex) OwnerNames(udid string) []string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var names []string
+	for _, owner := range idx.owners[udid] {
+		names = append(names, owner.Shortname)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RemoveOwners removes all users of the device with the UDID.
+func (idx *SearchIndex) RemoveOwners(udid string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.owners[udid]; !ok {
+		return
+	}
+	delete(idx.owners, udid)
+	idx.reindexUDID(udid)
+}
+
+// RemoveByUDID removes devices with the UDID from the index.
+func (idx *SearchIndex) RemoveByUDID(udid string) {
+	idx.removeWhere(func(d Device) bool { return d.UDID == udid })
+}
+
+// RemoveBySerial removes devices with the serial number from the index.
+func (idx *SearchIndex) RemoveBySerial(serial string) {
+	idx.removeWhere(func(d Device) bool { return d.SerialNumber == serial })
+}
+
+func (idx *SearchIndex) removeWhere(match func(Device) bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for uuid, d := range idx.devices {
+		if match(d.device) {
+			idx.removeDevice(uuid)
+		}
+	}
+}
+
+func (idx *SearchIndex) reindexUDID(udid string) {
+	d, ok := idx.devices[idx.uuids[udid]]
+	if !ok {
+		return
+	}
+	dev := d.device
+	idx.removeDevice(dev.UUID)
+	idx.addDevice(dev)
+}
+
+// addDevice and removeDevice must be called with idx.mu held.
+func (idx *SearchIndex) addDevice(dev Device) {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{SearchFieldDeviceName, dev.DeviceName},
+		{SearchFieldSerial, dev.SerialNumber},
+		{SearchFieldUDID, dev.UDID},
+		{SearchFieldAssetTag, dev.AssetTag},
+		{SearchFieldModel, dev.Model},
+		{SearchFieldModelName, dev.ModelName},
+		{SearchFieldProductName, dev.ProductName},
+		{SearchFieldDescription, dev.Description},
+	}
+	for _, owner := range idx.owners[dev.UDID] {
+		fields = append(fields,
+			struct{ name, value string }{SearchFieldOwner, owner.Shortname},
+			struct{ name, value string }{SearchFieldOwner, owner.Longname},
+		)
+	}
+
+	indexed := &indexedDevice{device: dev}
+	seen := make(map[[2]string]bool) // term and field
+	for _, f := range fields {
+		for _, term := range searchTerms(f.value) {
+			if seen[[2]string{term, f.name}] {
+				continue
+			}
+			seen[[2]string{term, f.name}] = true
+			if _, ok := idx.postings[term]; !ok {
+				idx.terms = nil
+			}
+			idx.postings[term] = append(idx.postings[term], posting{uuid: dev.UUID, field: f.name})
+			indexed.postings = append(indexed.postings, term)
+		}
+	}
+	idx.devices[dev.UUID] = indexed
+	if dev.UDID != "" {
+		idx.uuids[dev.UDID] = dev.UUID
+	}
+}
+
+func (idx *SearchIndex) removeDevice(uuid string) {
+	d, ok := idx.devices[uuid]
+	if !ok {
+		return
+	}
+	for _, term := range d.postings {
+		postings := idx.postings[term][:0]
+		for _, p := range idx.postings[term] {
+			if p.uuid != uuid {
+				postings = append(postings, p)
+			}
+		}
+		if len(postings) == 0 {
+			delete(idx.postings, term)
+			idx.terms = nil
+			continue
+		}
+		idx.postings[term] = postings
+	}
+	delete(idx.devices, uuid)
+	if idx.uuids[d.device.UDID] == uuid {
+		delete(idx.uuids, d.device.UDID)
+	}
+}
+
+// searchTerms returns the lowercased value and each of its words, so that
+// "jsmith-mbp" can be found by "jsmith-m" as well as by "mbp".
+func searchTerms(value string) []string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return nil
+	}
+	terms := []string{value}
+	words := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 1 || (len(words) == 1 && words[0] != value) {
+		terms = append(terms, words...)
+	}
+	return terms
+}
+
+// sortedTerms must be called with idx.mu held.
+func (idx *SearchIndex) sortedTerms() []string {
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+	return idx.terms
+}
+
+// Scores of a matching term. The score of a device is the sum of the best
+// score of each query word.
+const (
+	scoreExact  = 3
+	scorePrefix = 2
+	scoreFuzzy  = 1
+)
+
+type searchMatch struct {
+	score  int
+	fields map[string]bool
+}
+
+// Search returns the devices matching every word of the query, best match
+// first, along with the fields which matched.
+func (idx *SearchIndex) Search(opt SearchDevicesOption) []DeviceSearchResult {
+	words := strings.Fields(strings.ToLower(opt.Query))
+	if len(words) == 0 {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	terms := idx.sortedTerms()
+
+	var matches map[string]*searchMatch
+	for i, word := range words {
+		wordMatches := make(map[string]*searchMatch)
+		add := func(term string, score int) {
+			for _, p := range idx.postings[term] {
+				m, ok := wordMatches[p.uuid]
+				if !ok {
+					m = &searchMatch{fields: make(map[string]bool)}
+					wordMatches[p.uuid] = m
+				}
+				if score > m.score {
+					m.score = score
+				}
+				m.fields[p.field] = true
+			}
+		}
+
+		for j := sort.SearchStrings(terms, word); j < len(terms) && strings.HasPrefix(terms[j], word); j++ {
+			if terms[j] == word {
+				add(terms[j], scoreExact)
+			} else {
+				add(terms[j], scorePrefix)
+			}
+		}
+		if opt.Fuzzy {
+			maxEdits := fuzzyEdits(word)
+			for _, term := range terms {
+				if maxEdits > 0 && !strings.HasPrefix(term, word) && fuzzyMatch(word, term, maxEdits) {
+					add(term, scoreFuzzy)
+				}
+			}
+		}
+
+		if i == 0 {
+			matches = wordMatches
+			continue
+		}
+		// every word must match
+		for uuid, m := range matches {
+			wm, ok := wordMatches[uuid]
+			if !ok {
+				delete(matches, uuid)
+				continue
+			}
+			m.score += wm.score
+			for f := range wm.fields {
+				m.fields[f] = true
+			}
+		}
+	}
+
+	results := make([]DeviceSearchResult, 0, len(matches))
+	for uuid, m := range matches {
+		d := idx.devices[uuid].device
+		result := DeviceSearchResult{
+			DeviceDTO: DeviceDTO{
+				SerialNumber:     d.SerialNumber,
+				UDID:             d.UDID,
+				EnrollmentStatus: d.Enrolled,
+				LastSeen:         d.LastSeen,
+				DEPProfileStatus: d.DEPProfileStatus,
+				PushInvalid:      d.PushInvalid,
+			},
+			DeviceName:  d.DeviceName,
+			Model:       d.Model,
+			AssetTag:    d.AssetTag,
+			Description: d.Description,
+			Score:       m.score,
+		}
+		for _, owner := range idx.owners[d.UDID] {
+			result.Owners = append(result.Owners, owner.Shortname)
+		}
+		sort.Strings(result.Owners)
+		for f := range m.fields {
+			result.MatchedFields = append(result.MatchedFields, f)
+		}
+		sort.Strings(result.MatchedFields)
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].DeviceName != results[j].DeviceName {
+			return results[i].DeviceName < results[j].DeviceName
+		}
+		return results[i].SerialNumber < results[j].SerialNumber
+	})
+
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+const defaultSearchLimit = 50
+
+// fuzzyEdits is the number of typos allowed in a query word.
+func fuzzyEdits(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 3:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzyMatch reports whether term, or its prefix of the same length as the
+// word, is within maxEdits edits of the word.
+func fuzzyMatch(word, term string, maxEdits int) bool {
+	w, t := []rune(word), []rune(term)
+	if len(t) > len(w) && editDistance(w, t[:len(w)]) <= maxEdits {
+		return true
+	}
+	return editDistance(w, t) <= maxEdits
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// the number of insertions, deletions, substitutions and transpositions of
+// adjacent characters needed to turn a into b.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, minInt(d[i][j-1]+1, d[i-1][j-1]+cost))
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
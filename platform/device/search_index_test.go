@@ -0,0 +1,71 @@
+package device
+
+import (
+	"testing"
+)
+
+func TestSearchIndex(t *testing.T) {
+	idx := NewSearchIndex()
+	idx.IndexDevice(Device{UUID: "1", UDID: "udid-1", SerialNumber: "C02ABC123", DeviceName: "jsmith-mbp", Model: "MacBookPro16,1"})
+	idx.IndexDevice(Device{UUID: "2", UDID: "udid-2", SerialNumber: "C02XYZ987", DeviceName: "jdoe-imac", AssetTag: "A-1001"})
+	idx.IndexDevice(Device{UUID: "3", SerialNumber: "F17DEP000", Description: "IPAD WI-FI 64GB"})
+	idx.IndexOwner("udid-2", Owner{UserID: "u2", Shortname: "jdoe", Longname: "Jane Doe"})
+
+	search := func(query string, fuzzy bool) []string {
+		var serials []string
+		for _, r := range idx.Search(SearchDevicesOption{Query: query, Fuzzy: fuzzy}) {
+			serials = append(serials, r.SerialNumber)
+		}
+		return serials
+	}
+
+	tests := []struct {
+		query string
+		fuzzy bool
+		want  []string
+	}{
+		{query: "jsmith-m", want: []string{"C02ABC123"}},
+		{query: "mbp", want: []string{"C02ABC123"}},
+		{query: "jdoe-imac", want: []string{"C02XYZ987"}},
+		{query: "c02", want: []string{"C02XYZ987", "C02ABC123"}}, // serial prefix, same score ordered by device name
+		{query: "a-1001", want: []string{"C02XYZ987"}},
+		{query: "jane doe", want: []string{"C02XYZ987"}},
+		{query: "jane smith", want: nil},
+		{query: "ipad 64gb", want: []string{"F17DEP000"}},
+		{query: "jsmtih", want: nil},
+		{query: "jsmtih", fuzzy: true, want: []string{"C02ABC123"}},
+	}
+	for _, tt := range tests {
+		have := search(tt.query, tt.fuzzy)
+		if len(have) != len(tt.want) {
+			t.Errorf("%q: have %v, want %v", tt.query, have, tt.want)
+			continue
+		}
+		for i := range have {
+			if have[i] != tt.want[i] {
+				t.Errorf("%q: have %v, want %v", tt.query, have, tt.want)
+			}
+		}
+	}
+
+	// an exact match ranks above a prefix match
+	idx.IndexDevice(Device{UUID: "4", SerialNumber: "C02", DeviceName: "spare"})
+	if have, want := search("c02", false)[0], "C02"; have != want {
+		t.Errorf("have %s ranked first, want %s", have, want)
+	}
+
+	// updating a device replaces its terms
+	idx.IndexDevice(Device{UUID: "1", UDID: "udid-1", SerialNumber: "C02ABC123", DeviceName: "jsmith-air"})
+	if have := search("mbp", false); len(have) != 0 {
+		t.Errorf("found renamed device by its old name: %v", have)
+	}
+
+	idx.RemoveOwners("udid-2")
+	if have := search("jane", false); len(have) != 0 {
+		t.Errorf("found device by removed owner: %v", have)
+	}
+	idx.RemoveBySerial("C02XYZ987")
+	if have := search("jdoe", false); len(have) != 0 {
+		t.Errorf("found removed device: %v", have)
+	}
+}
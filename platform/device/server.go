@@ -11,18 +11,29 @@ import (
 type Endpoints struct {
 	ListDevicesEndpoint   endpoint.Endpoint
 	RemoveDevicesEndpoint endpoint.Endpoint
+	SearchDevicesEndpoint endpoint.Endpoint
+	FleetStatsEndpoint    endpoint.Endpoint
+
+	ListUserEnrollmentsEndpoint endpoint.Endpoint
 }
 
 func MakeServerEndpoints(s Service, outer endpoint.Middleware, others ...endpoint.Middleware) Endpoints {
 	return Endpoints{
 		ListDevicesEndpoint:   endpoint.Chain(outer, others...)(MakeListDevicesEndpoint(s)),
 		RemoveDevicesEndpoint: endpoint.Chain(outer, others...)(MakeRemoveDevicesEndpoint(s)),
+		SearchDevicesEndpoint: endpoint.Chain(outer, others...)(MakeSearchDevicesEndpoint(s)),
+		FleetStatsEndpoint:    endpoint.Chain(outer, others...)(MakeFleetStatsEndpoint(s)),
+
+		ListUserEnrollmentsEndpoint: endpoint.Chain(outer, others...)(MakeListUserEnrollmentsEndpoint(s)),
 	}
 }
 
 func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.ServerOption) {
 	// POST     /v1/devices		get a list of devices managed by the server
 	// DELETE  /v1/devices		remove one or more devices from the server
+	// POST     /v1/devices/search	search devices by name, serial, owner and other attributes
+	// GET      /v1/devices/stats	device counts by OS version, model, status and last seen
+	// POST     /v1/devices/user-enrollments	get a list of User Enrollment (BYOD) devices
 
 	r.Methods("POST").Path("/v1/devices").Handler(httptransport.NewServer(
 		e.ListDevicesEndpoint,
@@ -37,4 +48,25 @@ func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.S
 		httputil.EncodeJSONResponse,
 		options...,
 	))
+
+	r.Methods("POST").Path("/v1/devices/search").Handler(httptransport.NewServer(
+		e.SearchDevicesEndpoint,
+		decodeSearchDevicesRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/v1/devices/stats").Handler(httptransport.NewServer(
+		e.FleetStatsEndpoint,
+		decodeFleetStatsRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/devices/user-enrollments").Handler(httptransport.NewServer(
+		e.ListUserEnrollmentsEndpoint,
+		decodeListUserEnrollmentsRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
 }
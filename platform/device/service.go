@@ -2,28 +2,68 @@ package device
 
 import (
 	"context"
+	"sync"
+	"time"
 )
 
 type RemoveDevicesOptions struct {
-	UDIDs   []string `json:"udids"`
-	Serials []string `json:"serials"`
+	UDIDs         []string `json:"udids"`
+	Serials       []string `json:"serials"`
+	EnrollmentIDs []string `json:"enrollment_ids"`
 }
 
 type Service interface {
 	ListDevices(ctx context.Context, opt ListDevicesOption) ([]DeviceDTO, error)
 	RemoveDevices(ctx context.Context, opt RemoveDevicesOptions) error
+	SearchDevices(ctx context.Context, opt SearchDevicesOption) ([]DeviceSearchResult, error)
+	FleetStats(ctx context.Context) (*FleetStats, error)
+	ListUserEnrollments(ctx context.Context, opt ListUserEnrollmentsOption) ([]UserEnrollment, error)
 }
 
 type Store interface {
 	List(ctx context.Context, opt ListDevicesOption) ([]Device, error)
 	DeleteByUDID(ctx context.Context, udid string) error
 	DeleteBySerial(ctx context.Context, serial string) error
+	DeviceIterator
+	UserEnrollmentStore
 }
 
 type DeviceService struct {
-	store Store
+	store   Store
+	index   *SearchIndex
+	revoker CertificateRevoker
+
+	statsMu  sync.Mutex
+	statsTTL time.Duration
+	stats    *FleetStats
+}
+
+type Option func(*DeviceService)
+
+// WithSearchIndex enables device search. Removed devices are also removed
+// from the index.
+func WithSearchIndex(idx *SearchIndex) Option {
+	return func(svc *DeviceService) {
+		svc.index = idx
+	}
+}
+
+// CertificateRevoker revokes the identity certificate of a device.
+type CertificateRevoker interface {
+	RevokeDevice(ctx context.Context, udid string) error
+}
+
+// WithCertificateRevoker revokes the identity certificate of removed devices.
+func WithCertificateRevoker(r CertificateRevoker) Option {
+	return func(svc *DeviceService) {
+		svc.revoker = r
+	}
 }
 
-func New(store Store) *DeviceService {
-	return &DeviceService{store: store}
+func New(store Store, opts ...Option) *DeviceService {
+	svc := &DeviceService{store: store, statsTTL: DefaultStatsCacheTTL}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
 }
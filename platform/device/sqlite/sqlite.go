@@ -0,0 +1,286 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func columns() []string {
+	return []string{
+		"uuid",
+		"udid",
+		"serial_number",
+		"os_version",
+		"build_version",
+		"product_name",
+		"imei",
+		"meid",
+		"push_magic",
+		"awaiting_configuration",
+		"token",
+		"unlock_token",
+		"enrolled",
+		"description",
+		"model",
+		"model_name",
+		"device_name",
+		"color",
+		"asset_tag",
+		"dep_profile_status",
+		"dep_profile_uuid",
+		"dep_profile_assign_time",
+		"dep_profile_push_time",
+		"dep_profile_assigned_date",
+		"dep_profile_assigned_by",
+		"last_seen",
+		"bootstrap_token",
+		"supervised",
+		"push_invalid",
+		"enrollment_group",
+		"owner",
+	}
+}
+
+const (
+	tableName             = "devices"
+	udidCertAuthTableName = "udid_cert_auth"
+)
+
+func (d *SQLite) Save(ctx context.Context, device *device.Device) error {
+	query, args, err := sq.
+		Insert(tableName).
+		Options("OR REPLACE").
+		Columns(columns()...).
+		Values(
+			device.UUID,
+			device.UDID,
+			device.SerialNumber,
+			device.OSVersion,
+			device.BuildVersion,
+			device.ProductName,
+			device.IMEI,
+			device.MEID,
+			device.PushMagic,
+			device.AwaitingConfiguration,
+			device.Token,
+			device.UnlockToken,
+			device.Enrolled,
+			device.Description,
+			device.Model,
+			device.ModelName,
+			device.DeviceName,
+			device.Color,
+			device.AssetTag,
+			device.DEPProfileStatus,
+			device.DEPProfileUUID,
+			device.DEPProfileAssignTime,
+			device.DEPProfilePushTime,
+			device.DEPProfileAssignedDate,
+			device.DEPProfileAssignedBy,
+			device.LastSeen,
+			device.BootstrapToken,
+			device.Supervised,
+			device.PushInvalid,
+			device.Group,
+			device.Owner,
+		).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building device save query")
+	}
+
+	_, err = d.db.ExecContext(ctx, query, args...)
+	return errors.Wrap(err, "exec device save in sqlite")
+}
+
+func (d *SQLite) DeviceByUDID(ctx context.Context, udid string) (*device.Device, error) {
+	return d.deviceBy(ctx, "udid", udid)
+}
+
+func (d *SQLite) DeviceBySerial(ctx context.Context, serial string) (*device.Device, error) {
+	return d.deviceBy(ctx, "serial_number", serial)
+}
+
+func (d *SQLite) deviceBy(ctx context.Context, column, value string) (*device.Device, error) {
+	// DEP devices have no UDID and user enrollments have no serial, so an
+	// empty value never identifies a device.
+	if value == "" {
+		return nil, deviceNotFoundErr{}
+	}
+	query, args, err := sq.
+		Select(columns()...).
+		From(tableName).
+		Where(sq.Eq{column: value}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+
+	var dev device.Device
+	err = d.db.QueryRowxContext(ctx, query, args...).StructScan(&dev)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, deviceNotFoundErr{}
+	}
+	return &dev, errors.Wrapf(err, "finding device by %s", column)
+}
+
+func (d *SQLite) List(ctx context.Context, opt device.ListDevicesOption) ([]device.Device, error) {
+	stmt := sq.
+		Select(columns()...).
+		From(tableName).
+		OrderBy("serial_number", "udid")
+	if len(opt.FilterSerial) > 0 {
+		stmt = stmt.Where(sq.Eq{"serial_number": opt.FilterSerial})
+	}
+	if len(opt.FilterUDID) > 0 {
+		stmt = stmt.Where(sq.Eq{"udid": opt.FilterUDID})
+	}
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var list []device.Device
+	err = d.db.SelectContext(ctx, &list, query, args...)
+	return list, errors.Wrap(err, "list devices")
+}
+
+// eachDeviceBatch is the number of devices EachDevice selects per query.
+const eachDeviceBatch = 500
+
+// EachDevice calls fn for every device, ordered by UUID. Devices are selected
+// in batches so the whole fleet is never held in memory.
+func (d *SQLite) EachDevice(ctx context.Context, fn func(device.Device) error) error {
+	var after string
+	for {
+		query, args, err := sq.
+			Select(columns()...).
+			From(tableName).
+			Where(sq.Gt{"uuid": after}).
+			OrderBy("uuid").
+			Limit(eachDeviceBatch).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "building sql")
+		}
+		var batch []device.Device
+		if err := d.db.SelectContext(ctx, &batch, query, args...); err != nil {
+			return errors.Wrap(err, "select device batch")
+		}
+		for _, dev := range batch {
+			if err := fn(dev); err != nil {
+				return err
+			}
+		}
+		if len(batch) < eachDeviceBatch {
+			return nil
+		}
+		after = batch[len(batch)-1].UUID
+	}
+}
+
+// GetBootstrapToken returns the Bootstrap Token for the device by udid
+func (d *SQLite) GetBootstrapToken(ctx context.Context, udid string) ([]byte, error) {
+	dev, err := d.DeviceByUDID(ctx, udid)
+	if err != nil {
+		return nil, errors.Wrap(err, "lookup device by udid")
+	}
+	return dev.BootstrapToken, nil
+}
+
+func (d *SQLite) DeleteByUDID(ctx context.Context, udid string) error {
+	return d.deleteBy(ctx, "udid", udid)
+}
+
+func (d *SQLite) DeleteBySerial(ctx context.Context, serial string) error {
+	return d.deleteBy(ctx, "serial_number", serial)
+}
+
+func (d *SQLite) deleteBy(ctx context.Context, column, value string) error {
+	query, args, err := sq.
+		Delete(tableName).
+		Where(sq.Eq{column: value}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	res, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrapf(err, "delete device by %s", column)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return deviceNotFoundErr{}
+	}
+	return nil
+}
+
+func (d *SQLite) SaveUDIDCertHash(udid, certHash []byte) error {
+	query, args, err := sq.
+		Insert(udidCertAuthTableName).
+		Options("OR REPLACE").
+		Columns("udid", "cert_hash").
+		Values(string(udid), certHash).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building udid cert hash save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrap(err, "exec udid cert hash save in sqlite")
+}
+
+func (d *SQLite) GetUDIDCertHash(udid []byte) ([]byte, error) {
+	query, args, err := sq.
+		Select("cert_hash").
+		From(udidCertAuthTableName).
+		Where(sq.Eq{"udid": string(udid)}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var certHash []byte
+	err = d.db.QueryRowx(query, args...).Scan(&certHash)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, deviceNotFoundErr{}
+	}
+	return certHash, errors.Wrap(err, "finding udid cert hash")
+}
+
+// UDIDByCertHash returns the UDID the certificate hash is associated with.
+func (d *SQLite) UDIDByCertHash(certHash []byte) (string, error) {
+	query, args, err := sq.
+		Select("udid").
+		From(udidCertAuthTableName).
+		Where(sq.Eq{"cert_hash": certHash}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return "", errors.Wrap(err, "building sql")
+	}
+	var udid string
+	err = d.db.QueryRowx(query, args...).Scan(&udid)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return "", deviceNotFoundErr{}
+	}
+	return udid, errors.Wrap(err, "finding udid by cert hash")
+}
+
+type deviceNotFoundErr struct{}
+
+func (e deviceNotFoundErr) Error() string {
+	return "device not found"
+}
+
+func (e deviceNotFoundErr) NotFound() bool {
+	return true
+}
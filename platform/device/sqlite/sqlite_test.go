@@ -0,0 +1,210 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+func TestCrud(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+
+	// create
+	dev := &device.Device{
+		UUID:             "a-b-c-d",
+		UDID:             "UDID-FOO-BAR-BAZ",
+		SerialNumber:     "foobarbaz",
+		DEPProfileStatus: device.ASSIGNED,
+		LastSeen:         time.Now().UTC(),
+		BootstrapToken:   []byte("bootstrap"),
+	}
+	if err := db.Save(ctx, dev); err != nil {
+		t.Fatal(err)
+	}
+
+	// update
+	dev.DEPProfileStatus = device.PUSHED
+	dev.Enrolled = true
+	if err := db.Save(ctx, dev); err != nil {
+		t.Fatal(err)
+	}
+
+	// find
+	byUDID, err := db.DeviceByUDID(ctx, dev.UDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySerial, err := db.DeviceBySerial(ctx, dev.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, found := range []*device.Device{byUDID, bySerial} {
+		if have, want := found.DEPProfileStatus, dev.DEPProfileStatus; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+		if have, want := found.Enrolled, dev.Enrolled; have != want {
+			t.Errorf("have %v, want %v", have, want)
+		}
+		if have, want := found.LastSeen, dev.LastSeen; !have.Equal(want) {
+			t.Errorf("have %v, want %v", have, want)
+		}
+	}
+
+	token, err := db.GetBootstrapToken(ctx, dev.UDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(token), "bootstrap"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	if _, err := db.DeviceBySerial(ctx, ""); !isNotFound(err) {
+		t.Errorf("expected not found error for empty serial, got %v", err)
+	}
+
+	// list
+	if err := db.Save(ctx, &device.Device{UUID: "e-f-g-h", SerialNumber: "dep-only"}); err != nil {
+		t.Fatal(err)
+	}
+	devices, err := db.List(ctx, device.ListDevicesOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(devices), 2; have != want {
+		t.Errorf("have %d devices, want %d", have, want)
+	}
+	devices, err = db.List(ctx, device.ListDevicesOption{FilterSerial: []string{"dep-only"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(devices), 1; have != want {
+		t.Errorf("have %d filtered devices, want %d", have, want)
+	}
+
+	// delete
+	if err := db.DeleteByUDID(ctx, dev.UDID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteBySerial(ctx, "dep-only"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DeviceByUDID(ctx, dev.UDID); !isNotFound(err) {
+		t.Errorf("expected not found error after delete, got %v", err)
+	}
+}
+
+func TestUDIDCertHash(t *testing.T) {
+	db := setup(t)
+	udid := []byte("UDID-FOO-BAR-BAZ")
+
+	if _, err := db.GetUDIDCertHash(udid); !isNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := db.SaveUDIDCertHash(udid, []byte("hash")); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := db.GetUDIDCertHash(udid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(hash), "hash"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	bound, err := db.UDIDByCertHash([]byte("hash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := bound, string(udid); have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if _, err := db.UDIDByCertHash([]byte("other")); !isNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func isNotFound(err error) bool {
+	e, ok := err.(interface{ NotFound() bool })
+	return ok && e.NotFound()
+}
+
+func setup(t *testing.T) *SQLite {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db)
+}
+
+func TestEachDevice(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+
+	// more than one batch
+	total := eachDeviceBatch + 3
+	for i := 0; i < total; i++ {
+		dev := &device.Device{UUID: fmt.Sprintf("uuid-%04d", i), SerialNumber: fmt.Sprintf("serial-%04d", i)}
+		if err := db.Save(ctx, dev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	err := db.EachDevice(ctx, func(dev device.Device) error {
+		seen[dev.UUID] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(seen), total; have != want {
+		t.Errorf("have %d devices, want %d", have, want)
+	}
+}
+
+func TestUserEnrollments(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+
+	e := &device.UserEnrollment{
+		EnrollmentID:   "enrollment-1",
+		ManagedAppleID: "jane@example.org",
+		OSVersion:      "15.4",
+		Enrolled:       true,
+		LastSeen:       time.Now().UTC(),
+	}
+	if err := db.SaveUserEnrollment(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := db.UserEnrollmentByID(ctx, e.EnrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := found.ManagedAppleID, e.ManagedAppleID; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := found.LastSeen, e.LastSeen; !have.Equal(want) {
+		t.Errorf("have %v, want %v", have, want)
+	}
+
+	list, err := db.ListUserEnrollments(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(list), 1; have != want {
+		t.Errorf("have %d user enrollments, want %d", have, want)
+	}
+
+	if err := db.DeleteUserEnrollment(ctx, e.EnrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.UserEnrollmentByID(ctx, e.EnrollmentID); !isNotFound(err) {
+		t.Errorf("have %v, want not found error", err)
+	}
+}
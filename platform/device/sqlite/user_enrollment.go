@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+const userEnrollmentTableName = "user_enrollments"
+
+func userEnrollmentColumns() []string {
+	return []string{
+		"enrollment_id",
+		"managed_apple_id",
+		"os_version",
+		"build_version",
+		"product_name",
+		"model",
+		"model_name",
+		"device_name",
+		"enrolled",
+		"last_seen",
+	}
+}
+
+func (d *SQLite) SaveUserEnrollment(ctx context.Context, e *device.UserEnrollment) error {
+	query, args, err := sq.
+		Insert(userEnrollmentTableName).
+		Options("OR REPLACE").
+		Columns(userEnrollmentColumns()...).
+		Values(
+			e.EnrollmentID,
+			e.ManagedAppleID,
+			e.OSVersion,
+			e.BuildVersion,
+			e.ProductName,
+			e.Model,
+			e.ModelName,
+			e.DeviceName,
+			e.Enrolled,
+			e.LastSeen,
+		).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building user enrollment save query")
+	}
+	_, err = d.db.ExecContext(ctx, query, args...)
+	return errors.Wrap(err, "exec user enrollment save in sqlite")
+}
+
+func (d *SQLite) UserEnrollmentByID(ctx context.Context, enrollmentID string) (*device.UserEnrollment, error) {
+	query, args, err := sq.
+		Select(userEnrollmentColumns()...).
+		From(userEnrollmentTableName).
+		Where(sq.Eq{"enrollment_id": enrollmentID}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var e device.UserEnrollment
+	err = d.db.QueryRowxContext(ctx, query, args...).StructScan(&e)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, deviceNotFoundErr{}
+	}
+	return &e, errors.Wrap(err, "finding user enrollment by enrollment_id")
+}
+
+func (d *SQLite) ListUserEnrollments(ctx context.Context) ([]device.UserEnrollment, error) {
+	query, args, err := sq.
+		Select(userEnrollmentColumns()...).
+		From(userEnrollmentTableName).
+		OrderBy("enrollment_id").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var list []device.UserEnrollment
+	err = d.db.SelectContext(ctx, &list, query, args...)
+	return list, errors.Wrap(err, "list user enrollments")
+}
+
+func (d *SQLite) DeleteUserEnrollment(ctx context.Context, enrollmentID string) error {
+	query, args, err := sq.
+		Delete(userEnrollmentTableName).
+		Where(sq.Eq{"enrollment_id": enrollmentID}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	res, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "delete user enrollment")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return deviceNotFoundErr{}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"crypto/subtle"
+	"crypto/x509"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -15,16 +16,37 @@ import (
 type UDIDCertAuthStore interface {
 	SaveUDIDCertHash(udid, certHash []byte) error
 	GetUDIDCertHash(udid []byte) ([]byte, error)
+	UDIDByCertHash(certHash []byte) (string, error)
 }
 
-func UDIDCertAuthMiddleware(store UDIDCertAuthStore, logger log.Logger, warnOnly bool) mdm.Middleware {
+// CertificateRenewals reports whether a certificate which doesn't match the
+// one a device enrolled with is a renewal of its identity certificate.
+type CertificateRenewals interface {
+	CertificateRenewed(ctx context.Context, udid string, cert *x509.Certificate) (bool, error)
+}
+
+type UDIDCertAuthOption func(*udidCertAuthMiddleware)
+
+// WithCertificateRenewals accepts renewed identity certificates and
+// associates them with the device.
+func WithCertificateRenewals(r CertificateRenewals) UDIDCertAuthOption {
+	return func(mw *udidCertAuthMiddleware) {
+		mw.renewals = r
+	}
+}
+
+func UDIDCertAuthMiddleware(store UDIDCertAuthStore, logger log.Logger, warnOnly bool, opts ...UDIDCertAuthOption) mdm.Middleware {
 	return func(next mdm.Service) mdm.Service {
-		return &udidCertAuthMiddleware{
+		mw := &udidCertAuthMiddleware{
 			store:    store,
 			next:     next,
 			logger:   logger,
 			warnOnly: warnOnly,
 		}
+		for _, opt := range opts {
+			opt(mw)
+		}
+		return mw
 	}
 }
 
@@ -33,6 +55,7 @@ type udidCertAuthMiddleware struct {
 	next     mdm.Service
 	logger   log.Logger
 	warnOnly bool
+	renewals CertificateRenewals
 }
 
 func hashCertRaw(c []byte) []byte {
@@ -42,7 +65,8 @@ func hashCertRaw(c []byte) []byte {
 	return retBytes
 }
 
-func (mw *udidCertAuthMiddleware) validateUDIDCertAuth(udid, certHash []byte) (bool, error) {
+func (mw *udidCertAuthMiddleware) validateUDIDCertAuth(ctx context.Context, udid []byte, cert *x509.Certificate) (bool, error) {
+	certHash := hashCertRaw(cert.Raw)
 	dbCertHash, err := mw.store.GetUDIDCertHash(udid)
 	if err != nil && !isNotFound(err) {
 		return false, err
@@ -59,6 +83,25 @@ func (mw *udidCertAuthMiddleware) validateUDIDCertAuth(udid, certHash []byte) (b
 		return true, nil
 	}
 	if 1 != subtle.ConstantTimeCompare(certHash, dbCertHash) {
+		if mw.renewals != nil {
+			// the certificate of another device is never a renewal.
+			bound, err := mw.store.UDIDByCertHash(certHash)
+			if err != nil && !isNotFound(err) {
+				return false, err
+			}
+			if err == nil && bound != string(udid) {
+				level.Info(mw.logger).Log("msg", "device cert is bound to another udid", "udid", string(udid), "bound_udid", bound)
+				return false, nil
+			}
+			renewed, err := mw.renewals.CertificateRenewed(ctx, string(udid), cert)
+			if err != nil {
+				return false, err
+			}
+			if renewed {
+				level.Info(mw.logger).Log("msg", "saving renewed device cert hash", "udid", string(udid))
+				return true, mw.store.SaveUDIDCertHash(udid, certHash)
+			}
+		}
 		level.Info(mw.logger).Log("msg", "device cert hash mismatch", "udid", string(udid))
 		return false, nil
 	}
@@ -75,7 +118,7 @@ func (mw *udidCertAuthMiddleware) Acknowledge(ctx context.Context, req mdm.Ackno
 	if err != nil {
 		return nil, errors.Wrap(err, "error retrieving device certificate")
 	}
-	matched, err := mw.validateUDIDCertAuth([]byte(req.Response.UDID), hashCertRaw(devcert.Raw))
+	matched, err := mw.validateUDIDCertAuth(ctx, []byte(req.Response.UDID), devcert)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +145,7 @@ func (mw *udidCertAuthMiddleware) Checkin(ctx context.Context, req mdm.CheckinEv
 		}
 		return mw.next.Checkin(ctx, req)
 	case "TokenUpdate", "CheckOut", "GetBootstrapToken", "SetBootstrapToken":
-		matched, err := mw.validateUDIDCertAuth([]byte(req.Command.UDID), hashCertRaw(devcert.Raw))
+		matched, err := mw.validateUDIDCertAuth(ctx, []byte(req.Command.UDID), devcert)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,78 @@
+package device
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+)
+
+// certHashStore keeps the UDID certificate hashes in memory.
+type certHashStore map[string][]byte
+
+func (s certHashStore) SaveUDIDCertHash(udid, certHash []byte) error {
+	s[string(udid)] = certHash
+	return nil
+}
+
+func (s certHashStore) GetUDIDCertHash(udid []byte) ([]byte, error) {
+	h, ok := s[string(udid)]
+	if !ok {
+		return nil, notFoundError{}
+	}
+	return h, nil
+}
+
+func (s certHashStore) UDIDByCertHash(certHash []byte) (string, error) {
+	for udid, h := range s {
+		if string(h) == string(certHash) {
+			return udid, nil
+		}
+	}
+	return "", notFoundError{}
+}
+
+// renewedCerts accepts the certificates as renewals of any device.
+type renewedCerts map[string]bool
+
+func (r renewedCerts) CertificateRenewed(_ context.Context, udid string, cert *x509.Certificate) (bool, error) {
+	return r[string(cert.Raw)], nil
+}
+
+func TestRenewedCertificate(t *testing.T) {
+	enrolled := &x509.Certificate{Raw: []byte("enrolled")}
+	renewed := &x509.Certificate{Raw: []byte("renewed")}
+	// the fresh certificate of a second device.
+	fresh := &x509.Certificate{Raw: []byte("fresh")}
+	store := certHashStore{
+		"UDID-1": hashCertRaw(enrolled.Raw),
+		"UDID-2": hashCertRaw(fresh.Raw),
+	}
+	mw := UDIDCertAuthMiddleware(store, log.NewNopLogger(), false,
+		WithCertificateRenewals(renewedCerts{"renewed": true, "fresh": true}),
+	)(nil).(*udidCertAuthMiddleware)
+	ctx := context.Background()
+
+	matched, err := mw.validateUDIDCertAuth(ctx, []byte("UDID-1"), fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched {
+		t.Error("the certificate of another device was accepted")
+	}
+	if have, want := string(store["UDID-1"]), string(hashCertRaw(enrolled.Raw)); have != want {
+		t.Error("the certificate of another device was bound to the UDID")
+	}
+
+	matched, err = mw.validateUDIDCertAuth(ctx, []byte("UDID-1"), renewed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Error("the renewed certificate was not accepted")
+	}
+	if have, want := string(store["UDID-1"]), string(hashCertRaw(renewed.Raw)); have != want {
+		t.Error("the renewed certificate was not bound to the UDID")
+	}
+}
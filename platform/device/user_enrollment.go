@@ -0,0 +1,141 @@
+package device
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+	"github.com/micromdm/micromdm/platform/device/internal/deviceproto"
+)
+
+// UserEnrollment is a device enrolled with User Enrollment (BYOD). These
+// devices don't report a UDID or serial number and are identified by their
+// EnrollmentID instead. Commands and pushes for a user enrollment use the
+// EnrollmentID in place of a UDID.
+type UserEnrollment struct {
+	EnrollmentID   string    `json:"enrollment_id" db:"enrollment_id"`
+	ManagedAppleID string    `json:"managed_apple_id,omitempty" db:"managed_apple_id"`
+	OSVersion      string    `json:"os_version,omitempty" db:"os_version"`
+	BuildVersion   string    `json:"build_version,omitempty" db:"build_version"`
+	ProductName    string    `json:"product_name,omitempty" db:"product_name"`
+	Model          string    `json:"model,omitempty" db:"model"`
+	ModelName      string    `json:"model_name,omitempty" db:"model_name"`
+	DeviceName     string    `json:"device_name,omitempty" db:"device_name"`
+	Enrolled       bool      `json:"enrolled" db:"enrolled"`
+	LastSeen       time.Time `json:"last_seen" db:"last_seen"`
+}
+
+// ManagedAppleIDParam is the check-in parameter which carries the Managed
+// Apple ID of a user enrollment. The device does not report the account
+// itself, so it is set from the sign in invitation of the enrollment.
+const ManagedAppleIDParam = "managed_apple_id"
+
+// UserEnrollmentStore stores user enrollments.
+type UserEnrollmentStore interface {
+	SaveUserEnrollment(ctx context.Context, e *UserEnrollment) error
+	UserEnrollmentByID(ctx context.Context, enrollmentID string) (*UserEnrollment, error)
+	ListUserEnrollments(ctx context.Context) ([]UserEnrollment, error)
+	DeleteUserEnrollment(ctx context.Context, enrollmentID string) error
+}
+
+func MarshalUserEnrollment(e *UserEnrollment) ([]byte, error) {
+	return proto.Marshal(&deviceproto.UserEnrollment{
+		EnrollmentId:   e.EnrollmentID,
+		ManagedAppleId: e.ManagedAppleID,
+		OsVersion:      e.OSVersion,
+		BuildVersion:   e.BuildVersion,
+		ProductName:    e.ProductName,
+		Model:          e.Model,
+		ModelName:      e.ModelName,
+		DeviceName:     e.DeviceName,
+		Enrolled:       e.Enrolled,
+		LastSeen:       timeToNano(e.LastSeen),
+	})
+}
+
+func UnmarshalUserEnrollment(data []byte, e *UserEnrollment) error {
+	var pb deviceproto.UserEnrollment
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to user enrollment")
+	}
+	e.EnrollmentID = pb.GetEnrollmentId()
+	e.ManagedAppleID = pb.GetManagedAppleId()
+	e.OSVersion = pb.GetOsVersion()
+	e.BuildVersion = pb.GetBuildVersion()
+	e.ProductName = pb.GetProductName()
+	e.Model = pb.GetModel()
+	e.ModelName = pb.GetModelName()
+	e.DeviceName = pb.GetDeviceName()
+	e.Enrolled = pb.GetEnrolled()
+	e.LastSeen = timeFromNano(pb.GetLastSeen())
+	return nil
+}
+
+type ListUserEnrollmentsOption struct {
+	FilterEnrollmentID []string `json:"filter_enrollment_id"`
+}
+
+func (svc *DeviceService) ListUserEnrollments(ctx context.Context, opt ListUserEnrollmentsOption) ([]UserEnrollment, error) {
+	enrollments, err := svc.store.ListUserEnrollments(ctx)
+	if err != nil || len(opt.FilterEnrollmentID) == 0 {
+		return enrollments, err
+	}
+	var filtered []UserEnrollment
+	for _, e := range enrollments {
+		for _, id := range opt.FilterEnrollmentID {
+			if e.EnrollmentID == id {
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+type listUserEnrollmentsRequest struct{ Opts ListUserEnrollmentsOption }
+type listUserEnrollmentsResponse struct {
+	UserEnrollments []UserEnrollment `json:"user_enrollments"`
+	Err             error            `json:"err,omitempty"`
+}
+
+func (r listUserEnrollmentsResponse) Failed() error { return r.Err }
+
+func decodeListUserEnrollmentsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts ListUserEnrollmentsOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	req := listUserEnrollmentsRequest{
+		Opts: opts,
+	}
+	return req, err
+}
+
+func decodeListUserEnrollmentsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listUserEnrollmentsResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeListUserEnrollmentsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listUserEnrollmentsRequest)
+		enrollments, err := svc.ListUserEnrollments(ctx, req.Opts)
+		return listUserEnrollmentsResponse{
+			UserEnrollments: enrollments,
+			Err:             err,
+		}, nil
+	}
+}
+
+func (e Endpoints) ListUserEnrollments(ctx context.Context, opts ListUserEnrollmentsOption) ([]UserEnrollment, error) {
+	request := listUserEnrollmentsRequest{opts}
+	response, err := e.ListUserEnrollmentsEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(listUserEnrollmentsResponse).UserEnrollments, response.(listUserEnrollmentsResponse).Err
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
+	"github.com/groob/plist"
 	"github.com/pkg/errors"
 
 	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/apns"
 	"github.com/micromdm/micromdm/platform/dep/sync"
 	"github.com/micromdm/micromdm/platform/pubsub"
 )
@@ -18,20 +20,47 @@ type DeviceWorkerStore interface {
 	Save(ctx context.Context, d *Device) error
 	DeviceByUDID(ctx context.Context, udid string) (*Device, error)
 	DeviceBySerial(ctx context.Context, serial string) (*Device, error)
+	UserEnrollmentStore
 }
 
 type Worker struct {
 	db     DeviceWorkerStore
 	ps     pubsub.PublishSubscriber
 	logger log.Logger
+	index  *SearchIndex
 }
 
-func NewWorker(db DeviceWorkerStore, ps pubsub.PublishSubscriber, logger log.Logger) *Worker {
-	return &Worker{
+type WorkerOption func(*Worker)
+
+// WithWorkerSearchIndex updates the search index with every device and
+// device user the worker sees.
+func WithWorkerSearchIndex(idx *SearchIndex) WorkerOption {
+	return func(w *Worker) {
+		w.index = idx
+	}
+}
+
+func NewWorker(db DeviceWorkerStore, ps pubsub.PublishSubscriber, logger log.Logger, opts ...WorkerOption) *Worker {
+	w := &Worker{
 		db:     db,
 		ps:     ps,
 		logger: logger,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// save saves the device and updates the search index.
+func (w *Worker) save(ctx context.Context, dev *Device) error {
+	if err := w.db.Save(ctx, dev); err != nil {
+		return err
+	}
+	if w.index != nil {
+		w.index.IndexDevice(*dev)
+	}
+	return nil
 }
 
 func (w *Worker) Run(ctx context.Context) error {
@@ -64,6 +93,14 @@ func (w *Worker) Run(ctx context.Context) error {
 	if err != nil {
 		return errors.Wrapf(err, "subscribing %s to %s", subscription, mdm.ConnectTopic)
 	}
+	otaEnrollEvents, err := w.ps.Subscribe(ctx, subscription, OTAEnrollTopic)
+	if err != nil {
+		return errors.Wrapf(err, "subscribing %s to %s", subscription, OTAEnrollTopic)
+	}
+	pushInvalidEvents, err := w.ps.Subscribe(ctx, subscription, apns.PushInvalidTopic)
+	if err != nil {
+		return errors.Wrapf(err, "subscribing %s to %s", subscription, apns.PushInvalidTopic)
+	}
 
 	for {
 		var err error
@@ -84,6 +121,10 @@ func (w *Worker) Run(ctx context.Context) error {
 			err = w.updateFromDEPSync(ctx, ev.Message)
 		case ev := <-connectEvents:
 			err = w.updateFromAcknowledge(ctx, ev.Message)
+		case ev := <-otaEnrollEvents:
+			err = w.updateFromOTAEnroll(ctx, ev.Message)
+		case ev := <-pushInvalidEvents:
+			err = w.updateFromPushInvalid(ctx, ev.Message)
 		}
 		if err != nil {
 			level.Info(w.logger).Log(
@@ -139,7 +180,7 @@ func (w *Worker) updateFromDEPSync(ctx context.Context, message []byte) error {
 		dev.DEPProfileAssignedDate = dd.DeviceAssignedDate
 		dev.DEPProfileAssignedBy = dd.DeviceAssignedBy
 
-		if err := w.db.Save(ctx, dev); err != nil {
+		if err := w.save(ctx, dev); err != nil {
 			return errors.Wrap(err, "save device %s from DEP sync")
 		}
 	}
@@ -147,6 +188,62 @@ func (w *Worker) updateFromDEPSync(ctx context.Context, message []byte) error {
 	return nil
 }
 
+// updateFromOTAEnroll creates or updates the device from the verified
+// attributes of an OTA enrollment. The device is not enrolled until it
+// checks in.
+func (w *Worker) updateFromOTAEnroll(ctx context.Context, message []byte) error {
+	var ev OTAEnrollEvent
+	if err := UnmarshalOTAEnrollEvent(message, &ev); err != nil {
+		return errors.Wrap(err, "unmarshal OTA enroll event")
+	}
+
+	dev, err := w.db.DeviceByUDID(ctx, ev.UDID)
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "retrieve device with udid %s", ev.UDID)
+	}
+	if dev == nil {
+		dev, err = getOrCreateDeviceBySerial(ctx, w.db, ev.SerialNumber)
+		if err != nil {
+			return errors.Wrap(err, "get device for OTA enroll event")
+		}
+	}
+
+	if dev.UUID == "" {
+		dev.UUID = uuid.New().String()
+	}
+	dev.UDID = ev.UDID
+	dev.SerialNumber = ev.SerialNumber
+	dev.ProductName = ev.ProductName
+	dev.IMEI = ev.IMEI
+	dev.MEID = ev.MEID
+	dev.BuildVersion = ev.BuildVersion
+	if ev.DeviceName != "" {
+		dev.DeviceName = ev.DeviceName
+	}
+	dev.LastSeen = ev.Time
+	err = w.save(ctx, dev)
+	return errors.Wrapf(err, "saving updated device for OTA enroll event")
+}
+
+// updateFromPushInvalid marks the push token of the device invalid. Push
+// tokens of users and User Enrollments are not recorded on devices.
+func (w *Worker) updateFromPushInvalid(ctx context.Context, message []byte) error {
+	var attempt apns.PushAttempt
+	if err := apns.UnmarshalPushAttempt(message, &attempt); err != nil {
+		return errors.Wrap(err, "unmarshal push attempt")
+	}
+
+	dev, err := w.db.DeviceByUDID(ctx, attempt.UDID)
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "retrieve device with udid %s", attempt.UDID)
+	}
+	dev.PushInvalid = true
+	err = w.save(ctx, dev)
+	return errors.Wrapf(err, "saving updated device for push invalid event")
+}
+
 func (w *Worker) updateFromAcknowledge(ctx context.Context, message []byte) error {
 	var ev mdm.AcknowledgeEvent
 	if err := mdm.UnmarshalAcknowledgeEvent(message, &ev); err != nil {
@@ -154,7 +251,8 @@ func (w *Worker) updateFromAcknowledge(ctx context.Context, message []byte) erro
 	}
 
 	if ev.Response.EnrollmentID != nil {
-		return nil
+		err := w.updateUserEnrollment(ctx, *ev.Response.EnrollmentID, func(e *UserEnrollment) {})
+		return errors.Wrap(err, "saving updated user enrollment for acknowledge event")
 	}
 
 	dev, err := w.db.DeviceByUDID(ctx, ev.Response.UDID)
@@ -162,12 +260,29 @@ func (w *Worker) updateFromAcknowledge(ctx context.Context, message []byte) erro
 		return errors.Wrapf(err, "retrieve device with udid %s", ev.Response.UDID)
 	}
 	dev.LastSeen = time.Now()
+	if supervised, ok := supervisedFromResponse(ev.Raw); ok {
+		dev.Supervised = supervised
+	}
 
-	err = w.db.Save(ctx, dev)
+	err = w.save(ctx, dev)
 	return errors.Wrapf(err, "saving updated device for acknowledge event")
 
 }
 
+// supervisedFromResponse returns the IsSupervised query of a DeviceInformation
+// command response, if the response has one.
+func supervisedFromResponse(raw []byte) (supervised bool, ok bool) {
+	var resp struct {
+		QueryResponses struct {
+			IsSupervised *bool
+		}
+	}
+	if len(raw) == 0 || plist.Unmarshal(raw, &resp) != nil || resp.QueryResponses.IsSupervised == nil {
+		return false, false
+	}
+	return *resp.QueryResponses.IsSupervised, true
+}
+
 func (w *Worker) updateFromCheckout(ctx context.Context, message []byte) error {
 	var ev mdm.CheckinEvent
 	if err := mdm.UnmarshalCheckinEvent(message, &ev); err != nil {
@@ -175,7 +290,10 @@ func (w *Worker) updateFromCheckout(ctx context.Context, message []byte) error {
 	}
 
 	if ev.Command.EnrollmentID != "" {
-		return nil
+		err := w.updateUserEnrollment(ctx, ev.Command.EnrollmentID, func(e *UserEnrollment) {
+			e.Enrolled = false
+		})
+		return errors.Wrap(err, "saving updated user enrollment for checkout event")
 	}
 
 	dev, err := w.db.DeviceByUDID(ctx, ev.Command.UDID)
@@ -185,8 +303,11 @@ func (w *Worker) updateFromCheckout(ctx context.Context, message []byte) error {
 
 	dev.Enrolled = false
 	dev.LastSeen = time.Now()
+	if w.index != nil {
+		w.index.RemoveOwners(dev.UDID)
+	}
 
-	err = w.db.Save(ctx, dev)
+	err = w.save(ctx, dev)
 	return errors.Wrapf(err, "saving updated device for checkout event")
 
 }
@@ -205,7 +326,7 @@ func (w *Worker) updateFromGetBootstrapToken(ctx context.Context, message []byte
 	dev.AwaitingConfiguration = ev.Command.AwaitingConfiguration
 	dev.LastSeen = time.Now()
 
-	err = w.db.Save(ctx, dev)
+	err = w.save(ctx, dev)
 	return errors.Wrapf(err, "saving updated device for GetBootstrapToken event")
 
 }
@@ -225,7 +346,7 @@ func (w *Worker) updateFromSetBootstrapToken(ctx context.Context, message []byte
 	dev.AwaitingConfiguration = ev.Command.AwaitingConfiguration
 	dev.LastSeen = time.Now()
 
-	err = w.db.Save(ctx, dev)
+	err = w.save(ctx, dev)
 	return errors.Wrapf(err, "saving updated device for SetBootstrapToken event")
 
 }
@@ -236,8 +357,22 @@ func (w *Worker) updateFromTokenUpdate(ctx context.Context, message []byte) erro
 		return errors.Wrap(err, "unmarshal checkin event")
 	}
 
+	if ev.Command.EnrollmentID != "" && ev.Command.UserID == "" {
+		err := w.updateUserEnrollment(ctx, ev.Command.EnrollmentID, func(e *UserEnrollment) {
+			e.Enrolled = true
+		})
+		return errors.Wrap(err, "saving updated user enrollment for Token event")
+	}
+
 	// do not process managed user, or user enrollment checkin events while updating device records.
 	if ev.Command.UserID != "" || ev.Command.EnrollmentID != "" {
+		if w.index != nil && ev.Command.UserID != "" {
+			w.index.IndexOwner(ev.Command.UDID, Owner{
+				UserID:    ev.Command.UserID,
+				Shortname: ev.Command.UserShortName,
+				Longname:  ev.Command.UserLongName,
+			})
+		}
 		return nil
 	}
 
@@ -250,10 +385,11 @@ func (w *Worker) updateFromTokenUpdate(ctx context.Context, message []byte) erro
 	dev.UnlockToken = ev.Command.UnlockToken.String()
 	dev.AwaitingConfiguration = ev.Command.AwaitingConfiguration
 	dev.LastSeen = time.Now()
+	dev.PushInvalid = false
 	// first TokenUpdate event will have the enrollment status set to false.
 	newlyEnrolled := !dev.Enrolled
 	dev.Enrolled = true
-	if err := w.db.Save(ctx, dev); err != nil {
+	if err := w.save(ctx, dev); err != nil {
 		return errors.Wrapf(err, "saving updated device for Token event udid=%s", ev.Command.UDID)
 	}
 
@@ -273,7 +409,18 @@ func (w *Worker) updateFromAuthenticate(ctx context.Context, message []byte) err
 	}
 
 	if ev.Command.EnrollmentID != "" {
-		return nil
+		err := w.updateUserEnrollment(ctx, ev.Command.EnrollmentID, func(e *UserEnrollment) {
+			e.OSVersion = ev.Command.OSVersion
+			e.BuildVersion = ev.Command.BuildVersion
+			e.ProductName = ev.Command.ProductName
+			e.Model = ev.Command.Model
+			e.ModelName = ev.Command.ModelName
+			e.DeviceName = ev.Command.DeviceName
+			if id := ev.Params[ManagedAppleIDParam]; id != "" {
+				e.ManagedAppleID = id
+			}
+		})
+		return errors.Wrap(err, "saving user enrollment for authenticate event")
 	}
 
 	device, reenrolling, err := getOrCreateDevice(ctx, w.db, ev.Command.SerialNumber, ev.Command.UDID)
@@ -306,11 +453,33 @@ func (w *Worker) updateFromAuthenticate(ctx context.Context, message []byte) err
 	device.DeviceName = ev.Command.DeviceName
 	device.Model = ev.Command.Model
 	device.ModelName = ev.Command.ModelName
+	// group membership follows the enrollment profile the device enrolled with.
+	device.Group = ev.Params[GroupParam]
+	// the owner of an enrollment invitation is kept until the device
+	// enrolls with another invitation.
+	if owner := ev.Params[OwnerParam]; owner != "" {
+		device.Owner = owner
+	}
 	device.LastSeen = time.Now()
-	err = w.db.Save(ctx, device)
+	err = w.save(ctx, device)
 	return errors.Wrapf(err, "saving updated device for authenticate event")
 }
 
+// updateUserEnrollment applies update to the user enrollment with the
+// EnrollmentID, creating it if needed, and marks it as seen.
+func (w *Worker) updateUserEnrollment(ctx context.Context, enrollmentID string, update func(*UserEnrollment)) error {
+	e, err := w.db.UserEnrollmentByID(ctx, enrollmentID)
+	if err != nil && !isNotFound(err) {
+		return errors.Wrapf(err, "retrieve user enrollment %s", enrollmentID)
+	}
+	if e == nil {
+		e = &UserEnrollment{EnrollmentID: enrollmentID}
+	}
+	update(e)
+	e.LastSeen = time.Now()
+	return w.db.SaveUserEnrollment(ctx, e)
+}
+
 func getOrCreateDevice(ctx context.Context, db DeviceWorkerStore, serial, udid string) (dev *Device, reenrolling bool, err error) {
 	if udid != "" {
 		// first try to fetch a device by UDID.
@@ -0,0 +1,79 @@
+package device
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/micromdm/micromdm/mdm"
+)
+
+// memStore keeps devices in memory. The user enrollment methods are not used.
+type memStore struct {
+	UserEnrollmentStore
+	devices map[string]Device
+}
+
+func (s *memStore) Save(ctx context.Context, d *Device) error {
+	s.devices[d.UDID] = *d
+	return nil
+}
+
+func (s *memStore) DeviceByUDID(ctx context.Context, udid string) (*Device, error) {
+	d, ok := s.devices[udid]
+	if !ok {
+		return nil, notFoundError{}
+	}
+	return &d, nil
+}
+
+func (s *memStore) DeviceBySerial(ctx context.Context, serial string) (*Device, error) {
+	for _, d := range s.devices {
+		if d.SerialNumber == serial {
+			return &d, nil
+		}
+	}
+	return nil, notFoundError{}
+}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string  { return "not found" }
+func (notFoundError) NotFound() bool { return true }
+
+func TestAuthenticateOwner(t *testing.T) {
+	store := &memStore{devices: make(map[string]Device)}
+	w := NewWorker(store, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	authenticate := func(params map[string]string) Device {
+		t.Helper()
+		ev := mdm.CheckinEvent{Params: params}
+		ev.Command.MessageType = "Authenticate"
+		ev.Command.UDID = "UDID-1"
+		ev.Command.SerialNumber = "C02ABC"
+		msg, err := mdm.MarshalCheckinEvent(&ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.updateFromAuthenticate(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		return store.devices["UDID-1"]
+	}
+
+	dev := authenticate(map[string]string{OwnerParam: "jane", GroupParam: "lab"})
+	if have, want := dev.Owner, "jane"; have != want {
+		t.Errorf("have owner %q, want %q", have, want)
+	}
+	if have, want := dev.Group, "lab"; have != want {
+		t.Errorf("have group %q, want %q", have, want)
+	}
+
+	// re-enrolling without an invitation keeps the owner.
+	dev = authenticate(nil)
+	if have, want := dev.Owner, "jane"; have != want {
+		t.Errorf("have owner %q, want %q", have, want)
+	}
+}
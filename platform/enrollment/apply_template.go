@@ -0,0 +1,64 @@
+package enrollment
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+func (svc *EnrollmentService) ApplyTemplate(ctx context.Context, t *Template) error {
+	if err := t.Verify(); err != nil {
+		return err
+	}
+	return svc.store.Save(ctx, t)
+}
+
+type applyTemplateRequest struct {
+	Template *Template `json:"template"`
+}
+
+type applyTemplateResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r applyTemplateResponse) Failed() error { return r.Err }
+
+func decodeApplyTemplateRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req applyTemplateRequest
+	err := httputil.DecodeJSONRequest(r, &req)
+	return req, err
+}
+
+func decodeApplyTemplateResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp applyTemplateResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+var errEmptyRequest = errors.New("request must contain a template")
+
+func MakeApplyTemplateEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(applyTemplateRequest)
+		if req.Template == nil {
+			return applyTemplateResponse{Err: errEmptyRequest}, nil
+		}
+		err = svc.ApplyTemplate(ctx, req.Template)
+		return applyTemplateResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+func (e Endpoints) ApplyTemplate(ctx context.Context, t *Template) error {
+	request := applyTemplateRequest{Template: t}
+	resp, err := e.ApplyTemplateEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	return resp.(applyTemplateResponse).Err
+}
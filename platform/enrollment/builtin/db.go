@@ -0,0 +1,97 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/enrollment"
+)
+
+const TemplateBucket = "mdm.EnrollmentTemplates"
+
+type DB struct {
+	*bolt.DB
+}
+
+func NewDB(db *bolt.DB) (*DB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(TemplateBucket))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s bucket", TemplateBucket)
+	}
+	datastore := &DB{
+		DB: db,
+	}
+	return datastore, nil
+}
+
+func (db *DB) Save(ctx context.Context, t *enrollment.Template) error {
+	pb, err := enrollment.MarshalTemplate(t)
+	if err != nil {
+		return errors.Wrap(err, "marshal enrollment template")
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(TemplateBucket)).Put([]byte(t.Name), pb)
+	})
+	return errors.Wrap(err, "save enrollment template")
+}
+
+func (db *DB) TemplateByName(ctx context.Context, name string) (*enrollment.Template, error) {
+	var t enrollment.Template
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(TemplateBucket)).Get([]byte(name))
+		if v == nil {
+			return &notFound{"Template", fmt.Sprintf("name %s", name)}
+		}
+		return enrollment.UnmarshalTemplate(v, &t)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get enrollment template by name")
+	}
+	return &t, nil
+}
+
+func (db *DB) List(ctx context.Context) ([]enrollment.Template, error) {
+	var templates []enrollment.Template
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(TemplateBucket)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var t enrollment.Template
+			if err := enrollment.UnmarshalTemplate(v, &t); err != nil {
+				return err
+			}
+			templates = append(templates, t)
+		}
+		return nil
+	})
+	return templates, errors.Wrap(err, "list enrollment templates")
+}
+
+func (db *DB) Delete(ctx context.Context, name string) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TemplateBucket))
+		if b.Get([]byte(name)) == nil {
+			return &notFound{"Template", fmt.Sprintf("name %s", name)}
+		}
+		return b.Delete([]byte(name))
+	})
+	return errors.Wrapf(err, "delete enrollment template %s", name)
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
@@ -0,0 +1,69 @@
+package builtin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/micromdm/micromdm/platform/enrollment"
+)
+
+func TestSave(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	tmpl := &enrollment.Template{
+		Name:          "lab",
+		Organization:  "Example Inc.",
+		SCEPSubject:   "/O=Example Inc./CN=%SerialNumber%",
+		SCEPKeySize:   4096,
+		AccessRights:  3,
+		ExtraPayloads: []string{"<dict/>"},
+	}
+	if err := db.Save(ctx, tmpl); err != nil {
+		t.Fatalf("saving enrollment template: %s", err)
+	}
+
+	stored, err := db.TemplateByName(ctx, "lab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := stored.SCEPSubject, tmpl.SCEPSubject; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := stored.SCEPKeySize, tmpl.SCEPKeySize; have != want {
+		t.Errorf("have %d, want %d", have, want)
+	}
+	if have, want := len(stored.ExtraPayloads), 1; have != want {
+		t.Errorf("have %d extra payloads, want %d", have, want)
+	}
+
+	if err := db.Delete(ctx, "lab"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.TemplateByName(ctx, "lab"); !enrollment.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := db.Delete(ctx, "lab"); !enrollment.IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func setupDB(t *testing.T) *DB {
+	f, _ := ioutil.TempFile("", "bolt-")
+	f.Close()
+	os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0777, nil)
+	if err != nil {
+		t.Fatalf("couldn't open bolt, err %s\n", err)
+	}
+	templateDB, err := NewDB(db)
+	if err != nil {
+		t.Fatalf("couldn't create enrollment template DB, err %s\n", err)
+	}
+	return templateDB
+}
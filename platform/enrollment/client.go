@@ -0,0 +1,57 @@
+package enrollment
+
+import (
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransport.ClientOption) (Service, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var applyTemplateEndpoint endpoint.Endpoint
+	{
+		applyTemplateEndpoint = httptransport.NewClient(
+			"PUT",
+			httputil.CopyURL(u, "/v1/enrollment-templates"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeApplyTemplateResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var getTemplatesEndpoint endpoint.Endpoint
+	{
+		getTemplatesEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/enrollment-templates"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeGetTemplatesResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var removeTemplatesEndpoint endpoint.Endpoint
+	{
+		removeTemplatesEndpoint = httptransport.NewClient(
+			"DELETE",
+			httputil.CopyURL(u, "/v1/enrollment-templates"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeRemoveTemplatesResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	return Endpoints{
+		ApplyTemplateEndpoint:   applyTemplateEndpoint,
+		GetTemplatesEndpoint:    getTemplatesEndpoint,
+		RemoveTemplatesEndpoint: removeTemplatesEndpoint,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package enrollment
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type GetTemplatesOption struct {
+	FilterName string `json:"filter_name"`
+}
+
+func (svc *EnrollmentService) GetTemplates(ctx context.Context, opt GetTemplatesOption) ([]Template, error) {
+	if opt.FilterName != "" {
+		t, err := svc.store.TemplateByName(ctx, opt.FilterName)
+		if err != nil {
+			return nil, err
+		}
+		return []Template{*t}, nil
+	}
+	return svc.store.List(ctx)
+}
+
+type getTemplatesRequest struct{ Opts GetTemplatesOption }
+type getTemplatesResponse struct {
+	Templates []Template `json:"templates"`
+	Err       error      `json:"err,omitempty"`
+}
+
+func (r getTemplatesResponse) Failed() error { return r.Err }
+
+func decodeGetTemplatesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts GetTemplatesOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return getTemplatesRequest{Opts: opts}, err
+}
+
+func decodeGetTemplatesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getTemplatesResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeGetTemplatesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getTemplatesRequest)
+		templates, err := svc.GetTemplates(ctx, req.Opts)
+		return getTemplatesResponse{
+			Templates: templates,
+			Err:       err,
+		}, nil
+	}
+}
+
+func (e Endpoints) GetTemplates(ctx context.Context, opt GetTemplatesOption) ([]Template, error) {
+	request := getTemplatesRequest{opt}
+	response, err := e.GetTemplatesEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(getTemplatesResponse).Templates, response.(getTemplatesResponse).Err
+}
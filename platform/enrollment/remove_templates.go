@@ -0,0 +1,60 @@
+package enrollment
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+func (svc *EnrollmentService) RemoveTemplates(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if err := svc.store.Delete(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type removeTemplatesRequest struct {
+	Names []string `json:"names"`
+}
+
+type removeTemplatesResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r removeTemplatesResponse) Failed() error { return r.Err }
+
+func decodeRemoveTemplatesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req removeTemplatesRequest
+	err := httputil.DecodeJSONRequest(r, &req)
+	return req, err
+}
+
+func decodeRemoveTemplatesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp removeTemplatesResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeRemoveTemplatesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(removeTemplatesRequest)
+		err = svc.RemoveTemplates(ctx, req.Names)
+		return removeTemplatesResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+func (e Endpoints) RemoveTemplates(ctx context.Context, names []string) error {
+	request := removeTemplatesRequest{Names: names}
+	resp, err := e.RemoveTemplatesEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	return resp.(removeTemplatesResponse).Err
+}
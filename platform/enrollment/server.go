@@ -0,0 +1,50 @@
+package enrollment
+
+import (
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type Endpoints struct {
+	ApplyTemplateEndpoint   endpoint.Endpoint
+	GetTemplatesEndpoint    endpoint.Endpoint
+	RemoveTemplatesEndpoint endpoint.Endpoint
+}
+
+func MakeServerEndpoints(s Service, outer endpoint.Middleware, others ...endpoint.Middleware) Endpoints {
+	return Endpoints{
+		ApplyTemplateEndpoint:   endpoint.Chain(outer, others...)(MakeApplyTemplateEndpoint(s)),
+		GetTemplatesEndpoint:    endpoint.Chain(outer, others...)(MakeGetTemplatesEndpoint(s)),
+		RemoveTemplatesEndpoint: endpoint.Chain(outer, others...)(MakeRemoveTemplatesEndpoint(s)),
+	}
+}
+
+func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.ServerOption) {
+	// PUT     /v1/enrollment-templates			create or replace an enrollment template
+	// POST    /v1/enrollment-templates			get a list of enrollment templates
+	// DELETE  /v1/enrollment-templates			remove one or more enrollment templates
+
+	r.Methods("PUT").Path("/v1/enrollment-templates").Handler(httptransport.NewServer(
+		e.ApplyTemplateEndpoint,
+		decodeApplyTemplateRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/enrollment-templates").Handler(httptransport.NewServer(
+		e.GetTemplatesEndpoint,
+		decodeGetTemplatesRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("DELETE").Path("/v1/enrollment-templates").Handler(httptransport.NewServer(
+		e.RemoveTemplatesEndpoint,
+		decodeRemoveTemplatesRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+}
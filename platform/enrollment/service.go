@@ -0,0 +1,39 @@
+package enrollment
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+type Service interface {
+	ApplyTemplate(ctx context.Context, t *Template) error
+	GetTemplates(ctx context.Context, opt GetTemplatesOption) ([]Template, error)
+	RemoveTemplates(ctx context.Context, names []string) error
+}
+
+type Store interface {
+	Save(ctx context.Context, t *Template) error
+	TemplateByName(ctx context.Context, name string) (*Template, error)
+	List(ctx context.Context) ([]Template, error)
+	Delete(ctx context.Context, name string) error
+}
+
+type EnrollmentService struct {
+	store Store
+}
+
+func New(store Store) *EnrollmentService {
+	return &EnrollmentService{store: store}
+}
+
+// IsNotFound returns true if err is because a template doesn't exist.
+func IsNotFound(err error) bool {
+	type notFoundError interface {
+		error
+		NotFound() bool
+	}
+
+	_, ok := errors.Cause(err).(notFoundError)
+	return ok
+}
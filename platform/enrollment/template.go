@@ -0,0 +1,148 @@
+// Package enrollment stores named templates for the enrollment profile.
+package enrollment
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/groob/plist"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/platform/enrollment/internal/enrollmentproto"
+)
+
+// TemplateParam is the /mdm/enroll query parameter which selects a
+// template.
+const TemplateParam = "template"
+
+// Template customizes the enrollment profile. Empty fields keep the defaults
+// of the built-in enrollment profile.
+type Template struct {
+	Name         string `json:"name"`
+	Organization string `json:"organization,omitempty"`
+	DisplayName  string `json:"display_name,omitempty"`
+	Description  string `json:"description,omitempty"`
+	ConsentText  string `json:"consent_text,omitempty"`
+
+	// SCEPSubject is the subject of the device identity certificate, for
+	// example "/O=Acme/CN=%SerialNumber%".
+	SCEPSubject string `json:"scep_subject,omitempty"`
+	SCEPKeySize int    `json:"scep_key_size,omitempty"`
+
+	// AccessRights of the MDM payload. All rights if zero.
+	AccessRights int `json:"access_rights,omitempty"`
+
+	// CACertificates are PEM encoded certificates which are installed as
+	// trusted roots with the enrollment profile.
+	CACertificates []string `json:"ca_certificates,omitempty"`
+
+	// ExtraPayloads are property list dictionaries of additional payloads
+	// for the enrollment profile.
+	ExtraPayloads []string `json:"extra_payloads,omitempty"`
+}
+
+func (t *Template) Verify() error {
+	if t.Name == "" {
+		return errors.New("enrollment template must have a Name")
+	}
+	for _, element := range strings.Split(t.SCEPSubject, "/") {
+		if element != "" && !strings.Contains(element, "=") {
+			return fmt.Errorf("invalid SCEP subject %q: %q is not in the form key=value", t.SCEPSubject, element)
+		}
+	}
+	switch t.SCEPKeySize {
+	case 0, 1024, 2048, 4096:
+	default:
+		return fmt.Errorf("invalid SCEP key size %d: must be 1024, 2048 or 4096", t.SCEPKeySize)
+	}
+	// Apple requires the read right for each write right.
+	if t.AccessRights < 0 || t.AccessRights > 8191 ||
+		(t.AccessRights&2 != 0 && t.AccessRights&1 == 0) ||
+		(t.AccessRights&128 != 0 && t.AccessRights&64 == 0) {
+		return fmt.Errorf("invalid access rights %d", t.AccessRights)
+	}
+	for i := range t.CACertificates {
+		if _, err := t.CACertificate(i); err != nil {
+			return err
+		}
+	}
+	for i := range t.ExtraPayloads {
+		if _, err := t.ExtraPayload(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CACertificate returns the DER bytes of the i-th CA certificate.
+func (t *Template) CACertificate(i int) ([]byte, error) {
+	block, _ := pem.Decode([]byte(t.CACertificates[i]))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("CA certificate %d is not a PEM encoded certificate", i)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, errors.Wrapf(err, "parse CA certificate %d", i)
+	}
+	return block.Bytes, nil
+}
+
+// ExtraPayload returns the i-th extra payload as a dictionary.
+func (t *Template) ExtraPayload(i int) (map[string]interface{}, error) {
+	var payload map[string]interface{}
+	if err := plist.Unmarshal([]byte(t.ExtraPayloads[i]), &payload); err != nil {
+		return nil, errors.Wrapf(err, "extra payload %d is not a property list dictionary", i)
+	}
+	for _, key := range []string{"PayloadType", "PayloadIdentifier", "PayloadUUID"} {
+		if _, ok := payload[key].(string); !ok {
+			return nil, fmt.Errorf("extra payload %d must have a %s", i, key)
+		}
+	}
+	return payload, nil
+}
+
+func MarshalTemplate(t *Template) ([]byte, error) {
+	pb := enrollmentproto.Template{
+		Name:         t.Name,
+		Organization: t.Organization,
+		DisplayName:  t.DisplayName,
+		Description:  t.Description,
+		ConsentText:  t.ConsentText,
+		ScepSubject:  t.SCEPSubject,
+		ScepKeySize:  int64(t.SCEPKeySize),
+		AccessRights: int64(t.AccessRights),
+	}
+	for _, c := range t.CACertificates {
+		pb.CaCertificates = append(pb.CaCertificates, []byte(c))
+	}
+	for _, p := range t.ExtraPayloads {
+		pb.ExtraPayloads = append(pb.ExtraPayloads, []byte(p))
+	}
+	return proto.Marshal(&pb)
+}
+
+func UnmarshalTemplate(data []byte, t *Template) error {
+	var pb enrollmentproto.Template
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to enrollment template")
+	}
+	t.Name = pb.GetName()
+	t.Organization = pb.GetOrganization()
+	t.DisplayName = pb.GetDisplayName()
+	t.Description = pb.GetDescription()
+	t.ConsentText = pb.GetConsentText()
+	t.SCEPSubject = pb.GetScepSubject()
+	t.SCEPKeySize = int(pb.GetScepKeySize())
+	t.AccessRights = int(pb.GetAccessRights())
+	t.CACertificates = nil
+	for _, c := range pb.GetCaCertificates() {
+		t.CACertificates = append(t.CACertificates, string(c))
+	}
+	t.ExtraPayloads = nil
+	for _, p := range pb.GetExtraPayloads() {
+		t.ExtraPayloads = append(t.ExtraPayloads, string(p))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/invitation"
+)
+
+const InvitationBucket = "mdm.Invitations"
+
+type DB struct {
+	*bolt.DB
+}
+
+func NewDB(db *bolt.DB) (*DB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(InvitationBucket))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s bucket", InvitationBucket)
+	}
+	datastore := &DB{
+		DB: db,
+	}
+	return datastore, nil
+}
+
+func (db *DB) Save(ctx context.Context, inv *invitation.Invitation) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		return put(tx.Bucket([]byte(InvitationBucket)), inv)
+	})
+	return errors.Wrap(err, "save invitation")
+}
+
+func put(b *bolt.Bucket, inv *invitation.Invitation) error {
+	pb, err := invitation.MarshalInvitation(inv)
+	if err != nil {
+		return errors.Wrap(err, "marshal invitation")
+	}
+	return b.Put([]byte(inv.Token), pb)
+}
+
+func get(b *bolt.Bucket, token string) (*invitation.Invitation, error) {
+	v := b.Get([]byte(token))
+	if v == nil {
+		return nil, &notFound{"Invitation", fmt.Sprintf("token %s", token)}
+	}
+	var inv invitation.Invitation
+	err := invitation.UnmarshalInvitation(v, &inv)
+	return &inv, err
+}
+
+func (db *DB) InvitationByToken(ctx context.Context, token string) (*invitation.Invitation, error) {
+	var inv *invitation.Invitation
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		inv, err = get(tx.Bucket([]byte(InvitationBucket)), token)
+		return err
+	})
+	return inv, errors.Wrap(err, "get invitation by token")
+}
+
+func (db *DB) List(ctx context.Context) ([]invitation.Invitation, error) {
+	var invitations []invitation.Invitation
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(InvitationBucket)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var inv invitation.Invitation
+			if err := invitation.UnmarshalInvitation(v, &inv); err != nil {
+				return err
+			}
+			invitations = append(invitations, inv)
+		}
+		return nil
+	})
+	return invitations, errors.Wrap(err, "list invitations")
+}
+
+func (db *DB) Delete(ctx context.Context, token string) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(InvitationBucket))
+		if b.Get([]byte(token)) == nil {
+			return &notFound{"Invitation", fmt.Sprintf("token %s", token)}
+		}
+		return b.Delete([]byte(token))
+	})
+	return errors.Wrapf(err, "delete invitation %s", token)
+}
+
+func (db *DB) Redeem(ctx context.Context, token, udid string, now time.Time) (*invitation.Invitation, error) {
+	var inv *invitation.Invitation
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(InvitationBucket))
+		var err error
+		inv, err = get(b, token)
+		if err != nil {
+			return err
+		}
+		if err := inv.Redeem(udid, now); err != nil {
+			return err
+		}
+		return put(b, inv)
+	})
+	return inv, errors.Wrap(err, "redeem invitation")
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
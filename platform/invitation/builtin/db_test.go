@@ -0,0 +1,91 @@
+package builtin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/invitation"
+)
+
+func TestRedeem(t *testing.T) {
+	db := setupDB(t)
+	ctx := context.Background()
+
+	inv, err := invitation.NewInvitation("jane", "lab", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, inv); err != nil {
+		t.Fatalf("saving invitation: %s", err)
+	}
+
+	now := time.Now()
+	redeemed, err := db.Redeem(ctx, inv.Token, "UDID-1", now)
+	if err != nil {
+		t.Fatalf("redeem invitation: %s", err)
+	}
+	if have, want := redeemed.Owner, "jane"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	// the device re-enrolling with the same profile may redeem it again.
+	if _, err := db.Redeem(ctx, inv.Token, "UDID-1", now.Add(2*time.Hour)); err != nil {
+		t.Errorf("redeem invitation again: %s", err)
+	}
+
+	if _, err := db.Redeem(ctx, inv.Token, "UDID-2", now); errors.Cause(err) != invitation.ErrRedeemed {
+		t.Errorf("have %v, want %v", err, invitation.ErrRedeemed)
+	}
+
+	stored, err := db.InvitationByToken(ctx, inv.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := stored.UDID, "UDID-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+
+	expired, err := invitation.NewInvitation("joe", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Save(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Redeem(ctx, expired.Token, "UDID-3", now.Add(time.Hour)); errors.Cause(err) != invitation.ErrExpired {
+		t.Errorf("have %v, want %v", err, invitation.ErrExpired)
+	}
+
+	if err := db.Delete(ctx, expired.Token); err != nil {
+		t.Fatal(err)
+	}
+	invitations, err := db.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(invitations), 1; have != want {
+		t.Errorf("have %d invitations, want %d", have, want)
+	}
+}
+
+func setupDB(t *testing.T) *DB {
+	f, _ := ioutil.TempFile("", "bolt-")
+	f.Close()
+	os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0777, nil)
+	if err != nil {
+		t.Fatalf("couldn't open bolt, err %s\n", err)
+	}
+	invDB, err := NewDB(db)
+	if err != nil {
+		t.Fatalf("couldn't create invitation DB, err %s\n", err)
+	}
+	return invDB
+}
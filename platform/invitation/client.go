@@ -0,0 +1,57 @@
+package invitation
+
+import (
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransport.ClientOption) (Service, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var createInvitationEndpoint endpoint.Endpoint
+	{
+		createInvitationEndpoint = httptransport.NewClient(
+			"PUT",
+			httputil.CopyURL(u, "/v1/invitations"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeCreateInvitationResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var getInvitationsEndpoint endpoint.Endpoint
+	{
+		getInvitationsEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/invitations"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeGetInvitationsResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var removeInvitationsEndpoint endpoint.Endpoint
+	{
+		removeInvitationsEndpoint = httptransport.NewClient(
+			"DELETE",
+			httputil.CopyURL(u, "/v1/invitations"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeRemoveInvitationsResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	return Endpoints{
+		CreateInvitationEndpoint:  createInvitationEndpoint,
+		GetInvitationsEndpoint:    getInvitationsEndpoint,
+		RemoveInvitationsEndpoint: removeInvitationsEndpoint,
+	}, nil
+}
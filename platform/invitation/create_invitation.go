@@ -0,0 +1,71 @@
+package invitation
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type CreateInvitationOption struct {
+	// Owner and Group the enrolled device is bound to.
+	Owner string `json:"owner"`
+	Group string `json:"group"`
+	// TTLSeconds is how long the invitation can be used. DefaultTTL if zero.
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+func (svc *InvitationService) CreateInvitation(ctx context.Context, opt CreateInvitationOption) (*Invitation, error) {
+	inv, err := NewInvitation(opt.Owner, opt.Group, time.Duration(opt.TTLSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.store.Save(ctx, inv); err != nil {
+		return nil, err
+	}
+	svc.withURL(inv)
+	return inv, nil
+}
+
+type createInvitationRequest struct{ Opts CreateInvitationOption }
+type createInvitationResponse struct {
+	Invitation *Invitation `json:"invitation,omitempty"`
+	Err        error       `json:"err,omitempty"`
+}
+
+func (r createInvitationResponse) Failed() error { return r.Err }
+
+func decodeCreateInvitationRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts CreateInvitationOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return createInvitationRequest{Opts: opts}, err
+}
+
+func decodeCreateInvitationResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp createInvitationResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeCreateInvitationEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(createInvitationRequest)
+		inv, err := svc.CreateInvitation(ctx, req.Opts)
+		return createInvitationResponse{
+			Invitation: inv,
+			Err:        err,
+		}, nil
+	}
+}
+
+func (e Endpoints) CreateInvitation(ctx context.Context, opt CreateInvitationOption) (*Invitation, error) {
+	request := createInvitationRequest{opt}
+	response, err := e.CreateInvitationEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(createInvitationResponse).Invitation, response.(createInvitationResponse).Err
+}
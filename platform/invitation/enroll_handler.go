@@ -0,0 +1,58 @@
+package invitation
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+// Enroller returns an enrollment profile whose CheckInURL carries params.
+// It is implemented by the enroll.Service.
+type Enroller interface {
+	EnrollWithParams(ctx context.Context, params url.Values) (profile.Mobileconfig, error)
+}
+
+// MakeEnrollHandler returns the handler for the enrollment URL of
+// invitations. It serves the enrollment profile with the invitation token in
+// the CheckInURL, unless the invitation is unknown, expired or used.
+func MakeEnrollHandler(store Store, enroller Enroller, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get(TokenParam)
+		inv, err := store.InvitationByToken(r.Context(), token)
+		switch {
+		case isNotFound(err):
+			http.Error(w, "invitation not found", http.StatusNotFound)
+			return
+		case err != nil:
+			level.Info(logger).Log("msg", "get enrollment invitation", "err", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		case inv.UDID != "":
+			http.Error(w, ErrRedeemed.Error(), http.StatusGone)
+			return
+		case time.Now().After(inv.ExpiresAt):
+			http.Error(w, ErrExpired.Error(), http.StatusGone)
+			return
+		}
+
+		params := url.Values{TokenParam: {token}}
+		if inv.Group != "" {
+			params.Set(device.GroupParam, inv.Group)
+		}
+		mc, err := enroller.EnrollWithParams(r.Context(), params)
+		if err != nil {
+			level.Info(logger).Log("msg", "make invitation enrollment profile", "err", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-apple-aspen-config")
+		w.Write(mc)
+	}
+}
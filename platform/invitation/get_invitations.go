@@ -0,0 +1,79 @@
+package invitation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type GetInvitationsOption struct {
+	FilterToken string `json:"filter_token"`
+	FilterUDID  string `json:"filter_udid"`
+}
+
+func (svc *InvitationService) GetInvitations(ctx context.Context, opt GetInvitationsOption) ([]Invitation, error) {
+	if opt.FilterToken != "" {
+		inv, err := svc.store.InvitationByToken(ctx, opt.FilterToken)
+		if err != nil {
+			return nil, err
+		}
+		svc.withURL(inv)
+		return []Invitation{*inv}, nil
+	}
+	invitations, err := svc.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []Invitation
+	for _, inv := range invitations {
+		if opt.FilterUDID != "" && inv.UDID != opt.FilterUDID {
+			continue
+		}
+		svc.withURL(&inv)
+		filtered = append(filtered, inv)
+	}
+	return filtered, nil
+}
+
+type getInvitationsRequest struct{ Opts GetInvitationsOption }
+type getInvitationsResponse struct {
+	Invitations []Invitation `json:"invitations"`
+	Err         error        `json:"err,omitempty"`
+}
+
+func (r getInvitationsResponse) Failed() error { return r.Err }
+
+func decodeGetInvitationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts GetInvitationsOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return getInvitationsRequest{Opts: opts}, err
+}
+
+func decodeGetInvitationsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getInvitationsResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeGetInvitationsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(getInvitationsRequest)
+		invitations, err := svc.GetInvitations(ctx, req.Opts)
+		return getInvitationsResponse{
+			Invitations: invitations,
+			Err:         err,
+		}, nil
+	}
+}
+
+func (e Endpoints) GetInvitations(ctx context.Context, opt GetInvitationsOption) ([]Invitation, error) {
+	request := getInvitationsRequest{opt}
+	response, err := e.GetInvitationsEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(getInvitationsResponse).Invitations, response.(getInvitationsResponse).Err
+}
@@ -0,0 +1,136 @@
+// Package invitation provides single-use, expiring enrollment invitations.
+//
+// An invitation is an enrollment URL carrying a token. The token is added to
+// the CheckInURL of the enrollment profile, so the device sends it back on
+// Authenticate, where the invitation is redeemed and the device is bound to
+// the owner and group of the invitation.
+package invitation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/platform/invitation/internal/invitationproto"
+)
+
+// TokenParam is the enrollment URL and CheckInURL query parameter which
+// carries the invitation token.
+const TokenParam = "invitation"
+
+// DefaultTTL is how long an invitation is valid unless created with a TTL.
+const DefaultTTL = 7 * 24 * time.Hour
+
+type Invitation struct {
+	Token string `json:"token"`
+	// URL is the enrollment URL of the invitation. It is not stored.
+	URL       string    `json:"url,omitempty"`
+	Owner     string    `json:"owner,omitempty"`
+	Group     string    `json:"group,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// UDID and RedeemedAt are set when a device enrolls with the invitation.
+	// User Enrollments are recorded by their EnrollmentID.
+	UDID       string    `json:"udid,omitempty"`
+	RedeemedAt time.Time `json:"redeemed_at,omitempty"`
+
+	// SetupUser, SetupFullName and ManagedAppleID are set on the invitations
+	// created for users who sign in to enroll. The device gets them in its
+	// check-in parameters, as it can't be trusted to send them itself.
+	SetupUser      string `json:"setup_user,omitempty"`
+	SetupFullName  string `json:"setup_full_name,omitempty"`
+	ManagedAppleID string `json:"managed_apple_id,omitempty"`
+}
+
+var (
+	ErrRedeemed = errors.New("invitation already used by another device")
+	ErrExpired  = errors.New("invitation expired")
+)
+
+// NewInvitation creates an invitation with a random token, valid for ttl.
+func NewInvitation(owner, group string, ttl time.Duration) (*Invitation, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generate invitation token")
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	now := time.Now().UTC()
+	return &Invitation{
+		Token:     hex.EncodeToString(b),
+		Owner:     owner,
+		Group:     group,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// Redeem binds the invitation to the device with udid. A device may redeem
+// its own invitation again, which happens when it re-enrolls with the same
+// profile.
+func (i *Invitation) Redeem(udid string, now time.Time) error {
+	if i.UDID != "" {
+		if i.UDID == udid {
+			return nil
+		}
+		return ErrRedeemed
+	}
+	if now.After(i.ExpiresAt) {
+		return ErrExpired
+	}
+	i.UDID = udid
+	i.RedeemedAt = now.UTC()
+	return nil
+}
+
+func MarshalInvitation(i *Invitation) ([]byte, error) {
+	return proto.Marshal(&invitationproto.Invitation{
+		Token:          i.Token,
+		Owner:          i.Owner,
+		Group:          i.Group,
+		CreatedAt:      timeToNano(i.CreatedAt),
+		ExpiresAt:      timeToNano(i.ExpiresAt),
+		Udid:           i.UDID,
+		RedeemedAt:     timeToNano(i.RedeemedAt),
+		SetupUser:      i.SetupUser,
+		SetupFullName:  i.SetupFullName,
+		ManagedAppleId: i.ManagedAppleID,
+	})
+}
+
+func UnmarshalInvitation(data []byte, i *Invitation) error {
+	var pb invitationproto.Invitation
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to invitation")
+	}
+	i.Token = pb.GetToken()
+	i.Owner = pb.GetOwner()
+	i.Group = pb.GetGroup()
+	i.CreatedAt = timeFromNano(pb.GetCreatedAt())
+	i.ExpiresAt = timeFromNano(pb.GetExpiresAt())
+	i.UDID = pb.GetUdid()
+	i.RedeemedAt = timeFromNano(pb.GetRedeemedAt())
+	i.SetupUser = pb.GetSetupUser()
+	i.SetupFullName = pb.GetSetupFullName()
+	i.ManagedAppleID = pb.GetManagedAppleId()
+	return nil
+}
+
+func timeToNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func timeFromNano(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano).UTC()
+}
@@ -0,0 +1,116 @@
+package invitation
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// CheckinMiddleware redeems the invitation of devices which enroll with an
+// invitation token in their CheckInURL. The Authenticate check-in of a device
+// is rejected if the invitation doesn't exist, expired or was used by another
+// device. Otherwise the owner, group and signed in user of the invitation are
+// passed on in the check-in parameters, so the device record is bound to
+// them. Later check-ins of the device get them from the redeemed invitation
+// as well. Devices without an invitation token are passed through.
+func CheckinMiddleware(store Store, logger log.Logger) mdm.Middleware {
+	return func(next mdm.Service) mdm.Service {
+		return &checkinMiddleware{
+			store:  store,
+			logger: logger,
+			next:   next,
+		}
+	}
+}
+
+type checkinMiddleware struct {
+	store  Store
+	logger log.Logger
+	next   mdm.Service
+}
+
+// invitationParams only come from the invitation of a device, never from the
+// CheckInURL itself, which the device can change.
+var invitationParams = map[string]bool{
+	device.OwnerParam:          true,
+	device.SetupUserParam:      true,
+	device.SetupFullNameParam:  true,
+	device.ManagedAppleIDParam: true,
+}
+
+func (mw *checkinMiddleware) Checkin(ctx context.Context, req mdm.CheckinEvent) ([]byte, error) {
+	params := make(map[string]string, len(req.Params))
+	for k, v := range req.Params {
+		if !invitationParams[k] {
+			params[k] = v
+		}
+	}
+	req.Params = params
+
+	token := params[TokenParam]
+	if token == "" {
+		return mw.next.Checkin(ctx, req)
+	}
+	id := req.Command.UDID
+	if id == "" {
+		id = req.Command.EnrollmentID
+	}
+	var inv *Invitation
+	if req.Command.MessageType == "Authenticate" {
+		var err error
+		inv, err = mw.store.Redeem(ctx, token, id, time.Now())
+		if err != nil {
+			if isNotFound(err) || errors.Cause(err) == ErrRedeemed || errors.Cause(err) == ErrExpired {
+				level.Info(mw.logger).Log("msg", "rejected enrollment invitation", "udid", id, "err", err)
+				return nil, rejectErr{err}
+			}
+			return nil, errors.Wrap(err, "redeem enrollment invitation")
+		}
+		level.Info(mw.logger).Log("msg", "redeemed enrollment invitation", "udid", id, "owner", inv.Owner, "group", inv.Group)
+	} else {
+		var err error
+		inv, err = mw.store.InvitationByToken(ctx, token)
+		if isNotFound(err) {
+			return mw.next.Checkin(ctx, req)
+		} else if err != nil {
+			return nil, errors.Wrap(err, "get enrollment invitation")
+		}
+		if inv.UDID != id {
+			// the token of an invitation redeemed by another device.
+			return mw.next.Checkin(ctx, req)
+		}
+	}
+	for k, v := range map[string]string{
+		device.OwnerParam:          inv.Owner,
+		device.GroupParam:          inv.Group,
+		device.SetupUserParam:      inv.SetupUser,
+		device.SetupFullNameParam:  inv.SetupFullName,
+		device.ManagedAppleIDParam: inv.ManagedAppleID,
+	} {
+		if v != "" {
+			params[k] = v
+		}
+	}
+	return mw.next.Checkin(ctx, req)
+}
+
+func (mw *checkinMiddleware) Acknowledge(ctx context.Context, req mdm.AcknowledgeEvent) ([]byte, error) {
+	return mw.next.Acknowledge(ctx, req)
+}
+
+// rejectErr fails the enrollment of a device with an invalid invitation.
+type rejectErr struct{ err error }
+
+func (e rejectErr) Error() string {
+	return "enrollment invitation rejected: " + e.err.Error()
+}
+
+func (rejectErr) Checkout() bool {
+	return true
+}
@@ -0,0 +1,173 @@
+package invitation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+type memStore struct {
+	Store
+	invitations map[string]*Invitation
+}
+
+type notFoundErr struct{}
+
+func (notFoundErr) Error() string  { return "not found" }
+func (notFoundErr) NotFound() bool { return true }
+
+func (s *memStore) Redeem(ctx context.Context, token, udid string, now time.Time) (*Invitation, error) {
+	inv, ok := s.invitations[token]
+	if !ok {
+		return nil, notFoundErr{}
+	}
+	return inv, inv.Redeem(udid, now)
+}
+
+func (s *memStore) InvitationByToken(ctx context.Context, token string) (*Invitation, error) {
+	inv, ok := s.invitations[token]
+	if !ok {
+		return nil, notFoundErr{}
+	}
+	return inv, nil
+}
+
+type nopService struct {
+	checkins int
+	params   map[string]string
+}
+
+func (s *nopService) Checkin(ctx context.Context, req mdm.CheckinEvent) ([]byte, error) {
+	s.checkins++
+	s.params = req.Params
+	return nil, nil
+}
+
+func (s *nopService) Acknowledge(ctx context.Context, req mdm.AcknowledgeEvent) ([]byte, error) {
+	return nil, nil
+}
+
+func TestCheckinMiddleware(t *testing.T) {
+	inv, err := NewInvitation("jane", "lab", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memStore{invitations: map[string]*Invitation{inv.Token: inv}}
+	next := new(nopService)
+	svc := CheckinMiddleware(store, log.NewNopLogger())(next)
+
+	authenticate := func(udid, token string) error {
+		// devices can't claim an owner themselves.
+		ev := mdm.CheckinEvent{Params: map[string]string{device.OwnerParam: "mallory"}}
+		ev.Command.MessageType = "Authenticate"
+		ev.Command.UDID = udid
+		if token != "" {
+			ev.Params[TokenParam] = token
+		}
+		_, err := svc.Checkin(context.Background(), ev)
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		udid     string
+		token    string
+		rejected bool
+	}{
+		{"no invitation", "UDID-0", "", false},
+		{"redeem", "UDID-1", inv.Token, false},
+		{"re-enroll", "UDID-1", inv.Token, false},
+		{"reuse", "UDID-2", inv.Token, true},
+		{"unknown", "UDID-3", "bogus", true},
+	}
+	for _, tt := range tests {
+		next.params = nil
+		err := authenticate(tt.udid, tt.token)
+		if _, rejected := err.(rejectErr); rejected != tt.rejected {
+			t.Errorf("%s: have err %v, want rejected %v", tt.name, err, tt.rejected)
+		}
+		if tt.rejected {
+			continue
+		}
+		// the device record is bound to the owner and group of the invitation.
+		wantOwner, wantGroup := "", ""
+		if tt.token != "" {
+			wantOwner, wantGroup = "jane", "lab"
+		}
+		if have, want := next.params[device.OwnerParam], wantOwner; have != want {
+			t.Errorf("%s: have owner %q, want %q", tt.name, have, want)
+		}
+		if have, want := next.params[device.GroupParam], wantGroup; have != want {
+			t.Errorf("%s: have group %q, want %q", tt.name, have, want)
+		}
+	}
+	if have, want := inv.UDID, "UDID-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := next.checkins, 3; have != want {
+		t.Errorf("have %d check-ins passed through, want %d", have, want)
+	}
+}
+
+func TestCheckinMiddlewareSignIn(t *testing.T) {
+	inv, err := NewInvitation("", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.SetupUser = "jane"
+	inv.SetupFullName = "Jane Appleseed"
+	store := &memStore{invitations: map[string]*Invitation{inv.Token: inv}}
+	next := new(nopService)
+	svc := CheckinMiddleware(store, log.NewNopLogger())(next)
+
+	checkin := func(messageType, udid, token string) {
+		t.Helper()
+		// the device claims another user in its CheckInURL.
+		ev := mdm.CheckinEvent{Params: map[string]string{
+			device.SetupUserParam:      "mallory",
+			device.SetupFullNameParam:  "Mallory",
+			device.ManagedAppleIDParam: "mallory@example.org",
+		}}
+		ev.Command.MessageType = messageType
+		ev.Command.UDID = udid
+		if token != "" {
+			ev.Params[TokenParam] = token
+		}
+		next.params = nil
+		if _, err := svc.Checkin(context.Background(), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		messageType string
+		udid        string
+		token       string
+		user        string
+		fullName    string
+	}{
+		{"Authenticate", "UDID-0", "", "", ""},
+		{"TokenUpdate", "UDID-0", "", "", ""},
+		{"Authenticate", "UDID-1", inv.Token, "jane", "Jane Appleseed"},
+		{"TokenUpdate", "UDID-1", inv.Token, "jane", "Jane Appleseed"},
+		// the token of the invitation redeemed by UDID-1.
+		{"TokenUpdate", "UDID-2", inv.Token, "", ""},
+	}
+	for _, tt := range tests {
+		checkin(tt.messageType, tt.udid, tt.token)
+		if have, want := next.params[device.SetupUserParam], tt.user; have != want {
+			t.Errorf("%s %s: have setup user %q, want %q", tt.messageType, tt.udid, have, want)
+		}
+		if have, want := next.params[device.SetupFullNameParam], tt.fullName; have != want {
+			t.Errorf("%s %s: have setup full name %q, want %q", tt.messageType, tt.udid, have, want)
+		}
+		if have := next.params[device.ManagedAppleIDParam]; have != "" {
+			t.Errorf("%s %s: have managed apple id %q, want none", tt.messageType, tt.udid, have)
+		}
+	}
+}
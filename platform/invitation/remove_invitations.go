@@ -0,0 +1,62 @@
+package invitation
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+// RemoveInvitations revokes invitations. Devices which already enrolled with
+// a removed invitation stay enrolled.
+func (svc *InvitationService) RemoveInvitations(ctx context.Context, tokens []string) error {
+	for _, token := range tokens {
+		if err := svc.store.Delete(ctx, token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type removeInvitationsRequest struct {
+	Tokens []string `json:"tokens"`
+}
+
+type removeInvitationsResponse struct {
+	Err error `json:"err,omitempty"`
+}
+
+func (r removeInvitationsResponse) Failed() error { return r.Err }
+
+func decodeRemoveInvitationsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var req removeInvitationsRequest
+	err := httputil.DecodeJSONRequest(r, &req)
+	return req, err
+}
+
+func decodeRemoveInvitationsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp removeInvitationsResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeRemoveInvitationsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(removeInvitationsRequest)
+		err = svc.RemoveInvitations(ctx, req.Tokens)
+		return removeInvitationsResponse{
+			Err: err,
+		}, nil
+	}
+}
+
+func (e Endpoints) RemoveInvitations(ctx context.Context, tokens []string) error {
+	request := removeInvitationsRequest{Tokens: tokens}
+	resp, err := e.RemoveInvitationsEndpoint(ctx, request)
+	if err != nil {
+		return err
+	}
+	return resp.(removeInvitationsResponse).Err
+}
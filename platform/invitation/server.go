@@ -0,0 +1,50 @@
+package invitation
+
+import (
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type Endpoints struct {
+	CreateInvitationEndpoint  endpoint.Endpoint
+	GetInvitationsEndpoint    endpoint.Endpoint
+	RemoveInvitationsEndpoint endpoint.Endpoint
+}
+
+func MakeServerEndpoints(s Service, outer endpoint.Middleware, others ...endpoint.Middleware) Endpoints {
+	return Endpoints{
+		CreateInvitationEndpoint:  endpoint.Chain(outer, others...)(MakeCreateInvitationEndpoint(s)),
+		GetInvitationsEndpoint:    endpoint.Chain(outer, others...)(MakeGetInvitationsEndpoint(s)),
+		RemoveInvitationsEndpoint: endpoint.Chain(outer, others...)(MakeRemoveInvitationsEndpoint(s)),
+	}
+}
+
+func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.ServerOption) {
+	// PUT     /v1/invitations			create an enrollment invitation
+	// POST    /v1/invitations			get a list of enrollment invitations
+	// DELETE  /v1/invitations			revoke one or more enrollment invitations
+
+	r.Methods("PUT").Path("/v1/invitations").Handler(httptransport.NewServer(
+		e.CreateInvitationEndpoint,
+		decodeCreateInvitationRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/invitations").Handler(httptransport.NewServer(
+		e.GetInvitationsEndpoint,
+		decodeGetInvitationsRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("DELETE").Path("/v1/invitations").Handler(httptransport.NewServer(
+		e.RemoveInvitationsEndpoint,
+		decodeRemoveInvitationsRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+}
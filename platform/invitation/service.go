@@ -0,0 +1,51 @@
+package invitation
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Service interface {
+	CreateInvitation(ctx context.Context, opt CreateInvitationOption) (*Invitation, error)
+	GetInvitations(ctx context.Context, opt GetInvitationsOption) ([]Invitation, error)
+	RemoveInvitations(ctx context.Context, tokens []string) error
+}
+
+type Store interface {
+	Save(ctx context.Context, i *Invitation) error
+	InvitationByToken(ctx context.Context, token string) (*Invitation, error)
+	List(ctx context.Context) ([]Invitation, error)
+	Delete(ctx context.Context, token string) error
+
+	// Redeem redeems the invitation for the device with udid, as a single
+	// transaction so that an invitation can't be used by two devices.
+	Redeem(ctx context.Context, token, udid string, now time.Time) (*Invitation, error)
+}
+
+type InvitationService struct {
+	store     Store
+	serverURL string
+}
+
+// New returns an invitation service. serverURL is the public URL of the
+// server, used for the enrollment URL of invitations.
+func New(store Store, serverURL string) *InvitationService {
+	return &InvitationService{store: store, serverURL: serverURL}
+}
+
+func (svc *InvitationService) withURL(i *Invitation) {
+	i.URL = svc.serverURL + "/mdm/enroll?" + url.Values{TokenParam: {i.Token}}.Encode()
+}
+
+func isNotFound(err error) bool {
+	type notFoundError interface {
+		error
+		NotFound() bool
+	}
+
+	_, ok := errors.Cause(err).(notFoundError)
+	return ok
+}
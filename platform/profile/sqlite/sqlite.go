@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+const tableName = "profiles"
+
+type profileRow struct {
+	Identifier   string `db:"identifier"`
+	Mobileconfig []byte `db:"mobileconfig"`
+}
+
+func (d *SQLite) List() ([]profile.Profile, error) {
+	query, args, err := sq.
+		Select("identifier", "mobileconfig").
+		From(tableName).
+		OrderBy("identifier").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var rows []profileRow
+	if err := d.db.Select(&rows, query, args...); err != nil {
+		return nil, errors.Wrap(err, "list profiles")
+	}
+	var list []profile.Profile
+	for _, r := range rows {
+		list = append(list, profile.Profile{
+			Identifier:   r.Identifier,
+			Mobileconfig: profile.Mobileconfig(r.Mobileconfig),
+		})
+	}
+	return list, nil
+}
+
+func (d *SQLite) Save(p *profile.Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	query, args, err := sq.
+		Insert(tableName).
+		Options("OR REPLACE").
+		Columns("identifier", "mobileconfig").
+		Values(p.Identifier, []byte(p.Mobileconfig)).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building profile save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrap(err, "exec profile save in sqlite")
+}
+
+func (d *SQLite) ProfileById(ctx context.Context, id string) (*profile.Profile, error) {
+	query, args, err := sq.
+		Select("identifier", "mobileconfig").
+		From(tableName).
+		Where(sq.Eq{"identifier": id}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var r profileRow
+	err = d.db.QueryRowxContext(ctx, query, args...).StructScan(&r)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, &notFound{"Profile", fmt.Sprintf("id %s", id)}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "finding profile by id")
+	}
+	return &profile.Profile{
+		Identifier:   r.Identifier,
+		Mobileconfig: profile.Mobileconfig(r.Mobileconfig),
+	}, nil
+}
+
+func (d *SQLite) Delete(id string) error {
+	query, args, err := sq.
+		Delete(tableName).
+		Where(sq.Eq{"identifier": id}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	res, err := d.db.Exec(query, args...)
+	if err != nil {
+		return errors.Wrap(err, "delete profile")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return &notFound{"Profile", fmt.Sprintf("id %s", id)}
+	}
+	return nil
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
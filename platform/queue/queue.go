@@ -23,27 +23,39 @@ const (
 	CommandQueuedTopic = "mdm.CommandQueued"
 )
 
-type Store struct {
-	*bolt.DB
+// DeviceCommandStore persists the command queue of each device.
+type DeviceCommandStore interface {
+	Save(cmd *DeviceCommand) error
+	DeviceCommand(udid string) (*DeviceCommand, error)
+}
+
+// Queue is an MDM command queue backed by a DeviceCommandStore.
+type Queue struct {
+	DeviceCommandStore
 	logger         log.Logger
 	withoutHistory bool
 }
 
-type Option func(*Store)
+// Store is a DeviceCommandStore backed by BoltDB.
+type Store struct {
+	*bolt.DB
+}
+
+type Option func(*Queue)
 
 func WithLogger(logger log.Logger) Option {
-	return func(s *Store) {
-		s.logger = logger
+	return func(q *Queue) {
+		q.logger = logger
 	}
 }
 
 func WithoutHistory() Option {
-	return func(s *Store) {
-		s.withoutHistory = true
+	return func(q *Queue) {
+		q.withoutHistory = true
 	}
 }
 
-func (db *Store) Next(ctx context.Context, resp mdm.Response) ([]byte, error) {
+func (db *Queue) Next(ctx context.Context, resp mdm.Response) ([]byte, error) {
 	cmd, err := db.nextCommand(ctx, resp)
 	if err != nil {
 		return nil, err
@@ -54,7 +66,7 @@ func (db *Store) Next(ctx context.Context, resp mdm.Response) ([]byte, error) {
 	return cmd.Payload, nil
 }
 
-func (db *Store) Clear(ctx context.Context, event mdm.CheckinEvent) error {
+func (db *Queue) Clear(ctx context.Context, event mdm.CheckinEvent) error {
 	udid := event.Command.UDID
 	if event.Command.UserID != "" {
 		udid = event.Command.UserID
@@ -76,7 +88,7 @@ func (db *Store) Clear(ctx context.Context, event mdm.CheckinEvent) error {
 	return db.Save(dc)
 }
 
-func (db *Store) nextCommand(ctx context.Context, resp mdm.Response) (*Command, error) {
+func (db *Queue) nextCommand(ctx context.Context, resp mdm.Response) (*Command, error) {
 	// The UDID is the primary key for the queue.
 	// Depending on the enrollment type, replace the UDID with a different ID type.
 	// UserID for managed user channel
@@ -155,13 +167,13 @@ func (db *Store) nextCommand(ctx context.Context, resp mdm.Response) (*Command,
 	// If the regular queue is empty, send a command that got
 	// refused with NotNow before.
 	cmd, dc.Commands = popFirst(dc.Commands)
+	if cmd == nil && resp.Status != "NotNow" {
+		cmd, dc.NotNow = popFirst(dc.NotNow)
+	}
 	if cmd != nil {
+		cmd.TimesSent++
+		cmd.LastSentAt = time.Now().UTC()
 		dc.Commands = append(dc.Commands, *cmd)
-	} else if resp.Status != "NotNow" {
-		cmd, dc.NotNow = popFirst(dc.NotNow)
-		if cmd != nil {
-			dc.Commands = append(dc.Commands, *cmd)
-		}
 	}
 
 	// we only need to Save if there are command queue changes such as
@@ -175,6 +187,23 @@ func (db *Store) nextCommand(ctx context.Context, resp mdm.Response) (*Command,
 	return cmd, nil
 }
 
+// HasUnfetchedCommands reports whether the device has queued commands which
+// it hasn't fetched yet.
+func (db *Queue) HasUnfetchedCommands(ctx context.Context, udid string) (bool, error) {
+	dc, err := db.DeviceCommand(udid)
+	if isNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "get device command from queue, udid: %s", udid)
+	}
+	for _, cmd := range dc.Commands {
+		if cmd.TimesSent == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func popFirst(all []Command) (*Command, []Command) {
 	if len(all) == 0 {
 		return nil, all
@@ -194,7 +223,8 @@ func cut(all []Command, uuid string) (*Command, []Command) {
 	return nil, all
 }
 
-func NewQueue(db *bolt.DB, pubsub pubsub.PublishSubscriber, opts ...Option) (*Store, error) {
+// NewQueue creates a command queue stored in BoltDB.
+func NewQueue(db *bolt.DB, pubsub pubsub.PublishSubscriber, opts ...Option) (*Queue, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucketIfNotExists([]byte(DeviceCommandBucket))
 		return err
@@ -202,17 +232,21 @@ func NewQueue(db *bolt.DB, pubsub pubsub.PublishSubscriber, opts ...Option) (*St
 	if err != nil {
 		return nil, errors.Wrapf(err, "creating %s bucket", DeviceCommandBucket)
 	}
+	return New(&Store{DB: db}, pubsub, opts...)
+}
 
-	datastore := &Store{DB: db, logger: log.NewNopLogger()}
+// New creates a command queue which persists device commands in store.
+func New(store DeviceCommandStore, pubsub pubsub.PublishSubscriber, opts ...Option) (*Queue, error) {
+	q := &Queue{DeviceCommandStore: store, logger: log.NewNopLogger()}
 	for _, fn := range opts {
-		fn(datastore)
+		fn(q)
 	}
 
-	if err := datastore.pollCommands(pubsub); err != nil {
+	if err := q.pollCommands(pubsub); err != nil {
 		return nil, err
 	}
 
-	return datastore, nil
+	return q, nil
 }
 
 func (db *Store) Save(cmd *DeviceCommand) error {
@@ -260,7 +294,11 @@ func (e *notFound) Error() string {
 	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
 }
 
-func (db *Store) pollCommands(pubsub pubsub.PublishSubscriber) error {
+func (e *notFound) NotFound() bool {
+	return true
+}
+
+func (db *Queue) pollCommands(pubsub pubsub.PublishSubscriber) error {
 	commandEvents, err := pubsub.Subscribe(context.TODO(), "command-queue", command.CommandTopic)
 	if err != nil {
 		return errors.Wrapf(err,
@@ -319,10 +357,14 @@ func (db *Store) pollCommands(pubsub pubsub.PublishSubscriber) error {
 }
 
 func isNotFound(err error) bool {
-	if _, ok := err.(*notFound); ok {
-		return true
+	err = errors.Cause(err)
+	type notFoundErr interface {
+		error
+		NotFound() bool
 	}
-	return false
+
+	e, ok := err.(notFoundErr)
+	return ok && e.NotFound()
 }
 
 func PublishCommandQueued(pub pubsub.Publisher, udid, uuid string) error {
@@ -155,7 +155,49 @@ func TestNext_zeroCommands(t *testing.T) {
 
 }
 
-func setupDB(t *testing.T) (*Store, func()) {
+func TestHasUnfetchedCommands(t *testing.T) {
+	store, teardown := setupDB(t)
+	defer teardown()
+	ctx := context.Background()
+
+	unfetched, err := store.HasUnfetchedCommands(ctx, "TestDevice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unfetched {
+		t.Error("device without a queue has unfetched commands")
+	}
+
+	dc := &DeviceCommand{DeviceUDID: "TestDevice"}
+	dc.Commands = append(dc.Commands, Command{UUID: "xCmd"})
+	dc.Commands = append(dc.Commands, Command{UUID: "yCmd"})
+	if err := store.Save(dc); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := mdm.Response{UDID: dc.DeviceUDID, Status: "Idle"}
+	for i, want := range []bool{true, false} {
+		cmd, err := store.nextCommand(ctx, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := cmd.TimesSent, 1; have != want {
+			t.Errorf("have TimesSent %d, want %d", have, want)
+		}
+		if cmd.LastSentAt.IsZero() {
+			t.Error("LastSentAt not set")
+		}
+		unfetched, err := store.HasUnfetchedCommands(ctx, dc.DeviceUDID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := unfetched; have != want {
+			t.Errorf("after %d commands: have unfetched %v, want %v", i+1, have, want)
+		}
+	}
+}
+
+func setupDB(t *testing.T) (*Queue, func()) {
 	f, _ := ioutil.TempFile("", "bolt-")
 	teardown := func() {
 		f.Close()
@@ -173,6 +215,6 @@ func setupDB(t *testing.T) (*Store, func()) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	store := &Store{DB: db, logger: log.NewNopLogger()}
+	store := &Queue{DeviceCommandStore: &Store{DB: db}, logger: log.NewNopLogger()}
 	return store, teardown
 }
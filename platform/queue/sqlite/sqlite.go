@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/queue"
+)
+
+// Names of the queues a command can be in. The position column orders the
+// commands within a queue.
+const (
+	pendingQueue   = "pending"
+	completedQueue = "completed"
+	failedQueue    = "failed"
+	notNowQueue    = "not_now"
+)
+
+const tableName = "device_commands"
+
+// SQLite is a queue.DeviceCommandStore backed by SQLite.
+// Every command is a row keyed by device UDID, queue and position.
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func columns() []string {
+	return []string{
+		"device_udid",
+		"queue",
+		"position",
+		"uuid",
+		"payload",
+		"created_at",
+		"last_sent_at",
+		"acknowledged",
+		"times_sent",
+		"last_status",
+		"failure_message",
+	}
+}
+
+type commandRow struct {
+	DeviceUDID     string    `db:"device_udid"`
+	Queue          string    `db:"queue"`
+	Position       int       `db:"position"`
+	UUID           string    `db:"uuid"`
+	Payload        []byte    `db:"payload"`
+	CreatedAt      time.Time `db:"created_at"`
+	LastSentAt     time.Time `db:"last_sent_at"`
+	Acknowledged   time.Time `db:"acknowledged"`
+	TimesSent      int       `db:"times_sent"`
+	LastStatus     string    `db:"last_status"`
+	FailureMessage []byte    `db:"failure_message"`
+}
+
+func (r commandRow) command() queue.Command {
+	return queue.Command{
+		UUID:           r.UUID,
+		Payload:        r.Payload,
+		CreatedAt:      r.CreatedAt,
+		LastSentAt:     r.LastSentAt,
+		Acknowledged:   r.Acknowledged,
+		TimesSent:      r.TimesSent,
+		LastStatus:     r.LastStatus,
+		FailureMessage: r.FailureMessage,
+	}
+}
+
+// Save replaces all stored commands of the device with the commands in dc.
+func (d *SQLite) Save(dc *queue.DeviceCommand) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	query, args, err := sq.
+		Delete(tableName).
+		Where(sq.Eq{"device_udid": dc.DeviceUDID}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return errors.Wrap(err, "delete device commands")
+	}
+
+	queues := []struct {
+		name     string
+		commands []queue.Command
+	}{
+		{pendingQueue, dc.Commands},
+		{completedQueue, dc.Completed},
+		{failedQueue, dc.Failed},
+		{notNowQueue, dc.NotNow},
+	}
+	for _, q := range queues {
+		for i, cmd := range q.commands {
+			query, args, err := sq.
+				Insert(tableName).
+				Columns(columns()...).
+				Values(
+					dc.DeviceUDID,
+					q.name,
+					i,
+					cmd.UUID,
+					cmd.Payload,
+					cmd.CreatedAt,
+					cmd.LastSentAt,
+					cmd.Acknowledged,
+					cmd.TimesSent,
+					cmd.LastStatus,
+					cmd.FailureMessage,
+				).
+				ToSql()
+			if err != nil {
+				return errors.Wrap(err, "building device command save query")
+			}
+			if _, err := tx.Exec(query, args...); err != nil {
+				return errors.Wrapf(err, "save command %s in sqlite", cmd.UUID)
+			}
+		}
+	}
+	return errors.Wrap(tx.Commit(), "commit device commands")
+}
+
+func (d *SQLite) DeviceCommand(udid string) (*queue.DeviceCommand, error) {
+	query, args, err := sq.
+		Select(columns()...).
+		From(tableName).
+		Where(sq.Eq{"device_udid": udid}).
+		OrderBy("queue", "position").
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var rows []commandRow
+	if err := d.db.Select(&rows, query, args...); err != nil {
+		return nil, errors.Wrap(err, "get device commands")
+	}
+	if len(rows) == 0 {
+		return nil, &notFound{"DeviceCommand", fmt.Sprintf("udid %s", udid)}
+	}
+
+	dc := &queue.DeviceCommand{DeviceUDID: udid}
+	for _, r := range rows {
+		switch r.Queue {
+		case pendingQueue:
+			dc.Commands = append(dc.Commands, r.command())
+		case completedQueue:
+			dc.Completed = append(dc.Completed, r.command())
+		case failedQueue:
+			dc.Failed = append(dc.Failed, r.command())
+		case notNowQueue:
+			dc.NotNow = append(dc.NotNow, r.command())
+		}
+	}
+	return dc, nil
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
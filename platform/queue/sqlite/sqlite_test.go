@@ -0,0 +1,76 @@
+package sqlite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/queue"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+func TestSaveDeviceCommand(t *testing.T) {
+	store := setup(t)
+
+	if _, err := store.DeviceCommand("TestDevice"); err == nil {
+		t.Fatal("expected not found error for unknown device")
+	}
+
+	now := time.Now().UTC()
+	dc := &queue.DeviceCommand{DeviceUDID: "TestDevice"}
+	dc.Commands = append(dc.Commands, queue.Command{UUID: "xCmd", Payload: []byte("x")})
+	dc.Commands = append(dc.Commands, queue.Command{UUID: "yCmd", Payload: []byte("y")})
+	dc.Completed = append(dc.Completed, queue.Command{UUID: "zCmd", Acknowledged: now, TimesSent: 1})
+	dc.NotNow = append(dc.NotNow, queue.Command{UUID: "wCmd"})
+	if err := store.Save(dc); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := store.DeviceCommand("TestDevice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(found.Commands), 2; have != want {
+		t.Fatalf("have %d commands, want %d", have, want)
+	}
+	if have, want := found.Commands[0].UUID, "xCmd"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := string(found.Commands[1].Payload), "y"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := len(found.Completed), 1; have != want {
+		t.Fatalf("have %d completed commands, want %d", have, want)
+	}
+	if have, want := found.Completed[0].Acknowledged, now; !have.Equal(want) {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := len(found.NotNow), 1; have != want {
+		t.Errorf("have %d NotNow commands, want %d", have, want)
+	}
+
+	// saving again replaces the previous queues.
+	found.Commands = found.Commands[1:]
+	found.NotNow = nil
+	if err := store.Save(found); err != nil {
+		t.Fatal(err)
+	}
+	found, err = store.DeviceCommand("TestDevice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(found.Commands), 1; have != want {
+		t.Errorf("have %d commands, want %d", have, want)
+	}
+	if have, want := len(found.NotNow), 0; have != want {
+		t.Errorf("have %d NotNow commands, want %d", have, want)
+	}
+}
+
+func setup(t *testing.T) *SQLite {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db)
+}
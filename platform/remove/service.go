@@ -37,3 +37,32 @@ type logmw struct {
 	logger log.Logger
 	next   Service
 }
+
+// CertificateRevoker revokes the identity certificate of a device.
+type CertificateRevoker interface {
+	RevokeDevice(ctx context.Context, udid string) error
+}
+
+// RevokeMiddleware revokes the identity certificate of blocked devices, so
+// that they can't check in again before they enroll again.
+func RevokeMiddleware(r CertificateRevoker) Middleware {
+	return func(next Service) Service {
+		return revokemw{revoker: r, next: next}
+	}
+}
+
+type revokemw struct {
+	revoker CertificateRevoker
+	next    Service
+}
+
+func (mw revokemw) BlockDevice(ctx context.Context, udid string) error {
+	if err := mw.next.BlockDevice(ctx, udid); err != nil {
+		return err
+	}
+	return mw.revoker.RevokeDevice(ctx, udid)
+}
+
+func (mw revokemw) UnblockDevice(ctx context.Context, udid string) error {
+	return mw.next.UnblockDevice(ctx, udid)
+}
@@ -0,0 +1,223 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	boltdepot "github.com/micromdm/scep/v2/depot/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/scep"
+)
+
+const (
+	// CertificateBucket is the bucket of the BoltDB depot from
+	// github.com/micromdm/scep, which also holds the CA and serial.
+	CertificateBucket = "scep_certificates"
+	PreviousCABucket  = "mdm.SCEPPreviousCAs"
+	RevocationBucket  = "mdm.SCEPRevocations"
+	RenewalBucket     = "mdm.SCEPRenewals"
+)
+
+// Depot is the BoltDB SCEP depot which can also list the issued certificates.
+type Depot struct {
+	*boltdepot.Depot
+}
+
+func NewDepot(db *bolt.DB) (*Depot, error) {
+	d, err := boltdepot.NewBoltDepot(db)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{PreviousCABucket, RevocationBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return errors.Wrapf(err, "creating %s bucket", name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Depot{Depot: d}, nil
+}
+
+// depotKeys are the keys of the certificate bucket which aren't issued
+// certificates.
+var depotKeys = [][]byte{[]byte("serial"), []byte("ca_certificate"), []byte("ca_key")}
+
+func (d *Depot) EachCertificate(fn func(*x509.Certificate) error) error {
+	err := d.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(CertificateBucket)).Cursor()
+	next:
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			for _, key := range depotKeys {
+				if bytes.Equal(k, key) {
+					continue next
+				}
+			}
+			cert, err := x509.ParseCertificate(v)
+			if err != nil {
+				return errors.Wrapf(err, "parse certificate %s", k)
+			}
+			if err := fn(cert); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "list scep certificates")
+}
+
+// RotateCA replaces the CA certificate and key, keeping the replaced CA
+// certificate in the previous CAs.
+func (d *Depot) RotateCA(crt *x509.Certificate, key *rsa.PrivateKey) error {
+	err := d.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(CertificateBucket))
+		if old := b.Get([]byte("ca_certificate")); old != nil {
+			// values are only valid during the transaction.
+			prev, err := x509.ParseCertificate(append([]byte(nil), old...))
+			if err != nil {
+				return errors.Wrap(err, "parse CA certificate")
+			}
+			if err := tx.Bucket([]byte(PreviousCABucket)).Put([]byte(scep.Fingerprint(prev)), prev.Raw); err != nil {
+				return err
+			}
+		}
+		if err := b.Put([]byte("ca_certificate"), crt.Raw); err != nil {
+			return err
+		}
+		return b.Put([]byte("ca_key"), x509.MarshalPKCS1PrivateKey(key))
+	})
+	return errors.Wrap(err, "rotate scep CA")
+}
+
+// PreviousCAs returns the CAs replaced by RotateCA, newest first.
+func (d *Depot) PreviousCAs() ([]*x509.Certificate, error) {
+	var cas []*x509.Certificate
+	err := d.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(PreviousCABucket)).ForEach(func(k, v []byte) error {
+			cert, err := x509.ParseCertificate(v)
+			if err != nil {
+				return errors.Wrapf(err, "parse CA certificate %s", k)
+			}
+			cas = append(cas, cert)
+			return nil
+		})
+	})
+	sort.Slice(cas, func(i, j int) bool { return cas[i].NotBefore.After(cas[j].NotBefore) })
+	return cas, errors.Wrap(err, "list previous scep CAs")
+}
+
+// revocationKey is the key of a revocation, "<issuer>.<serial>" with the
+// decimal serial number.
+func revocationKey(issuer string, serial *big.Int) []byte {
+	return []byte(issuer + "." + serial.String())
+}
+
+// Revoke records the revocation of the certificate with issuer and serial.
+// Revocation times are stored as RFC 3339 text, keyed by revocationKey.
+func (d *Depot) Revoke(issuer string, serial *big.Int, at time.Time) error {
+	err := d.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(RevocationBucket))
+		if b.Get(revocationKey(issuer, serial)) != nil {
+			return nil // keep the first revocation time
+		}
+		return b.Put(revocationKey(issuer, serial), []byte(at.UTC().Format(time.RFC3339Nano)))
+	})
+	return errors.Wrapf(err, "revoke certificate %s", serial)
+}
+
+func (d *Depot) IsRevoked(issuer string, serial *big.Int) (bool, error) {
+	var revoked bool
+	err := d.View(func(tx *bolt.Tx) error {
+		revoked = tx.Bucket([]byte(RevocationBucket)).Get(revocationKey(issuer, serial)) != nil
+		return nil
+	})
+	return revoked, err
+}
+
+func (d *Depot) Revocations() ([]scep.Revocation, error) {
+	var revocations []scep.Revocation
+	err := d.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(RevocationBucket)).ForEach(func(k, v []byte) error {
+			i := bytes.LastIndexByte(k, '.')
+			if i < 0 {
+				return fmt.Errorf("invalid revocation key %q", k)
+			}
+			serial, ok := new(big.Int).SetString(string(k[i+1:]), 10)
+			if !ok {
+				return fmt.Errorf("invalid serial %q", k)
+			}
+			at, err := time.Parse(time.RFC3339Nano, string(v))
+			if err != nil {
+				return errors.Wrapf(err, "parse revocation time of %s", k)
+			}
+			revocations = append(revocations, scep.Revocation{Issuer: string(k[:i]), SerialNumber: serial, RevokedAt: at})
+			return nil
+		})
+	})
+	return revocations, errors.Wrap(err, "list revocations")
+}
+
+type RenewalDB struct {
+	*bolt.DB
+}
+
+func NewRenewalDB(db *bolt.DB) (*RenewalDB, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(RenewalBucket))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s bucket", RenewalBucket)
+	}
+	return &RenewalDB{DB: db}, nil
+}
+
+func (db *RenewalDB) SaveRenewal(ctx context.Context, r *scep.Renewal) error {
+	pb, err := scep.MarshalRenewal(r)
+	if err != nil {
+		return errors.Wrap(err, "marshal renewal")
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(RenewalBucket)).Put([]byte(r.UDID), pb)
+	})
+	return errors.Wrap(err, "save renewal")
+}
+
+func (db *RenewalDB) Renewal(ctx context.Context, udid string) (*scep.Renewal, error) {
+	var r scep.Renewal
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(RenewalBucket)).Get([]byte(udid))
+		if v == nil {
+			return &notFound{"Renewal", fmt.Sprintf("udid %s", udid)}
+		}
+		return scep.UnmarshalRenewal(v, &r)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get renewal")
+	}
+	return &r, nil
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
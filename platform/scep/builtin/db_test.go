@@ -0,0 +1,197 @@
+package builtin
+
+import (
+	"context"
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/scep"
+)
+
+func TestEachCertificate(t *testing.T) {
+	db := setupDB(t)
+	depot, err := NewDepot(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := depot.CreateOrLoadKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := depot.CreateOrLoadCA(key, 1, "MicroMDM", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := depot.Put(ca.Subject.CommonName, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	// the CA, key and serial are not listed.
+	var certs []*x509.Certificate
+	err = depot.EachCertificate(func(c *x509.Certificate) error {
+		certs = append(certs, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(certs), 1; have != want {
+		t.Fatalf("have %d certificates, want %d", have, want)
+	}
+	if !certs[0].Equal(ca) {
+		t.Error("EachCertificate returned a different certificate")
+	}
+}
+
+func TestRenewal(t *testing.T) {
+	db, err := NewRenewalDB(setupDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := db.Renewal(ctx, "UDID-1"); !isNotFound(err) {
+		t.Errorf("have %v, want not found", err)
+	}
+
+	now := time.Now().UTC()
+	r := &scep.Renewal{
+		UDID:        "UDID-1",
+		CommandUUID: "command-1",
+		NotAfter:    now.AddDate(0, 0, 10),
+		RequestedAt: now,
+	}
+	if err := db.SaveRenewal(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := db.Renewal(ctx, "UDID-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := stored.CommandUUID, r.CommandUUID; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if !stored.NotAfter.Equal(r.NotAfter) || !stored.RequestedAt.Equal(r.RequestedAt) {
+		t.Errorf("have %v, want %v", stored, r)
+	}
+	if !stored.RenewedAt.IsZero() {
+		t.Errorf("have renewed at %v, want zero time", stored.RenewedAt)
+	}
+}
+
+func isNotFound(err error) bool {
+	nf, ok := errors.Cause(err).(interface{ NotFound() bool })
+	return ok && nf.NotFound()
+}
+
+func setupDB(t *testing.T) *bolt.DB {
+	f, _ := ioutil.TempFile("", "bolt-")
+	f.Close()
+	os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0777, nil)
+	if err != nil {
+		t.Fatalf("couldn't open bolt, err %s\n", err)
+	}
+	return db
+}
+
+func TestRotateCA(t *testing.T) {
+	depot, err := NewDepot(setupDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := depot.CreateOrLoadKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := depot.CreateOrLoadCA(key, 1, "MicroMDM", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crt, newKey, err := scep.NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := depot.RotateCA(crt, newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, caKey, err := depot.CA(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain[0].Equal(crt) || caKey.N.Cmp(newKey.N) != 0 {
+		t.Error("CA returned the replaced certificate or key")
+	}
+	previous, err := depot.PreviousCAs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(previous), 1; have != want {
+		t.Fatalf("have %d previous CAs, want %d", have, want)
+	}
+	if !previous[0].Equal(old) {
+		t.Error("previous CA is not the replaced certificate")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	depot, err := NewDepot(setupDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	if err := depot.Revoke("issuer", big.NewInt(5), now); err != nil {
+		t.Fatal(err)
+	}
+	// revoking again keeps the first revocation time.
+	if err := depot.Revoke("issuer", big.NewInt(5), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := depot.IsRevoked("issuer", big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("certificate 5 is not revoked")
+	}
+	revoked, err = depot.IsRevoked("issuer", big.NewInt(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("certificate 6 is revoked")
+	}
+	// serial numbers are only unique per issuer.
+	revoked, err = depot.IsRevoked("other", big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("certificate 5 of another issuer is revoked")
+	}
+
+	revocations, err := depot.Revocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(revocations), 1; have != want {
+		t.Fatalf("have %d revocations, want %d", have, want)
+	}
+	if !revocations[0].RevokedAt.Equal(now) {
+		t.Errorf("have %v, want %v", revocations[0].RevokedAt, now)
+	}
+	if have, want := revocations[0].Issuer, "issuer"; have != want {
+		t.Errorf("have issuer %s, want %s", have, want)
+	}
+}
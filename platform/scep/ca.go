@@ -0,0 +1,148 @@
+package scep
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/micromdm/scep/v2/depot"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// CA validity and subject of the SCEP CA created by the server.
+const (
+	DefaultCAYears = 5
+	CAOrganization = "MicroMDM"
+	CACountry      = "US"
+)
+
+// CADepot is a SCEP depot whose CA can be rotated. Certificates issued by
+// previous CAs are still trusted, but new certificates are only issued by the
+// current CA.
+type CADepot interface {
+	CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error)
+	PreviousCAs() ([]*x509.Certificate, error)
+	// RotateCA replaces the CA with crt and key, and adds the CA it
+	// replaces to the previous CAs.
+	RotateCA(crt *x509.Certificate, key *rsa.PrivateKey) error
+}
+
+// NewCA creates a self-signed SCEP CA, like the one the server creates on
+// first start.
+func NewCA(years int) (*x509.Certificate, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "generate CA key")
+	}
+	ca := depot.NewCACert(
+		depot.WithYears(years),
+		depot.WithOrganization(CAOrganization),
+		depot.WithOrganizationalUnit("MICROMDM SCEP CA"),
+		depot.WithCountry(CACountry),
+	)
+	der, err := ca.SelfSign(rand.Reader, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "sign CA certificate")
+	}
+	crt, err := x509.ParseCertificate(der)
+	return crt, key, err
+}
+
+// Fingerprint is the hex SHA-256 fingerprint of a certificate.
+func Fingerprint(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// CA is a SCEP CA trusted by the server.
+type CA struct {
+	Subject     string    `json:"subject"`
+	Fingerprint string    `json:"fingerprint"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	// Current is true for the CA which issues new certificates.
+	Current bool `json:"current"`
+	// Devices is the number of enrolled devices with an identity
+	// certificate issued by the CA.
+	Devices int `json:"devices"`
+}
+
+type Service interface {
+	GetCAs(ctx context.Context) ([]CA, error)
+	RotateCA(ctx context.Context, opt RotateCAOption) ([]CA, error)
+	ListCertificates(ctx context.Context, opt ListCertificatesOption) ([]Certificate, error)
+}
+
+// Depot is the SCEP depot of the server.
+type Depot interface {
+	CADepot
+	CertificateStore
+	RevocationStore
+}
+
+type CAService struct {
+	depot   Depot
+	devices DeviceStore
+	rotated func(*x509.Certificate, *rsa.PrivateKey) error
+
+	// rotations are serialized so that a CA is never lost.
+	mu sync.Mutex
+}
+
+type CAOption func(*CAService)
+
+// WithCARotated calls fn with the new CA after a rotation, to have the SCEP
+// server use it.
+func WithCARotated(fn func(*x509.Certificate, *rsa.PrivateKey) error) CAOption {
+	return func(svc *CAService) {
+		svc.rotated = fn
+	}
+}
+
+func NewCAService(depot Depot, devices DeviceStore, opts ...CAOption) *CAService {
+	svc := &CAService{depot: depot, devices: devices}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
+}
+
+// eachIdentity calls fn with every enrolled device and the SCEP certificate it
+// is authenticated with. Devices whose certificate is not in the depot are
+// skipped.
+func eachIdentity(ctx context.Context, devices DeviceStore, certs CertificateStore, fn func(device.Device, *x509.Certificate) error) error {
+	byHash := make(map[[sha256.Size]byte]*x509.Certificate)
+	err := certs.EachCertificate(func(c *x509.Certificate) error {
+		byHash[sha256.Sum256(c.Raw)] = c
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "list SCEP certificates")
+	}
+
+	return devices.EachDevice(ctx, func(dev device.Device) error {
+		if !dev.Enrolled || dev.UDID == "" {
+			return nil
+		}
+		hash, err := devices.GetUDIDCertHash([]byte(dev.UDID))
+		if isNotFound(err) {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "get certificate hash of %s", dev.UDID)
+		}
+		var key [sha256.Size]byte
+		copy(key[:], hash)
+		cert, ok := byHash[key]
+		if !ok {
+			return nil
+		}
+		return fn(dev, cert)
+	})
+}
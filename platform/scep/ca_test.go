@@ -0,0 +1,144 @@
+package scep
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+func TestRotateCA(t *testing.T) {
+	oldCA, oldKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldIdentity := issue(t, oldCA, oldKey, 2)
+	depot := &memoryDepot{crt: oldCA, key: oldKey, certList: certList{oldIdentity}}
+	devices := &deviceList{
+		devices: []device.Device{{UDID: "UDID-1", Enrolled: true}, {UDID: "UDID-2", Enrolled: true}},
+		hashes:  map[string][]byte{"UDID-1": hash(oldIdentity), "UDID-2": hash(oldIdentity)},
+	}
+	var rotated *x509.Certificate
+	svc := NewCAService(depot, devices, WithCARotated(func(crt *x509.Certificate, _ *rsa.PrivateKey) error {
+		rotated = crt
+		return nil
+	}))
+
+	ctx := context.Background()
+	cas, err := svc.RotateCA(ctx, RotateCAOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated == nil || !rotated.Equal(depot.crt) {
+		t.Error("SCEP server was not given the new CA")
+	}
+	if have, want := len(cas), 2; have != want {
+		t.Fatalf("have %d CAs, want %d", have, want)
+	}
+	if !cas[0].Current || cas[0].Fingerprint != Fingerprint(depot.crt) {
+		t.Errorf("have %+v, want the new CA first", cas[0])
+	}
+	if have, want := cas[1].Devices, 2; have != want {
+		t.Errorf("have %d devices on the previous CA, want %d", have, want)
+	}
+
+	// a device renews its identity with the new CA.
+	newIdentity := issue(t, depot.crt, depot.key, 3)
+	depot.certList = append(depot.certList, newIdentity)
+	devices.hashes["UDID-2"] = hash(newIdentity)
+	cas, err = svc.GetCAs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := cas[0].Devices, 1; have != want {
+		t.Errorf("have %d devices on the current CA, want %d", have, want)
+	}
+	if have, want := cas[1].Devices, 1; have != want {
+		t.Errorf("have %d devices on the previous CA, want %d", have, want)
+	}
+}
+
+func issue(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+type memoryDepot struct {
+	crt      *x509.Certificate
+	key      *rsa.PrivateKey
+	previous []*x509.Certificate
+	certList
+	revoked []Revocation
+}
+
+func (m *memoryDepot) CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error) {
+	return []*x509.Certificate{m.crt}, m.key, nil
+}
+
+func (m *memoryDepot) Put(name string, crt *x509.Certificate) error {
+	m.certList = append(m.certList, crt)
+	return nil
+}
+
+func (m *memoryDepot) Serial() (*big.Int, error) {
+	return big.NewInt(int64(len(m.certList) + 2)), nil
+}
+
+func (m *memoryDepot) HasCN(cn string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error) {
+	for _, c := range m.certList {
+		if c.Subject.CommonName == cn {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryDepot) PreviousCAs() ([]*x509.Certificate, error) {
+	return m.previous, nil
+}
+
+func (m *memoryDepot) RotateCA(crt *x509.Certificate, key *rsa.PrivateKey) error {
+	m.previous = append([]*x509.Certificate{m.crt}, m.previous...)
+	m.crt, m.key = crt, key
+	return nil
+}
+
+func (m *memoryDepot) Revoke(issuer string, serial *big.Int, at time.Time) error {
+	m.revoked = append(m.revoked, Revocation{Issuer: issuer, SerialNumber: serial, RevokedAt: at})
+	return nil
+}
+
+func (m *memoryDepot) IsRevoked(issuer string, serial *big.Int) (bool, error) {
+	for _, r := range m.revoked {
+		if r.Issuer == issuer && r.SerialNumber.Cmp(serial) == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *memoryDepot) Revocations() ([]Revocation, error) {
+	return m.revoked, nil
+}
@@ -0,0 +1,57 @@
+package scep
+
+import (
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+func NewHTTPClient(instance, token string, logger log.Logger, opts ...httptransport.ClientOption) (Service, error) {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	var getCAsEndpoint endpoint.Endpoint
+	{
+		getCAsEndpoint = httptransport.NewClient(
+			"GET",
+			httputil.CopyURL(u, "/v1/scep/cas"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeGetCAsResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var rotateCAEndpoint endpoint.Endpoint
+	{
+		rotateCAEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/scep/cas/rotate"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeGetCAsResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	var listCertificatesEndpoint endpoint.Endpoint
+	{
+		listCertificatesEndpoint = httptransport.NewClient(
+			"POST",
+			httputil.CopyURL(u, "/v1/scep/certificates"),
+			httputil.EncodeRequestWithToken(token, httptransport.EncodeJSONRequest),
+			decodeListCertificatesResponse,
+			opts...,
+		).Endpoint()
+	}
+
+	return Endpoints{
+		GetCAsEndpoint:           getCAsEndpoint,
+		RotateCAEndpoint:         rotateCAEndpoint,
+		ListCertificatesEndpoint: listCertificatesEndpoint,
+	}, nil
+}
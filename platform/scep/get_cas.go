@@ -0,0 +1,81 @@
+package scep
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// GetCAs returns the current SCEP CA followed by the previous CAs, with the
+// number of devices which still use an identity issued by each.
+func (svc *CAService) GetCAs(ctx context.Context) ([]CA, error) {
+	current, _, err := svc.depot.CA(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "get SCEP CA")
+	}
+	previous, err := svc.depot.PreviousCAs()
+	if err != nil {
+		return nil, errors.Wrap(err, "get previous SCEP CAs")
+	}
+	certs := append(current[:1:1], previous...)
+
+	cas := make([]CA, len(certs))
+	for i, c := range certs {
+		cas[i] = CA{
+			Subject:     c.Subject.String(),
+			Fingerprint: Fingerprint(c),
+			NotBefore:   c.NotBefore,
+			NotAfter:    c.NotAfter,
+			Current:     i == 0,
+		}
+	}
+	err = eachIdentity(ctx, svc.devices, svc.depot, func(_ device.Device, cert *x509.Certificate) error {
+		for i, c := range certs {
+			if cert.CheckSignatureFrom(c) == nil {
+				cas[i].Devices++
+				break
+			}
+		}
+		return nil
+	})
+	return cas, err
+}
+
+type getCAsRequest struct{}
+type getCAsResponse struct {
+	CAs []CA  `json:"cas"`
+	Err error `json:"err,omitempty"`
+}
+
+func (r getCAsResponse) Failed() error { return r.Err }
+
+func decodeGetCAsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	return getCAsRequest{}, nil
+}
+
+func decodeGetCAsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp getCAsResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeGetCAsEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		cas, err := svc.GetCAs(ctx)
+		return getCAsResponse{CAs: cas, Err: err}, nil
+	}
+}
+
+func (e Endpoints) GetCAs(ctx context.Context) ([]CA, error) {
+	response, err := e.GetCAsEndpoint(ctx, getCAsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return response.(getCAsResponse).CAs, response.(getCAsResponse).Err
+}
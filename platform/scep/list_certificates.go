@@ -0,0 +1,126 @@
+package scep
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// Status of an issued certificate.
+const (
+	CertificateValid   = "valid"
+	CertificateExpired = "expired"
+	CertificateRevoked = "revoked"
+)
+
+// Certificate is a certificate issued by the SCEP CA.
+type Certificate struct {
+	SerialNumber string    `json:"serial_number"`
+	CommonName   string    `json:"common_name"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	// UDID of the enrolled device authenticated with the certificate.
+	UDID      string    `json:"udid,omitempty"`
+	Status    string    `json:"status"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type ListCertificatesOption struct {
+	FilterUDID   string `json:"filter_udid"`
+	FilterStatus string `json:"filter_status"`
+}
+
+func (svc *CAService) ListCertificates(ctx context.Context, opt ListCertificatesOption) ([]Certificate, error) {
+	revocations, err := svc.depot.Revocations()
+	if err != nil {
+		return nil, errors.Wrap(err, "list revocations")
+	}
+	// serial numbers are only unique per issuer.
+	revokedAt := make(map[string]time.Time, len(revocations))
+	for _, r := range revocations {
+		revokedAt[r.Issuer+"/"+r.SerialNumber.String()] = r.RevokedAt
+	}
+	udids := make(map[string]string)
+	err = eachIdentity(ctx, svc.devices, svc.depot, func(dev device.Device, c *x509.Certificate) error {
+		udids[Fingerprint(c)] = dev.UDID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var certs []Certificate
+	err = svc.depot.EachCertificate(func(c *x509.Certificate) error {
+		serial := c.SerialNumber.String()
+		cert := Certificate{
+			SerialNumber: serial,
+			CommonName:   c.Subject.CommonName,
+			NotBefore:    c.NotBefore,
+			NotAfter:     c.NotAfter,
+			UDID:         udids[Fingerprint(c)],
+			Status:       CertificateValid,
+		}
+		if at, ok := revokedAt[IssuerID(c)+"/"+serial]; ok {
+			cert.Status, cert.RevokedAt = CertificateRevoked, at
+		} else if now.After(c.NotAfter) {
+			cert.Status = CertificateExpired
+		}
+		if opt.FilterUDID != "" && cert.UDID != opt.FilterUDID {
+			return nil
+		}
+		if opt.FilterStatus != "" && cert.Status != opt.FilterStatus {
+			return nil
+		}
+		certs = append(certs, cert)
+		return nil
+	})
+	return certs, errors.Wrap(err, "list SCEP certificates")
+}
+
+type listCertificatesRequest struct{ Opts ListCertificatesOption }
+type listCertificatesResponse struct {
+	Certificates []Certificate `json:"certificates"`
+	Err          error         `json:"err,omitempty"`
+}
+
+func (r listCertificatesResponse) Failed() error { return r.Err }
+
+func decodeListCertificatesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts ListCertificatesOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return listCertificatesRequest{Opts: opts}, err
+}
+
+func decodeListCertificatesResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	var resp listCertificatesResponse
+	err := httputil.DecodeJSONResponse(r, &resp)
+	return resp, err
+}
+
+func MakeListCertificatesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(listCertificatesRequest)
+		certs, err := svc.ListCertificates(ctx, req.Opts)
+		return listCertificatesResponse{
+			Certificates: certs,
+			Err:          err,
+		}, nil
+	}
+}
+
+func (e Endpoints) ListCertificates(ctx context.Context, opt ListCertificatesOption) ([]Certificate, error) {
+	request := listCertificatesRequest{opt}
+	response, err := e.ListCertificatesEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(listCertificatesResponse).Certificates, response.(listCertificatesResponse).Err
+}
@@ -0,0 +1,157 @@
+package scep
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	scepclient "github.com/micromdm/scep/v2/client"
+	scepmsg "github.com/micromdm/scep/v2/scep"
+	"github.com/pkg/errors"
+)
+
+// ProxyDepot holds the keypair which signs the requests to the upstream SCEP
+// server and stores the certificates it issues.
+type ProxyDepot interface {
+	CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error)
+	Put(name string, crt *x509.Certificate) error
+}
+
+// proxyTimeout limits each request to the upstream SCEP server.
+const proxyTimeout = 30 * time.Second
+
+// ProxySigner issues device identity certificates from an external CA. It
+// forwards the certificate requests of devices to an upstream SCEP server,
+// acting as a registration authority with the SCEP CA of the server.
+//
+// The request of the device is forwarded unchanged, including its challenge.
+type ProxySigner struct {
+	client  scepclient.Client
+	depot   ProxyDepot
+	caCerts []*x509.Certificate
+	logger  log.Logger
+}
+
+// NewProxySigner returns a signer which forwards requests to the SCEP server
+// at upstreamURL, like https://ca.acme.co/scep. The CA certificates of the
+// upstream server are fetched once.
+func NewProxySigner(ctx context.Context, upstreamURL string, depot ProxyDepot, logger log.Logger) (*ProxySigner, error) {
+	client, err := scepclient.New(upstreamURL, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "create upstream SCEP client")
+	}
+	ctx, cancel := context.WithTimeout(ctx, proxyTimeout)
+	defer cancel()
+	resp, num, err := client.GetCACert(ctx, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "get upstream SCEP CA certificates")
+	}
+	var certs []*x509.Certificate
+	if num > 1 {
+		certs, err = scepmsg.CACerts(resp)
+	} else {
+		certs, err = x509.ParseCertificates(resp)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "parse upstream SCEP CA certificates")
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("upstream SCEP server returned no CA certificates")
+	}
+	return &ProxySigner{client: client, depot: depot, caCerts: certs, logger: logger}, nil
+}
+
+// CACerts returns the CA certificates of the upstream SCEP server.
+func (p *ProxySigner) CACerts() []*x509.Certificate {
+	return p.caCerts
+}
+
+// SignCSR requests a certificate for the CSR from the upstream SCEP server and
+// stores it in the depot.
+func (p *ProxySigner) SignCSR(m *scepmsg.CSRReqMessage) (*x509.Certificate, error) {
+	ra, key, err := p.depot.CA(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "load SCEP CA")
+	}
+	tmpl := &scepmsg.PKIMessage{
+		MessageType: scepmsg.PKCSReq,
+		Recipients:  p.caCerts,
+		SignerKey:   key,
+		SignerCert:  ra[0],
+	}
+	msg, err := scepmsg.NewCSRRequest(m.CSR, tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "create upstream SCEP request")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), proxyTimeout)
+	defer cancel()
+	resp, err := p.client.PKIOperation(ctx, msg.Raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "upstream SCEP PKIOperation")
+	}
+	respMsg, err := scepmsg.ParsePKIMessage(resp, scepmsg.WithCACerts(msg.Recipients))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse upstream SCEP response")
+	}
+	switch respMsg.PKIStatus {
+	case scepmsg.FAILURE:
+		return nil, errors.Errorf("upstream SCEP request failed: %s", respMsg.FailInfo)
+	case scepmsg.PENDING:
+		return nil, errors.New("upstream SCEP request is pending manual approval, which is not supported")
+	}
+	if err := respMsg.DecryptPKIEnvelope(ra[0], key); err != nil {
+		return nil, errors.Wrap(err, "decrypt upstream SCEP response")
+	}
+
+	crt := respMsg.CertRepMessage.Certificate
+	if crt == nil {
+		return nil, errors.New("upstream SCEP response has no certificate")
+	}
+	if pub, ok := crt.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(m.CSR.PublicKey) {
+		return nil, errors.New("upstream SCEP certificate does not match the request")
+	}
+	if err := p.depot.Put(crt.Subject.CommonName, crt); err != nil {
+		return nil, errors.Wrap(err, "store upstream SCEP certificate")
+	}
+	level.Info(p.logger).Log(
+		"msg", "issued device certificate from upstream CA",
+		"cn", crt.Subject.CommonName,
+		"serial", crt.SerialNumber,
+		"issuer", crt.Issuer.String(),
+	)
+	return crt, nil
+}
+
+// ParseCABundle parses the PEM encoded certificates of an external CA, which
+// are trusted for device identities. Every certificate must be a CA.
+func ParseCABundle(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse CA bundle certificate")
+		}
+		if !crt.IsCA {
+			return nil, errors.Errorf("CA bundle certificate %q is not a CA", crt.Subject.String())
+		}
+		certs = append(certs, crt)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates in CA bundle")
+	}
+	return certs, nil
+}
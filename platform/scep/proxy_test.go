@@ -0,0 +1,124 @@
+package scep
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/micromdm/scep/v2/cryptoutil/x509util"
+	"github.com/micromdm/scep/v2/depot"
+	scepmsg "github.com/micromdm/scep/v2/scep"
+	scepserver "github.com/micromdm/scep/v2/server"
+)
+
+func TestProxySigner(t *testing.T) {
+	upstreamCA, upstreamKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := upstreamSCEPServer(t, upstreamCA, upstreamKey, "secret")
+	defer upstream.Close()
+
+	ra, raKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryDepot{crt: ra, key: raKey}
+	signer, err := NewProxySigner(context.Background(), upstream.URL+"/scep", store, log.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(signer.CACerts()), 1; have != want {
+		t.Fatalf("have %d upstream CA certificates, want %d", have, want)
+	}
+
+	crt, err := signer.SignCSR(&scepmsg.CSRReqMessage{CSR: newCSR(t, "device", "secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(upstreamCA)
+	if _, err := crt.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("certificate not issued by the upstream CA: %s", err)
+	}
+	if have, want := len(store.certList), 1; have != want {
+		t.Errorf("have %d stored certificates, want %d", have, want)
+	}
+
+	// the upstream server checks the challenge of the device.
+	if _, err := signer.SignCSR(&scepmsg.CSRReqMessage{CSR: newCSR(t, "other", "wrong")}); err == nil {
+		t.Error("expected an error for a request with the wrong challenge")
+	}
+	if have, want := len(store.certList), 1; have != want {
+		t.Errorf("have %d stored certificates, want %d", have, want)
+	}
+}
+
+func TestParseCABundle(t *testing.T) {
+	ca, caKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := issue(t, ca, caKey, 2)
+
+	certs, err := ParseCABundle(pemCerts(ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(certs), 1; have != want {
+		t.Errorf("have %d certificates, want %d", have, want)
+	}
+	if _, err := ParseCABundle(pemCerts(ca, identity)); err == nil {
+		t.Error("expected an error for a bundle with a certificate which is not a CA")
+	}
+	if _, err := ParseCABundle(nil); err == nil {
+		t.Error("expected an error for an empty bundle")
+	}
+}
+
+func pemCerts(certs ...*x509.Certificate) []byte {
+	var data []byte
+	for _, crt := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	return data
+}
+
+func upstreamSCEPServer(t *testing.T, ca *x509.Certificate, key *rsa.PrivateKey, challenge string) *httptest.Server {
+	t.Helper()
+	store := &memoryDepot{crt: ca, key: key}
+	signer := scepserver.ChallengeMiddleware(challenge, depot.NewSigner(store))
+	svc, err := scepserver.NewService(ca, key, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.NewNopLogger()
+	return httptest.NewServer(scepserver.MakeHTTPHandler(scepserver.MakeServerEndpoints(svc), svc, logger))
+}
+
+func newCSR(t *testing.T, cn, challenge string) *x509.CertificateRequest {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509util.CertificateRequest{
+		CertificateRequest: x509.CertificateRequest{Subject: pkix.Name{CommonName: cn}},
+		ChallengePassword:  challenge,
+	}
+	der, err := x509util.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return csr
+}
@@ -0,0 +1,346 @@
+// Package scep manages the SCEP CA of the server and the device identity
+// certificates it issues.
+package scep
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	scepmsg "github.com/micromdm/scep/v2/scep"
+	scepserver "github.com/micromdm/scep/v2/server"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/micromdm/micromdm/mdm/mdm"
+	"github.com/micromdm/micromdm/platform/challenge"
+	"github.com/micromdm/micromdm/platform/command"
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/profile"
+	"github.com/micromdm/micromdm/platform/scep/internal/scepproto"
+)
+
+// CertificateStore lists the certificates issued by the SCEP CA.
+type CertificateStore interface {
+	EachCertificate(fn func(*x509.Certificate) error) error
+}
+
+// Renewal is a renewal of the identity certificate of a device.
+type Renewal struct {
+	UDID        string    `json:"udid"`
+	CommandUUID string    `json:"command_uuid"`
+	NotAfter    time.Time `json:"not_after"` // of the renewed certificate
+	RequestedAt time.Time `json:"requested_at"`
+	// RenewedAt is when the device first used the new certificate.
+	RenewedAt time.Time `json:"renewed_at"`
+	// Challenge is the SCEP challenge of the renewal profile. It is cleared
+	// when the new certificate is issued, whose SHA-256 hash is kept in
+	// CertificateHash.
+	Challenge       string `json:"-"`
+	CertificateHash []byte `json:"-"`
+}
+
+// RenewalStore stores the latest Renewal of each device.
+type RenewalStore interface {
+	SaveRenewal(ctx context.Context, r *Renewal) error
+	Renewal(ctx context.Context, udid string) (*Renewal, error)
+}
+
+// DeviceStore is used to find the identity certificates of enrolled devices.
+type DeviceStore interface {
+	EachDevice(ctx context.Context, fn func(device.Device) error) error
+	GetUDIDCertHash(udid []byte) ([]byte, error)
+}
+
+// EnrollmentProfiles makes the enrollment profiles which are installed again
+// to renew the identity certificate. The profile must use the SCEP challenge
+// of the context, see challenge.NewContext.
+type EnrollmentProfiles interface {
+	EnrollWithParams(ctx context.Context, params url.Values) (profile.Mobileconfig, error)
+}
+
+// renewalRetry is how long the worker waits for a device to use its new
+// certificate before it requests the renewal again.
+const renewalRetry = 7 * 24 * time.Hour
+
+// RenewalWorker renews the identity certificates of devices which expire
+// within the renewal days. It installs the enrollment profile again, which
+// makes the device request a new certificate with SCEP. Every renewal profile
+// has its own SCEP challenge, which RenewalMiddleware redeems.
+type RenewalWorker struct {
+	devices  DeviceStore
+	certs    CertificateStore
+	renewals RenewalStore
+	profiles EnrollmentProfiles
+	cmdsvc   command.Service
+	days     int
+	interval time.Duration
+	logger   log.Logger
+}
+
+type RenewalOption func(*RenewalWorker)
+
+// WithRenewalInterval sets how often the worker looks for expiring
+// certificates. The default is every hour.
+func WithRenewalInterval(d time.Duration) RenewalOption {
+	return func(w *RenewalWorker) {
+		w.interval = d
+	}
+}
+
+// NewRenewalWorker returns a worker which renews certificates which expire
+// within days.
+func NewRenewalWorker(
+	devices DeviceStore,
+	certs CertificateStore,
+	renewals RenewalStore,
+	profiles EnrollmentProfiles,
+	cmdsvc command.Service,
+	days int,
+	logger log.Logger,
+	opts ...RenewalOption,
+) *RenewalWorker {
+	w := &RenewalWorker{
+		devices:  devices,
+		certs:    certs,
+		renewals: renewals,
+		profiles: profiles,
+		cmdsvc:   cmdsvc,
+		days:     days,
+		interval: time.Hour,
+		logger:   logger,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *RenewalWorker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.RenewExpiring(ctx, time.Now()); err != nil {
+			level.Info(w.logger).Log("msg", "renew device certificates", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RenewExpiring requests the renewal of the certificates of enrolled devices
+// which expire within the renewal days of now.
+func (w *RenewalWorker) RenewExpiring(ctx context.Context, now time.Time) error {
+	deadline := now.AddDate(0, 0, w.days)
+	return eachIdentity(ctx, w.devices, w.certs, func(dev device.Device, cert *x509.Certificate) error {
+		if cert.NotAfter.After(deadline) {
+			return nil
+		}
+
+		prev, err := w.renewals.Renewal(ctx, dev.UDID)
+		if err != nil && !isNotFound(err) {
+			return errors.Wrapf(err, "get renewal of %s", dev.UDID)
+		}
+		if prev != nil && prev.RenewedAt.IsZero() && prev.NotAfter.Equal(cert.NotAfter) && now.Sub(prev.RequestedAt) < renewalRetry {
+			// still waiting for the device to use the new certificate.
+			return nil
+		}
+		if err := w.renew(ctx, dev, cert, now); err != nil {
+			level.Info(w.logger).Log("msg", "renew device certificate", "udid", dev.UDID, "err", err)
+		}
+		return nil
+	})
+}
+
+func (w *RenewalWorker) renew(ctx context.Context, dev device.Device, cert *x509.Certificate, now time.Time) error {
+	chal, err := newRenewalChallenge(dev.UDID)
+	if err != nil {
+		return err
+	}
+	// keep the device in its enrollment group.
+	var params url.Values
+	if dev.Group != "" {
+		params = url.Values{device.GroupParam: {dev.Group}}
+	}
+	mc, err := w.profiles.EnrollWithParams(challenge.NewContext(ctx, chal), params)
+	if err != nil {
+		return errors.Wrap(err, "make enrollment profile")
+	}
+	payload, err := w.cmdsvc.NewCommand(ctx, &mdm.CommandRequest{
+		UDID: dev.UDID,
+		Command: &mdm.Command{
+			RequestType:    "InstallProfile",
+			InstallProfile: &mdm.InstallProfile{Payload: mc},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "queue InstallProfile")
+	}
+	level.Info(w.logger).Log(
+		"msg", "requested device certificate renewal",
+		"udid", dev.UDID,
+		"not_after", cert.NotAfter,
+		"command_uuid", payload.CommandUUID,
+	)
+	return w.renewals.SaveRenewal(ctx, &Renewal{
+		UDID:        dev.UDID,
+		CommandUUID: payload.CommandUUID,
+		NotAfter:    cert.NotAfter,
+		RequestedAt: now,
+		Challenge:   chal,
+	})
+}
+
+// renewalChallengePrefix starts the SCEP challenges of renewal profiles,
+// which are "renewal:<udid>:<random>".
+const renewalChallengePrefix = "renewal:"
+
+func newRenewalChallenge(udid string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generate renewal challenge")
+	}
+	return renewalChallengePrefix + udid + ":" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// renewalChallengeUDID returns the UDID of a renewal challenge.
+func renewalChallengeUDID(chal string) (string, bool) {
+	if !strings.HasPrefix(chal, renewalChallengePrefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(chal, renewalChallengePrefix)
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// RenewalMiddleware signs the CSRs with the challenge of a pending renewal
+// with signer, and records the issued certificate on the renewal. A renewal
+// challenge is used once, and only until the renewal is requested again.
+// Other CSRs are passed to next, which checks their challenge.
+func RenewalMiddleware(renewals RenewalStore, signer, next scepserver.CSRSigner) scepserver.CSRSignerFunc {
+	var mu sync.Mutex
+	return func(m *scepmsg.CSRReqMessage) (*x509.Certificate, error) {
+		udid, ok := renewalChallengeUDID(m.ChallengePassword)
+		if !ok {
+			return next.SignCSR(m)
+		}
+		// a challenge is redeemed once.
+		mu.Lock()
+		defer mu.Unlock()
+		ctx := context.Background()
+		r, err := renewals.Renewal(ctx, udid)
+		if isNotFound(err) {
+			return next.SignCSR(m)
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "get renewal of %s", udid)
+		}
+		if r.Challenge == "" || subtle.ConstantTimeCompare([]byte(r.Challenge), []byte(m.ChallengePassword)) != 1 ||
+			time.Since(r.RequestedAt) > renewalRetry {
+			return next.SignCSR(m)
+		}
+		cert, err := signer.SignCSR(m)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(cert.Raw)
+		r.Challenge, r.CertificateHash = "", sum[:]
+		if err := renewals.SaveRenewal(ctx, r); err != nil {
+			return nil, errors.Wrapf(err, "save renewal of %s", udid)
+		}
+		return cert, nil
+	}
+}
+
+// RenewalTracker records when devices first use their renewed certificates.
+type RenewalTracker struct {
+	renewals RenewalStore
+	logger   log.Logger
+}
+
+func NewRenewalTracker(renewals RenewalStore, logger log.Logger) *RenewalTracker {
+	return &RenewalTracker{renewals: renewals, logger: logger}
+}
+
+// CertificateRenewed reports whether cert is the renewed certificate of the
+// device, and records when the device first used it. It is called with
+// certificates which don't match the one the device enrolled with. Only the
+// certificate issued for the challenge of the renewal profile is accepted.
+func (t *RenewalTracker) CertificateRenewed(ctx context.Context, udid string, cert *x509.Certificate) (bool, error) {
+	r, err := t.renewals.Renewal(ctx, udid)
+	if isNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "get renewal of %s", udid)
+	}
+	sum := sha256.Sum256(cert.Raw)
+	if !r.RenewedAt.IsZero() || subtle.ConstantTimeCompare(r.CertificateHash, sum[:]) != 1 {
+		return false, nil
+	}
+	r.RenewedAt = time.Now().UTC()
+	if err := t.renewals.SaveRenewal(ctx, r); err != nil {
+		return false, err
+	}
+	level.Info(t.logger).Log("msg", "device certificate renewed", "udid", udid, "not_after", cert.NotAfter)
+	return true, nil
+}
+
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(interface{ NotFound() bool })
+	return ok && e.NotFound()
+}
+
+func MarshalRenewal(r *Renewal) ([]byte, error) {
+	return proto.Marshal(&scepproto.Renewal{
+		Udid:            r.UDID,
+		CommandUuid:     r.CommandUUID,
+		NotAfter:        timeToNano(r.NotAfter),
+		RequestedAt:     timeToNano(r.RequestedAt),
+		RenewedAt:       timeToNano(r.RenewedAt),
+		Challenge:       r.Challenge,
+		CertificateHash: r.CertificateHash,
+	})
+}
+
+func UnmarshalRenewal(data []byte, r *Renewal) error {
+	var pb scepproto.Renewal
+	if err := proto.Unmarshal(data, &pb); err != nil {
+		return errors.Wrap(err, "unmarshal proto to renewal")
+	}
+	r.UDID = pb.GetUdid()
+	r.CommandUUID = pb.GetCommandUuid()
+	r.NotAfter = timeFromNano(pb.GetNotAfter())
+	r.RequestedAt = timeFromNano(pb.GetRequestedAt())
+	r.RenewedAt = timeFromNano(pb.GetRenewedAt())
+	r.Challenge = pb.GetChallenge()
+	r.CertificateHash = pb.GetCertificateHash()
+	return nil
+}
+
+func timeToNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func timeFromNano(nano int64) time.Time {
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano).UTC()
+}
@@ -0,0 +1,270 @@
+package scep
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	scepmsg "github.com/micromdm/scep/v2/scep"
+	scepserver "github.com/micromdm/scep/v2/server"
+
+	"github.com/micromdm/micromdm/mdm/mdm"
+	"github.com/micromdm/micromdm/platform/challenge"
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/profile"
+)
+
+func TestRenewExpiring(t *testing.T) {
+	now := time.Now().UTC()
+	expiring := &x509.Certificate{Raw: []byte("expiring"), NotAfter: now.AddDate(0, 0, 10)}
+	valid := &x509.Certificate{Raw: []byte("valid"), NotAfter: now.AddDate(1, 0, 0)}
+
+	devices := &deviceList{
+		devices: []device.Device{
+			{UDID: "UDID-1", Enrolled: true, Group: "lab"},
+			{UDID: "UDID-2", Enrolled: true},
+			{UDID: "UDID-3", Enrolled: false},
+		},
+		hashes: map[string][]byte{
+			"UDID-1": hash(expiring),
+			"UDID-2": hash(valid),
+			"UDID-3": hash(expiring),
+		},
+	}
+	renewals := renewalMap{}
+	cmds := &queuedCommands{}
+	w := NewRenewalWorker(
+		devices,
+		certList{expiring, valid},
+		renewals,
+		enrollProfiles{},
+		cmds,
+		30,
+		log.NewNopLogger(),
+	)
+
+	ctx := context.Background()
+	if err := w.RenewExpiring(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(cmds.requests), 1; have != want {
+		t.Fatalf("have %d commands, want %d", have, want)
+	}
+	req := cmds.requests[0]
+	if have, want := req.UDID, "UDID-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	r := renewals["UDID-1"]
+	if r == nil {
+		t.Fatal("renewal was not saved")
+	}
+	if have, want := r.CommandUUID, "command-1"; have != want {
+		t.Errorf("have %s, want %s", have, want)
+	}
+	if have, want := string(req.InstallProfile.Payload), "group=lab "+r.Challenge; have != want {
+		t.Errorf("have profile %s, want %s", have, want)
+	}
+
+	// the pending renewal is not requested again.
+	if err := w.RenewExpiring(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(cmds.requests), 1; have != want {
+		t.Errorf("have %d commands, want %d", have, want)
+	}
+
+	// unless the device never used the new certificate.
+	if err := w.RenewExpiring(ctx, now.Add(renewalRetry)); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(cmds.requests), 2; have != want {
+		t.Errorf("have %d commands, want %d", have, want)
+	}
+
+	tracker := NewRenewalTracker(renewals, log.NewNopLogger())
+	old := &x509.Certificate{Raw: []byte("old"), NotBefore: now.AddDate(-1, 0, 0)}
+	if renewed, err := tracker.CertificateRenewed(ctx, "UDID-1", old); err != nil || renewed {
+		t.Errorf("have %v, %v, want certificate issued before the renewal to be rejected", renewed, err)
+	}
+	renewedCert := &x509.Certificate{Raw: []byte("renewed"), NotBefore: time.Now()}
+	if renewed, err := tracker.CertificateRenewed(ctx, "UDID-1", renewedCert); err != nil || renewed {
+		t.Errorf("have %v, %v, want a certificate which was not issued for the renewal to be rejected", renewed, err)
+	}
+
+	signer := RenewalMiddleware(renewals, issuedCert(renewedCert), issuedCert(nil))
+	if _, err := signer.SignCSR(&scepmsg.CSRReqMessage{ChallengePassword: renewals["UDID-1"].Challenge}); err != nil {
+		t.Fatal(err)
+	}
+	if renewed, err := tracker.CertificateRenewed(ctx, "UDID-1", renewedCert); err != nil || !renewed {
+		t.Errorf("have %v, %v, want renewed certificate", renewed, err)
+	}
+	if renewals["UDID-1"].RenewedAt.IsZero() {
+		t.Error("renewal time was not saved")
+	}
+	if renewed, err := tracker.CertificateRenewed(ctx, "UDID-1", renewedCert); err != nil || renewed {
+		t.Errorf("have %v, %v, want a renewal to be used once", renewed, err)
+	}
+	if renewed, err := tracker.CertificateRenewed(ctx, "UDID-2", renewedCert); err != nil || renewed {
+		t.Errorf("have %v, %v, want no renewal", renewed, err)
+	}
+}
+
+func TestRenewalMiddleware(t *testing.T) {
+	now := time.Now().UTC()
+	renewals := renewalMap{}
+	for _, udid := range []string{"UDID-1", "UDID-2"} {
+		chal, err := newRenewalChallenge(udid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		renewals[udid] = &Renewal{UDID: udid, RequestedAt: now, Challenge: chal}
+	}
+	challenge1 := renewals["UDID-1"].Challenge
+	challenge2 := renewals["UDID-2"].Challenge
+
+	renewed := &x509.Certificate{Raw: []byte("renewed")}
+	fresh := &x509.Certificate{Raw: []byte("fresh")}
+	enrolled := &x509.Certificate{Raw: []byte("enrolled")}
+	signer := RenewalMiddleware(renewals, issuedCert(renewed), issuedCert(enrolled))
+	sign := func(chal string) *x509.Certificate {
+		t.Helper()
+		cert, err := signer.SignCSR(&scepmsg.CSRReqMessage{ChallengePassword: chal})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	// CSRs with other challenges are signed by next.
+	if cert := sign("static"); cert != enrolled {
+		t.Error("CSR without a renewal challenge was not passed on")
+	}
+	// the challenge of one device doesn't redeem the renewal of another.
+	forged := renewalChallengePrefix + "UDID-2" + challenge1[strings.LastIndex(challenge1, ":"):]
+	if cert := sign(forged); cert != enrolled {
+		t.Error("forged renewal challenge was accepted")
+	}
+	if cert := sign(challenge1); cert != renewed {
+		t.Error("renewal challenge was not redeemed")
+	}
+	if have, want := renewals["UDID-1"].CertificateHash, hash(renewed); string(have) != string(want) {
+		t.Errorf("have certificate hash %x, want %x", have, want)
+	}
+	// a renewal challenge is used once.
+	if cert := sign(challenge1); cert != enrolled {
+		t.Error("renewal challenge was redeemed twice")
+	}
+
+	// a fresh certificate of the second device is not a renewal of the first.
+	tracker := NewRenewalTracker(renewals, log.NewNopLogger())
+	signer = RenewalMiddleware(renewals, issuedCert(fresh), issuedCert(enrolled))
+	if cert := sign(challenge2); cert != fresh {
+		t.Error("renewal challenge of the second device was not redeemed")
+	}
+	if ok, err := tracker.CertificateRenewed(context.Background(), "UDID-1", fresh); err != nil || ok {
+		t.Errorf("have %v, %v, want the certificate of another device to be rejected", ok, err)
+	}
+	if ok, err := tracker.CertificateRenewed(context.Background(), "UDID-2", fresh); err != nil || !ok {
+		t.Errorf("have %v, %v, want renewed certificate", ok, err)
+	}
+
+	// challenges of outdated renewals are not redeemed.
+	chal, err := newRenewalChallenge("UDID-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewals["UDID-3"] = &Renewal{UDID: "UDID-3", RequestedAt: now.Add(-renewalRetry - time.Hour), Challenge: chal}
+	if cert := sign(chal); cert != enrolled {
+		t.Error("challenge of an outdated renewal was redeemed")
+	}
+}
+
+// issuedCert is a CSRSigner which returns the certificate.
+func issuedCert(cert *x509.Certificate) scepserver.CSRSignerFunc {
+	return func(*scepmsg.CSRReqMessage) (*x509.Certificate, error) {
+		return cert, nil
+	}
+}
+
+func hash(c *x509.Certificate) []byte {
+	sum := sha256.Sum256(c.Raw)
+	return sum[:]
+}
+
+type deviceList struct {
+	devices []device.Device
+	hashes  map[string][]byte
+}
+
+func (d *deviceList) EachDevice(ctx context.Context, fn func(device.Device) error) error {
+	for _, dev := range d.devices {
+		if err := fn(dev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *deviceList) GetUDIDCertHash(udid []byte) ([]byte, error) {
+	h, ok := d.hashes[string(udid)]
+	if !ok {
+		return nil, notFound{}
+	}
+	return h, nil
+}
+
+type certList []*x509.Certificate
+
+func (l certList) EachCertificate(fn func(*x509.Certificate) error) error {
+	for _, c := range l {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type renewalMap map[string]*Renewal
+
+func (m renewalMap) SaveRenewal(ctx context.Context, r *Renewal) error {
+	saved := *r
+	m[r.UDID] = &saved
+	return nil
+}
+
+func (m renewalMap) Renewal(ctx context.Context, udid string) (*Renewal, error) {
+	r, ok := m[udid]
+	if !ok {
+		return nil, notFound{}
+	}
+	copied := *r
+	return &copied, nil
+}
+
+// enrollProfiles returns the params and the challenge as the profile.
+type enrollProfiles struct{}
+
+func (enrollProfiles) EnrollWithParams(ctx context.Context, params url.Values) (profile.Mobileconfig, error) {
+	chal, _ := challenge.FromContext(ctx)
+	return profile.Mobileconfig(params.Encode() + " " + chal), nil
+}
+
+type queuedCommands struct {
+	requests []*mdm.CommandRequest
+}
+
+func (q *queuedCommands) NewCommand(ctx context.Context, req *mdm.CommandRequest) (*mdm.CommandPayload, error) {
+	q.requests = append(q.requests, req)
+	return &mdm.CommandPayload{CommandUUID: fmt.Sprintf("command-%d", len(q.requests))}, nil
+}
+
+type notFound struct{}
+
+func (notFound) Error() string  { return "not found" }
+func (notFound) NotFound() bool { return true }
@@ -0,0 +1,136 @@
+package scep
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// Revocation is a revoked certificate.
+type Revocation struct {
+	// Issuer is the IssuerID of the certificate. Serial numbers are only
+	// unique per issuer.
+	Issuer       string
+	SerialNumber *big.Int
+	RevokedAt    time.Time
+}
+
+// RevocationStore stores the issuers and serial numbers of revoked
+// certificates.
+type RevocationStore interface {
+	Revoke(issuer string, serial *big.Int, at time.Time) error
+	IsRevoked(issuer string, serial *big.Int) (bool, error)
+	Revocations() ([]Revocation, error)
+}
+
+// IssuerID identifies the CA which issued a certificate. It hashes the issuer
+// name with the authority key identifier, since CAs replaced by RotateCA have
+// the same name as the current CA.
+func IssuerID(c *x509.Certificate) string {
+	return issuerID(c.RawIssuer, c.AuthorityKeyId)
+}
+
+// caIssuerID is the IssuerID of the certificates issued by ca.
+func caIssuerID(ca *x509.Certificate) string {
+	return issuerID(ca.RawSubject, ca.SubjectKeyId)
+}
+
+func issuerID(name, keyID []byte) string {
+	h := sha256.New()
+	h.Write(name)
+	h.Write(keyID)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RevokeDevice revokes the identity certificate a device is authenticated
+// with. Devices without a known certificate are ignored.
+func (svc *CAService) RevokeDevice(ctx context.Context, udid string) error {
+	hash, err := svc.devices.GetUDIDCertHash([]byte(udid))
+	if isNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "get certificate hash of %s", udid)
+	}
+	var key [sha256.Size]byte
+	copy(key[:], hash)
+
+	var cert *x509.Certificate
+	err = svc.depot.EachCertificate(func(c *x509.Certificate) error {
+		if sha256.Sum256(c.Raw) == key {
+			cert = c
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "list SCEP certificates")
+	}
+	if cert == nil {
+		return nil
+	}
+	return errors.Wrapf(svc.depot.Revoke(IssuerID(cert), cert.SerialNumber, time.Now().UTC()), "revoke certificate of %s", udid)
+}
+
+// crlValidity is how long a CRL is valid for. CRLs are created on request, so
+// clients get the latest revocations when they refresh it.
+const crlValidity = 24 * time.Hour
+
+// CRL returns a DER encoded CRL with the revoked certificates of the current
+// CA, signed by it. Certificates of previous and upstream CAs are left out,
+// their serial numbers may be reused by the current CA.
+func (svc *CAService) CRL(now time.Time) ([]byte, error) {
+	chain, key, err := svc.depot.CA(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "get SCEP CA")
+	}
+	revocations, err := svc.depot.Revocations()
+	if err != nil {
+		return nil, errors.Wrap(err, "list revocations")
+	}
+	issuer := caIssuerID(chain[0])
+	var revoked []pkix.RevokedCertificate
+	for _, r := range revocations {
+		if r.Issuer != issuer {
+			continue
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   r.SerialNumber,
+			RevocationTime: r.RevokedAt,
+		})
+	}
+	tmpl := &x509.RevocationList{
+		RevokedCertificates: revoked,
+		// the CRL number only has to increase.
+		Number:     big.NewInt(now.Unix()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(crlValidity),
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, tmpl, chain[0], key)
+	return crl, errors.Wrap(err, "create CRL")
+}
+
+// CRLPath is where the CRL of the SCEP CA is served.
+const CRLPath = "/scep/crl"
+
+// MakeCRLHandler returns the handler of CRLPath.
+func MakeCRLHandler(svc *CAService, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		crl, err := svc.CRL(time.Now().UTC())
+		if err != nil {
+			level.Info(logger).Log("msg", "create CRL", "err", err)
+			http.Error(w, "failed to create CRL", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crl)
+	})
+}
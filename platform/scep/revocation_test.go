@@ -0,0 +1,103 @@
+package scep
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+func TestRevokeDevice(t *testing.T) {
+	ca, caKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a previous CA has the same name as the current one.
+	previous, previousKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, upstreamKey, err := NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := issue(t, ca, caKey, 2)
+	other := issue(t, ca, caKey, 3)
+	// the certificates of other issuers reuse the serial numbers of the
+	// current CA.
+	previousIdentity := issue(t, previous, previousKey, 2)
+	upstreamIdentity := issue(t, upstream, upstreamKey, 3)
+	depot := &memoryDepot{
+		crt:      ca,
+		key:      caKey,
+		previous: []*x509.Certificate{previous},
+		certList: certList{identity, other, previousIdentity, upstreamIdentity},
+	}
+	devices := &deviceList{
+		devices: []device.Device{
+			{UDID: "UDID-1", Enrolled: true},
+			{UDID: "UDID-2", Enrolled: true},
+			{UDID: "UDID-3", Enrolled: true},
+		},
+		hashes: map[string][]byte{
+			"UDID-1": hash(identity),
+			"UDID-2": hash(previousIdentity),
+			"UDID-3": hash(upstreamIdentity),
+		},
+	}
+	svc := NewCAService(depot, devices)
+	ctx := context.Background()
+
+	for _, udid := range []string{"UDID-1", "UDID-2", "UDID-3"} {
+		if err := svc.RevokeDevice(ctx, udid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// devices without a certificate are ignored.
+	if err := svc.RevokeDevice(ctx, "UDID-4"); err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := svc.ListCertificates(ctx, ListCertificatesOption{FilterStatus: CertificateRevoked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(certs), 3; have != want {
+		t.Fatalf("have %d revoked certificates, want %d", have, want)
+	}
+	for _, c := range certs {
+		if c.UDID == "" {
+			t.Errorf("certificate %s of no device is revoked", c.SerialNumber)
+		}
+	}
+	revoked, err := depot.IsRevoked(IssuerID(other), other.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("revoking a certificate of another issuer revoked one of the current CA")
+	}
+
+	now := time.Now().UTC()
+	der, err := svc.CRL(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseCRL(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.CheckCRLSignature(crl); err != nil {
+		t.Errorf("verify CRL signature: %s", err)
+	}
+	// only the certificates of the current CA are in its CRL.
+	entries := crl.TBSCertList.RevokedCertificates
+	if have, want := len(entries), 1; have != want {
+		t.Fatalf("have %d CRL entries, want %d", have, want)
+	}
+	if entries[0].SerialNumber.Cmp(identity.SerialNumber) != 0 {
+		t.Errorf("have serial %s in CRL, want %s", entries[0].SerialNumber, identity.SerialNumber)
+	}
+}
@@ -0,0 +1,69 @@
+package scep
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type RotateCAOption struct {
+	// Years the new CA is valid for. DefaultCAYears if zero.
+	Years int `json:"years"`
+}
+
+// RotateCA creates a new SCEP CA which issues all new certificates. The
+// previous CA stays trusted for the identities it issued.
+func (svc *CAService) RotateCA(ctx context.Context, opt RotateCAOption) ([]CA, error) {
+	years := opt.Years
+	if years == 0 {
+		years = DefaultCAYears
+	}
+	if years < 0 {
+		return nil, errors.New("CA validity years must be positive")
+	}
+	crt, key, err := NewCA(years)
+	if err != nil {
+		return nil, err
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	if err := svc.depot.RotateCA(crt, key); err != nil {
+		return nil, errors.Wrap(err, "rotate SCEP CA")
+	}
+	if svc.rotated != nil {
+		if err := svc.rotated(crt, key); err != nil {
+			return nil, err
+		}
+	}
+	return svc.GetCAs(ctx)
+}
+
+type rotateCARequest struct{ Opts RotateCAOption }
+
+func decodeRotateCARequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var opts RotateCAOption
+	err := httputil.DecodeJSONRequest(r, &opts)
+	return rotateCARequest{Opts: opts}, err
+}
+
+func MakeRotateCAEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(rotateCARequest)
+		cas, err := svc.RotateCA(ctx, req.Opts)
+		return getCAsResponse{CAs: cas, Err: err}, nil
+	}
+}
+
+func (e Endpoints) RotateCA(ctx context.Context, opt RotateCAOption) ([]CA, error) {
+	request := rotateCARequest{opt}
+	response, err := e.RotateCAEndpoint(ctx, request.Opts)
+	if err != nil {
+		return nil, err
+	}
+	return response.(getCAsResponse).CAs, response.(getCAsResponse).Err
+}
@@ -0,0 +1,50 @@
+package scep
+
+import (
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+
+	"github.com/micromdm/micromdm/pkg/httputil"
+)
+
+type Endpoints struct {
+	GetCAsEndpoint           endpoint.Endpoint
+	RotateCAEndpoint         endpoint.Endpoint
+	ListCertificatesEndpoint endpoint.Endpoint
+}
+
+func MakeServerEndpoints(s Service, outer endpoint.Middleware, others ...endpoint.Middleware) Endpoints {
+	return Endpoints{
+		GetCAsEndpoint:           endpoint.Chain(outer, others...)(MakeGetCAsEndpoint(s)),
+		RotateCAEndpoint:         endpoint.Chain(outer, others...)(MakeRotateCAEndpoint(s)),
+		ListCertificatesEndpoint: endpoint.Chain(outer, others...)(MakeListCertificatesEndpoint(s)),
+	}
+}
+
+func RegisterHTTPHandlers(r *mux.Router, e Endpoints, options ...httptransport.ServerOption) {
+	// GET     /v1/scep/cas			get the SCEP CAs and the devices using them
+	// POST    /v1/scep/cas/rotate		rotate the SCEP CA
+	// POST    /v1/scep/certificates		get a list of issued certificates and their status
+
+	r.Methods("GET").Path("/v1/scep/cas").Handler(httptransport.NewServer(
+		e.GetCAsEndpoint,
+		decodeGetCAsRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/scep/cas/rotate").Handler(httptransport.NewServer(
+		e.RotateCAEndpoint,
+		decodeRotateCARequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/v1/scep/certificates").Handler(httptransport.NewServer(
+		e.ListCertificatesEndpoint,
+		decodeListCertificatesRequest,
+		httputil.EncodeJSONResponse,
+		options...,
+	))
+}
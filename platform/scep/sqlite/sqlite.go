@@ -0,0 +1,365 @@
+// Package sqlite implements a SCEP certificate depot backed by SQLite.
+//
+// It stores the same data as the BoltDB depot from github.com/micromdm/scep:
+// the CA certificate and key, the next serial number and every issued
+// certificate, named "<common name>.<serial>". CAs replaced by RotateCA are
+// kept in scep_previous_cas and the issuers and serial numbers of revoked
+// certificates in scep_revocations.
+package sqlite
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/micromdm/scep/v2/depot"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/scep"
+)
+
+const (
+	depotTableName        = "scep_depot"
+	certificatesTableName = "scep_certificates"
+	previousCAsTableName  = "scep_previous_cas"
+	revocationsTableName  = "scep_revocations"
+)
+
+// SQLite is a depot.Depot backed by SQLite.
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+// depotColumn returns a single column of the scep_depot row, or nil if the
+// row or the value does not exist.
+func (d *SQLite) depotColumn(q sqlx.Queryer, column string) ([]byte, error) {
+	query, args, err := sq.
+		Select(column).
+		From(depotTableName).
+		Where(sq.Eq{"id": 1}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var value []byte
+	err = q.QueryRowx(query, args...).Scan(&value)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, nil
+	}
+	return value, errors.Wrapf(err, "get scep %s", column)
+}
+
+func (d *SQLite) saveDepotColumn(e sqlx.Execer, column string, value interface{}) error {
+	query := fmt.Sprintf(
+		`INSERT INTO %[1]s (id, %[2]s) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET %[2]s = excluded.%[2]s`,
+		depotTableName, column,
+	)
+	_, err := e.Exec(query, value)
+	return errors.Wrapf(err, "save scep %s", column)
+}
+
+func (d *SQLite) CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error) {
+	caCert, err := d.depotColumn(d.db, "ca_certificate")
+	if err != nil {
+		return nil, nil, err
+	}
+	if caCert == nil {
+		return nil, nil, errors.New("no ca_certificate in depot")
+	}
+	cert, err := x509.ParseCertificate(caCert)
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, err := d.depotColumn(d.db, "ca_key")
+	if err != nil {
+		return nil, nil, err
+	}
+	if caKey == nil {
+		return nil, nil, errors.New("no ca_key in depot")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []*x509.Certificate{cert}, key, nil
+}
+
+// Put stores crt under the current serial number and increments the serial.
+func (d *SQLite) Put(cn string, crt *x509.Certificate) error {
+	if crt == nil || crt.Raw == nil {
+		return fmt.Errorf("%q does not specify a valid certificate for storage", cn)
+	}
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	serial, err := d.serial(tx)
+	if err != nil {
+		return err
+	}
+	query, args, err := sq.
+		Insert(certificatesTableName).
+		Options("OR REPLACE").
+		Columns("name", "common_name", "serial", "certificate").
+		Values(cn+"."+serial.String(), cn, serial.String(), crt.Raw).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building certificate save query")
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return errors.Wrap(err, "save scep certificate")
+	}
+	next := new(big.Int).Add(serial, big.NewInt(1))
+	if err := d.saveDepotColumn(tx, "serial", next.String()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *SQLite) Serial() (*big.Int, error) {
+	return d.serial(d.db)
+}
+
+// serial returns the next serial number. Like the BoltDB depot, serial
+// numbers start at 2.
+func (d *SQLite) serial(q sqlx.Queryer) (*big.Int, error) {
+	value, err := d.depotColumn(q, "serial")
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return big.NewInt(2), nil
+	}
+	s, ok := new(big.Int).SetString(string(value), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid scep serial %q", value)
+	}
+	return s, nil
+}
+
+func (d *SQLite) HasCN(cn string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error) {
+	// TODO: implement allowTime
+	// TODO: implement revocation
+	if cert == nil {
+		return false, errors.New("nil certificate provided")
+	}
+	query, args, err := sq.
+		Select("certificate").
+		From(certificatesTableName).
+		Where(sq.Eq{"common_name": cert.Subject.CommonName}).
+		ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "building sql")
+	}
+	var certs [][]byte
+	if err := d.db.Select(&certs, query, args...); err != nil {
+		return false, errors.Wrap(err, "find scep certificates by common name")
+	}
+	for _, raw := range certs {
+		if bytes.Equal(raw, cert.Raw) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// EachCertificate calls fn with every issued certificate.
+func (d *SQLite) EachCertificate(fn func(*x509.Certificate) error) error {
+	query, args, err := sq.
+		Select("certificate").
+		From(certificatesTableName).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	var certs [][]byte
+	if err := d.db.Select(&certs, query, args...); err != nil {
+		return errors.Wrap(err, "list scep certificates")
+	}
+	for _, raw := range certs {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return errors.Wrap(err, "parse scep certificate")
+		}
+		if err := fn(cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *SQLite) CreateOrLoadKey(bits int) (*rsa.PrivateKey, error) {
+	priv, err := d.depotColumn(d.db, "ca_key")
+	if err != nil {
+		return nil, err
+	}
+	if priv != nil {
+		return x509.ParsePKCS1PrivateKey(priv)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.saveDepotColumn(d.db, "ca_key", x509.MarshalPKCS1PrivateKey(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (d *SQLite) CreateOrLoadCA(key *rsa.PrivateKey, years int, org, country string) (*x509.Certificate, error) {
+	caCert, err := d.depotColumn(d.db, "ca_certificate")
+	if err != nil {
+		return nil, err
+	}
+	if caCert != nil {
+		return x509.ParseCertificate(caCert)
+	}
+
+	newCert := depot.NewCACert(
+		depot.WithYears(years),
+		depot.WithOrganization(org),
+		depot.WithOrganizationalUnit("MICROMDM SCEP CA"),
+		depot.WithCountry(country),
+	)
+	crtBytes, err := newCert.SelfSign(rand.Reader, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.saveDepotColumn(d.db, "ca_certificate", crtBytes); err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(crtBytes)
+}
+
+// RotateCA replaces the CA certificate and key, keeping the replaced CA
+// certificate in the previous CAs.
+func (d *SQLite) RotateCA(crt *x509.Certificate, key *rsa.PrivateKey) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	defer tx.Rollback()
+
+	old, err := d.depotColumn(tx, "ca_certificate")
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		sum := sha256.Sum256(old)
+		query, args, err := sq.
+			Insert(previousCAsTableName).
+			Options("OR REPLACE").
+			Columns("fingerprint", "certificate").
+			Values(hex.EncodeToString(sum[:]), old).
+			ToSql()
+		if err != nil {
+			return errors.Wrap(err, "building previous CA save query")
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return errors.Wrap(err, "save previous scep CA")
+		}
+	}
+	if err := d.saveDepotColumn(tx, "ca_certificate", crt.Raw); err != nil {
+		return err
+	}
+	if err := d.saveDepotColumn(tx, "ca_key", x509.MarshalPKCS1PrivateKey(key)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PreviousCAs returns the CAs replaced by RotateCA, newest first.
+func (d *SQLite) PreviousCAs() ([]*x509.Certificate, error) {
+	query, args, err := sq.
+		Select("certificate").
+		From(previousCAsTableName).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var raws [][]byte
+	if err := d.db.Select(&raws, query, args...); err != nil {
+		return nil, errors.Wrap(err, "list previous scep CAs")
+	}
+	cas := make([]*x509.Certificate, 0, len(raws))
+	for _, raw := range raws {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse previous scep CA")
+		}
+		cas = append(cas, cert)
+	}
+	sort.Slice(cas, func(i, j int) bool { return cas[i].NotBefore.After(cas[j].NotBefore) })
+	return cas, nil
+}
+
+// Revoke records the revocation of the certificate with issuer and serial.
+// The first revocation time is kept.
+func (d *SQLite) Revoke(issuer string, serial *big.Int, at time.Time) error {
+	query, args, err := sq.
+		Insert(revocationsTableName).
+		Options("OR IGNORE").
+		Columns("issuer", "serial", "revoked_at").
+		Values(issuer, serial.String(), at.UTC()).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building revocation save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrapf(err, "revoke certificate %s", serial)
+}
+
+func (d *SQLite) IsRevoked(issuer string, serial *big.Int) (bool, error) {
+	query, args, err := sq.
+		Select("COUNT(*)").
+		From(revocationsTableName).
+		Where(sq.Eq{"issuer": issuer, "serial": serial.String()}).
+		ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "building sql")
+	}
+	var count int
+	err = d.db.Get(&count, query, args...)
+	return count > 0, errors.Wrapf(err, "check revocation of %s", serial)
+}
+
+func (d *SQLite) Revocations() ([]scep.Revocation, error) {
+	query, args, err := sq.
+		Select("issuer", "serial", "revoked_at").
+		From(revocationsTableName).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var rows []struct {
+		Issuer    string    `db:"issuer"`
+		Serial    string    `db:"serial"`
+		RevokedAt time.Time `db:"revoked_at"`
+	}
+	if err := d.db.Select(&rows, query, args...); err != nil {
+		return nil, errors.Wrap(err, "list revocations")
+	}
+	revocations := make([]scep.Revocation, len(rows))
+	for i, r := range rows {
+		serial, ok := new(big.Int).SetString(r.Serial, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial %q", r.Serial)
+		}
+		revocations[i] = scep.Revocation{Issuer: r.Issuer, SerialNumber: serial, RevokedAt: r.RevokedAt}
+	}
+	return revocations, nil
+}
@@ -0,0 +1,189 @@
+package sqlite
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/micromdm/micromdm/platform/scep"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+func TestDepot(t *testing.T) {
+	db := setup(t)
+
+	key, err := db.CreateOrLoadKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := db.CreateOrLoadCA(key, 1, "MicroMDM", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// loading again returns the stored CA
+	loaded, err := db.CreateOrLoadCA(key, 1, "MicroMDM", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equal(ca) {
+		t.Error("CreateOrLoadCA created a new CA certificate")
+	}
+	chain, caKey, err := db.CA(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(chain), 1; have != want {
+		t.Fatalf("have %d CA certificates, want %d", have, want)
+	}
+	if !chain[0].Equal(ca) || caKey.N.Cmp(key.N) != 0 {
+		t.Error("CA returned a different certificate or key")
+	}
+
+	serial, err := db.Serial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := serial.Int64(), int64(2); have != want {
+		t.Errorf("have serial %d, want %d", have, want)
+	}
+
+	if err := db.Put(ca.Subject.CommonName, ca); err != nil {
+		t.Fatal(err)
+	}
+	serial, err = db.Serial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := serial.Int64(), int64(3); have != want {
+		t.Errorf("have serial %d, want %d", have, want)
+	}
+
+	hasCN, err := db.HasCN(ca.Subject.CommonName, 0, ca, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasCN {
+		t.Error("expected stored certificate to be found")
+	}
+	hasCN, err = db.HasCN("other", 0, &x509.Certificate{Raw: []byte("other")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasCN {
+		t.Error("found certificate which was never stored")
+	}
+
+	var count int
+	err = db.EachCertificate(func(c *x509.Certificate) error {
+		count++
+		if !c.Equal(ca) {
+			t.Error("EachCertificate returned a different certificate")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := count, 1; have != want {
+		t.Errorf("have %d certificates, want %d", have, want)
+	}
+}
+
+func TestRotateCA(t *testing.T) {
+	db := setup(t)
+
+	key, err := db.CreateOrLoadKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := db.CreateOrLoadCA(key, 1, "MicroMDM", "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, newKey, err := scep.NewCA(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RotateCA(crt, newKey); err != nil {
+		t.Fatal(err)
+	}
+
+	chain, caKey, err := db.CA(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chain[0].Equal(crt) || caKey.N.Cmp(newKey.N) != 0 {
+		t.Error("CA returned the replaced certificate or key")
+	}
+	previous, err := db.PreviousCAs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(previous), 1; have != want {
+		t.Fatalf("have %d previous CAs, want %d", have, want)
+	}
+	if !previous[0].Equal(old) {
+		t.Error("previous CA is not the replaced certificate")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	db := setup(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := db.Revoke("issuer", big.NewInt(5), now); err != nil {
+		t.Fatal(err)
+	}
+	// revoking again keeps the first revocation time.
+	if err := db.Revoke("issuer", big.NewInt(5), now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := db.IsRevoked("issuer", big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("certificate 5 is not revoked")
+	}
+	revoked, err = db.IsRevoked("issuer", big.NewInt(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("certificate 6 is revoked")
+	}
+	// serial numbers are only unique per issuer.
+	revoked, err = db.IsRevoked("other", big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("certificate 5 of another issuer is revoked")
+	}
+
+	revocations, err := db.Revocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(revocations), 1; have != want {
+		t.Fatalf("have %d revocations, want %d", have, want)
+	}
+	if !revocations[0].RevokedAt.Equal(now) {
+		t.Errorf("have %v, want %v", revocations[0].RevokedAt, now)
+	}
+	if have, want := revocations[0].Issuer, "issuer"; have != want {
+		t.Errorf("have issuer %s, want %s", have, want)
+	}
+}
+
+func setup(t *testing.T) *SQLite {
+	db, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db)
+}
@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+
+	"github.com/micromdm/micromdm/platform/user"
+)
+
+type SQLite struct{ db *sqlx.DB }
+
+func New(db *sqlx.DB) *SQLite {
+	return &SQLite{db: db}
+}
+
+func columns() []string {
+	return []string{
+		"uuid",
+		"udid",
+		"user_id",
+		"user_shortname",
+		"user_longname",
+		"auth_token",
+		"password_hash",
+		"hidden",
+	}
+}
+
+const tableName = "users"
+
+// userRow is the table representation of a user.
+type userRow struct {
+	UUID          string `db:"uuid"`
+	UDID          string `db:"udid"`
+	UserID        string `db:"user_id"`
+	UserShortname string `db:"user_shortname"`
+	UserLongname  string `db:"user_longname"`
+	AuthToken     string `db:"auth_token"`
+	PasswordHash  []byte `db:"password_hash"`
+	Hidden        bool   `db:"hidden"`
+}
+
+func (r userRow) user() user.User {
+	return user.User{
+		UUID:          r.UUID,
+		UDID:          r.UDID,
+		UserID:        r.UserID,
+		UserShortname: r.UserShortname,
+		UserLongname:  r.UserLongname,
+		AuthToken:     r.AuthToken,
+		PasswordHash:  r.PasswordHash,
+		Hidden:        r.Hidden,
+	}
+}
+
+func (d *SQLite) List() ([]user.User, error) {
+	return d.selectUsers(context.TODO(), nil)
+}
+
+func (d *SQLite) DeviceUsers(udid string) ([]user.User, error) {
+	users, err := d.selectUsers(context.TODO(), sq.Eq{"udid": udid})
+	return users, errors.Wrap(err, "get device users")
+}
+
+func (d *SQLite) selectUsers(ctx context.Context, where sq.Sqlizer) ([]user.User, error) {
+	stmt := sq.
+		Select(columns()...).
+		From(tableName).
+		OrderBy("user_shortname", "uuid")
+	if where != nil {
+		stmt = stmt.Where(where)
+	}
+	query, args, err := stmt.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var rows []userRow
+	if err := d.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, errors.Wrap(err, "list users")
+	}
+	var users []user.User
+	for _, r := range rows {
+		users = append(users, r.user())
+	}
+	return users, nil
+}
+
+func (d *SQLite) Save(u *user.User) error {
+	query, args, err := sq.
+		Insert(tableName).
+		Options("OR REPLACE").
+		Columns(columns()...).
+		Values(
+			u.UUID,
+			u.UDID,
+			u.UserID,
+			u.UserShortname,
+			u.UserLongname,
+			u.AuthToken,
+			u.PasswordHash,
+			u.Hidden,
+		).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building user save query")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrap(err, "exec user save in sqlite")
+}
+
+func (d *SQLite) User(ctx context.Context, uuid string) (*user.User, error) {
+	u, err := d.userBy(ctx, "uuid", uuid)
+	return u, errors.Wrap(err, "get user by uuid from sqlite")
+}
+
+func (d *SQLite) UserByUserID(userID string) (*user.User, error) {
+	u, err := d.userBy(context.TODO(), "user_id", userID)
+	return u, errors.Wrap(err, "get user by user id from sqlite")
+}
+
+func (d *SQLite) userBy(ctx context.Context, column, value string) (*user.User, error) {
+	if value == "" {
+		return nil, &notFound{"User", fmt.Sprintf("empty %s", column)}
+	}
+	query, args, err := sq.
+		Select(columns()...).
+		From(tableName).
+		Where(sq.Eq{column: value}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "building sql")
+	}
+	var r userRow
+	err = d.db.QueryRowxContext(ctx, query, args...).StructScan(&r)
+	if errors.Cause(err) == sql.ErrNoRows {
+		return nil, &notFound{"User", fmt.Sprintf("%s %s", column, value)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := r.user()
+	return &u, nil
+}
+
+func (d *SQLite) DeleteDeviceUsers(udid string) error {
+	query, args, err := sq.
+		Delete(tableName).
+		Where(sq.Eq{"udid": udid}).
+		ToSql()
+	if err != nil {
+		return errors.Wrap(err, "building sql")
+	}
+	_, err = d.db.Exec(query, args...)
+	return errors.Wrapf(err, "delete users for UDID %s", udid)
+}
+
+type notFound struct {
+	ResourceType string
+	Message      string
+}
+
+func (e *notFound) Error() string {
+	return fmt.Sprintf("not found: %s %s", e.ResourceType, e.Message)
+}
+
+func (e *notFound) NotFound() bool {
+	return true
+}
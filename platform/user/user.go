@@ -3,9 +3,11 @@ package user
 
 import (
 	"github.com/google/uuid"
+	"github.com/groob/plist"
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/micromdm/micromdm/pkg/crypto/password"
 	"github.com/micromdm/micromdm/platform/user/internal/userproto"
 )
 
@@ -60,3 +62,19 @@ func UnmarshalUser(data []byte, u *User) error {
 	u.Hidden = pb.GetHidden()
 	return nil
 }
+
+// VerifyPassword checks the plaintext password against the
+// SALTED-SHA512-PBKDF2 PasswordHash of the user. It returns
+// password.ErrNoMatch if the password does not match.
+func (u User) VerifyPassword(plaintext string) error {
+	if len(u.PasswordHash) == 0 {
+		return password.ErrNoMatch
+	}
+	var hash struct {
+		SaltedSHA512PBKDF2 password.SaltedSHA512PBKDF2Dictionary `plist:"SALTED-SHA512-PBKDF2"`
+	}
+	if err := plist.Unmarshal(u.PasswordHash, &hash); err != nil {
+		return errors.Wrap(err, "unmarshal user password hash")
+	}
+	return password.Verify(plaintext, hash.SaltedSHA512PBKDF2)
+}
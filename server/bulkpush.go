@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+
+	"github.com/micromdm/micromdm/platform/apns"
+	"github.com/micromdm/micromdm/platform/device"
+)
+
+// pushDevices selects the devices of bulk pushes from the device store.
+type pushDevices struct {
+	store device.DeviceIterator
+}
+
+func (p pushDevices) SelectDevices(ctx context.Context, serials []string, filter *apns.BulkPushFilter) ([]string, error) {
+	bySerial := make(map[string]bool, len(serials))
+	for _, serial := range serials {
+		bySerial[serial] = true
+	}
+	var udids []string
+	err := p.store.EachDevice(ctx, func(d device.Device) error {
+		if d.UDID == "" {
+			return nil
+		}
+		if bySerial[d.SerialNumber] || matchesPushFilter(d, filter) {
+			udids = append(udids, d.UDID)
+		}
+		return nil
+	})
+	return udids, err
+}
+
+func matchesPushFilter(d device.Device, filter *apns.BulkPushFilter) bool {
+	switch {
+	case filter == nil:
+		return false
+	case filter.EnrolledOnly && !d.Enrolled:
+		return false
+	case filter.Group != "" && d.Group != filter.Group:
+		return false
+	case !filter.LastSeenBefore.IsZero() && !d.LastSeen.Before(filter.LastSeenBefore):
+		return false
+	}
+	return true
+}
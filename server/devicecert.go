@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rsa"
 	"crypto/x509"
+	"math/big"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -11,22 +12,42 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/scep"
 )
 
 type ScepVerifyDepot interface {
 	CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error)
+	PreviousCAs() ([]*x509.Certificate, error)
+	IsRevoked(issuer string, serial *big.Int) (bool, error)
 	HasCN(cn string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error)
 }
 
-func VerifyCertificateMiddleware(validateSCEPIssuer bool, validateSCEPExpiration bool, store ScepVerifyDepot, logger log.Logger) mdm.Middleware {
+type VerifyOption func(*verifyCertificateMiddleware)
+
+// WithCABundle trusts device certificates issued by the CAs of an external
+// PKI, like the CA of an upstream SCEP server. Device certificates which are
+// not in the depot are accepted if they verify against the bundle, as with
+// validateSCEPIssuer.
+func WithCABundle(certs []*x509.Certificate) VerifyOption {
+	return func(mw *verifyCertificateMiddleware) {
+		mw.bundle = certs
+		mw.validateSCEPIssuer = true
+	}
+}
+
+func VerifyCertificateMiddleware(validateSCEPIssuer bool, validateSCEPExpiration bool, store ScepVerifyDepot, logger log.Logger, opts ...VerifyOption) mdm.Middleware {
 	return func(next mdm.Service) mdm.Service {
-		return &verifyCertificateMiddleware{
+		mw := &verifyCertificateMiddleware{
 			store:                  store,
 			next:                   next,
 			logger:                 logger,
 			validateSCEPIssuer:     validateSCEPIssuer,
 			validateSCEPExpiration: validateSCEPExpiration,
 		}
+		for _, opt := range opts {
+			opt(mw)
+		}
+		return mw
 	}
 }
 
@@ -36,6 +57,7 @@ type verifyCertificateMiddleware struct {
 	logger                 log.Logger
 	validateSCEPIssuer     bool
 	validateSCEPExpiration bool
+	bundle                 []*x509.Certificate
 }
 
 func (mw *verifyCertificateMiddleware) verifyIssuer(devcert *x509.Certificate) error {
@@ -50,8 +72,17 @@ func (mw *verifyCertificateMiddleware) verifyIssuer(devcert *x509.Certificate) e
 		return errors.Wrap(err, "error retrieving CA")
 	}
 
+	// device certificates issued before the CA was rotated are still
+	// trusted.
+	previous, err := mw.store.PreviousCAs()
+	if err != nil {
+		return errors.Wrap(err, "error retrieving previous CAs")
+	}
+
+	// every certificate of the CA bundle is trusted, so a bundle with only
+	// the issuing CA of an external PKI is enough.
 	roots := x509.NewCertPool()
-	for _, cert := range ca {
+	for _, cert := range append(append(ca, previous...), mw.bundle...) {
 		roots.AddCert(cert)
 	}
 
@@ -69,11 +100,38 @@ func (mw *verifyCertificateMiddleware) verifyIssuer(devcert *x509.Certificate) e
 	return nil
 }
 
+// revokedCertificateError is returned for revoked device certificates. The
+// device is asked to check out, like a blocked device.
+type revokedCertificateError struct{}
+
+func (revokedCertificateError) Error() string {
+	return "device certificate is revoked"
+}
+
+func (revokedCertificateError) Checkout() bool {
+	return true
+}
+
+func (mw *verifyCertificateMiddleware) checkRevoked(devcert *x509.Certificate) error {
+	revoked, err := mw.store.IsRevoked(scep.IssuerID(devcert), devcert.SerialNumber)
+	if err != nil {
+		return errors.Wrap(err, "error checking device certificate revocation")
+	}
+	if revoked {
+		_ = level.Info(mw.logger).Log("err", "revoked device certificate", "serial", devcert.SerialNumber, "issuer", devcert.Issuer.String())
+		return revokedCertificateError{}
+	}
+	return nil
+}
+
 func (mw *verifyCertificateMiddleware) Acknowledge(ctx context.Context, req mdm.AcknowledgeEvent) ([]byte, error) {
 	devcert, err := mdm.DeviceCertificateFromContext(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "error retrieving device certificate")
 	}
+	if err := mw.checkRevoked(devcert); err != nil {
+		return nil, err
+	}
 	hasCN, err := mw.store.HasCN(devcert.Subject.CommonName, 0, devcert, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "error checking device certificate")
@@ -101,6 +159,9 @@ func (mw *verifyCertificateMiddleware) Checkin(ctx context.Context, req mdm.Chec
 	if err != nil {
 		return nil, errors.Wrap(err, "error retrieving device certificate")
 	}
+	if err := mw.checkRevoked(devcert); err != nil {
+		return nil, err
+	}
 	hasCN, err := mw.store.HasCN(devcert.Subject.CommonName, 0, devcert, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "error checking device certificate")
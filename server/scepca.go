@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	scep "github.com/micromdm/scep/v2/server"
+)
+
+// rotatingSCEPService is the SCEP service of the server. It is replaced with
+// a service using the new CA when the SCEP CA is rotated.
+type rotatingSCEPService struct {
+	signer scep.CSRSigner
+	opts   []scep.ServiceOption
+	logger log.Logger
+
+	mu  sync.RWMutex
+	svc scep.Service
+}
+
+func newRotatingSCEPService(crt *x509.Certificate, key *rsa.PrivateKey, signer scep.CSRSigner, logger log.Logger, opts ...scep.ServiceOption) (*rotatingSCEPService, error) {
+	svc := &rotatingSCEPService{signer: signer, opts: opts, logger: logger}
+	return svc, svc.setCA(crt, key)
+}
+
+func (s *rotatingSCEPService) setCA(crt *x509.Certificate, key *rsa.PrivateKey) error {
+	svc, err := scep.NewService(crt, key, s.signer, s.opts...)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.svc = scep.NewLoggingService(s.logger, svc)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *rotatingSCEPService) service() scep.Service {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.svc
+}
+
+func (s *rotatingSCEPService) GetCACaps(ctx context.Context) ([]byte, error) {
+	return s.service().GetCACaps(ctx)
+}
+
+func (s *rotatingSCEPService) GetCACert(ctx context.Context, message string) ([]byte, int, error) {
+	return s.service().GetCACert(ctx, message)
+}
+
+func (s *rotatingSCEPService) PKIOperation(ctx context.Context, msg []byte) ([]byte, error) {
+	return s.service().PKIOperation(ctx, msg)
+}
+
+func (s *rotatingSCEPService) GetNextCACert(ctx context.Context) ([]byte, error) {
+	return s.service().GetNextCACert(ctx)
+}
@@ -12,68 +12,126 @@ import (
 	"github.com/micromdm/micromdm/dep"
 	"github.com/micromdm/micromdm/mdm"
 	"github.com/micromdm/micromdm/mdm/enroll"
+	"github.com/micromdm/micromdm/pkg/crypto/profileutil"
+	"github.com/micromdm/micromdm/platform/acme"
 	"github.com/micromdm/micromdm/platform/apns"
 	apnsbuiltin "github.com/micromdm/micromdm/platform/apns/builtin"
+	apnssqlite "github.com/micromdm/micromdm/platform/apns/sqlite"
+	blueprintbuiltin "github.com/micromdm/micromdm/platform/blueprint/builtin"
+	blueprintsqlite "github.com/micromdm/micromdm/platform/blueprint/sqlite"
+	"github.com/micromdm/micromdm/platform/challenge"
+	challengebuiltin "github.com/micromdm/micromdm/platform/challenge/builtin"
 	"github.com/micromdm/micromdm/platform/command"
 	"github.com/micromdm/micromdm/platform/config"
 	configbuiltin "github.com/micromdm/micromdm/platform/config/builtin"
+	configsqlite "github.com/micromdm/micromdm/platform/config/sqlite"
 	"github.com/micromdm/micromdm/platform/dep/sync"
 	syncbuiltin "github.com/micromdm/micromdm/platform/dep/sync/builtin"
+	syncsqlite "github.com/micromdm/micromdm/platform/dep/sync/sqlite"
 	"github.com/micromdm/micromdm/platform/device"
 	devicebuiltin "github.com/micromdm/micromdm/platform/device/builtin"
+	devicesqlite "github.com/micromdm/micromdm/platform/device/sqlite"
+	"github.com/micromdm/micromdm/platform/enrollment"
+	enrollmentbuiltin "github.com/micromdm/micromdm/platform/enrollment/builtin"
+	"github.com/micromdm/micromdm/platform/invitation"
+	invitationbuiltin "github.com/micromdm/micromdm/platform/invitation/builtin"
 	"github.com/micromdm/micromdm/platform/profile"
 	profilebuiltin "github.com/micromdm/micromdm/platform/profile/builtin"
+	profilesqlite "github.com/micromdm/micromdm/platform/profile/sqlite"
 	"github.com/micromdm/micromdm/platform/pubsub"
 	"github.com/micromdm/micromdm/platform/pubsub/inmem"
 	"github.com/micromdm/micromdm/platform/queue"
 	queueinmem "github.com/micromdm/micromdm/platform/queue/inmem"
+	queuesqlite "github.com/micromdm/micromdm/platform/queue/sqlite"
 	block "github.com/micromdm/micromdm/platform/remove"
 	blockbuiltin "github.com/micromdm/micromdm/platform/remove/builtin"
+	scepplatform "github.com/micromdm/micromdm/platform/scep"
+	scepbuiltin "github.com/micromdm/micromdm/platform/scep/builtin"
+	scepsqlite "github.com/micromdm/micromdm/platform/scep/sqlite"
+	userbuiltin "github.com/micromdm/micromdm/platform/user/builtin"
+	usersqlite "github.com/micromdm/micromdm/platform/user/sqlite"
 	"github.com/micromdm/micromdm/workflow/webhook"
 
 	"github.com/boltdb/bolt"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/micromdm/scep/v2/challenge"
-	boltchallenge "github.com/micromdm/scep/v2/challenge/bolt"
+	"github.com/jmoiron/sqlx"
 	"github.com/micromdm/scep/v2/depot"
-	boltdepot "github.com/micromdm/scep/v2/depot/bolt"
 	scep "github.com/micromdm/scep/v2/server"
 	"github.com/pkg/errors"
 )
 
+// Values of Server.SignProfiles, which select the profiles signed with
+// Server.ProfileSigner.
+const (
+	// SignEnrollmentProfiles signs enrollment and OTA profiles.
+	SignEnrollmentProfiles = "enrollment"
+	// SignAllProfiles also signs the profiles of InstallProfile commands.
+	SignAllProfiles = "all"
+)
+
 type Server struct {
 	ConfigPath             string
 	Depsim                 string
 	PubClient              pubsub.PublishSubscriber
 	DB                     *bolt.DB
+	SQLiteDB               *sqlx.DB
+	Storage                string
 	ServerPublicURL        string
 	SCEPChallenge          string
 	SCEPClientValidity     int
 	TLSCertPath            string
-	SCEPDepot              depot.Depot
+	SignProfiles           string
+	ProfileSigner          *profileutil.Signer
+	SCEPSubject            string
+	EnrollRestrictions     *enroll.Restrictions
+	EnrollDEPDevicesOnly   bool
+	OTAStrict              bool
+	SCEPDepot              SCEPDepot
+	SCEPRenewalDays        int
+	SCEPRenewalDB          scepplatform.RenewalStore
+	SCEPUpstreamURL        string
+	SCEPCABundle           []*x509.Certificate
 	UseDynSCEPChallenge    bool
 	GenDynSCEPChallenge    bool
-	SCEPChallengeDepot     challenge.Store
+	SCEPChallengeDepot     SCEPChallengeStore
+	SCEPChallengeService   *challenge.ChallengeService
+	ACMEEnrollment         bool
+	ACMEHardwareBound      bool
+	ACMEIssuer             *acme.Issuer
 	ProfileDB              profile.Store
 	ConfigDB               config.Store
 	RemoveDB               block.Store
+	InvitationDB           invitation.Store
+	EnrollmentTemplateDB   enrollment.Store
+	DeviceDB               DeviceStore
+	UserDB                 UserStore
+	BlueprintDB            BlueprintStore
+	DeviceSearchIndex      *device.SearchIndex
 	CommandWebhookURL      string
 	DEPClient              *dep.Client
-	SyncDB                 *syncbuiltin.DB
+	SyncDB                 SyncStore
 	NoCmdHistory           bool
 	ValidateSCEPIssuer     bool
 	ValidateSCEPExpiration bool
 	UDIDCertAuthWarnOnly   bool
+	ClientCertAuth         bool
 	Queue                  string
+	PushConcurrency        int
+	PushRate               int
+	RepushInterval         time.Duration
+	RepushMaxInterval      time.Duration
 
 	APNSPushService apns.Service
 	CommandService  command.Service
 	MDMService      mdm.Service
 	EnrollService   enroll.Service
 	SCEPService     scep.Service
+	SCEPCAService   *scepplatform.CAService
 	ConfigService   config.Service
 
+	scepService *rotatingSCEPService
+
 	WebhooksHTTPClient *http.Client
 }
 
@@ -86,18 +144,38 @@ func (c *Server) Setup(logger log.Logger) error {
 		return err
 	}
 
+	if err := c.setupSQLite(); err != nil {
+		return err
+	}
+
 	if err := c.setupRemoveService(); err != nil {
 		return err
 	}
 
+	if err := c.setupInvitationDB(); err != nil {
+		return err
+	}
+
+	if err := c.setupEnrollmentTemplateDB(); err != nil {
+		return err
+	}
+
 	if err := c.setupConfigStore(); err != nil {
 		return err
 	}
 
+	c.setupPushCertificateExpiry(logger)
+
 	if err := c.setupSCEP(logger); err != nil {
 		return err
 	}
 
+	c.setupACME()
+
+	if err := c.setupDeviceDB(); err != nil {
+		return err
+	}
+
 	if err := c.setupPushService(logger); err != nil {
 		return err
 	}
@@ -110,6 +188,8 @@ func (c *Server) Setup(logger log.Logger) error {
 		return err
 	}
 
+	c.setupSCEPCAService()
+
 	if err := c.setupCommandQueue(logger); err != nil {
 		return err
 	}
@@ -122,12 +202,28 @@ func (c *Server) Setup(logger log.Logger) error {
 		return err
 	}
 
+	if err := c.setupUserDB(); err != nil {
+		return err
+	}
+
+	if err := c.setupDeviceSearchIndex(); err != nil {
+		return err
+	}
+
+	if err := c.setupBlueprintDB(); err != nil {
+		return err
+	}
+
 	err := c.setupEnrollmentService()
 
 	return err
 }
 
 func (c *Server) setupProfileDB() error {
+	if c.Storage == StorageSQLite {
+		c.ProfileDB = profilesqlite.New(c.SQLiteDB)
+		return nil
+	}
 	profileDB, err := profilebuiltin.NewDB(c.DB)
 	if err != nil {
 		return err
@@ -136,6 +232,67 @@ func (c *Server) setupProfileDB() error {
 	return nil
 }
 
+func (c *Server) setupDeviceDB() error {
+	if c.Storage == StorageSQLite {
+		c.DeviceDB = devicesqlite.New(c.SQLiteDB)
+		return nil
+	}
+	devDB, err := devicebuiltin.NewDB(c.DB)
+	if err != nil {
+		return errors.Wrap(err, "new device db")
+	}
+	c.DeviceDB = devDB
+	return nil
+}
+
+// setupDeviceSearchIndex indexes the stored devices and their users. The
+// device worker keeps the index up to date afterwards.
+func (c *Server) setupDeviceSearchIndex() error {
+	idx := device.NewSearchIndex()
+	if err := idx.Load(context.Background(), c.DeviceDB); err != nil {
+		return errors.Wrap(err, "load devices into search index")
+	}
+	users, err := c.UserDB.List()
+	if err != nil {
+		return errors.Wrap(err, "load users into search index")
+	}
+	for _, u := range users {
+		idx.IndexOwner(u.UDID, device.Owner{
+			UserID:    u.UserID,
+			Shortname: u.UserShortname,
+			Longname:  u.UserLongname,
+		})
+	}
+	c.DeviceSearchIndex = idx
+	return nil
+}
+
+func (c *Server) setupUserDB() error {
+	if c.Storage == StorageSQLite {
+		c.UserDB = usersqlite.New(c.SQLiteDB)
+		return nil
+	}
+	userDB, err := userbuiltin.NewDB(c.DB)
+	if err != nil {
+		return err
+	}
+	c.UserDB = userDB
+	return nil
+}
+
+func (c *Server) setupBlueprintDB() error {
+	if c.Storage == StorageSQLite {
+		c.BlueprintDB = blueprintsqlite.New(c.SQLiteDB, c.ProfileDB)
+		return nil
+	}
+	bpDB, err := blueprintbuiltin.NewDB(c.DB, c.ProfileDB)
+	if err != nil {
+		return err
+	}
+	c.BlueprintDB = bpDB
+	return nil
+}
+
 func (c *Server) setupPubSub() error {
 	c.PubClient = inmem.NewPubSub()
 	return nil
@@ -161,12 +318,33 @@ func (c *Server) setupRemoveService() error {
 	return nil
 }
 
+func (c *Server) setupInvitationDB() error {
+	invitationDB, err := invitationbuiltin.NewDB(c.DB)
+	if err != nil {
+		return err
+	}
+	c.InvitationDB = invitationDB
+	return nil
+}
+
+func (c *Server) setupEnrollmentTemplateDB() error {
+	templateDB, err := enrollmentbuiltin.NewDB(c.DB)
+	if err != nil {
+		return err
+	}
+	c.EnrollmentTemplateDB = templateDB
+	return nil
+}
+
 func (c *Server) setupCommandService() error {
 	commandService, err := command.New(c.PubClient)
 	if err != nil {
 		return err
 	}
 	c.CommandService = commandService
+	if c.SignProfiles == SignAllProfiles {
+		c.CommandService = command.SigningMiddleware(c.ProfileSigner)(c.CommandService)
+	}
 	return nil
 }
 
@@ -180,33 +358,58 @@ func (c *Server) setupCommandQueue(logger log.Logger) error {
 		if c.NoCmdHistory {
 			opts = append(opts, queue.WithoutHistory())
 		}
-		var err error
-		q, err = queue.NewQueue(c.DB, c.PubClient, opts...)
+		var (
+			builtinQueue *queue.Queue
+			err          error
+		)
+		if c.Storage == StorageSQLite {
+			builtinQueue, err = queue.New(queuesqlite.New(c.SQLiteDB), c.PubClient, opts...)
+		} else {
+			builtinQueue, err = queue.NewQueue(c.DB, c.PubClient, opts...)
+		}
 		if err != nil {
 			return err
 		}
+		q = builtinQueue
+		if c.RepushInterval > 0 {
+			repushWorker := apns.NewRepushWorker(c.APNSPushService, builtinQueue, c.PubClient,
+				c.RepushInterval, c.RepushMaxInterval, log.With(logger, "component", "repush"))
+			go repushWorker.Run(context.Background())
+		}
 	case "":
 		return errors.New("empty command queue type")
 	default:
 		return fmt.Errorf("invalid command queue type: %s", c.Queue)
 	}
 
-	devDB, err := devicebuiltin.NewDB(c.DB)
-	if err != nil {
-		return errors.Wrap(err, "new device db")
-	}
-
 	var mdmService mdm.Service
 	{
-		svc := mdm.NewService(c.PubClient, q, devDB)
+		svc := mdm.NewService(c.PubClient, q, c.DeviceDB)
 		mdmService = svc
 		mdmService = block.RemoveMiddleware(c.RemoveDB)(mdmService)
 
+		invitationLogger := log.With(logger, "component", "invitation")
+		mdmService = invitation.CheckinMiddleware(c.InvitationDB, invitationLogger)(mdmService)
+
+		if c.SCEPChallengeDepot != nil {
+			challengeLogger := log.With(logger, "component", "scep_challenge")
+			mdmService = challenge.CheckinMiddleware(c.SCEPChallengeDepot, challengeLogger)(mdmService)
+		}
+
 		udidauthLogger := log.With(logger, "component", "udidcertauth")
-		mdmService = device.UDIDCertAuthMiddleware(devDB, udidauthLogger, c.UDIDCertAuthWarnOnly)(mdmService)
+		var udidauthOpts []device.UDIDCertAuthOption
+		if c.SCEPRenewalDB != nil {
+			tracker := scepplatform.NewRenewalTracker(c.SCEPRenewalDB, log.With(logger, "component", "scep_renewal"))
+			udidauthOpts = append(udidauthOpts, device.WithCertificateRenewals(tracker))
+		}
+		mdmService = device.UDIDCertAuthMiddleware(c.DeviceDB, udidauthLogger, c.UDIDCertAuthWarnOnly, udidauthOpts...)(mdmService)
 
 		verifycertLogger := log.With(logger, "component", "verifycert")
-		mdmService = VerifyCertificateMiddleware(c.ValidateSCEPIssuer, c.ValidateSCEPExpiration, c.SCEPDepot, verifycertLogger)(mdmService)
+		var verifyOpts []VerifyOption
+		if len(c.SCEPCABundle) > 0 {
+			verifyOpts = append(verifyOpts, WithCABundle(c.SCEPCABundle))
+		}
+		mdmService = VerifyCertificateMiddleware(c.ValidateSCEPIssuer, c.ValidateSCEPExpiration, c.SCEPDepot, verifycertLogger, verifyOpts...)(mdmService)
 	}
 	c.MDMService = mdmService
 
@@ -230,23 +433,49 @@ type pushServiceCert struct {
 }
 
 func (c *Server) setupConfigStore() error {
-	db, err := configbuiltin.NewDB(c.DB, c.PubClient)
-	if err != nil {
-		return err
+	if c.Storage == StorageSQLite {
+		c.ConfigDB = configsqlite.New(c.SQLiteDB, c.PubClient)
+	} else {
+		db, err := configbuiltin.NewDB(c.DB, c.PubClient)
+		if err != nil {
+			return err
+		}
+		c.ConfigDB = db
 	}
-	c.ConfigDB = db
-	c.ConfigService = config.New(db)
+	c.ConfigService = config.New(c.ConfigDB)
 
 	return nil
 }
 
+// setupPushCertificateExpiry starts the worker which warns about the expiry
+// of the push certificate.
+func (c *Server) setupPushCertificateExpiry(logger log.Logger) {
+	worker := config.NewExpiryWorker(c.ConfigDB, c.PubClient, log.With(logger, "component", "push-certificate"))
+	go worker.Run(context.Background())
+}
+
+type pushInfoStore interface {
+	apns.Store
+	apns.WorkerStore
+}
+
 func (c *Server) setupPushService(logger log.Logger) error {
-	db, err := apnsbuiltin.NewDB(c.DB, c.PubClient)
-	if err != nil {
-		return err
+	var db pushInfoStore
+	if c.Storage == StorageSQLite {
+		db = apnssqlite.New(c.SQLiteDB)
+	} else {
+		boltDB, err := apnsbuiltin.NewDB(c.DB, c.PubClient)
+		if err != nil {
+			return err
+		}
+		db = boltDB
 	}
 
-	service, err := apns.New(db, c.ConfigDB, c.PubClient)
+	service, err := apns.New(db, c.ConfigDB, c.PubClient,
+		apns.WithPublisher(c.PubClient),
+		apns.WithLogger(log.With(logger, "component", "apns")),
+		apns.WithBulkPush(pushDevices{store: c.DeviceDB}, c.PushConcurrency, c.PushRate),
+	)
 	if err != nil {
 		return errors.Wrap(err, "starting micromdm push service")
 	}
@@ -260,15 +489,54 @@ func (c *Server) setupPushService(logger log.Logger) error {
 	return nil
 }
 
+// setupACME creates the ACME issuer for enrollment profiles which request
+// the device identity certificate over ACME. It signs with the SCEP CA and
+// accepts the same challenges as SCEP.
+func (c *Server) setupACME() {
+	if !c.ACMEEnrollment {
+		return
+	}
+	opts := []acme.Option{acme.WithValidityDays(c.SCEPClientValidity)}
+	if c.UseDynSCEPChallenge {
+		opts = append(opts, acme.WithChallengeStore(c.SCEPChallengeDepot))
+	} else {
+		opts = append(opts, acme.WithStaticChallenge(c.SCEPChallenge))
+	}
+	c.ACMEIssuer = acme.NewIssuer(c.SCEPDepot, c.ServerPublicURL, opts...)
+}
+
 func (c *Server) setupEnrollmentService() error {
 	var (
-		SCEPCertificateSubject string
-		err                    error
+		opts = []enroll.Option{
+			enroll.WithPublisher(c.PubClient),
+			enroll.WithInvitations(c.InvitationDB),
+		}
+		err error
 	)
+	if c.OTAStrict {
+		opts = append(opts, enroll.WithStrictOTA())
+	}
+	if c.ACMEIssuer != nil {
+		opts = append(opts, enroll.WithACME(c.ACMEIssuer.DirectoryURL(), c.ACMEHardwareBound))
+	}
+	if c.ClientCertAuth {
+		opts = append(opts, enroll.WithClientCertAuth())
+	}
 
-	chalStore := c.SCEPChallengeDepot
-	if !c.GenDynSCEPChallenge {
-		chalStore = nil
+	if c.EnrollRestrictions != nil || c.EnrollDEPDevicesOnly {
+		restrictions := new(enroll.Restrictions)
+		if c.EnrollRestrictions != nil {
+			*restrictions = *c.EnrollRestrictions
+		}
+		if c.EnrollDEPDevicesOnly {
+			restrictions.DEPDevices = c.DeviceDB
+		}
+		opts = append(opts, enroll.WithRestrictions(restrictions))
+	}
+
+	var chalStore enroll.ChallengeStore
+	if c.GenDynSCEPChallenge && c.SCEPChallengeService != nil {
+		chalStore = c.SCEPChallengeService
 	}
 
 	// TODO: clean up order of inputs. Maybe pass *SCEPConfig as an arg?
@@ -280,11 +548,19 @@ func (c *Server) setupEnrollmentService() error {
 		c.SCEPChallenge,
 		c.ServerPublicURL,
 		c.TLSCertPath,
-		SCEPCertificateSubject,
+		c.SCEPSubject,
 		c.ProfileDB,
 		chalStore,
+		c.EnrollmentTemplateDB,
+		opts...,
 	)
-	return errors.Wrap(err, "setting up enrollment service")
+	if err != nil {
+		return errors.Wrap(err, "setting up enrollment service")
+	}
+	if c.SignProfiles != "" {
+		c.EnrollService = enroll.SigningMiddleware(c.ProfileSigner)(c.EnrollService)
+	}
+	return nil
 }
 
 func (c *Server) setupDepClient() error {
@@ -342,14 +618,17 @@ func (c *Server) CreateDEPSyncer(logger log.Logger) (sync.Syncer, error) {
 		opts = append(opts, sync.WithClient(client))
 	}
 
-	syncdb, err := syncbuiltin.NewDB(c.DB)
-	if err != nil {
-		return nil, err
+	if c.Storage == StorageSQLite {
+		c.SyncDB = syncsqlite.New(c.SQLiteDB)
+	} else {
+		syncdb, err := syncbuiltin.NewDB(c.DB)
+		if err != nil {
+			return nil, err
+		}
+		c.SyncDB = syncdb
 	}
-	c.SyncDB = syncdb
 
-	var syncer sync.Syncer
-	syncer, err = sync.NewWatcher(c.SyncDB, c.PubClient, opts...)
+	syncer, err := sync.NewWatcher(c.SyncDB, c.PubClient, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -357,42 +636,87 @@ func (c *Server) CreateDEPSyncer(logger log.Logger) (sync.Syncer, error) {
 }
 
 func (c *Server) setupSCEP(logger log.Logger) error {
-	svcBoltDepot, err := boltdepot.NewBoltDepot(c.DB)
-	if err != nil {
-		return err
+	var svcDepot SCEPDepot
+	if c.Storage == StorageSQLite {
+		svcDepot = scepsqlite.New(c.SQLiteDB)
+	} else {
+		svcBoltDepot, err := scepbuiltin.NewDepot(c.DB)
+		if err != nil {
+			return err
+		}
+		svcDepot = svcBoltDepot
 	}
-	c.SCEPDepot = svcBoltDepot
+	c.SCEPDepot = svcDepot
 
-	key, err := svcBoltDepot.CreateOrLoadKey(2048)
+	if c.SCEPRenewalDays > 0 {
+		renewalDB, err := scepbuiltin.NewRenewalDB(c.DB)
+		if err != nil {
+			return err
+		}
+		c.SCEPRenewalDB = renewalDB
+	}
+
+	key, err := svcDepot.CreateOrLoadKey(2048)
 	if err != nil {
 		return err
 	}
 
-	crt, err := svcBoltDepot.CreateOrLoadCA(key, 5, "MicroMDM", "US")
+	crt, err := svcDepot.CreateOrLoadCA(key, scepplatform.DefaultCAYears, scepplatform.CAOrganization, scepplatform.CACountry)
 	if err != nil {
 		return err
 	}
 
-	var signer scep.CSRSigner = depot.NewSigner(
-		c.SCEPDepot,
-		depot.WithAllowRenewalDays(0),
-		depot.WithValidityDays(c.SCEPClientValidity),
+	var (
+		signer scep.CSRSigner
+		opts   []scep.ServiceOption
 	)
+	if c.SCEPUpstreamURL != "" {
+		// the SCEP CA of the server signs the requests to the upstream
+		// server, and devices get the upstream CA certificates with it.
+		proxy, err := scepplatform.NewProxySigner(context.Background(), c.SCEPUpstreamURL, c.SCEPDepot, log.With(logger, "component", "scep_proxy"))
+		if err != nil {
+			return err
+		}
+		signer = proxy
+		for _, ca := range proxy.CACerts() {
+			opts = append(opts, scep.WithAddlCA(ca))
+		}
+	} else {
+		signer = depot.NewSigner(
+			c.SCEPDepot,
+			depot.WithAllowRenewalDays(c.SCEPRenewalDays),
+			depot.WithValidityDays(c.SCEPClientValidity),
+		)
+	}
+	issuer := signer
 	if c.UseDynSCEPChallenge {
-		c.SCEPChallengeDepot, err = boltchallenge.NewBoltDepot(c.DB)
+		c.SCEPChallengeDepot, err = challengebuiltin.NewDB(c.DB)
 		if err != nil {
 			return err
 		}
-		signer = challenge.Middleware(c.SCEPChallengeDepot, signer)
+		c.SCEPChallengeService = challenge.NewService(c.SCEPChallengeDepot)
+		signer = challenge.Middleware(c.SCEPChallengeDepot, c.InvitationDB, signer)
 	} else {
 		signer = scep.ChallengeMiddleware(c.SCEPChallenge, signer)
 	}
+	if c.SCEPRenewalDB != nil {
+		// renewal profiles have a challenge of their own.
+		signer = scepplatform.RenewalMiddleware(c.SCEPRenewalDB, issuer, signer)
+	}
 
-	c.SCEPService, err = scep.NewService(crt, key, signer)
+	c.scepService, err = newRotatingSCEPService(crt, key, signer, logger, opts...)
 	if err != nil {
 		return err
 	}
-	c.SCEPService = scep.NewLoggingService(logger, c.SCEPService)
+	c.SCEPService = c.scepService
 
 	return nil
 }
+
+func (c *Server) setupSCEPCAService() {
+	c.SCEPCAService = scepplatform.NewCAService(
+		c.SCEPDepot,
+		c.DeviceDB,
+		scepplatform.WithCARotated(c.scepService.setCA),
+	)
+}
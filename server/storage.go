@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+
+	scepchallenge "github.com/micromdm/scep/v2/challenge"
+	"github.com/micromdm/scep/v2/depot"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/blueprint"
+	"github.com/micromdm/micromdm/platform/challenge"
+	"github.com/micromdm/micromdm/platform/dep/sync"
+	"github.com/micromdm/micromdm/platform/device"
+	"github.com/micromdm/micromdm/platform/scep"
+	"github.com/micromdm/micromdm/platform/user"
+	"github.com/micromdm/micromdm/sqlite"
+)
+
+// Storage backends which can be selected with Server.Storage.
+// SCEP challenges, SCEP certificate renewals, the device block list,
+// enrollment invitations and enrollment templates are always stored in BoltDB.
+const (
+	StorageBuiltin = "builtin"
+	StorageSQLite  = "sqlite"
+)
+
+// DeviceStore is the device storage used by the device service, the device
+// worker and the MDM service.
+type DeviceStore interface {
+	device.Store
+	device.DeviceWorkerStore
+	device.UDIDCertAuthStore
+	GetBootstrapToken(ctx context.Context, udid string) ([]byte, error)
+}
+
+// UserStore is the user storage used by the user service and worker.
+type UserStore interface {
+	user.Store
+	user.WorkerStore
+}
+
+// BlueprintStore is the blueprint storage used by the blueprint service and
+// worker.
+type BlueprintStore interface {
+	blueprint.Store
+	blueprint.BlueprintWorkerStore
+}
+
+// SyncStore is the DEP sync storage used by the DEP sync service and watcher.
+type SyncStore interface {
+	sync.DB
+	sync.WatcherDB
+}
+
+// SCEPDepot is the SCEP certificate depot which also creates the SCEP CA.
+type SCEPDepot interface {
+	depot.Depot
+	scep.Depot
+	CreateOrLoadKey(bits int) (*rsa.PrivateKey, error)
+	CreateOrLoadCA(key *rsa.PrivateKey, years int, org, country string) (*x509.Certificate, error)
+}
+
+// SCEPChallengeStore stores the dynamic SCEP challenges. Unbound challenges
+// are also used by the ACME issuer.
+type SCEPChallengeStore interface {
+	challenge.Store
+	scepchallenge.Store
+}
+
+func (c *Server) setupSQLite() error {
+	switch c.Storage {
+	case StorageBuiltin:
+		return nil
+	case StorageSQLite:
+	case "":
+		return errors.New("empty storage type")
+	default:
+		return fmt.Errorf("invalid storage type: %s", c.Storage)
+	}
+
+	db, err := sqlite.Open(filepath.Join(c.ConfigPath, "micromdm.sqlite"))
+	if err != nil {
+		return errors.Wrap(err, "opening sqlite")
+	}
+	c.SQLiteDB = db
+	return nil
+}
@@ -0,0 +1,145 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS devices (
+    uuid TEXT PRIMARY KEY,
+    udid TEXT DEFAULT '',
+    serial_number TEXT DEFAULT '',
+    os_version TEXT DEFAULT '',
+    build_version TEXT DEFAULT '',
+    product_name TEXT DEFAULT '',
+    imei TEXT DEFAULT '',
+    meid TEXT DEFAULT '',
+    push_magic TEXT DEFAULT '',
+    awaiting_configuration BOOLEAN DEFAULT false,
+    token TEXT DEFAULT '',
+    unlock_token TEXT DEFAULT '',
+    enrolled BOOLEAN DEFAULT false,
+    description TEXT DEFAULT '',
+    model TEXT DEFAULT '',
+    model_name TEXT DEFAULT '',
+    device_name TEXT DEFAULT '',
+    color TEXT DEFAULT '',
+    asset_tag TEXT DEFAULT '',
+    dep_profile_status TEXT DEFAULT '',
+    dep_profile_uuid TEXT DEFAULT '',
+    dep_profile_assign_time TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    dep_profile_push_time TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    dep_profile_assigned_date TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    dep_profile_assigned_by TEXT DEFAULT '',
+    last_seen TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    bootstrap_token BLOB
+);
+
+CREATE INDEX IF NOT EXISTS devices_udid_idx ON devices (udid);
+CREATE INDEX IF NOT EXISTS devices_serial_number_idx ON devices (serial_number);
+
+
+CREATE TABLE IF NOT EXISTS udid_cert_auth (
+    udid TEXT PRIMARY KEY,
+    cert_hash BLOB NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS push_info (
+    udid TEXT PRIMARY KEY,
+    token TEXT DEFAULT '',
+    push_magic TEXT DEFAULT '',
+    mdm_topic TEXT DEFAULT ''
+);
+
+
+CREATE TABLE IF NOT EXISTS profiles (
+    identifier TEXT PRIMARY KEY,
+    mobileconfig BLOB NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS blueprints (
+    uuid TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    install_application_manifest_urls TEXT DEFAULT '[]',
+    profile_ids TEXT DEFAULT '[]',
+    user_uuids TEXT DEFAULT '[]',
+    skip_primary_setup_account_creation BOOLEAN DEFAULT false,
+    set_primary_setup_account_as_regular_user BOOLEAN DEFAULT false,
+    apply_at TEXT DEFAULT '[]'
+);
+
+
+CREATE TABLE IF NOT EXISTS users (
+    uuid TEXT PRIMARY KEY,
+    udid TEXT DEFAULT '',
+    user_id TEXT DEFAULT '',
+    user_shortname TEXT DEFAULT '',
+    user_longname TEXT DEFAULT '',
+    auth_token TEXT DEFAULT '',
+    password_hash BLOB,
+    hidden BOOLEAN DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS users_udid_idx ON users (udid);
+CREATE INDEX IF NOT EXISTS users_user_id_idx ON users (user_id);
+
+
+CREATE TABLE IF NOT EXISTS server_config (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    push_certificate BLOB,
+    private_key BLOB
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_tokens (
+    consumer_key TEXT PRIMARY KEY,
+    token TEXT NOT NULL,
+    added_at TIMESTAMP DEFAULT '1970-01-01 00:00:00'
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_keypair (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    key BLOB NOT NULL,
+    certificate BLOB NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_sync_cursor (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    value TEXT DEFAULT '',
+    created_at TIMESTAMP DEFAULT '1970-01-01 00:00:00'
+);
+
+
+CREATE TABLE IF NOT EXISTS dep_auto_assigners (
+    filter TEXT PRIMARY KEY,
+    profile_uuid TEXT NOT NULL
+);
+
+
+CREATE TABLE IF NOT EXISTS device_commands (
+    device_udid TEXT NOT NULL,
+    queue TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    uuid TEXT DEFAULT '',
+    payload BLOB,
+    created_at TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    last_sent_at TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    acknowledged TIMESTAMP DEFAULT '1970-01-01 00:00:00',
+    times_sent INTEGER DEFAULT 0,
+    last_status TEXT DEFAULT '',
+    failure_message BLOB,
+    PRIMARY KEY (device_udid, queue, position)
+);
+
+
+-- +goose Down
+DROP TABLE IF EXISTS devices;
+DROP TABLE IF EXISTS udid_cert_auth;
+DROP TABLE IF EXISTS push_info;
+DROP TABLE IF EXISTS profiles;
+DROP TABLE IF EXISTS blueprints;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS server_config;
+DROP TABLE IF EXISTS dep_tokens;
+DROP TABLE IF EXISTS dep_keypair;
+DROP TABLE IF EXISTS dep_sync_cursor;
+DROP TABLE IF EXISTS dep_auto_assigners;
+DROP TABLE IF EXISTS device_commands;
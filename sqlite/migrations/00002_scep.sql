@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS scep_depot (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    ca_certificate BLOB,
+    ca_key BLOB,
+    serial TEXT DEFAULT ''
+);
+
+
+CREATE TABLE IF NOT EXISTS scep_certificates (
+    name TEXT PRIMARY KEY,
+    common_name TEXT NOT NULL,
+    serial TEXT NOT NULL,
+    certificate BLOB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scep_certificates_common_name_idx ON scep_certificates (common_name);
+
+
+-- +goose Down
+DROP TABLE IF EXISTS scep_depot;
+DROP TABLE IF EXISTS scep_certificates;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE devices ADD COLUMN supervised BOOLEAN DEFAULT false;
+
+
+-- +goose Down
+ALTER TABLE devices DROP COLUMN supervised;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS user_enrollments (
+    enrollment_id TEXT PRIMARY KEY,
+    managed_apple_id TEXT DEFAULT '',
+    os_version TEXT DEFAULT '',
+    build_version TEXT DEFAULT '',
+    product_name TEXT DEFAULT '',
+    model TEXT DEFAULT '',
+    model_name TEXT DEFAULT '',
+    device_name TEXT DEFAULT '',
+    enrolled BOOLEAN DEFAULT false,
+    last_seen TIMESTAMP DEFAULT '1970-01-01 00:00:00'
+);
+
+
+-- +goose Down
+DROP TABLE IF EXISTS user_enrollments;
@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE devices ADD COLUMN enrollment_group TEXT DEFAULT '';
+ALTER TABLE blueprints ADD COLUMN enrollment_groups TEXT DEFAULT '[]';
+
+
+-- +goose Down
+ALTER TABLE devices DROP COLUMN enrollment_group;
+ALTER TABLE blueprints DROP COLUMN enrollment_groups;
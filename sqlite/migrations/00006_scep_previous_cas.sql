@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS scep_previous_cas (
+    fingerprint TEXT PRIMARY KEY,
+    certificate BLOB NOT NULL
+);
+
+
+-- +goose Down
+DROP TABLE IF EXISTS scep_previous_cas;
@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE devices ADD COLUMN owner TEXT DEFAULT '';
+
+
+-- +goose Down
+ALTER TABLE devices DROP COLUMN owner;
@@ -0,0 +1,129 @@
+// Package sqlite opens the SQLite database used by the sqlite storage backend.
+//
+// The schema lives in the migrations directory and uses the same goose
+// annotations as the Postgres migrations in pg/migrations. Migrations are
+// embedded in the binary and applied by Open, recording their versions in the
+// goose_db_version table so that the goose CLI can inspect the database too.
+package sqlite
+
+import (
+	"embed"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens (creating if needed) the SQLite database at path and applies any
+// pending schema migrations. Use ":memory:" for an in-memory database.
+func Open(path string) (*sqlx.DB, error) {
+	db, err := sqlx.Open("sqlite3", dsn(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening sqlite database")
+	}
+	// SQLite only allows a single writer, and every new connection to an
+	// in-memory database would get its own empty database.
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "connecting to sqlite database")
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func dsn(path string) string {
+	if path == ":memory:" {
+		return "file::memory:?_foreign_keys=1"
+	}
+	return "file:" + path + "?_foreign_keys=1&_busy_timeout=5000&_journal_mode=WAL"
+}
+
+// Migrate applies all embedded migrations which have not been applied yet.
+func Migrate(db *sqlx.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS goose_db_version (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`)
+	if err != nil {
+		return errors.Wrap(err, "creating goose_db_version table")
+	}
+
+	var current int64
+	err = db.Get(&current, `SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied = 1`)
+	if err != nil {
+		return errors.Wrap(err, "reading current schema version")
+	}
+
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return errors.Wrap(err, "reading embedded migrations")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	for _, f := range files {
+		version, err := migrationVersion(f.Name())
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+		data, err := migrations.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return errors.Wrapf(err, "reading migration %s", f.Name())
+		}
+		if err := applyMigration(db, version, upStatements(string(data))); err != nil {
+			return errors.Wrapf(err, "applying migration %s", f.Name())
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sqlx.DB, version int64, up string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "begin transaction")
+	}
+	if _, err := tx.Exec(up); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, 1)`, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrationVersion parses the version prefix of a goose migration file name,
+// for example 1 for 00001_tables.sql.
+func migrationVersion(name string) (int64, error) {
+	prefix := strings.SplitN(name, "_", 2)[0]
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	return version, errors.Wrapf(err, "parsing version of migration %s", name)
+}
+
+// upStatements returns the section of a goose migration between the
+// "+goose Up" and "+goose Down" annotations.
+func upStatements(migration string) string {
+	if i := strings.Index(migration, "-- +goose Up"); i >= 0 {
+		migration = migration[i+len("-- +goose Up"):]
+	}
+	if i := strings.Index(migration, "-- +goose Down"); i >= 0 {
+		migration = migration[:i]
+	}
+	return migration
+}
@@ -0,0 +1,35 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMigratesOnce(t *testing.T) {
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "micromdm.sqlite")
+	for i := 0; i < 2; i++ {
+		db, err := Open(path)
+		if err != nil {
+			t.Fatalf("open %d: %s", i, err)
+		}
+		var applied int
+		if err := db.Get(&applied, `SELECT COUNT(*) FROM goose_db_version`); err != nil {
+			t.Fatal(err)
+		}
+		if have, want := applied, len(files); have != want {
+			t.Errorf("have %d applied migrations, want %d", have, want)
+		}
+		db.Close()
+	}
+}
+
+func TestUpStatements(t *testing.T) {
+	migration := "-- +goose Up\nCREATE TABLE foo (id INTEGER);\n-- +goose Down\nDROP TABLE foo;\n"
+	if have, want := upStatements(migration), "\nCREATE TABLE foo (id INTEGER);\n"; have != want {
+		t.Errorf("have %q, want %q", have, want)
+	}
+}
@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/micromdm/micromdm/platform/config"
+)
+
+func pushCertificateEvent(topic string, data []byte) (*Event, error) {
+	var details config.PushCertificateDetails
+	if err := json.Unmarshal(data, &details); err != nil {
+		return nil, errors.Wrap(err, "unmarshal push certificate details for webhook")
+	}
+
+	webhookEvent := Event{
+		Topic:     topic,
+		EventID:   uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+
+		PushCertificateEvent: &details,
+	}
+
+	return &webhookEvent, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/micromdm/micromdm/mdm"
+	"github.com/micromdm/micromdm/platform/config"
 	"github.com/micromdm/micromdm/platform/pubsub"
 )
 
@@ -18,8 +19,9 @@ type Event struct {
 	EventID   string    `json:"event_id"`
 	CreatedAt time.Time `json:"created_at"`
 
-	AcknowledgeEvent *AcknowledgeEvent `json:"acknowledge_event,omitempty"`
-	CheckinEvent     *CheckinEvent     `json:"checkin_event,omitempty"`
+	AcknowledgeEvent     *AcknowledgeEvent              `json:"acknowledge_event,omitempty"`
+	CheckinEvent         *CheckinEvent                  `json:"checkin_event,omitempty"`
+	PushCertificateEvent *config.PushCertificateDetails `json:"push_certificate_event,omitempty"`
 }
 
 type Worker struct {
@@ -91,6 +93,11 @@ func (w *Worker) Run(ctx context.Context) error {
 		return errors.Wrapf(err, "subscribe %s to %s", subscription, mdm.SetBootstrapTokenTopic)
 	}
 
+	pushCertificateEvents, err := w.sub.Subscribe(ctx, subscription, config.PushCertificateExpiryTopic)
+	if err != nil {
+		return errors.Wrapf(err, "subscribe %s to %s", subscription, config.PushCertificateExpiryTopic)
+	}
+
 	for {
 		var (
 			event *Event
@@ -111,6 +118,8 @@ func (w *Worker) Run(ctx context.Context) error {
 			event, err = checkinEvent(ev.Topic, ev.Message)
 		case ev := <-setBootstrapTokenEvents:
 			event, err = checkinEvent(ev.Topic, ev.Message)
+		case ev := <-pushCertificateEvents:
+			event, err = pushCertificateEvent(ev.Topic, ev.Message)
 		}
 
 		if err != nil {